@@ -8,7 +8,7 @@ import {
   setEventFolder,
   updateFolder,
 } from "../utils/services/FolderService"
-import { archiveEvent } from "../utils/services/EventService"
+import { archiveEvent, getAllEvents } from "../utils/services/EventService"
 
 Vue.use(Vuex)
 
@@ -190,7 +190,7 @@ export default new Vuex.Store({
     // Events
     getEvents({ commit, dispatch, state }) {
       if (state.authUser) {
-        return Promise.allSettled([get("/user/folders"), get("/user/events")])
+        return Promise.allSettled([get("/user/folders"), getAllEvents()])
           .then(([folders, events]) => {
             if (
               folders.status === "fulfilled" &&
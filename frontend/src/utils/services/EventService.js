@@ -1,4 +1,4 @@
-import { post } from "../fetch_utils"
+import { get, post } from "../fetch_utils"
 
 export const archiveEvent = (eventId, archive) => {
   return post(`/events/${eventId}/archive`, {
@@ -11,3 +11,17 @@ export const scheduleEvent = (eventId, scheduledEvent) => {
     scheduledEvent: scheduledEvent,
   })
 }
+
+/** Returns all of the user's events, fetching them a page at a time */
+export const getAllEvents = async () => {
+  const events = []
+  let cursor = null
+  do {
+    const page = await get(
+      `/user/events?limit=100${cursor ? `&cursor=${cursor}` : ""}`
+    )
+    events.push(...page.events)
+    cursor = page.nextCursor
+  } while (cursor)
+  return events
+}
@@ -1,5 +1,6 @@
 import { calendarTypes } from "@/constants"
 import store from "@/store"
+import { getCalendarAccountKey } from "./date_utils"
 
 /** Redirects user to the correct google sign in page */
 export const signInGoogle = ({
@@ -48,6 +49,7 @@ export const signInGoogle = ({
 export const signInOutlook = ({
   state = {},
   requestCalendarPermission = false,
+  requestContactsPermission = false,
 }) => {
   const clientId = window.__TIMEFUL_CONFIG__?.microsoftClientId
   
@@ -67,6 +69,9 @@ export const signInOutlook = ({
   if (requestCalendarPermission) {
     scope += " Calendars.Read"
   }
+  if (requestContactsPermission) {
+    scope += " People.Read"
+  }
   scope = encodeURIComponent(scope)
 
   let stateString = ""
@@ -76,6 +81,29 @@ export const signInOutlook = ({
   state = encodeURIComponent(JSON.stringify(state))
   stateString = `&state=${state}`
 
-  const url = `https://login.microsoftonline.com/common/oauth2/v2.0/authorize?client_id=${clientId}&response_type=code&redirect_uri=${redirectUri}&response_mode=query&scope=${scope}${stateString}`
+  // Make sure contacts access is granted to the account the user is signed in with
+  let loginHintString = ""
+  if (requestContactsPermission && store.state.authUser) {
+    loginHintString = `&login_hint=${encodeURIComponent(store.state.authUser.email)}`
+  }
+
+  const url = `https://login.microsoftonline.com/common/oauth2/v2.0/authorize?client_id=${clientId}&response_type=code&redirect_uri=${redirectUri}&response_mode=query&scope=${scope}${stateString}${loginHintString}`
   window.location.href = url
 }
+
+/**
+ * Redirects user to the sign in page of the account their contacts are searched in, requesting access to them.
+ * Like the server, this is the Google account they signed in with, otherwise their Outlook account
+ */
+export const signInForContacts = ({ state = {} }) => {
+  const authUser = store.state.authUser
+  const calendarAccounts = authUser?.calendarAccounts ?? {}
+  if (
+    !calendarAccounts[getCalendarAccountKey(authUser?.email, calendarTypes.GOOGLE)] &&
+    calendarAccounts[getCalendarAccountKey(authUser?.email, calendarTypes.OUTLOOK)]
+  ) {
+    signInOutlook({ state, requestContactsPermission: true })
+  } else {
+    signInGoogle({ state, requestContactsPermission: true })
+  }
+}
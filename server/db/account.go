@@ -0,0 +1,395 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/models"
+)
+
+// Returned when deleting a user whose events would go to a user that doesn't exist or isn't their friend
+var ErrTransferNotAccepted = errors.New("events can only be transferred to a friend")
+
+// What's left to do after a user is deleted
+type DeletedUser struct {
+	// Ids of the reminder email tasks that should be cancelled
+	TaskIds []string
+	// The sign up blocks the user gave up, by event, whose waitlists should be moved up
+	FreedSignUpBlockIds map[primitive.ObjectID][]primitive.ObjectID
+}
+
+/*
+Deletes the user along with everything stored about them: their responses, sign ups, attendee and remindee
+entries, the event history entries about those, folders, event templates, friend requests, friendships, and
+activity logs. The changes they made to other events stay in those events' history without their user id. The events they own are
+either permanently deleted or, if `transferTo` isn't nil, given to that user. Events are only given to a user
+that exists and has accepted the user as a friend, otherwise ErrTransferNotAccepted is returned and nothing
+is deleted.
+
+The user document is deleted last, so that the deletion can be retried if anything fails
+*/
+func DeleteUser(ctx context.Context, user *models.User, transferTo *primitive.ObjectID) (*DeletedUser, error) {
+	if transferTo != nil {
+		if *transferTo == user.Id {
+			return nil, ErrTransferNotAccepted
+		}
+		count, err := UsersCollection.CountDocuments(ctx, bson.M{"_id": *transferTo, "friendIds": user.Id})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrTransferNotAccepted
+		}
+	}
+
+	deleted := &DeletedUser{
+		TaskIds:             make([]string, 0),
+		FreedSignUpBlockIds: make(map[primitive.ObjectID][]primitive.ObjectID),
+	}
+
+	// Owned events. Events in the trash are never transferred
+	ownedEventsFilter := bson.M{"ownerId": user.Id}
+	if transferTo != nil {
+		if _, err := purgeEvents(ctx, bson.M{"ownerId": user.Id, "isDeleted": true}, 0); err != nil {
+			return nil, err
+		}
+		if _, err := EventsCollection.UpdateMany(ctx, ownedEventsFilter, bson.M{
+			"$set": bson.M{"ownerId": *transferTo},
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		// Cancel the reminders of the events, which was already done for events in the trash
+		var ownedEvents []models.Event
+		cursor, err := EventsCollection.Find(ctx, bson.M{
+			"ownerId":   user.Id,
+			"isDeleted": bson.M{"$ne": true},
+		}, options.Find().SetProjection(bson.M{"remindees": 1}))
+		if err != nil {
+			return nil, err
+		}
+		if err := cursor.All(ctx, &ownedEvents); err != nil {
+			return nil, err
+		}
+		for _, event := range ownedEvents {
+			for _, remindee := range eventRemindees(&event) {
+				deleted.TaskIds = append(deleted.TaskIds, remindee.TaskIds...)
+			}
+		}
+
+		if _, err := purgeEvents(ctx, ownedEventsFilter, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	// Responses to other events, including the ones they left as a guest with their email, keeping the
+	// events' number of responses up to date
+	if err := deleteUserResponses(ctx, userResponsesFilter(user)); err != nil {
+		return nil, err
+	}
+	var signedUpEvents []models.Event
+	cursor, err := EventsCollection.Find(ctx, bson.M{
+		"signUpResponses." + user.Id.Hex(): bson.M{"$exists": true},
+		"isDeleted":                        bson.M{"$ne": true},
+	}, options.Find().SetProjection(bson.M{"signUpResponses." + user.Id.Hex(): 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &signedUpEvents); err != nil {
+		return nil, err
+	}
+	for _, event := range signedUpEvents {
+		if response := event.SignUpResponses[user.Id.Hex()]; response != nil && len(response.SignUpBlockIds) > 0 {
+			deleted.FreedSignUpBlockIds[event.Id] = response.SignUpBlockIds
+		}
+	}
+	if _, err := EventsCollection.UpdateMany(ctx, bson.M{
+		"signUpResponses." + user.Id.Hex(): bson.M{"$exists": true},
+	}, bson.M{
+		"$unset": bson.M{"signUpResponses." + user.Id.Hex(): ""},
+	}); err != nil {
+		return nil, err
+	}
+
+	// Event history entries about their responses and attendee entries, and who made the other changes
+	if _, err := EventHistoryCollection.DeleteMany(ctx, bson.M{"$or": userEventHistoryFilters(user)}); err != nil {
+		return nil, err
+	}
+	if _, err := EventHistoryCollection.UpdateMany(ctx, bson.M{"actorId": user.Id}, bson.M{
+		"$unset": bson.M{"actorId": ""},
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(user.Email) > 0 {
+		// Remindee entries, along with their scheduled reminders
+		var remindedEvents []models.Event
+		cursor, err := EventsCollection.Find(ctx, bson.M{
+			"remindees.email": user.Email,
+		}, options.Find().SetProjection(bson.M{"remindees": 1}))
+		if err != nil {
+			return nil, err
+		}
+		if err := cursor.All(ctx, &remindedEvents); err != nil {
+			return nil, err
+		}
+		for _, event := range remindedEvents {
+			for _, remindee := range eventRemindees(&event) {
+				if remindee.Email == user.Email {
+					deleted.TaskIds = append(deleted.TaskIds, remindee.TaskIds...)
+				}
+			}
+		}
+		if _, err := EventsCollection.UpdateMany(ctx, bson.M{
+			"remindees.email": user.Email,
+		}, bson.M{
+			"$pull": bson.M{"remindees": bson.M{"email": user.Email}},
+		}); err != nil {
+			return nil, err
+		}
+
+		// Attendee entries
+		if _, err := AttendeesCollection.DeleteMany(ctx, bson.M{"email": user.Email}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Folders, and the folders shared with them
+	if _, err := FolderEventsCollection.DeleteMany(ctx, bson.M{"userId": user.Id}); err != nil {
+		return nil, err
+	}
+	if _, err := FoldersCollection.DeleteMany(ctx, bson.M{"userId": user.Id}); err != nil {
+		return nil, err
+	}
+	if _, err := FoldersCollection.UpdateMany(ctx, bson.M{"sharedWith.userId": user.Id}, bson.M{
+		"$pull": bson.M{"sharedWith": bson.M{"userId": user.Id}},
+	}); err != nil {
+		return nil, err
+	}
+
+	// Event templates
+	if _, err := EventTemplatesCollection.DeleteMany(ctx, bson.M{"userId": user.Id}); err != nil {
+		return nil, err
+	}
+
+	// Friend requests and friendships
+	if _, err := FriendRequestsCollection.DeleteMany(ctx, bson.M{
+		"$or": bson.A{bson.M{"from": user.Id}, bson.M{"to": user.Id}},
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := UsersCollection.UpdateMany(ctx, bson.M{"friendIds": user.Id}, bson.M{
+		"$pull": bson.M{"friendIds": user.Id},
+	}); err != nil {
+		return nil, err
+	}
+
+	// Activity logs
+	if _, err := DailyUserLogCollection.UpdateMany(ctx, bson.M{"userIds": user.Id}, bson.M{
+		"$pull": bson.M{"userIds": user.Id},
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, err := UsersCollection.DeleteOne(ctx, bson.M{"_id": user.Id}); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// Returns a filter that matches the responses the user left, either signed in or as a guest with their email
+func userResponsesFilter(user *models.User) bson.M {
+	filters := bson.A{bson.M{"userId": user.Id.Hex()}}
+	if len(user.Email) > 0 {
+		filters = append(filters, bson.M{"response.email": user.Email})
+	}
+	return bson.M{"$or": filters}
+}
+
+// Returns filters that match the event history entries about the user's responses, sign ups and attendee entries,
+// including the responses they left as a guest with their email
+func userEventHistoryFilters(user *models.User) bson.A {
+	filters := bson.A{bson.M{"before.userId": user.Id.Hex()}}
+	if len(user.Email) > 0 {
+		filters = append(filters,
+			bson.M{"before.response.email": user.Email},
+			bson.M{"before.signUpResponse.email": user.Email},
+			bson.M{"before.email": user.Email},
+			bson.M{"after.email": user.Email},
+		)
+	}
+	return filters
+}
+
+// Deletes the responses matching the filter and decrements the number of responses of their events
+func deleteUserResponses(ctx context.Context, filter bson.M) error {
+	var eventResponses []models.EventResponse
+	cursor, err := EventResponsesCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"eventId": 1}))
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(ctx, &eventResponses); err != nil {
+		return err
+	}
+
+	for _, eventResponse := range eventResponses {
+		deleted, err := DeleteEventResponse(eventResponse.Id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			continue
+		}
+		if _, err := IncrementNumResponses(eventResponse.EventId, -1); err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+	}
+	return nil
+}
+
+func eventRemindees(event *models.Event) []models.Remindee {
+	if event.Remindees == nil {
+		return []models.Remindee{}
+	}
+	return *event.Remindees
+}
+
+// Everything stored about a user, for data export requests
+type UserData struct {
+	User            *models.User           `json:"user"`
+	OwnedEvents     []models.Event         `json:"ownedEvents"`
+	Responses       []models.EventResponse `json:"responses"`
+	SignUpResponses []UserSignUpResponse   `json:"signUpResponses"`
+	Reminders       []UserReminder         `json:"reminders"`
+	Attendees       []models.Attendee      `json:"attendees"`
+	Folders         []models.Folder        `json:"folders"`
+	EventTemplates  []models.EventTemplate `json:"eventTemplates"`
+	FriendRequests  []models.FriendRequest `json:"friendRequests"`
+	FriendIds       []primitive.ObjectID   `json:"friendIds"`
+	ActiveDates     []primitive.DateTime   `json:"activeDates"`
+
+	// The changes the user made to events, and the changes made to their responses and attendee entries
+	EventHistory []models.EventHistoryEntry `json:"eventHistory"`
+}
+
+// The user's sign up response to an event they don't own
+type UserSignUpResponse struct {
+	EventId  primitive.ObjectID     `json:"eventId"`
+	Response *models.SignUpResponse `json:"response"`
+}
+
+// An event the user gets email reminders for
+type UserReminder struct {
+	EventId  primitive.ObjectID `json:"eventId"`
+	Remindee models.Remindee    `json:"remindee"`
+}
+
+// Returns everything stored about the user
+func GetUserData(ctx context.Context, user *models.User) (*UserData, error) {
+	data := &UserData{
+		User:            user,
+		OwnedEvents:     make([]models.Event, 0),
+		Responses:       make([]models.EventResponse, 0),
+		SignUpResponses: make([]UserSignUpResponse, 0),
+		Reminders:       make([]UserReminder, 0),
+		Attendees:       make([]models.Attendee, 0),
+		Folders:         make([]models.Folder, 0),
+		EventTemplates:  make([]models.EventTemplate, 0),
+		FriendRequests:  make([]models.FriendRequest, 0),
+		FriendIds:       append(make([]primitive.ObjectID, 0), user.FriendIds...),
+		ActiveDates:     make([]primitive.DateTime, 0),
+		EventHistory:    make([]models.EventHistoryEntry, 0),
+	}
+
+	find := func(collection *mongo.Collection, filter bson.M, results interface{}, opts ...*options.FindOptions) error {
+		cursor, err := collection.Find(ctx, filter, opts...)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, results)
+	}
+
+	if err := find(EventsCollection, bson.M{"ownerId": user.Id}, &data.OwnedEvents); err != nil {
+		return nil, err
+	}
+	if err := find(EventResponsesCollection, userResponsesFilter(user), &data.Responses); err != nil {
+		return nil, err
+	}
+
+	var signedUpEvents []models.Event
+	if err := find(EventsCollection, bson.M{
+		"ownerId":                          bson.M{"$ne": user.Id},
+		"signUpResponses." + user.Id.Hex(): bson.M{"$exists": true},
+	}, &signedUpEvents, options.Find().SetProjection(bson.M{"signUpResponses." + user.Id.Hex(): 1})); err != nil {
+		return nil, err
+	}
+	for _, event := range signedUpEvents {
+		data.SignUpResponses = append(data.SignUpResponses, UserSignUpResponse{EventId: event.Id, Response: event.SignUpResponses[user.Id.Hex()]})
+	}
+
+	if len(user.Email) > 0 {
+		var remindedEvents []models.Event
+		if err := find(EventsCollection, bson.M{
+			"ownerId":         bson.M{"$ne": user.Id},
+			"remindees.email": user.Email,
+		}, &remindedEvents, options.Find().SetProjection(bson.M{"remindees": 1})); err != nil {
+			return nil, err
+		}
+		for _, event := range remindedEvents {
+			for _, remindee := range eventRemindees(&event) {
+				if remindee.Email == user.Email {
+					data.Reminders = append(data.Reminders, UserReminder{EventId: event.Id, Remindee: remindee})
+				}
+			}
+		}
+
+		if err := find(AttendeesCollection, bson.M{"email": user.Email}, &data.Attendees); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := find(FoldersCollection, bson.M{"userId": user.Id}, &data.Folders); err != nil {
+		return nil, err
+	}
+	for i, folder := range data.Folders {
+		eventIds, err := GetEventsInFolder(folder.Id, user.Id)
+		if err != nil {
+			return nil, err
+		}
+		data.Folders[i].EventIds = eventIds
+	}
+
+	if err := find(EventTemplatesCollection, bson.M{"userId": user.Id}, &data.EventTemplates); err != nil {
+		return nil, err
+	}
+
+	if err := find(FriendRequestsCollection, bson.M{
+		"$or": bson.A{bson.M{"from": user.Id}, bson.M{"to": user.Id}},
+	}, &data.FriendRequests); err != nil {
+		return nil, err
+	}
+
+	if err := find(EventHistoryCollection, bson.M{
+		"$or": append(userEventHistoryFilters(user), bson.M{"actorId": user.Id}),
+	}, &data.EventHistory, options.Find().SetSort(bson.M{"_id": 1})); err != nil {
+		return nil, err
+	}
+
+	var logs []models.DailyUserLog
+	if err := find(DailyUserLogCollection, bson.M{"userIds": user.Id}, &logs, options.Find().
+		SetProjection(bson.M{"date": 1}).
+		SetSort(bson.M{"date": 1}),
+	); err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		data.ActiveDates = append(data.ActiveDates, log.Date)
+	}
+
+	return data, nil
+}
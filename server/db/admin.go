@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func CreateAdminAuditLogEntry(entry *models.AdminAuditLogEntry) error {
+	entry.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	result, err := AdminAuditLogCollection.InsertOne(context.Background(), entry)
+	if err != nil {
+		return err
+	}
+	entry.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Returns a page of audit log entries, most recent first. Only returns entries about the user if `targetUserId`
+// isn't nil, and entries older than `cursor` if it isn't nil. Also returns the cursor of the next page, which
+// is nil on the last page
+func GetAdminAuditLog(targetUserId *primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.AdminAuditLogEntry, *primitive.ObjectID, error) {
+	filter := bson.M{}
+	if targetUserId != nil {
+		filter["targetUserId"] = *targetUserId
+	}
+	entries := make([]models.AdminAuditLogEntry, 0)
+	next, err := findPage(AdminAuditLogCollection, filter, cursor, limit, &entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next {
+		return entries[:limit], &entries[limit-1].Id, nil
+	}
+	return entries, nil, nil
+}
+
+// Returns a page of users whose name or email contains the query, most recently signed up first, along with the
+// cursor of the next page, which is nil on the last page
+func SearchUsers(query string, cursor *primitive.ObjectID, limit int) ([]models.User, *primitive.ObjectID, error) {
+	filter := bson.M{}
+	if len(query) > 0 {
+		regex := primitive.Regex{Pattern: utils.EscapeRegExp(query), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"email": regex},
+			bson.M{"firstName": regex},
+			bson.M{"lastName": regex},
+		}
+	}
+	users := make([]models.User, 0)
+	next, err := findPage(UsersCollection, filter, cursor, limit, &users)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next {
+		return users[:limit], &users[limit-1].Id, nil
+	}
+	return users, nil, nil
+}
+
+// Decodes the documents matching the filter into `results`, newest first, starting after `cursor` if it isn't
+// nil. Gets one more document than `limit` and returns whether it exists, i.e. whether there's another page
+func findPage[T any](collection *mongo.Collection, filter bson.M, cursor *primitive.ObjectID, limit int, results *[]T) (bool, error) {
+	if cursor != nil {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$lt": *cursor}}}}
+	}
+	result, err := collection.Find(context.Background(), filter, options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(int64(limit)+1),
+	)
+	if err != nil {
+		return false, err
+	}
+	if err := result.All(context.Background(), results); err != nil {
+		return false, err
+	}
+	return len(*results) > limit, nil
+}
+
+// Removes the access and refresh tokens of the user's OAuth calendar accounts, so that they have to sign in to
+// them again. Only removes the tokens of the account with the given key if it isn't empty. Returns the number of
+// accounts that were signed out
+func ForceCalendarReauth(user *models.User, calendarAccountKey string) (int, error) {
+	signedOut := make(map[string]models.CalendarAccount)
+	for key, account := range user.CalendarAccounts {
+		if account.OAuth2CalendarAuth != nil && (len(calendarAccountKey) == 0 || key == calendarAccountKey) {
+			account.OAuth2CalendarAuth = &models.OAuth2CalendarAuth{Scope: account.OAuth2CalendarAuth.Scope}
+			signedOut[key] = account
+		}
+	}
+
+	// Only the signed out accounts are written, so accounts added in the meantime are kept
+	if err := SetCalendarAccounts(context.Background(), user.Id, signedOut); err != nil {
+		return 0, err
+	}
+	return len(signedOut), nil
+}
+
+// Returns the event with the given _id or shortId even if it's in the trash, or nil if it doesn't exist
+func GetEventIncludingDeleted(id string) (*models.Event, error) {
+	filter := bson.M{"shortId": id}
+	if objectId, err := primitive.ObjectIDFromHex(id); err == nil {
+		filter = bson.M{"_id": objectId}
+	}
+
+	var event models.Event
+	err := EventsCollection.FindOne(context.Background(), filter).Decode(&event)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func SetUserRole(userId primitive.ObjectID, role models.UserRole) error {
+	update := bson.M{"$set": bson.M{"role": role}}
+	if role == models.RegularUser {
+		update = bson.M{"$unset": bson.M{"role": ""}}
+	}
+	_, err := UsersCollection.UpdateByID(context.Background(), userId, update)
+	return err
+}
+
+// Gives the event to another user. The event is taken out of the previous owner's folders
+func TransferEventOwnership(event *models.Event, newOwnerId primitive.ObjectID) error {
+	ctx := context.Background()
+	if _, err := EventsCollection.UpdateByID(ctx, event.Id, bson.M{"$set": bson.M{"ownerId": newOwnerId}}); err != nil {
+		return err
+	}
+	if event.OwnerId != primitive.NilObjectID {
+		if _, err := FolderEventsCollection.DeleteMany(ctx, bson.M{"eventId": event.Id, "userId": event.OwnerId}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Permanently deletes the event, whether or not it's in the trash, along with its responses, attendees, history,
+// and folder mappings. Returns whether the event existed
+func HardDeleteEvent(eventId primitive.ObjectID) (bool, error) {
+	deleted, err := purgeEvents(context.Background(), bson.M{"_id": eventId}, 0)
+	return deleted > 0, err
+}
+
+// Counts of what's stored on this instance
+type InstanceStats struct {
+	Users        int `json:"users"`
+	PremiumUsers int `json:"premiumUsers"`
+	AdminUsers   int `json:"adminUsers"`
+	// Users that were active on any day in the last 30 days
+	ActiveUsers int `json:"activeUsers"`
+
+	Events int `json:"events"`
+	// Events created in the last 30 days
+	NewEvents     int `json:"newEvents"`
+	DeletedEvents int `json:"deletedEvents"`
+	Groups        int `json:"groups"`
+	SignUpForms   int `json:"signUpForms"`
+	Responses     int `json:"responses"`
+
+	Folders        int `json:"folders"`
+	EventTemplates int `json:"eventTemplates"`
+}
+
+func GetInstanceStats(ctx context.Context) (*InstanceStats, error) {
+	monthAgo := time.Now().AddDate(0, 0, -30)
+	notDeleted := bson.M{"isDeleted": bson.M{"$ne": true}}
+
+	var stats InstanceStats
+	counts := []struct {
+		count      *int
+		collection *mongo.Collection
+		filter     bson.M
+	}{
+		{&stats.Users, UsersCollection, bson.M{}},
+		{&stats.PremiumUsers, UsersCollection, bson.M{"$or": bson.A{
+			bson.M{"stripeCustomerId": bson.M{"$type": "string"}},
+			bson.M{"isPremium": true},
+		}}},
+		{&stats.AdminUsers, UsersCollection, bson.M{"role": models.AdminUser}},
+		{&stats.Events, EventsCollection, notDeleted},
+		{&stats.NewEvents, EventsCollection, bson.M{"_id": bson.M{"$gte": primitive.NewObjectIDFromTimestamp(monthAgo)}}},
+		{&stats.DeletedEvents, EventsCollection, bson.M{"isDeleted": true}},
+		{&stats.Groups, EventsCollection, bson.M{"$and": bson.A{notDeleted, bson.M{"type": models.GROUP}}}},
+		{&stats.SignUpForms, EventsCollection, bson.M{"$and": bson.A{notDeleted, bson.M{"isSignUpForm": true}}}},
+		{&stats.Responses, EventResponsesCollection, bson.M{}},
+		{&stats.Folders, FoldersCollection, notDeleted},
+		{&stats.EventTemplates, EventTemplatesCollection, bson.M{}},
+	}
+	for _, c := range counts {
+		count, err := c.collection.CountDocuments(ctx, c.filter)
+		if err != nil {
+			return nil, err
+		}
+		*c.count = int(count)
+	}
+
+	activeUserIds, err := DailyUserLogCollection.Distinct(ctx, "userIds", bson.M{
+		"date": bson.M{"$gte": primitive.NewDateTimeFromTime(monthAgo)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.ActiveUsers = len(activeUserIds)
+
+	return &stats, nil
+}
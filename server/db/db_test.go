@@ -11,13 +11,18 @@ import (
 )
 
 func TestGetDailyUserLogByDate(t *testing.T) {
-	db.GetDailyUserLogByDate(time.Now(), 7)
+	if _, err := db.GetDailyUserLogByDate(time.Now(), 7); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestGenerateShortEventId(t *testing.T) {
 	db.Init()
 
 	objectId, _ := primitive.ObjectIDFromHex("6607d6409f96021811c0a55f")
-	id := db.GenerateShortEventId(objectId)
+	id, err := db.GenerateShortEventId(objectId)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(id)
 }
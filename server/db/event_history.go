@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+func CreateEventHistoryEntry(entry *models.EventHistoryEntry) error {
+	entry.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	result, err := EventHistoryCollection.InsertOne(context.Background(), entry)
+	if err != nil {
+		return err
+	}
+	entry.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Returns a page of the event's history, most recent first, with the users that made the changes populated.
+// Only returns entries older than `cursor` if it isn't nil. Also returns the cursor of the next page, which is
+// nil on the last page
+func GetEventHistory(eventId primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.EventHistoryEntry, *primitive.ObjectID, error) {
+	entries := make([]models.EventHistoryEntry, 0)
+	next, err := findPage(EventHistoryCollection, bson.M{"eventId": eventId}, cursor, limit, &entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nextCursor *primitive.ObjectID
+	if next {
+		entries = entries[:limit]
+		nextCursor = &entries[limit-1].Id
+	}
+
+	// Get the users that made the changes at once
+	actorIds := make([]primitive.ObjectID, 0)
+	for _, entry := range entries {
+		if entry.ActorId != nil {
+			actorIds = append(actorIds, *entry.ActorId)
+		}
+	}
+	actors, err := GetPublicUsers(actorIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	actorsById := make(map[primitive.ObjectID]*models.User)
+	for i := range actors {
+		actorsById[actors[i].Id] = &actors[i]
+	}
+	for i := range entries {
+		if entries[i].ActorId != nil {
+			entries[i].Actor = actorsById[*entries[i].ActorId]
+		}
+	}
+
+	return entries, nextCursor, nil
+}
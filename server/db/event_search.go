@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Type to filter by that matches sign up forms, which are stored as specific dates or days of the week events
+const SignUpFormType models.EventType = "sign_up"
+
+// Filters for the events on a user's dashboard. Zero values don't filter
+type EventSearch struct {
+	// Words to look for in the name, description, and location
+	Query string
+	// Only events of these types, where SignUpFormType matches sign up forms
+	Types []models.EventType
+	// Only archived events if true, only unarchived events if false
+	Archived *bool
+	// Only events that have been scheduled if true, only unscheduled events if false
+	Scheduled *bool
+	// Only events with a date in this range
+	TimeMin *time.Time
+	TimeMax *time.Time
+	// Only these events instead of the user's events, used to list the events in a folder
+	EventIds *[]primitive.ObjectID
+
+	// Only events created before this event, which is the NextCursor of the previous page
+	Cursor *primitive.ObjectID
+	// Maximum number of events to return, 0 returns every event
+	Limit int
+}
+
+// A page of the events on a user's dashboard
+type EventSearchResult struct {
+	Events []models.Event `json:"events"`
+	// Pass as the cursor to get the next page, nil if this is the last page
+	NextCursor *primitive.ObjectID `json:"nextCursor"`
+	// Number of events that match the filters, across every page
+	Total int `json:"total"`
+}
+
+/*
+Returns the events the user owns, responded to, or was added to as an attendee that match the filters, most
+recently created first
+*/
+func SearchEvents(ctx context.Context, user *models.User, search EventSearch) (*EventSearchResult, error) {
+	// Get the event ids that the user has responded to or is an attendee of
+	respondedEventIds, err := findEventIds(ctx, EventResponsesCollection, bson.M{"userId": user.Id.Hex()})
+	if err != nil {
+		return nil, err
+	}
+	attendeeEventIds, err := findEventIds(ctx, AttendeesCollection, bson.M{"email": user.Email, "declined": false})
+	if err != nil {
+		return nil, err
+	}
+
+	filters := bson.A{
+		bson.M{
+			"$or": bson.A{
+				bson.M{"isDeleted": bson.M{"$exists": false}},
+				bson.M{"isDeleted": false},
+			},
+		},
+	}
+	if search.EventIds != nil {
+		filters = append(filters, bson.M{"_id": bson.M{"$in": *search.EventIds}})
+	} else {
+		filters = append(filters, bson.M{
+			"$or": bson.A{
+				bson.M{"_id": bson.M{"$in": append(respondedEventIds, attendeeEventIds...)}},
+				bson.M{"ownerId": user.Id},
+			},
+		})
+	}
+
+	if len(search.Types) > 0 {
+		typeFilters := bson.A{}
+		for _, eventType := range search.Types {
+			if eventType == SignUpFormType {
+				typeFilters = append(typeFilters, bson.M{"isSignUpForm": true})
+			} else {
+				typeFilters = append(typeFilters, bson.M{"type": eventType, "isSignUpForm": bson.M{"$ne": true}})
+			}
+		}
+		filters = append(filters, bson.M{"$or": typeFilters})
+	}
+	if search.Archived != nil {
+		if *search.Archived {
+			filters = append(filters, bson.M{"isArchived": true})
+		} else {
+			filters = append(filters, bson.M{"isArchived": bson.M{"$ne": true}})
+		}
+	}
+	if search.Scheduled != nil {
+		if *search.Scheduled {
+			filters = append(filters, bson.M{"scheduledEvent": bson.M{"$type": "object"}})
+		} else {
+			filters = append(filters, bson.M{"scheduledEvent": bson.M{"$not": bson.M{"$type": "object"}}})
+		}
+	}
+	if search.TimeMin != nil || search.TimeMax != nil {
+		dateRange := bson.M{}
+		if search.TimeMin != nil {
+			dateRange["$gte"] = primitive.NewDateTimeFromTime(*search.TimeMin)
+		}
+		if search.TimeMax != nil {
+			dateRange["$lt"] = primitive.NewDateTimeFromTime(*search.TimeMax)
+		}
+		filters = append(filters, bson.M{"dates": bson.M{"$elemMatch": dateRange}})
+	}
+
+	// $text has to be at the top level of the query
+	filter := bson.M{"$and": filters}
+	if len(search.Query) > 0 {
+		filter["$text"] = bson.M{"$search": search.Query}
+	}
+
+	result := &EventSearchResult{Events: make([]models.Event, 0)}
+	if search.Limit > 0 {
+		total, err := EventsCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = int(total)
+	}
+
+	pageFilter := filter
+	if search.Cursor != nil {
+		pageFilter = bson.M{"$and": append(filters, bson.M{"_id": bson.M{"$lt": *search.Cursor}})}
+		if text, ok := filter["$text"]; ok {
+			pageFilter["$text"] = text
+		}
+	}
+	opts := options.Find().SetSort(bson.M{"_id": -1})
+	if search.Limit > 0 {
+		// Get one more event than needed to know whether there's another page
+		opts.SetLimit(int64(search.Limit) + 1)
+	}
+	cursor, err := EventsCollection.Find(ctx, pageFilter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &result.Events); err != nil {
+		return nil, err
+	}
+
+	if search.Limit > 0 && len(result.Events) > search.Limit {
+		result.Events = result.Events[:search.Limit]
+		result.NextCursor = &result.Events[search.Limit-1].Id
+	}
+	if search.Limit == 0 {
+		result.Total = len(result.Events)
+	}
+
+	// Set the hasResponded field for availability groups, which is whether the user responded to a group
+	// they're an attendee of
+	responded := utils.ArrayToSet(respondedEventIds)
+	hasResponded := make(models.Set[primitive.ObjectID])
+	for _, eventId := range attendeeEventIds {
+		if _, ok := responded[eventId]; ok {
+			hasResponded[eventId] = struct{}{}
+		}
+	}
+	for i, event := range result.Events {
+		if event.Type == models.GROUP {
+			_, ok := hasResponded[event.Id]
+			result.Events[i].HasResponded = &ok
+		}
+	}
+
+	return result, nil
+}
+
+// Returns the distinct eventIds of the documents in the collection that match the filter
+func findEventIds(ctx context.Context, collection *mongo.Collection, filter bson.M) ([]primitive.ObjectID, error) {
+	values, err := collection.Distinct(ctx, "eventId", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	eventIds := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if eventId, ok := value.(primitive.ObjectID); ok {
+			eventIds = append(eventIds, eventId)
+		}
+	}
+	return eventIds, nil
+}
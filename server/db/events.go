@@ -2,22 +2,23 @@ package db
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"schej.it/server/logger"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"schej.it/server/models"
 )
 
-// Returns an event based on its _id
-func GetEventById(eventId string) *models.Event {
+// Returns an event based on its _id, or nil if it doesn't exist or was deleted
+func GetEventById(eventId string) (*models.Event, error) {
 	objectId, err := primitive.ObjectIDFromHex(eventId)
 	if err != nil {
 		// eventId is malformatted
-		return nil
+		return nil, nil
 	}
 	result := EventsCollection.FindOne(context.Background(), bson.M{
 		"$and": bson.A{
@@ -32,20 +33,20 @@ func GetEventById(eventId string) *models.Event {
 	})
 	if result.Err() == mongo.ErrNoDocuments {
 		// Event does not exist!
-		return nil
+		return nil, nil
 	}
 
 	// Decode result
 	var event models.Event
 	if err := result.Decode(&event); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
-	return &event
+	return &event, nil
 }
 
-// Returns an event based on its shortId
-func GetEventByShortId(shortEventId string) *models.Event {
+// Returns an event based on its shortId, or nil if it doesn't exist or was deleted
+func GetEventByShortId(shortEventId string) (*models.Event, error) {
 	result := EventsCollection.FindOne(context.Background(), bson.M{
 		"$and": bson.A{
 			bson.M{"shortId": shortEventId},
@@ -59,20 +60,20 @@ func GetEventByShortId(shortEventId string) *models.Event {
 	})
 	if result.Err() == mongo.ErrNoDocuments {
 		// Event does not exist!
-		return nil
+		return nil, nil
 	}
 
 	// Decode result
 	var event models.Event
 	if err := result.Decode(&event); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
-	return &event
+	return &event, nil
 }
 
-// Returns an event by either its _id or shortId
-func GetEventByEitherId(id string) *models.Event {
+// Returns an event by either its _id or shortId, or nil if it doesn't exist or was deleted
+func GetEventByEitherId(id string) (*models.Event, error) {
 	if len(id) <= 10 {
 		return GetEventByShortId(id)
 	}
@@ -80,59 +81,51 @@ func GetEventByEitherId(id string) *models.Event {
 	return GetEventById(id)
 }
 
-func GetEventResponses(eventId string) []models.EventResponse {
+func GetEventResponses(eventId string) ([]models.EventResponse, error) {
 	objectId, err := primitive.ObjectIDFromHex(eventId)
 	if err != nil {
 		// eventId is malformatted
-		return []models.EventResponse{}
+		return []models.EventResponse{}, nil
 	}
 
 	result, err := EventResponsesCollection.Find(context.Background(), bson.M{
 		"eventId": objectId,
 	})
 	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	if result.Err() == mongo.ErrNoDocuments {
-		// Event responses do not exist!
-		return []models.EventResponse{}
+		return nil, err
 	}
 
-	var eventResponses []models.EventResponse
+	eventResponses := make([]models.EventResponse, 0)
 	if err := result.All(context.Background(), &eventResponses); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
-	return eventResponses
+	return eventResponses, nil
 }
 
-func GetAttendees(eventId string) []models.Attendee {
+func GetAttendees(eventId string) ([]models.Attendee, error) {
 	objectId, err := primitive.ObjectIDFromHex(eventId)
 	if err != nil {
 		// eventId is malformatted
-		return []models.Attendee{}
+		return []models.Attendee{}, nil
 	}
 
 	result, err := AttendeesCollection.Find(context.Background(), bson.M{
 		"eventId": objectId,
 	})
 	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	if result.Err() == mongo.ErrNoDocuments {
-		// Attendees do not exist!
-		return []models.Attendee{}
+		return nil, err
 	}
 
-	var attendees []models.Attendee
+	attendees := make([]models.Attendee, 0)
 	if err := result.All(context.Background(), &attendees); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
-	return attendees
+	return attendees, nil
 }
 
-func GetEventsCreatedThisMonth(userId primitive.ObjectID) int {
+func GetEventsCreatedThisMonth(userId primitive.ObjectID) (int, error) {
 	// Get the start of this month
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
@@ -144,14 +137,14 @@ func GetEventsCreatedThisMonth(userId primitive.ObjectID) int {
 		},
 	})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return 0, err
 	}
 
-	return int(result)
+	return int(result), nil
 }
 
 // Returns a random unique short event id seeded by the actual event id
-func GenerateShortEventId(eventId primitive.ObjectID) string {
+func GenerateShortEventId(eventId primitive.ObjectID) (string, error) {
 	r := rand.New(rand.NewSource(eventId.Timestamp().Unix()))
 
 	id := ""
@@ -164,32 +157,145 @@ func GenerateShortEventId(eventId primitive.ObjectID) string {
 	}
 
 	i := 0
-	event := GetEventByShortId(id)
-	for event != nil && i < 5 {
+	event, err := GetEventByShortId(id)
+	for err == nil && event != nil && i < 5 {
 		// Event exists, keep on adding letters until event doesn't exist anymore, max of 5 more letters
 		index := r.Intn(len(letters))
 		letter := letters[index : index+1]
 		id += letter
-		event = GetEventByShortId(id)
+		event, err = GetEventByShortId(id)
 		i++
 	}
+	if err != nil {
+		return "", err
+	}
 
 	if event != nil {
-		logger.StdErr.Panicln("Couldn't generate unique id")
+		return "", errors.New("couldn't generate a unique short event id")
+	}
+
+	return id, nil
+}
+
+// Updates every field of the given event except its sign up responses, which are written atomically
+// with SetSignUpResponse and DeleteSignUpResponse so that concurrent sign ups aren't overwritten, and
+// its numbers of responses, which are only changed with IncrementNumResponses, ClaimGuestResponse and
+// ReleaseGuestResponse for the same reason
+func UpdateEvent(event *models.Event) error {
+	data, err := bson.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var update bson.M
+	if err := bson.Unmarshal(data, &update); err != nil {
+		return err
+	}
+	delete(update, "signUpResponses")
+	delete(update, "numResponses")
+	delete(update, "numGuestResponses")
+
+	_, err = EventsCollection.UpdateByID(context.Background(), event.Id, bson.M{"$set": update})
+	return err
+}
+
+// Adds `delta` to the number of responses of the event and returns the new number of responses
+func IncrementNumResponses(eventId primitive.ObjectID, delta int) (int, error) {
+	var result struct {
+		NumResponses int `bson:"numResponses"`
+	}
+	err := EventsCollection.FindOneAndUpdate(context.Background(), bson.M{
+		"_id": eventId,
+	}, bson.M{
+		"$inc": bson.M{"numResponses": delta},
+	}, options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"numResponses": 1}),
+	).Decode(&result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.NumResponses, nil
+}
+
+// Adds one to the number of guest responses of the event, but only if it has fewer than `maxGuestResponses` at the
+// time of the write, so that concurrent guests can't go past the limit. 0 means there's no limit. Returns whether
+// the guest response was counted
+func ClaimGuestResponse(eventId primitive.ObjectID, maxGuestResponses int) (bool, error) {
+	filter := bson.M{"_id": eventId}
+	if maxGuestResponses > 0 {
+		filter["$or"] = bson.A{
+			bson.M{"numGuestResponses": bson.M{"$lt": maxGuestResponses}},
+			bson.M{"numGuestResponses": bson.M{"$exists": false}},
+		}
+	}
+	result, err := EventsCollection.UpdateOne(context.Background(), filter, bson.M{
+		"$inc": bson.M{"numGuestResponses": 1},
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return id
+	return result.MatchedCount > 0, nil
 }
 
-// Updates the name of a guest response
-func UpdateGuestResponseName(eventId string, oldName string, newName string) {
+// Subtracts one from the number of guest responses of the event, after a guest response was deleted or turned out
+// to already exist
+func ReleaseGuestResponse(eventId primitive.ObjectID) error {
+	_, err := EventsCollection.UpdateOne(context.Background(), bson.M{
+		"_id":               eventId,
+		"numGuestResponses": bson.M{"$gt": 0},
+	}, bson.M{
+		"$inc": bson.M{"numGuestResponses": -1},
+	})
+	return err
+}
+
+// Sets the response of the user with the key `userId`, creating it if it doesn't exist.
+// Returns whether a new response was created
+func UpsertEventResponse(eventId primitive.ObjectID, userId string, response *models.Response) (bool, error) {
+	upsert := func() (*mongo.UpdateResult, error) {
+		return EventResponsesCollection.UpdateOne(context.Background(), bson.M{
+			"eventId": eventId,
+			"userId":  userId,
+		}, bson.M{
+			"$set": bson.M{"response": response},
+		}, options.Update().SetUpsert(true))
+	}
+
+	result, err := upsert()
+	if mongo.IsDuplicateKeyError(err) {
+		// Another request created the response at the same time, so it can be updated now
+		result, err = upsert()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return result.UpsertedCount > 0, nil
+}
+
+// Deletes the event response with the given _id. Returns whether it existed
+func DeleteEventResponse(eventResponseId primitive.ObjectID) (bool, error) {
+	result, err := EventResponsesCollection.DeleteOne(context.Background(), bson.M{
+		"_id": eventResponseId,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// Updates the name of a guest response. Returns whether the response existed
+func UpdateGuestResponseName(eventId string, oldName string, newName string) (bool, error) {
 	objectId, err := primitive.ObjectIDFromHex(eventId)
 	if err != nil {
 		// eventId is malformatted
-		return
+		return false, nil
 	}
 
-	_, err = EventResponsesCollection.UpdateOne(context.Background(), bson.M{
+	result, err := EventResponsesCollection.UpdateOne(context.Background(), bson.M{
 		"eventId": objectId,
 		"userId":  oldName,
 	}, bson.M{
@@ -199,6 +305,27 @@ func UpdateGuestResponseName(eventId string, oldName string, newName string) {
 		},
 	})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// Archives or unarchives the events the user owns out of the given ones, skipping deleted events.
+// Returns the number of events that were changed
+func SetEventsArchived(eventIds []primitive.ObjectID, ownerId primitive.ObjectID, archived bool) (int, error) {
+	if len(eventIds) == 0 {
+		return 0, nil
+	}
+
+	result, err := EventsCollection.UpdateMany(context.Background(), bson.M{
+		"_id":       bson.M{"$in": eventIds},
+		"ownerId":   ownerId,
+		"isDeleted": bson.M{"$ne": true},
+	}, bson.M{
+		"$set": bson.M{"isArchived": archived},
+	})
+	if err != nil {
+		return 0, err
 	}
+	return int(result.ModifiedCount), nil
 }
@@ -2,18 +2,17 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"schej.it/server/logger"
 	"schej.it/server/models"
 )
 
 func CreateFolder(folder *models.Folder) (primitive.ObjectID, error) {
 	result, err := FoldersCollection.InsertOne(context.Background(), folder)
 	if err != nil {
-		logger.StdErr.Panicln(err)
 		return primitive.NilObjectID, err
 	}
 	return result.InsertedID.(primitive.ObjectID), nil
@@ -121,33 +120,180 @@ func SetEventFolder(eventId primitive.ObjectID, folderId *primitive.ObjectID, us
 	return nil
 }
 
+// Moves the folder, its subfolders, and the events in them that the user owns to the trash. Everything is
+// marked with the same deletion time as the folder, so that it can be restored along with it
 func DeleteFolder(folderId primitive.ObjectID, userId primitive.ObjectID) error {
 	ctx := context.Background()
+	deletedAt := primitive.NewDateTimeFromTime(time.Now())
+
 	// Mark this folder as deleted
-	_, err := FoldersCollection.UpdateOne(ctx, bson.M{"_id": folderId, "userId": userId}, bson.M{"$set": bson.M{"isDeleted": true}})
+	result, err := FoldersCollection.UpdateOne(ctx, bson.M{"_id": folderId, "userId": userId}, bson.M{"$set": bson.M{"isDeleted": true, "deletedAt": deletedAt}})
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return nil
+	}
 
-	// Find all event mappings for this folder
-	eventIds, err := GetEventsInFolder(folderId, userId)
+	// Mark the subfolders that aren't deleted already as deleted
+	subfolderIds, err := getSubfolderIds(ctx, folderId, bson.M{
+		"$or": bson.A{
+			bson.M{"isDeleted": bson.M{"$exists": false}},
+			bson.M{"isDeleted": false},
+		},
+	})
 	if err != nil {
 		return err
 	}
+	if len(subfolderIds) > 0 {
+		_, err = FoldersCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": subfolderIds}}, bson.M{"$set": bson.M{"isDeleted": true, "deletedAt": deletedAt}})
+		if err != nil {
+			return err
+		}
+	}
 
-	// Mark all events in this folder as deleted (if the user owns the event)
-	if len(eventIds) > 0 {
-		_, err = EventsCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": eventIds}, "ownerId": userId}, bson.M{"$set": bson.M{"isDeleted": true}})
+	// Mark the events in each folder as deleted (if the user owns the event and it isn't deleted already),
+	// remembering which folder they were in so that restoring the folder puts them back
+	folderIds := append(subfolderIds, folderId)
+	for _, id := range folderIds {
+		eventIds, err := GetEventsInFolder(id, userId)
+		if err != nil {
+			return err
+		}
+		if len(eventIds) == 0 {
+			continue
+		}
+		_, err = EventsCollection.UpdateMany(ctx, bson.M{
+			"_id":     bson.M{"$in": eventIds},
+			"ownerId": userId,
+			"$or": bson.A{
+				bson.M{"isDeleted": bson.M{"$exists": false}},
+				bson.M{"isDeleted": false},
+			},
+		}, bson.M{"$set": bson.M{"isDeleted": true, "deletedAt": deletedAt, "deletedFromFolderId": id}})
 		if err != nil {
 			return err
 		}
 	}
 
 	// Delete the mappings
-	_, err = FolderEventsCollection.DeleteMany(ctx, bson.M{"folderId": folderId, "userId": userId})
+	_, err = FolderEventsCollection.DeleteMany(ctx, bson.M{"folderId": bson.M{"$in": folderIds}, "userId": userId})
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// Returns the folder with the given _id whoever owns it, or an error if it doesn't exist or was deleted
+func GetFolder(folderId primitive.ObjectID) (*models.Folder, error) {
+	var folder models.Folder
+	err := FoldersCollection.FindOne(context.Background(), bson.M{
+		"_id": folderId,
+		"$or": bson.A{
+			bson.M{"isDeleted": bson.M{"$exists": false}},
+			bson.M{"isDeleted": false},
+		},
+	}).Decode(&folder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &folder, nil
+}
+
+// Returns the folders directly inside the folder that aren't deleted
+func GetSubfolders(folderId primitive.ObjectID) ([]models.Folder, error) {
+	cursor, err := FoldersCollection.Find(context.Background(), bson.M{
+		"parentId": folderId,
+		"$or": bson.A{
+			bson.M{"isDeleted": bson.M{"$exists": false}},
+			bson.M{"isDeleted": false},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]models.Folder, 0)
+	if err = cursor.All(context.Background(), &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// Returns the folders other users shared with the user. The folders inside them aren't included
+func GetSharedFolders(userId primitive.ObjectID) ([]models.Folder, error) {
+	cursor, err := FoldersCollection.Find(context.Background(), bson.M{
+		"sharedWith.userId": userId,
+		"$or": bson.A{
+			bson.M{"isDeleted": bson.M{"$exists": false}},
+			bson.M{"isDeleted": false},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]models.Folder, 0)
+	if err = cursor.All(context.Background(), &folders); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// Shares the folder with a user, replacing their role if the folder was already shared with them
+func ShareFolder(folderId primitive.ObjectID, share models.FolderShare) error {
+	ctx := context.Background()
+
+	result, err := FoldersCollection.UpdateOne(ctx, bson.M{
+		"_id":               folderId,
+		"sharedWith.userId": share.UserId,
+	}, bson.M{"$set": bson.M{"sharedWith.$.role": share.Role}})
+	if err != nil || result.MatchedCount > 0 {
+		return err
+	}
+
+	_, err = FoldersCollection.UpdateOne(ctx, bson.M{
+		"_id":               folderId,
+		"sharedWith.userId": bson.M{"$ne": share.UserId},
+	}, bson.M{"$push": bson.M{"sharedWith": share}})
+	return err
+}
+
+// Stops sharing the folder with the user
+func UnshareFolder(folderId primitive.ObjectID, userId primitive.ObjectID) error {
+	_, err := FoldersCollection.UpdateOne(context.Background(), bson.M{"_id": folderId}, bson.M{
+		"$pull": bson.M{"sharedWith": bson.M{"userId": userId}},
+	})
+	return err
+}
+
+// Returns the ids of the folders nested anywhere inside the folder that match the filter. Folders that don't
+// match the filter aren't descended into
+func getSubfolderIds(ctx context.Context, folderId primitive.ObjectID, filter bson.M) ([]primitive.ObjectID, error) {
+	subfolderIds := make([]primitive.ObjectID, 0)
+	visited := map[primitive.ObjectID]bool{folderId: true}
+	parentIds := []primitive.ObjectID{folderId}
+	for len(parentIds) > 0 {
+		levelFilter := bson.M{"parentId": bson.M{"$in": parentIds}}
+		for key, value := range filter {
+			levelFilter[key] = value
+		}
+		ids, err := findIds(ctx, FoldersCollection, levelFilter, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Guard against cycles, which the routes don't allow but could be left by concurrent moves
+		parentIds = make([]primitive.ObjectID, 0, len(ids))
+		for _, id := range ids {
+			if !visited[id] {
+				visited[id] = true
+				parentIds = append(parentIds, id)
+			}
+		}
+		subfolderIds = append(subfolderIds, parentIds...)
+	}
+	return subfolderIds, nil
+}
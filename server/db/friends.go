@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/models"
+)
+
+// The user fields that friends and people sending friend requests can see
+var publicUserProjection = bson.M{"email": 1, "firstName": 1, "lastName": 1, "picture": 1}
+
+// Creates a friend request from one user to another
+func CreateFriendRequest(from primitive.ObjectID, to primitive.ObjectID) (*models.FriendRequest, error) {
+	friendRequest := models.FriendRequest{
+		From:      from,
+		To:        to,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	result, err := FriendRequestsCollection.InsertOne(context.Background(), friendRequest)
+	if err != nil {
+		return nil, err
+	}
+	friendRequest.Id = result.InsertedID.(primitive.ObjectID)
+	return &friendRequest, nil
+}
+
+// Returns the friend request that either user sent the other, or nil if there isn't one
+func GetFriendRequestBetween(userId primitive.ObjectID, otherUserId primitive.ObjectID) (*models.FriendRequest, error) {
+	var friendRequest models.FriendRequest
+	err := FriendRequestsCollection.FindOne(context.Background(), bson.M{
+		"$or": bson.A{
+			bson.M{"from": userId, "to": otherUserId},
+			bson.M{"from": otherUserId, "to": userId},
+		},
+	}).Decode(&friendRequest)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &friendRequest, nil
+}
+
+// Returns the friend requests sent to and sent by the user, newest first, with the other user populated
+func GetFriendRequests(userId primitive.ObjectID) ([]models.FriendRequest, []models.FriendRequest, error) {
+	cursor, err := FriendRequestsCollection.Find(context.Background(), bson.M{
+		"$or": bson.A{bson.M{"from": userId}, bson.M{"to": userId}},
+	}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, nil, err
+	}
+	var friendRequests []models.FriendRequest
+	if err := cursor.All(context.Background(), &friendRequests); err != nil {
+		return nil, nil, err
+	}
+
+	// Get the other user of every request at once
+	otherUserIds := make([]primitive.ObjectID, len(friendRequests))
+	for i, friendRequest := range friendRequests {
+		if friendRequest.From == userId {
+			otherUserIds[i] = friendRequest.To
+		} else {
+			otherUserIds[i] = friendRequest.From
+		}
+	}
+	otherUsers, err := GetPublicUsers(otherUserIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	usersById := make(map[primitive.ObjectID]*models.User)
+	for i := range otherUsers {
+		usersById[otherUsers[i].Id] = &otherUsers[i]
+	}
+
+	incoming := make([]models.FriendRequest, 0)
+	outgoing := make([]models.FriendRequest, 0)
+	for _, friendRequest := range friendRequests {
+		if friendRequest.From == userId {
+			friendRequest.ToUser = usersById[friendRequest.To]
+			outgoing = append(outgoing, friendRequest)
+		} else {
+			friendRequest.FromUser = usersById[friendRequest.From]
+			incoming = append(incoming, friendRequest)
+		}
+	}
+	return incoming, outgoing, nil
+}
+
+// Makes the users that the friend request is between friends and deletes the request
+func AcceptFriendRequest(friendRequest *models.FriendRequest) error {
+	if err := setFriends(friendRequest.From, friendRequest.To, "$addToSet"); err != nil {
+		return err
+	}
+	_, err := FriendRequestsCollection.DeleteOne(context.Background(), bson.M{"_id": friendRequest.Id})
+	return err
+}
+
+// Removes the users from each other's friends
+func RemoveFriends(userId primitive.ObjectID, otherUserId primitive.ObjectID) error {
+	return setFriends(userId, otherUserId, "$pull")
+}
+
+// Adds the users to or removes them from each other's friends with the given update operator
+func setFriends(userId primitive.ObjectID, otherUserId primitive.ObjectID, operator string) error {
+	if _, err := UsersCollection.UpdateByID(context.Background(), userId, bson.M{
+		operator: bson.M{"friendIds": otherUserId},
+	}); err != nil {
+		return err
+	}
+	_, err := UsersCollection.UpdateByID(context.Background(), otherUserId, bson.M{
+		operator: bson.M{"friendIds": userId},
+	})
+	return err
+}
+
+// Returns the users with the given _ids, with only the fields that other users can see, sorted by name
+func GetPublicUsers(userIds []primitive.ObjectID) ([]models.User, error) {
+	users := make([]models.User, 0)
+	if len(userIds) == 0 {
+		return users, nil
+	}
+
+	cursor, err := UsersCollection.Find(context.Background(), bson.M{
+		"_id": bson.M{"$in": userIds},
+	}, options.Find().SetProjection(publicUserProjection).SetSort(bson.D{{Key: "firstName", Value: 1}, {Key: "lastName", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
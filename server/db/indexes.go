@@ -0,0 +1,253 @@
+package db
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/logger"
+)
+
+// An index that should exist on a collection
+type declaredIndex struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+
+	// Only index documents matching this filter, used for unique fields that not every document has
+	PartialFilter bson.M
+}
+
+// Returns the indexes that should exist on each collection
+func declaredIndexes() map[*mongo.Collection][]declaredIndex {
+	return map[*mongo.Collection][]declaredIndex{
+		EventsCollection: {
+			{Name: "shortId_1", Keys: bson.D{{Key: "shortId", Value: 1}}, Unique: true, PartialFilter: bson.M{"shortId": bson.M{"$type": "string"}}},
+			{Name: "ownerId_1__id_-1", Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Name: "deletedAt_1", Keys: bson.D{{Key: "deletedAt", Value: 1}}, PartialFilter: bson.M{"isDeleted": true}},
+			{Name: "remindees.email_1", Keys: bson.D{{Key: "remindees.email", Value: 1}}},
+			{Name: "name_text_description_text_location_text", Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}, {Key: "location", Value: "text"}}},
+		},
+		EventResponsesCollection: {
+			{Name: "eventId_1_userId_1", Keys: bson.D{{Key: "eventId", Value: 1}, {Key: "userId", Value: 1}}, Unique: true},
+			{Name: "userId_1", Keys: bson.D{{Key: "userId", Value: 1}}},
+			{Name: "response.email_1", Keys: bson.D{{Key: "response.email", Value: 1}}, PartialFilter: bson.M{"response.email": bson.M{"$type": "string"}}},
+		},
+		AttendeesCollection: {
+			{Name: "email_1_declined_1", Keys: bson.D{{Key: "email", Value: 1}, {Key: "declined", Value: 1}}},
+			{Name: "eventId_1_email_1", Keys: bson.D{{Key: "eventId", Value: 1}, {Key: "email", Value: 1}}},
+		},
+		FoldersCollection: {
+			{Name: "userId_1", Keys: bson.D{{Key: "userId", Value: 1}}},
+			{Name: "deletedAt_1", Keys: bson.D{{Key: "deletedAt", Value: 1}}, PartialFilter: bson.M{"isDeleted": true}},
+			{Name: "parentId_1", Keys: bson.D{{Key: "parentId", Value: 1}}},
+			{Name: "sharedWith.userId_1", Keys: bson.D{{Key: "sharedWith.userId", Value: 1}}},
+		},
+		FolderEventsCollection: {
+			{Name: "folderId_1_userId_1", Keys: bson.D{{Key: "folderId", Value: 1}, {Key: "userId", Value: 1}}},
+			{Name: "eventId_1_userId_1", Keys: bson.D{{Key: "eventId", Value: 1}, {Key: "userId", Value: 1}}},
+		},
+		EventTemplatesCollection: {
+			{Name: "userId_1", Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		FriendRequestsCollection: {
+			{Name: "from_1_to_1", Keys: bson.D{{Key: "from", Value: 1}, {Key: "to", Value: 1}}, Unique: true},
+			{Name: "to_1", Keys: bson.D{{Key: "to", Value: 1}}},
+		},
+		AdminAuditLogCollection: {
+			{Name: "targetUserId_1", Keys: bson.D{{Key: "targetUserId", Value: 1}}, PartialFilter: bson.M{"targetUserId": bson.M{"$exists": true}}},
+			{Name: "targetEventId_1", Keys: bson.D{{Key: "targetEventId", Value: 1}}, PartialFilter: bson.M{"targetEventId": bson.M{"$exists": true}}},
+		},
+		EventHistoryCollection: {
+			{Name: "eventId_1__id_-1", Keys: bson.D{{Key: "eventId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Name: "actorId_1", Keys: bson.D{{Key: "actorId", Value: 1}}},
+		},
+		UsersCollection: {
+			{Name: "email_1", Keys: bson.D{{Key: "email", Value: 1}}},
+			{Name: "friendIds_1", Keys: bson.D{{Key: "friendIds", Value: 1}}},
+			{Name: "stripeCustomerId_1", Keys: bson.D{{Key: "stripeCustomerId", Value: 1}}, PartialFilter: bson.M{"stripeCustomerId": bson.M{"$type": "string"}}},
+		},
+		DailyUserLogCollection: {
+			{Name: "date_1", Keys: bson.D{{Key: "date", Value: 1}}},
+		},
+	}
+}
+
+// Returns the names of indexes that earlier versions created and that have since been replaced, which are only
+// dropped if MONGO_DROP_OBSOLETE_INDEXES is "true"
+func obsoleteIndexes() map[*mongo.Collection][]string {
+	return map[*mongo.Collection][]string{
+		// Responses and attendees used to be stored on the event, but have moved to their own collections
+		EventsCollection: {"responses_userId_1", "attendees_email_1", "responses_userId_id_1", "attendees_email_declined_id_1"},
+	}
+}
+
+// An index as returned by listIndexes
+type existingIndex struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique"`
+	PartialFilterExpression bson.M `bson:"partialFilterExpression"`
+
+	// The fields of a text index, which are listed under the _fts and _ftsx keys instead
+	Weights bson.M `bson:"weights"`
+}
+
+/*
+Creates any declared indexes that are missing, and logs indexes that are obsolete, differ from their declaration, or
+aren't declared. Should be called after migrations have been applied.
+
+Indexes that differ from their declaration are only dropped and recreated if the MONGO_RECREATE_CHANGED_INDEXES
+environment variable is "true", so that large indexes aren't rebuilt by surprise. Likewise, obsolete indexes are only
+dropped if MONGO_DROP_OBSOLETE_INDEXES is "true". Indexes that aren't declared are never dropped, since an operator
+may have added them on purpose
+*/
+func EnsureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	recreateChanged := os.Getenv("MONGO_RECREATE_CHANGED_INDEXES") == "true"
+	dropObsolete := os.Getenv("MONGO_DROP_OBSOLETE_INDEXES") == "true"
+	obsolete := obsoleteIndexes()
+
+	for collection, indexes := range declaredIndexes() {
+		cursor, err := collection.Indexes().List(ctx)
+		if err != nil {
+			// Most likely the database isn't reachable, so don't wait on every other collection too
+			logger.StdErr.Printf("Couldn't list indexes on %s: %v\n", collection.Name(), err)
+			return
+		}
+		var existingIndexes []existingIndex
+		if err := cursor.All(ctx, &existingIndexes); err != nil {
+			logger.StdErr.Printf("Couldn't list indexes on %s: %v\n", collection.Name(), err)
+			continue
+		}
+		existingByName := make(map[string]existingIndex)
+		for _, index := range existingIndexes {
+			existingByName[index.Name] = index
+		}
+
+		for _, name := range obsolete[collection] {
+			if _, exists := existingByName[name]; !exists {
+				continue
+			}
+			delete(existingByName, name)
+			if !dropObsolete {
+				logger.StdErr.Printf("Index %s on %s is obsolete, set MONGO_DROP_OBSOLETE_INDEXES=true to drop it\n", name, collection.Name())
+				continue
+			}
+			if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+				logger.StdErr.Printf("Couldn't drop obsolete index %s on %s: %v\n", name, collection.Name(), err)
+				continue
+			}
+			logger.StdOut.Printf("Dropped obsolete index %s on %s\n", name, collection.Name())
+		}
+
+		for _, index := range indexes {
+			existing, exists := existingByName[index.Name]
+			delete(existingByName, index.Name)
+
+			if exists {
+				if index.matches(existing) {
+					continue
+				}
+				if !recreateChanged {
+					logger.StdErr.Printf("Index %s on %s doesn't match its declaration, set MONGO_RECREATE_CHANGED_INDEXES=true to recreate it\n", index.Name, collection.Name())
+					continue
+				}
+				if _, err := collection.Indexes().DropOne(ctx, index.Name); err != nil {
+					logger.StdErr.Printf("Couldn't drop index %s on %s: %v\n", index.Name, collection.Name(), err)
+					continue
+				}
+			}
+
+			opts := options.Index().SetName(index.Name)
+			if index.Unique {
+				opts.SetUnique(true)
+			}
+			if index.PartialFilter != nil {
+				opts.SetPartialFilterExpression(index.PartialFilter)
+			}
+			if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: index.Keys, Options: opts}); err != nil {
+				logger.StdErr.Printf("Couldn't create index %s on %s: %v\n", index.Name, collection.Name(), err)
+				continue
+			}
+			logger.StdOut.Printf("Created index %s on %s\n", index.Name, collection.Name())
+		}
+
+		// Whatever is left over isn't declared. Leave it alone in case it was added on purpose
+		for name := range existingByName {
+			if name == "_id_" {
+				continue
+			}
+			logger.StdOut.Printf("Index %s on %s isn't declared in db/indexes.go\n", name, collection.Name())
+		}
+	}
+}
+
+// Returns whether the existing index has the same keys and options as the declaration
+func (index declaredIndex) matches(existing existingIndex) bool {
+	if index.Unique != existing.Unique || !index.keysMatch(existing) {
+		return false
+	}
+
+	// Compare partial filters by their bson representation, since nested values decode with different types
+	if len(index.PartialFilter) == 0 && len(existing.PartialFilterExpression) == 0 {
+		return true
+	}
+	declared, err1 := bson.Marshal(index.PartialFilter)
+	actual, err2 := bson.Marshal(existing.PartialFilterExpression)
+	return err1 == nil && err2 == nil && reflect.DeepEqual(declared, actual)
+}
+
+// Returns whether the existing index has the same keys as the declaration
+func (index declaredIndex) keysMatch(existing existingIndex) bool {
+	// Text fields are compared as a set, since their order doesn't matter and isn't kept
+	existingKeys := make(bson.D, 0, len(existing.Key))
+	for _, key := range existing.Key {
+		if key.Key != "_fts" && key.Key != "_ftsx" {
+			existingKeys = append(existingKeys, key)
+		}
+	}
+	declaredKeys := make(bson.D, 0, len(index.Keys))
+	textFields := 0
+	for _, key := range index.Keys {
+		if key.Value == "text" {
+			if _, ok := existing.Weights[key.Key]; !ok {
+				return false
+			}
+			textFields++
+		} else {
+			declaredKeys = append(declaredKeys, key)
+		}
+	}
+	if textFields != len(existing.Weights) || len(declaredKeys) != len(existingKeys) {
+		return false
+	}
+
+	for i, key := range declaredKeys {
+		if key.Key != existingKeys[i].Key || toFloat(key.Value) != toFloat(existingKeys[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Index key directions can come back from the server as int32, int64, or double
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDeclaredIndexMatchesTextIndex(t *testing.T) {
+	index := declaredIndex{
+		Name: "ownerId_1_name_text_description_text",
+		Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+	}
+
+	// listIndexes returns the text fields as weights, in no particular order
+	existing := existingIndex{
+		Name:    index.Name,
+		Key:     bson.D{{Key: "ownerId", Value: int32(1)}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+		Weights: bson.M{"description": int32(1), "name": int32(1)},
+	}
+	if !index.matches(existing) {
+		t.Errorf("text index %v doesn't match its declaration", existing)
+	}
+
+	existing.Weights = bson.M{"name": int32(1)}
+	if index.matches(existing) {
+		t.Errorf("text index missing a field matches its declaration")
+	}
+}
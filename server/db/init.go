@@ -7,6 +7,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"schej.it/server/logger"
 )
 
@@ -20,6 +21,10 @@ var EventResponsesCollection *mongo.Collection
 var AttendeesCollection *mongo.Collection
 var FoldersCollection *mongo.Collection
 var FolderEventsCollection *mongo.Collection
+var EventTemplatesCollection *mongo.Collection
+var AdminAuditLogCollection *mongo.Collection
+var EventHistoryCollection *mongo.Collection
+var SchemaMigrationsCollection *mongo.Collection
 
 func Init() func() {
 	// Get MongoDB URI from environment variable, default to localhost
@@ -37,7 +42,8 @@ func Init() func() {
 	// Establish mongodb connection
 	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	Client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	var err error
+	Client, err = mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
 	if err != nil {
 		logger.StdErr.Panicln(err)
 	}
@@ -52,13 +58,26 @@ func Init() func() {
 	AttendeesCollection = Db.Collection("attendees")
 	FoldersCollection = Db.Collection("folders")
 	FolderEventsCollection = Db.Collection("folderEvents")
+	EventTemplatesCollection = Db.Collection("eventTemplates")
+	AdminAuditLogCollection = Db.Collection("adminAuditLog")
+	EventHistoryCollection = Db.Collection("eventHistory")
+	SchemaMigrationsCollection = Db.Collection("schema_migrations")
 
-	// Return a function to close the connection
+	// Return a function to close the connection. The connect context has expired by then, so use a new one
 	return func() {
-		Client.Disconnect(ctx)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := Client.Disconnect(ctx); err != nil {
+			logger.StdErr.Println(err)
+		}
 	}
 }
 
+// Returns an error if the primary of the database can't be reached
+func Ping(ctx context.Context) error {
+	return Client.Ping(ctx, readpref.Primary())
+}
+
 // MongoDB backup / restore commands
 
 // Backup
@@ -0,0 +1,300 @@
+package memory
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+type AccountRepository struct {
+	// Deleting or exporting a user goes through everything stored about them
+	database *Database
+}
+
+// Deletes the user the same way as db.DeleteUser, except for their activity logs, which aren't stored in memory
+func (r *AccountRepository) Delete(ctx context.Context, user *models.User, transferTo *primitive.ObjectID) (*db.DeletedUser, error) {
+	d := r.database
+	if transferTo != nil {
+		d.Users.mutex.Lock()
+		newOwner, ok := d.Users.users[*transferTo]
+		accepted := ok && *transferTo != user.Id && utils.Contains(newOwner.FriendIds, user.Id)
+		d.Users.mutex.Unlock()
+		if !accepted {
+			return nil, db.ErrTransferNotAccepted
+		}
+	}
+
+	deleted := &db.DeletedUser{
+		TaskIds:             make([]string, 0),
+		FreedSignUpBlockIds: make(map[primitive.ObjectID][]primitive.ObjectID),
+	}
+
+	// Owned events. Events in the trash are never transferred
+	if transferTo != nil {
+		d.Events.purge(func(event *models.Event) bool {
+			return event.OwnerId == user.Id && utils.Coalesce(event.IsDeleted)
+		})
+		d.Events.mutex.Lock()
+		for _, event := range d.Events.events {
+			if event.OwnerId == user.Id {
+				event.OwnerId = *transferTo
+			}
+		}
+		d.Events.mutex.Unlock()
+	} else {
+		d.Events.mutex.Lock()
+		for _, event := range d.Events.events {
+			if event.OwnerId == user.Id && !utils.Coalesce(event.IsDeleted) {
+				for _, remindee := range utils.Coalesce(event.Remindees) {
+					deleted.TaskIds = append(deleted.TaskIds, remindee.TaskIds...)
+				}
+			}
+		}
+		d.Events.mutex.Unlock()
+		d.Events.purge(func(event *models.Event) bool {
+			return event.OwnerId == user.Id
+		})
+	}
+
+	// Responses to other events, including the ones they left as a guest with their email
+	respondedEventIds := make([]primitive.ObjectID, 0)
+	d.Responses.mutex.Lock()
+	for id, eventResponse := range d.Responses.responses {
+		if isUserResponse(user, eventResponse) {
+			delete(d.Responses.responses, id)
+			respondedEventIds = append(respondedEventIds, eventResponse.EventId)
+		}
+	}
+	d.Responses.mutex.Unlock()
+	for _, eventId := range respondedEventIds {
+		d.Events.IncrementNumResponses(eventId, -1)
+	}
+
+	// Sign ups and remindee entries
+	d.Events.mutex.Lock()
+	for _, event := range d.Events.events {
+		if response, ok := event.SignUpResponses[user.Id.Hex()]; ok {
+			if response != nil && len(response.SignUpBlockIds) > 0 && !utils.Coalesce(event.IsDeleted) {
+				deleted.FreedSignUpBlockIds[event.Id] = response.SignUpBlockIds
+			}
+			delete(event.SignUpResponses, user.Id.Hex())
+		}
+		if len(user.Email) > 0 && event.Remindees != nil {
+			remindees := make([]models.Remindee, 0, len(*event.Remindees))
+			for _, remindee := range *event.Remindees {
+				if remindee.Email == user.Email {
+					deleted.TaskIds = append(deleted.TaskIds, remindee.TaskIds...)
+				} else {
+					remindees = append(remindees, remindee)
+				}
+			}
+			event.Remindees = &remindees
+		}
+	}
+	d.Events.mutex.Unlock()
+
+	// Event history entries about their responses and attendee entries, and who made the other changes
+	d.EventHistory.mutex.Lock()
+	entries := make([]*models.EventHistoryEntry, 0, len(d.EventHistory.entries))
+	for _, entry := range d.EventHistory.entries {
+		if isAboutUser(user, entry) {
+			continue
+		}
+		if entry.ActorId != nil && *entry.ActorId == user.Id {
+			entry.ActorId = nil
+		}
+		entries = append(entries, entry)
+	}
+	d.EventHistory.entries = entries
+	d.EventHistory.mutex.Unlock()
+
+	if len(user.Email) > 0 {
+		d.Attendees.mutex.Lock()
+		for id, attendee := range d.Attendees.attendees {
+			if attendee.Email == user.Email {
+				delete(d.Attendees.attendees, id)
+			}
+		}
+		d.Attendees.mutex.Unlock()
+	}
+
+	// Folders, and the folders shared with them
+	d.Folders.mutex.Lock()
+	for id, folderEvent := range d.Folders.folderEvents {
+		if folderEvent.UserId == user.Id {
+			delete(d.Folders.folderEvents, id)
+		}
+	}
+	for id, folder := range d.Folders.folders {
+		if folder.UserId == user.Id {
+			delete(d.Folders.folders, id)
+			continue
+		}
+		sharedWith := make([]models.FolderShare, 0, len(folder.SharedWith))
+		for _, share := range folder.SharedWith {
+			if share.UserId != user.Id {
+				sharedWith = append(sharedWith, share)
+			}
+		}
+		folder.SharedWith = sharedWith
+	}
+	d.Folders.mutex.Unlock()
+
+	d.Templates.mutex.Lock()
+	for id, template := range d.Templates.templates {
+		if template.UserId == user.Id {
+			delete(d.Templates.templates, id)
+		}
+	}
+	d.Templates.mutex.Unlock()
+
+	// Friend requests and friendships
+	d.FriendRequests.mutex.Lock()
+	for id, friendRequest := range d.FriendRequests.friendRequests {
+		if friendRequest.From == user.Id || friendRequest.To == user.Id {
+			delete(d.FriendRequests.friendRequests, id)
+		}
+	}
+	d.FriendRequests.mutex.Unlock()
+
+	d.Users.mutex.Lock()
+	defer d.Users.mutex.Unlock()
+	for _, other := range d.Users.users {
+		friendIds := make([]primitive.ObjectID, 0, len(other.FriendIds))
+		for _, friendId := range other.FriendIds {
+			if friendId != user.Id {
+				friendIds = append(friendIds, friendId)
+			}
+		}
+		other.FriendIds = friendIds
+	}
+	delete(d.Users.users, user.Id)
+
+	return deleted, nil
+}
+
+// Returns everything stored about the user the same way as db.GetUserData, without any active dates since daily
+// user logs aren't stored in memory
+func (r *AccountRepository) GetData(ctx context.Context, user *models.User) (*db.UserData, error) {
+	d := r.database
+	data := &db.UserData{
+		User:            user,
+		OwnedEvents:     make([]models.Event, 0),
+		Responses:       make([]models.EventResponse, 0),
+		SignUpResponses: make([]db.UserSignUpResponse, 0),
+		Reminders:       make([]db.UserReminder, 0),
+		Attendees:       make([]models.Attendee, 0),
+		Folders:         make([]models.Folder, 0),
+		EventTemplates:  make([]models.EventTemplate, 0),
+		FriendRequests:  make([]models.FriendRequest, 0),
+		FriendIds:       append(make([]primitive.ObjectID, 0), user.FriendIds...),
+		ActiveDates:     make([]primitive.DateTime, 0),
+		EventHistory:    make([]models.EventHistoryEntry, 0),
+	}
+
+	d.Events.mutex.Lock()
+	for _, event := range d.Events.events {
+		if event.OwnerId == user.Id {
+			data.OwnedEvents = append(data.OwnedEvents, *clone(event))
+			continue
+		}
+		if response, ok := event.SignUpResponses[user.Id.Hex()]; ok {
+			data.SignUpResponses = append(data.SignUpResponses, db.UserSignUpResponse{EventId: event.Id, Response: clone(response)})
+		}
+		for _, remindee := range utils.Coalesce(event.Remindees) {
+			if len(user.Email) > 0 && remindee.Email == user.Email {
+				data.Reminders = append(data.Reminders, db.UserReminder{EventId: event.Id, Remindee: remindee})
+			}
+		}
+	}
+	d.Events.mutex.Unlock()
+
+	d.Responses.mutex.Lock()
+	for _, eventResponse := range d.Responses.responses {
+		if isUserResponse(user, eventResponse) {
+			data.Responses = append(data.Responses, *clone(eventResponse))
+		}
+	}
+	d.Responses.mutex.Unlock()
+
+	if len(user.Email) > 0 {
+		d.Attendees.mutex.Lock()
+		for _, attendee := range d.Attendees.attendees {
+			if attendee.Email == user.Email {
+				data.Attendees = append(data.Attendees, *clone(attendee))
+			}
+		}
+		d.Attendees.mutex.Unlock()
+	}
+
+	d.Folders.mutex.Lock()
+	for _, folder := range d.Folders.folders {
+		if folder.UserId == user.Id {
+			folder := clone(folder)
+			folder.EventIds = d.Folders.getEventIds(folder.Id, user.Id)
+			data.Folders = append(data.Folders, *folder)
+		}
+	}
+	d.Folders.mutex.Unlock()
+
+	d.Templates.mutex.Lock()
+	for _, template := range d.Templates.templates {
+		if template.UserId == user.Id {
+			data.EventTemplates = append(data.EventTemplates, *clone(template))
+		}
+	}
+	d.Templates.mutex.Unlock()
+
+	d.FriendRequests.mutex.Lock()
+	for _, friendRequest := range d.FriendRequests.friendRequests {
+		if friendRequest.From == user.Id || friendRequest.To == user.Id {
+			data.FriendRequests = append(data.FriendRequests, *clone(friendRequest))
+		}
+	}
+	d.FriendRequests.mutex.Unlock()
+
+	d.EventHistory.mutex.Lock()
+	for _, entry := range d.EventHistory.entries {
+		if isAboutUser(user, entry) || (entry.ActorId != nil && *entry.ActorId == user.Id) {
+			data.EventHistory = append(data.EventHistory, *clone(entry))
+		}
+	}
+	d.EventHistory.mutex.Unlock()
+
+	return data, nil
+}
+
+// Returns whether the user left the response, either signed in or as a guest with their email
+func isUserResponse(user *models.User, eventResponse *models.EventResponse) bool {
+	return eventResponse.UserId == user.Id.Hex() ||
+		(len(user.Email) > 0 && eventResponse.Response != nil && eventResponse.Response.Email == user.Email)
+}
+
+// Returns whether the event history entry is about the user's response, sign up or attendee entry, matching the
+// same fields as db.DeleteUser
+func isAboutUser(user *models.User, entry *models.EventHistoryEntry) bool {
+	document, err := bson.Marshal(entry)
+	if err != nil {
+		panic(err)
+	}
+	matches := func(value string, path ...string) bool {
+		field, err := bson.Raw(document).LookupErr(path...)
+		if err != nil {
+			return false
+		}
+		fieldValue, ok := field.StringValueOK()
+		return ok && fieldValue == value
+	}
+
+	if matches(user.Id.Hex(), "before", "userId") {
+		return true
+	}
+	return len(user.Email) > 0 && (matches(user.Email, "before", "response", "email") ||
+		matches(user.Email, "before", "signUpResponse", "email") ||
+		matches(user.Email, "before", "email") ||
+		matches(user.Email, "after", "email"))
+}
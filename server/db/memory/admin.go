@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+type AdminRepository struct {
+	mutex   sync.Mutex
+	entries []*models.AdminAuditLogEntry
+
+	// Used to count what's stored
+	database *Database
+}
+
+func (r *AdminRepository) InsertAuditLogEntry(entry *models.AdminAuditLogEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry.Id = primitive.NewObjectID()
+	entry.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	r.entries = append(r.entries, clone(entry))
+	return nil
+}
+
+func (r *AdminRepository) GetAuditLog(targetUserId *primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.AdminAuditLogEntry, *primitive.ObjectID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Entries are inserted in order, so the most recent ones are at the end
+	entries := make([]models.AdminAuditLogEntry, 0)
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		if (targetUserId != nil && (entry.TargetUserId == nil || *entry.TargetUserId != *targetUserId)) ||
+			(cursor != nil && entry.Id.Hex() >= cursor.Hex()) {
+			continue
+		}
+		if len(entries) == limit {
+			return entries, &entries[limit-1].Id, nil
+		}
+		entries = append(entries, *clone(entry))
+	}
+	return entries, nil, nil
+}
+
+// Counts everything except active users, since daily user logs aren't stored in memory
+func (r *AdminRepository) GetInstanceStats(ctx context.Context) (*db.InstanceStats, error) {
+	monthAgo := time.Now().AddDate(0, 0, -30)
+	var stats db.InstanceStats
+
+	users := r.database.Users
+	users.mutex.Lock()
+	for _, user := range users.users {
+		stats.Users++
+		if user.StripeCustomerId != nil || utils.Coalesce(user.IsPremium) {
+			stats.PremiumUsers++
+		}
+		if user.Role == models.AdminUser {
+			stats.AdminUsers++
+		}
+	}
+	users.mutex.Unlock()
+
+	events := r.database.Events
+	events.mutex.Lock()
+	for _, event := range events.events {
+		if !event.Id.Timestamp().Before(monthAgo) {
+			stats.NewEvents++
+		}
+		if utils.Coalesce(event.IsDeleted) {
+			stats.DeletedEvents++
+			continue
+		}
+		stats.Events++
+		if event.Type == models.GROUP {
+			stats.Groups++
+		}
+		if utils.Coalesce(event.IsSignUpForm) {
+			stats.SignUpForms++
+		}
+	}
+	events.mutex.Unlock()
+
+	responses := r.database.Responses
+	responses.mutex.Lock()
+	stats.Responses = len(responses.responses)
+	responses.mutex.Unlock()
+
+	folders := r.database.Folders
+	folders.mutex.Lock()
+	for _, folder := range folders.folders {
+		if !utils.Coalesce(folder.IsDeleted) {
+			stats.Folders++
+		}
+	}
+	folders.mutex.Unlock()
+
+	templates := r.database.Templates
+	templates.mutex.Lock()
+	stats.EventTemplates = len(templates.templates)
+	templates.mutex.Unlock()
+
+	return &stats, nil
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func (r *EventRepository) Search(user *models.User, search db.EventSearch) (*db.EventSearchResult, error) {
+	// Get the event ids that the user has responded to or is an attendee of
+	responded := make(models.Set[primitive.ObjectID])
+	r.database.Responses.mutex.Lock()
+	for _, eventResponse := range r.database.Responses.responses {
+		if eventResponse.UserId == user.Id.Hex() {
+			responded[eventResponse.EventId] = struct{}{}
+		}
+	}
+	r.database.Responses.mutex.Unlock()
+
+	// Like the {declined: false} filter, attendees that never set whether they declined are left out
+	attending := make(models.Set[primitive.ObjectID])
+	r.database.Attendees.mutex.Lock()
+	for _, attendee := range r.database.Attendees.attendees {
+		if attendee.Email == user.Email && attendee.Declined != nil && !*attendee.Declined {
+			attending[attendee.EventId] = struct{}{}
+		}
+	}
+	r.database.Attendees.mutex.Unlock()
+
+	var eventIds models.Set[primitive.ObjectID]
+	if search.EventIds != nil {
+		eventIds = utils.ArrayToSet(*search.EventIds)
+	}
+
+	r.mutex.Lock()
+	matches := make([]models.Event, 0)
+	for _, event := range r.events {
+		if utils.Coalesce(event.IsDeleted) {
+			continue
+		}
+		if eventIds != nil {
+			if _, ok := eventIds[event.Id]; !ok {
+				continue
+			}
+		} else {
+			_, isRespondent := responded[event.Id]
+			_, isAttendee := attending[event.Id]
+			if !isRespondent && !isAttendee && event.OwnerId != user.Id {
+				continue
+			}
+		}
+		if matchesEventSearch(event, search) {
+			matches = append(matches, *clone(event))
+		}
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Id.Hex() > matches[j].Id.Hex() })
+
+	result := &db.EventSearchResult{Events: make([]models.Event, 0), Total: len(matches)}
+	for _, event := range matches {
+		if search.Cursor != nil && event.Id.Hex() >= search.Cursor.Hex() {
+			continue
+		}
+		if search.Limit > 0 && len(result.Events) == search.Limit {
+			result.NextCursor = &result.Events[search.Limit-1].Id
+			break
+		}
+		result.Events = append(result.Events, event)
+	}
+	if search.Limit == 0 {
+		result.Total = len(result.Events)
+	}
+
+	// Set the hasResponded field for availability groups, which is whether the user responded to a group
+	// they're an attendee of
+	for i, event := range result.Events {
+		if event.Type == models.GROUP {
+			_, isRespondent := responded[event.Id]
+			_, isAttendee := attending[event.Id]
+			hasResponded := isRespondent && isAttendee
+			result.Events[i].HasResponded = &hasResponded
+		}
+	}
+
+	return result, nil
+}
+
+// Returns whether the event matches the filters of the search other than which events to look through
+func matchesEventSearch(event *models.Event, search db.EventSearch) bool {
+	if len(search.Types) > 0 {
+		matchesType := false
+		for _, eventType := range search.Types {
+			if eventType == db.SignUpFormType {
+				matchesType = matchesType || utils.Coalesce(event.IsSignUpForm)
+			} else {
+				matchesType = matchesType || (event.Type == eventType && !utils.Coalesce(event.IsSignUpForm))
+			}
+		}
+		if !matchesType {
+			return false
+		}
+	}
+	if search.Archived != nil && utils.Coalesce(event.IsArchived) != *search.Archived {
+		return false
+	}
+	if search.Scheduled != nil && (event.ScheduledEvent != nil) != *search.Scheduled {
+		return false
+	}
+	if search.TimeMin != nil || search.TimeMax != nil {
+		inRange := false
+		for _, date := range event.Dates {
+			if (search.TimeMin == nil || !date.Time().Before(*search.TimeMin)) && (search.TimeMax == nil || date.Time().Before(*search.TimeMax)) {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return false
+		}
+	}
+	if len(search.Query) > 0 {
+		// Stands in for the text index, matching events that contain any of the words
+		text := strings.ToLower(strings.Join([]string{event.Name, utils.Coalesce(event.Description), utils.Coalesce(event.Location)}, " "))
+		matchesQuery := false
+		for _, word := range strings.Fields(strings.ToLower(search.Query)) {
+			if strings.Contains(text, word) {
+				matchesQuery = true
+				break
+			}
+		}
+		if !matchesQuery {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+type FriendRequestRepository struct {
+	mutex          sync.Mutex
+	friendRequests map[primitive.ObjectID]*models.FriendRequest
+
+	// Used to populate the other users of friend requests and to make users friends
+	users *UserRepository
+}
+
+func (r *FriendRequestRepository) Create(from primitive.ObjectID, to primitive.ObjectID) (*models.FriendRequest, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	friendRequest := &models.FriendRequest{
+		Id:        primitive.NewObjectID(),
+		From:      from,
+		To:        to,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	r.friendRequests[friendRequest.Id] = clone(friendRequest)
+	return friendRequest, nil
+}
+
+func (r *FriendRequestRepository) GetById(friendRequestId string) (*models.FriendRequest, error) {
+	objectId, err := primitive.ObjectIDFromHex(friendRequestId)
+	if err != nil {
+		return nil, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if friendRequest, ok := r.friendRequests[objectId]; ok {
+		return clone(friendRequest), nil
+	}
+	return nil, nil
+}
+
+func (r *FriendRequestRepository) GetBetween(userId primitive.ObjectID, otherUserId primitive.ObjectID) (*models.FriendRequest, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, friendRequest := range r.friendRequests {
+		if (friendRequest.From == userId && friendRequest.To == otherUserId) ||
+			(friendRequest.From == otherUserId && friendRequest.To == userId) {
+			return clone(friendRequest), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *FriendRequestRepository) GetForUser(userId primitive.ObjectID) ([]models.FriendRequest, []models.FriendRequest, error) {
+	r.mutex.Lock()
+	friendRequests := make([]models.FriendRequest, 0)
+	for _, friendRequest := range r.friendRequests {
+		if friendRequest.From == userId || friendRequest.To == userId {
+			friendRequests = append(friendRequests, *clone(friendRequest))
+		}
+	}
+	r.mutex.Unlock()
+	sort.Slice(friendRequests, func(i, j int) bool { return friendRequests[i].CreatedAt > friendRequests[j].CreatedAt })
+
+	incoming := make([]models.FriendRequest, 0)
+	outgoing := make([]models.FriendRequest, 0)
+	for _, friendRequest := range friendRequests {
+		if friendRequest.From == userId {
+			friendRequest.ToUser = r.getPublicUser(friendRequest.To)
+			outgoing = append(outgoing, friendRequest)
+		} else {
+			friendRequest.FromUser = r.getPublicUser(friendRequest.From)
+			incoming = append(incoming, friendRequest)
+		}
+	}
+	return incoming, outgoing, nil
+}
+
+// Returns the user with only the fields that other users can see, or nil if they don't exist
+func (r *FriendRequestRepository) getPublicUser(userId primitive.ObjectID) *models.User {
+	users, _ := r.users.GetPublic([]primitive.ObjectID{userId})
+	if len(users) == 0 {
+		return nil
+	}
+	return &users[0]
+}
+
+func (r *FriendRequestRepository) Accept(friendRequest *models.FriendRequest) error {
+	r.users.addFriends(friendRequest.From, friendRequest.To)
+	return r.Delete(friendRequest.Id)
+}
+
+func (r *FriendRequestRepository) Delete(friendRequestId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.friendRequests, friendRequestId)
+	return nil
+}
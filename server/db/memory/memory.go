@@ -0,0 +1,1128 @@
+/* In-memory implementations of the db repositories, used to test route handlers without a Mongo server */
+package memory
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// An in-memory database. Documents are copied on the way in and out, so callers can't modify what's stored
+// without going through a repository, just like with Mongo
+type Database struct {
+	Events    *EventRepository
+	Responses *ResponseRepository
+	Users     *UserRepository
+	Attendees *AttendeeRepository
+	Folders   *FolderRepository
+
+	EventHistory   *EventHistoryRepository
+	Templates      *TemplateRepository
+	FriendRequests *FriendRequestRepository
+	Admin          *AdminRepository
+	Accounts       *AccountRepository
+}
+
+func New() *Database {
+	database := &Database{
+		Events:    &EventRepository{events: make(map[primitive.ObjectID]*models.Event)},
+		Responses: &ResponseRepository{responses: make(map[primitive.ObjectID]*models.EventResponse)},
+		Users:     &UserRepository{users: make(map[primitive.ObjectID]*models.User)},
+		Attendees: &AttendeeRepository{attendees: make(map[primitive.ObjectID]*models.Attendee)},
+		Folders: &FolderRepository{
+			folders:      make(map[primitive.ObjectID]*models.Folder),
+			folderEvents: make(map[primitive.ObjectID]*models.FolderEvent),
+		},
+		EventHistory:   &EventHistoryRepository{},
+		Templates:      &TemplateRepository{templates: make(map[primitive.ObjectID]*models.EventTemplate)},
+		FriendRequests: &FriendRequestRepository{friendRequests: make(map[primitive.ObjectID]*models.FriendRequest)},
+		Admin:          &AdminRepository{},
+		Accounts:       &AccountRepository{},
+	}
+	database.Events.database = database
+	database.Folders.events = database.Events
+	database.EventHistory.users = database.Users
+	database.FriendRequests.users = database.Users
+	database.Admin.database = database
+	database.Accounts.database = database
+	return database
+}
+
+// Returns the repositories to pass to the route handlers
+func (d *Database) Repositories() *db.Repositories {
+	return &db.Repositories{
+		Events:    d.Events,
+		Responses: d.Responses,
+		Users:     d.Users,
+		Attendees: d.Attendees,
+		Folders:   d.Folders,
+
+		EventHistory:   d.EventHistory,
+		Templates:      d.Templates,
+		FriendRequests: d.FriendRequests,
+		Admin:          d.Admin,
+		Accounts:       d.Accounts,
+	}
+}
+
+type EventRepository struct {
+	mutex  sync.Mutex
+	events map[primitive.ObjectID]*models.Event
+
+	// Permanently deleting an event deletes everything that belongs to it
+	database *Database
+}
+
+// Adds the event, giving it an _id if it doesn't have one
+func (r *EventRepository) Insert(event *models.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if event.Id.IsZero() {
+		event.Id = primitive.NewObjectID()
+	}
+	r.events[event.Id] = clone(event)
+	return nil
+}
+
+func (r *EventRepository) GetById(eventId string) (*models.Event, error) {
+	objectId, err := primitive.ObjectIDFromHex(eventId)
+	if err != nil {
+		return nil, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[objectId]
+	if !ok || utils.Coalesce(event.IsDeleted) {
+		return nil, nil
+	}
+	return clone(event), nil
+}
+
+func (r *EventRepository) GetByEitherId(id string) (*models.Event, error) {
+	if len(id) > 10 {
+		return r.GetById(id)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, event := range r.events {
+		if event.ShortId != nil && *event.ShortId == id && !utils.Coalesce(event.IsDeleted) {
+			return clone(event), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *EventRepository) Update(event *models.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.events[event.Id]
+	if !ok {
+		return nil
+	}
+
+	// Fields that are omitted when encoding the event are left as they are, like with $set
+	updates, err := toM(event)
+	if err != nil {
+		return err
+	}
+	delete(updates, "signUpResponses")
+	delete(updates, "numResponses")
+	delete(updates, "numGuestResponses")
+	return set(existing, updates)
+}
+
+func (r *EventRepository) IncrementNumResponses(eventId primitive.ObjectID, delta int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		return 0, mongo.ErrNoDocuments
+	}
+	numResponses := utils.Coalesce(event.NumResponses) + delta
+	event.NumResponses = &numResponses
+	return numResponses, nil
+}
+
+func (r *EventRepository) ClaimGuestResponse(eventId primitive.ObjectID, maxGuestResponses int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		return false, nil
+	}
+	numGuestResponses := utils.Coalesce(event.NumGuestResponses)
+	if maxGuestResponses > 0 && numGuestResponses >= maxGuestResponses {
+		return false, nil
+	}
+	numGuestResponses++
+	event.NumGuestResponses = &numGuestResponses
+	return true, nil
+}
+
+func (r *EventRepository) ReleaseGuestResponse(eventId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok || utils.Coalesce(event.NumGuestResponses) <= 0 {
+		return nil
+	}
+	numGuestResponses := *event.NumGuestResponses - 1
+	event.NumGuestResponses = &numGuestResponses
+	return nil
+}
+
+func (r *EventRepository) ClaimSendEmailAfterXResponses(eventId primitive.ObjectID, sendEmailAfterXResponses int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok || event.SendEmailAfterXResponses == nil || *event.SendEmailAfterXResponses != sendEmailAfterXResponses {
+		return false, nil
+	}
+	claimed := -1
+	event.SendEmailAfterXResponses = &claimed
+	return true, nil
+}
+
+func (r *EventRepository) SetSignUpResponse(eventId primitive.ObjectID, userKey string, response *models.SignUpResponse, claimedBlocks []models.SignUpBlock, maxGuestResponses int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		return false, nil
+	}
+	for _, block := range claimedBlocks {
+		if block.Capacity != nil && utils.CountSignUps(event, block.Id, userKey) >= *block.Capacity {
+			return false, nil
+		}
+	}
+	if _, ok := event.SignUpResponses[userKey]; !ok && response.UserId.IsZero() && maxGuestResponses > 0 && utils.CountGuestSignUps(event) >= maxGuestResponses {
+		return false, nil
+	}
+
+	if event.SignUpResponses == nil {
+		event.SignUpResponses = make(map[string]*models.SignUpResponse)
+	}
+	event.SignUpResponses[userKey] = clone(response)
+	return true, nil
+}
+
+func (r *EventRepository) PromoteFromSignUpWaitlist(eventId primitive.ObjectID, userKey string, block *models.SignUpBlock) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		return false, nil
+	}
+	response, ok := event.SignUpResponses[userKey]
+	if !ok {
+		return false, nil
+	}
+	waitlist := make([]models.SignUpWaitlistEntry, 0)
+	for _, entry := range response.Waitlist {
+		if entry.SignUpBlockId != block.Id {
+			waitlist = append(waitlist, entry)
+		}
+	}
+	if len(waitlist) == len(response.Waitlist) {
+		return false, nil
+	}
+	if block.Capacity != nil && utils.CountSignUps(event, block.Id, userKey) >= *block.Capacity {
+		return false, nil
+	}
+
+	response = clone(response)
+	response.SignUpBlockIds = append(response.SignUpBlockIds, block.Id)
+	response.Waitlist = waitlist
+	event.SignUpResponses[userKey] = response
+	return true, nil
+}
+
+func (r *EventRepository) DeleteSignUpResponse(eventId primitive.ObjectID, userKey string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if event, ok := r.events[eventId]; ok {
+		delete(event.SignUpResponses, userKey)
+	}
+	return nil
+}
+
+// Returns a short id that no other event has, made of the end of the event's _id
+func (r *EventRepository) GenerateShortId(eventId primitive.ObjectID) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	hex := eventId.Hex()
+	for length := 5; length < len(hex); length++ {
+		shortId := hex[len(hex)-length:]
+		taken := false
+		for _, event := range r.events {
+			if event.ShortId != nil && *event.ShortId == shortId {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return shortId, nil
+		}
+	}
+	return hex, nil
+}
+
+func (r *EventRepository) SetFields(eventId primitive.ObjectID, updates bson.M) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		return nil
+	}
+	return set(event, updates)
+}
+
+func (r *EventRepository) UpdateOwned(eventId primitive.ObjectID, ownerId primitive.ObjectID, updates bson.M) (*models.Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok || event.OwnerId != ownerId {
+		return nil, mongo.ErrNoDocuments
+	}
+	before := clone(event)
+	if err := set(event, updates); err != nil {
+		return nil, err
+	}
+	return before, nil
+}
+
+func (r *EventRepository) AddRemindees(eventId primitive.ObjectID, remindees []models.Remindee) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok {
+		return nil
+	}
+	existing := make([]models.Remindee, 0)
+	if event.Remindees != nil {
+		existing = *event.Remindees
+	}
+	for _, remindee := range remindees {
+		existing = append(existing, *clone(&remindee))
+	}
+	event.Remindees = &existing
+	return nil
+}
+
+func (r *EventRepository) SetArchived(eventIds []primitive.ObjectID, ownerId primitive.ObjectID, archived bool) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	changed := 0
+	for _, eventId := range eventIds {
+		event, ok := r.events[eventId]
+		if !ok || event.OwnerId != ownerId || utils.Coalesce(event.IsDeleted) {
+			continue
+		}
+		if utils.Coalesce(event.IsArchived) != archived {
+			changed++
+		}
+		event.IsArchived = &archived
+	}
+	return changed, nil
+}
+
+func (r *EventRepository) CountCreatedThisMonth(userId primitive.ObjectID) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	count := 0
+	for _, event := range r.events {
+		if event.OwnerId == userId && !event.Id.Timestamp().Before(startOfMonth) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type ResponseRepository struct {
+	mutex     sync.Mutex
+	responses map[primitive.ObjectID]*models.EventResponse
+}
+
+func (r *ResponseRepository) GetByEventId(eventId primitive.ObjectID) ([]models.EventResponse, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	eventResponses := make([]models.EventResponse, 0)
+	for _, eventResponse := range r.responses {
+		if eventResponse.EventId == eventId {
+			eventResponses = append(eventResponses, *clone(eventResponse))
+		}
+	}
+	return eventResponses, nil
+}
+
+func (r *ResponseRepository) Insert(eventResponse *models.EventResponse) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Mirror the unique index on eventId and userId
+	for _, existing := range r.responses {
+		if existing.EventId == eventResponse.EventId && existing.UserId == eventResponse.UserId {
+			return mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "duplicate key"}}}
+		}
+	}
+
+	if eventResponse.Id.IsZero() {
+		eventResponse.Id = primitive.NewObjectID()
+	}
+	r.responses[eventResponse.Id] = clone(eventResponse)
+	return nil
+}
+
+func (r *ResponseRepository) InsertMany(eventResponses []models.EventResponse) error {
+	for i := range eventResponses {
+		if err := r.Insert(&eventResponses[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ResponseRepository) Upsert(eventId primitive.ObjectID, userId string, response *models.Response) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, eventResponse := range r.responses {
+		if eventResponse.EventId == eventId && eventResponse.UserId == userId {
+			eventResponse.Response = clone(response)
+			return false, nil
+		}
+	}
+
+	id := primitive.NewObjectID()
+	r.responses[id] = &models.EventResponse{Id: id, EventId: eventId, UserId: userId, Response: clone(response)}
+	return true, nil
+}
+
+func (r *ResponseRepository) Delete(eventResponseId primitive.ObjectID) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.responses[eventResponseId]
+	delete(r.responses, eventResponseId)
+	return ok, nil
+}
+
+func (r *ResponseRepository) RenameGuest(eventId primitive.ObjectID, oldName string, newName string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, eventResponse := range r.responses {
+		if eventResponse.EventId == eventId && eventResponse.UserId == oldName {
+			eventResponse.UserId = newName
+			if eventResponse.Response != nil {
+				eventResponse.Response.Name = newName
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Deletes the responses that belong to the events
+func (r *ResponseRepository) deleteForEvents(eventIds map[primitive.ObjectID]bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, eventResponse := range r.responses {
+		if eventIds[eventResponse.EventId] {
+			delete(r.responses, id)
+		}
+	}
+}
+
+type UserRepository struct {
+	mutex sync.Mutex
+	users map[primitive.ObjectID]*models.User
+}
+
+// Adds the user, giving them an _id if they don't have one
+func (r *UserRepository) Insert(user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if user.Id.IsZero() {
+		user.Id = primitive.NewObjectID()
+	}
+	r.users[user.Id] = clone(user)
+	return nil
+}
+
+func (r *UserRepository) GetById(userId string) (*models.User, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if user, ok := r.users[objectId]; ok {
+		return clone(user), nil
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return clone(user), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) GetByStripeCustomerId(stripeCustomerId string) (*models.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, user := range r.users {
+		if user.StripeCustomerId != nil && *user.StripeCustomerId == stripeCustomerId {
+			return clone(user), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) GetByIds(userIds []primitive.ObjectID) ([]models.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	users := make([]models.User, 0)
+	for _, userId := range userIds {
+		if user, ok := r.users[userId]; ok {
+			users = append(users, *clone(user))
+		}
+	}
+	return users, nil
+}
+
+func (r *UserRepository) GetPublic(userIds []primitive.ObjectID) ([]models.User, error) {
+	users, err := r.GetByIds(userIds)
+	if err != nil {
+		return nil, err
+	}
+	for i, user := range users {
+		users[i] = models.User{
+			Id:        user.Id,
+			Email:     user.Email,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Picture:   user.Picture,
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].FirstName != users[j].FirstName {
+			return users[i].FirstName < users[j].FirstName
+		}
+		return users[i].LastName < users[j].LastName
+	})
+	return users, nil
+}
+
+func (r *UserRepository) Search(query string, cursor *primitive.ObjectID, limit int) ([]models.User, *primitive.ObjectID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	query = strings.ToLower(query)
+	users := make([]models.User, 0)
+	for _, user := range r.users {
+		if cursor != nil && user.Id.Hex() >= cursor.Hex() {
+			continue
+		}
+		if strings.Contains(strings.ToLower(user.Email), query) ||
+			strings.Contains(strings.ToLower(user.FirstName), query) ||
+			strings.Contains(strings.ToLower(user.LastName), query) {
+			users = append(users, *clone(user))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id.Hex() > users[j].Id.Hex() })
+	if len(users) > limit {
+		return users[:limit], &users[limit-1].Id, nil
+	}
+	return users, nil, nil
+}
+
+func (r *UserRepository) Update(userId primitive.ObjectID, user *models.User) error {
+	// Like $set with a document, only the fields that aren't empty are set
+	updates, err := toM(user)
+	if err != nil {
+		return err
+	}
+	delete(updates, "_id")
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.users[userId]
+	if !ok {
+		return nil
+	}
+	return set(stored, updates)
+}
+
+func (r *UserRepository) SetFields(userId primitive.ObjectID, updates bson.M) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[userId]
+	if !ok {
+		return nil
+	}
+	return set(user, updates)
+}
+
+func (r *UserRepository) SetCalendarAccounts(userId primitive.ObjectID, accounts map[string]models.CalendarAccount) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[userId]
+	if !ok || len(accounts) == 0 {
+		return nil
+	}
+	if user.CalendarAccounts == nil {
+		user.CalendarAccounts = make(map[string]models.CalendarAccount)
+	}
+	for key, account := range accounts {
+		user.CalendarAccounts[key] = *clone(&account)
+	}
+	return nil
+}
+
+func (r *UserRepository) RemoveCalendarAccount(userId primitive.ObjectID, calendarAccountKey string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if user, ok := r.users[userId]; ok {
+		delete(user.CalendarAccounts, calendarAccountKey)
+	}
+	return nil
+}
+
+func (r *UserRepository) IncrementNumEventsCreated(userId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if user, ok := r.users[userId]; ok {
+		user.NumEventsCreated++
+	}
+	return nil
+}
+
+// Adds the users to each other's friends
+func (r *UserRepository) addFriends(userId primitive.ObjectID, otherUserId primitive.ObjectID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, ids := range [][2]primitive.ObjectID{{userId, otherUserId}, {otherUserId, userId}} {
+		user, ok := r.users[ids[0]]
+		if !ok || utils.Contains(user.FriendIds, ids[1]) {
+			continue
+		}
+		user.FriendIds = append(user.FriendIds, ids[1])
+	}
+}
+
+func (r *UserRepository) RemoveFriends(userId primitive.ObjectID, otherUserId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, ids := range [][2]primitive.ObjectID{{userId, otherUserId}, {otherUserId, userId}} {
+		user, ok := r.users[ids[0]]
+		if !ok {
+			continue
+		}
+		friendIds := make([]primitive.ObjectID, 0, len(user.FriendIds))
+		for _, friendId := range user.FriendIds {
+			if friendId != ids[1] {
+				friendIds = append(friendIds, friendId)
+			}
+		}
+		user.FriendIds = friendIds
+	}
+	return nil
+}
+
+func (r *UserRepository) SetRole(userId primitive.ObjectID, role models.UserRole) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if user, ok := r.users[userId]; ok {
+		user.Role = role
+	}
+	return nil
+}
+
+func (r *UserRepository) ForceCalendarReauth(user *models.User, calendarAccountKey string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.users[user.Id]
+	if !ok {
+		return 0, nil
+	}
+	// Like db.ForceCalendarReauth, only the accounts signed out of are written
+	numSignedOut := 0
+	for key, account := range user.CalendarAccounts {
+		if account.OAuth2CalendarAuth != nil && (len(calendarAccountKey) == 0 || key == calendarAccountKey) {
+			account.OAuth2CalendarAuth = &models.OAuth2CalendarAuth{Scope: account.OAuth2CalendarAuth.Scope}
+			if stored.CalendarAccounts == nil {
+				stored.CalendarAccounts = make(map[string]models.CalendarAccount)
+			}
+			stored.CalendarAccounts[key] = account
+			numSignedOut++
+		}
+	}
+	return numSignedOut, nil
+}
+
+type AttendeeRepository struct {
+	mutex     sync.Mutex
+	attendees map[primitive.ObjectID]*models.Attendee
+}
+
+// Adds the attendee, giving them an _id if they don't have one
+func (r *AttendeeRepository) Insert(attendee *models.Attendee) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if attendee.Id.IsZero() {
+		attendee.Id = primitive.NewObjectID()
+	}
+	r.attendees[attendee.Id] = clone(attendee)
+	return nil
+}
+
+func (r *AttendeeRepository) GetByEventId(eventId primitive.ObjectID) ([]models.Attendee, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attendees := make([]models.Attendee, 0)
+	for _, attendee := range r.attendees {
+		if attendee.EventId == eventId {
+			attendees = append(attendees, *clone(attendee))
+		}
+	}
+	return attendees, nil
+}
+
+func (r *AttendeeRepository) SetDeclined(eventId primitive.ObjectID, email string, declined bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Like UpdateOne, only the first matching attendee is updated
+	for _, attendee := range r.attendees {
+		if attendee.EventId == eventId && attendee.Email == email {
+			attendee.Declined = &declined
+			break
+		}
+	}
+	return nil
+}
+
+func (r *AttendeeRepository) GetByEmail(eventId primitive.ObjectID, email string) (*models.Attendee, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, attendee := range r.attendees {
+		if attendee.EventId == eventId && attendee.Email == email {
+			return clone(attendee), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *AttendeeRepository) InsertMany(attendees []models.Attendee) error {
+	for i := range attendees {
+		if err := r.Insert(&attendees[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *AttendeeRepository) SetRole(eventId primitive.ObjectID, email string, role models.ParticipantRole) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, attendee := range r.attendees {
+		if attendee.EventId == eventId && attendee.Email == email {
+			attendee.Role = role
+			break
+		}
+	}
+	return nil
+}
+
+func (r *AttendeeRepository) Delete(eventId primitive.ObjectID, email string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, attendee := range r.attendees {
+		if attendee.EventId == eventId && attendee.Email == email {
+			delete(r.attendees, id)
+			break
+		}
+	}
+	return nil
+}
+
+// Deletes the attendees of the events
+func (r *AttendeeRepository) deleteForEvents(eventIds map[primitive.ObjectID]bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, attendee := range r.attendees {
+		if eventIds[attendee.EventId] {
+			delete(r.attendees, id)
+		}
+	}
+}
+
+type FolderRepository struct {
+	mutex        sync.Mutex
+	folders      map[primitive.ObjectID]*models.Folder
+	folderEvents map[primitive.ObjectID]*models.FolderEvent
+
+	// Deleting a folder deletes the events in it
+	events *EventRepository
+}
+
+func (r *FolderRepository) Create(folder *models.Folder) (primitive.ObjectID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := clone(folder)
+	if stored.Id.IsZero() {
+		stored.Id = primitive.NewObjectID()
+	}
+	r.folders[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (r *FolderRepository) GetById(folderId primitive.ObjectID, userId primitive.ObjectID) (*models.Folder, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok || folder.UserId != userId || utils.Coalesce(folder.IsDeleted) {
+		return nil, mongo.ErrNoDocuments
+	}
+	return clone(folder), nil
+}
+
+func (r *FolderRepository) GetAll(userId primitive.ObjectID) ([]models.Folder, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folders := make([]models.Folder, 0)
+	for _, folder := range r.folders {
+		if folder.UserId != userId || utils.Coalesce(folder.IsDeleted) {
+			continue
+		}
+		folder := clone(folder)
+		folder.EventIds = r.getEventIds(folder.Id, userId)
+		folders = append(folders, *folder)
+	}
+	return folders, nil
+}
+
+func (r *FolderRepository) GetEventIds(folderId primitive.ObjectID, userId primitive.ObjectID) ([]primitive.ObjectID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.getEventIds(folderId, userId), nil
+}
+
+func (r *FolderRepository) getEventIds(folderId primitive.ObjectID, userId primitive.ObjectID) []primitive.ObjectID {
+	eventIds := make([]primitive.ObjectID, 0)
+	for _, folderEvent := range r.folderEvents {
+		if folderEvent.FolderId == folderId && folderEvent.UserId == userId {
+			eventIds = append(eventIds, folderEvent.EventId)
+		}
+	}
+	return eventIds
+}
+
+func (r *FolderRepository) Update(folderId primitive.ObjectID, userId primitive.ObjectID, updates bson.M) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok || folder.UserId != userId {
+		return nil
+	}
+	return set(folder, updates)
+}
+
+func (r *FolderRepository) SetEventFolder(eventId primitive.ObjectID, folderId *primitive.ObjectID, userId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, folderEvent := range r.folderEvents {
+		if folderEvent.EventId == eventId && folderEvent.UserId == userId {
+			delete(r.folderEvents, id)
+		}
+	}
+
+	if folderId != nil {
+		id := primitive.NewObjectID()
+		r.folderEvents[id] = &models.FolderEvent{Id: id, FolderId: *folderId, EventId: eventId, UserId: userId}
+	}
+	return nil
+}
+
+func (r *FolderRepository) Delete(folderId primitive.ObjectID, userId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok || folder.UserId != userId {
+		return nil
+	}
+
+	deletedAt := primitive.NewDateTimeFromTime(time.Now())
+	folderIds := []primitive.ObjectID{folderId}
+	for i := 0; i < len(folderIds); i++ {
+		for _, subfolder := range r.folders {
+			if subfolder.ParentId != nil && *subfolder.ParentId == folderIds[i] && !utils.Coalesce(subfolder.IsDeleted) {
+				folderIds = append(folderIds, subfolder.Id)
+			}
+		}
+	}
+
+	r.events.mutex.Lock()
+	for _, id := range folderIds {
+		id := id
+		r.folders[id].IsDeleted = utils.TruePtr()
+		r.folders[id].DeletedAt = &deletedAt
+		for _, eventId := range r.getEventIds(id, userId) {
+			if event, ok := r.events.events[eventId]; ok && event.OwnerId == userId && !utils.Coalesce(event.IsDeleted) {
+				event.IsDeleted = utils.TruePtr()
+				event.DeletedAt = &deletedAt
+				event.DeletedFromFolderId = &id
+			}
+		}
+	}
+	r.events.mutex.Unlock()
+
+	for mappingId, folderEvent := range r.folderEvents {
+		if folderEvent.UserId == userId && utils.Contains(folderIds, folderEvent.FolderId) {
+			delete(r.folderEvents, mappingId)
+		}
+	}
+	return nil
+}
+
+func (r *FolderRepository) Get(folderId primitive.ObjectID) (*models.Folder, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok || utils.Coalesce(folder.IsDeleted) {
+		return nil, mongo.ErrNoDocuments
+	}
+	return clone(folder), nil
+}
+
+func (r *FolderRepository) GetSubfolders(folderId primitive.ObjectID) ([]models.Folder, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folders := make([]models.Folder, 0)
+	for _, folder := range r.folders {
+		if folder.ParentId != nil && *folder.ParentId == folderId && !utils.Coalesce(folder.IsDeleted) {
+			folders = append(folders, *clone(folder))
+		}
+	}
+	return folders, nil
+}
+
+func (r *FolderRepository) GetShared(userId primitive.ObjectID) ([]models.Folder, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folders := make([]models.Folder, 0)
+	for _, folder := range r.folders {
+		if utils.Coalesce(folder.IsDeleted) {
+			continue
+		}
+		for _, share := range folder.SharedWith {
+			if share.UserId == userId {
+				folders = append(folders, *clone(folder))
+				break
+			}
+		}
+	}
+	return folders, nil
+}
+
+func (r *FolderRepository) Share(folderId primitive.ObjectID, share models.FolderShare) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok {
+		return nil
+	}
+	for i := range folder.SharedWith {
+		if folder.SharedWith[i].UserId == share.UserId {
+			folder.SharedWith[i].Role = share.Role
+			return nil
+		}
+	}
+	folder.SharedWith = append(folder.SharedWith, share)
+	return nil
+}
+
+func (r *FolderRepository) Unshare(folderId primitive.ObjectID, userId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok {
+		return nil
+	}
+	sharedWith := make([]models.FolderShare, 0, len(folder.SharedWith))
+	for _, share := range folder.SharedWith {
+		if share.UserId != userId {
+			sharedWith = append(sharedWith, share)
+		}
+	}
+	folder.SharedWith = sharedWith
+	return nil
+}
+
+type EventHistoryRepository struct {
+	mutex   sync.Mutex
+	entries []*models.EventHistoryEntry
+
+	// Used to populate the users that made the changes
+	users *UserRepository
+}
+
+func (r *EventHistoryRepository) Insert(entry *models.EventHistoryEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry.Id = primitive.NewObjectID()
+	entry.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	r.entries = append(r.entries, clone(entry))
+	return nil
+}
+
+func (r *EventHistoryRepository) GetByEventId(eventId primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.EventHistoryEntry, *primitive.ObjectID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Entries are inserted in order, so the most recent ones are at the end
+	entries := make([]models.EventHistoryEntry, 0)
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		if entry.EventId != eventId || (cursor != nil && entry.Id.Hex() >= cursor.Hex()) {
+			continue
+		}
+		if len(entries) == limit {
+			return entries, &entries[limit-1].Id, nil
+		}
+
+		copy := clone(entry)
+		if copy.ActorId != nil {
+			// The in-memory user repository never fails
+			copy.Actor, _ = r.users.GetById(copy.ActorId.Hex())
+		}
+		entries = append(entries, *copy)
+	}
+	return entries, nil, nil
+}
+
+var errUnsupportedUpdate = errors.New("updates must only set top level fields")
+
+// Returns a deep copy of the document, made by encoding and decoding it like a round trip through Mongo would
+func clone[T any](document *T) *T {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	var copy T
+	if err := bson.Unmarshal(data, &copy); err != nil {
+		panic(err)
+	}
+	return &copy
+}
+
+// Returns the fields of the document as they would be stored
+func toM(document interface{}) (bson.M, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	if err := bson.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Applies a $set of top level fields to the document
+func set[T any](document *T, updates bson.M) error {
+	fields, err := toM(document)
+	if err != nil {
+		return err
+	}
+	for key, value := range updates {
+		if len(key) == 0 || key[0] == '$' || strings.Contains(key, ".") {
+			return errUnsupportedUpdate
+		}
+		fields[key] = value
+	}
+
+	data, err := bson.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var updated T
+	if err := bson.Unmarshal(data, &updated); err != nil {
+		return err
+	}
+	*document = updated
+	return nil
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+type TemplateRepository struct {
+	mutex     sync.Mutex
+	templates map[primitive.ObjectID]*models.EventTemplate
+}
+
+func (r *TemplateRepository) Create(template *models.EventTemplate) (primitive.ObjectID, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored := clone(template)
+	if stored.Id.IsZero() {
+		stored.Id = primitive.NewObjectID()
+	}
+	r.templates[stored.Id] = stored
+	return stored.Id, nil
+}
+
+func (r *TemplateRepository) Get(templateId primitive.ObjectID, userId primitive.ObjectID) (*models.EventTemplate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	template, ok := r.templates[templateId]
+	if !ok || template.UserId != userId {
+		return nil, nil
+	}
+	return clone(template), nil
+}
+
+func (r *TemplateRepository) GetAll(userId primitive.ObjectID) ([]models.EventTemplate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	templates := make([]models.EventTemplate, 0)
+	for _, template := range r.templates {
+		if template.UserId == userId {
+			templates = append(templates, *clone(template))
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+func (r *TemplateRepository) Count(userId primitive.ObjectID) (int, error) {
+	templates, err := r.GetAll(userId)
+	return len(templates), err
+}
+
+func (r *TemplateRepository) Replace(template *models.EventTemplate) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.templates[template.Id]
+	if !ok || existing.UserId != template.UserId {
+		return false, nil
+	}
+	r.templates[template.Id] = clone(template)
+	return true, nil
+}
+
+func (r *TemplateRepository) Delete(templateId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	template, ok := r.templates[templateId]
+	if !ok || template.UserId != userId {
+		return false, nil
+	}
+	delete(r.templates, templateId)
+	return true, nil
+}
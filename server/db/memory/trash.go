@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func (r *EventRepository) GetDeleted(userId primitive.ObjectID) ([]models.Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := make([]models.Event, 0)
+	for _, event := range r.events {
+		if event.OwnerId == userId && utils.Coalesce(event.IsDeleted) {
+			events = append(events, *clone(event))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return deletedAfter(events[i].DeletedAt, events[i].Id, events[j].DeletedAt, events[j].Id)
+	})
+	return events, nil
+}
+
+func (r *EventRepository) Restore(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event, ok := r.events[eventId]
+	if !ok || event.OwnerId != userId || !utils.Coalesce(event.IsDeleted) {
+		return false, nil
+	}
+	event.IsDeleted = nil
+	event.DeletedAt = nil
+	event.DeletedFromFolderId = nil
+	return true, nil
+}
+
+func (r *EventRepository) Purge(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	purged := r.purge(func(event *models.Event) bool {
+		return event.Id == eventId && event.OwnerId == userId && utils.Coalesce(event.IsDeleted)
+	})
+	return purged > 0, nil
+}
+
+func (r *EventRepository) GetIncludingDeleted(id string) (*models.Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	for _, event := range r.events {
+		if (err == nil && event.Id == objectId) || (err != nil && event.ShortId != nil && *event.ShortId == id) {
+			return clone(event), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *EventRepository) TransferOwnership(event *models.Event, newOwnerId primitive.ObjectID) error {
+	r.mutex.Lock()
+	if stored, ok := r.events[event.Id]; ok {
+		stored.OwnerId = newOwnerId
+	}
+	r.mutex.Unlock()
+
+	if event.OwnerId != primitive.NilObjectID {
+		r.database.Folders.deleteMappings(func(folderEvent *models.FolderEvent) bool {
+			return folderEvent.EventId == event.Id && folderEvent.UserId == event.OwnerId
+		})
+	}
+	return nil
+}
+
+func (r *EventRepository) ClaimUnowned(eventId primitive.ObjectID, ownerId primitive.ObjectID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if event, ok := r.events[eventId]; ok && event.OwnerId.IsZero() {
+		event.OwnerId = ownerId
+	}
+	return nil
+}
+
+func (r *EventRepository) HardDelete(eventId primitive.ObjectID) (bool, error) {
+	purged := r.purge(func(event *models.Event) bool {
+		return event.Id == eventId
+	})
+	return purged > 0, nil
+}
+
+// Deletes the events that match along with everything that belongs to them. Returns the number of events deleted
+func (r *EventRepository) purge(matches func(event *models.Event) bool) int {
+	r.mutex.Lock()
+	eventIds := make(map[primitive.ObjectID]bool)
+	for id, event := range r.events {
+		if matches(event) {
+			eventIds[id] = true
+		}
+	}
+	r.mutex.Unlock()
+	if len(eventIds) == 0 {
+		return 0
+	}
+
+	// Each repository is locked on its own so that this can't deadlock with the folder repository
+	r.database.Responses.deleteForEvents(eventIds)
+	r.database.Attendees.deleteForEvents(eventIds)
+	r.database.Folders.deleteMappings(func(folderEvent *models.FolderEvent) bool {
+		return eventIds[folderEvent.EventId]
+	})
+	r.database.EventHistory.deleteForEvents(eventIds)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for id := range eventIds {
+		delete(r.events, id)
+	}
+	return len(eventIds)
+}
+
+func (r *FolderRepository) GetDeleted(userId primitive.ObjectID) ([]models.Folder, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events.mutex.Lock()
+	defer r.events.mutex.Unlock()
+
+	folders := make([]models.Folder, 0)
+	for _, folder := range r.folders {
+		if folder.UserId != userId || !utils.Coalesce(folder.IsDeleted) {
+			continue
+		}
+		folder := clone(folder)
+		folder.EventIds = make([]primitive.ObjectID, 0)
+		for _, event := range r.events.events {
+			if event.OwnerId == userId && utils.Coalesce(event.IsDeleted) && event.DeletedFromFolderId != nil && *event.DeletedFromFolderId == folder.Id {
+				folder.EventIds = append(folder.EventIds, event.Id)
+			}
+		}
+		folders = append(folders, *folder)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return deletedAfter(folders[i].DeletedAt, folders[i].Id, folders[j].DeletedAt, folders[j].Id)
+	})
+	return folders, nil
+}
+
+func (r *FolderRepository) Restore(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	folder, ok := r.folders[folderId]
+	if !ok || folder.UserId != userId || !utils.Coalesce(folder.IsDeleted) {
+		return false, nil
+	}
+	deletedAt := folder.DeletedAt
+	folder.IsDeleted = nil
+	folder.DeletedAt = nil
+
+	// If the folder it was in is gone, it's moved to the top level
+	if folder.ParentId != nil {
+		if parent, ok := r.folders[*folder.ParentId]; !ok || utils.Coalesce(parent.IsDeleted) {
+			folder.ParentId = nil
+		}
+	}
+	if deletedAt == nil {
+		return true, nil
+	}
+
+	folderIds := append(r.deletedSubfolderIds(folderId, *deletedAt), folderId)
+	for _, id := range folderIds {
+		r.folders[id].IsDeleted = nil
+		r.folders[id].DeletedAt = nil
+	}
+
+	// Put the events back into the folders they were in
+	r.events.mutex.Lock()
+	defer r.events.mutex.Unlock()
+	for _, event := range r.events.events {
+		if r.deletedFromFolders(event, folderIds, userId, *deletedAt) {
+			id := primitive.NewObjectID()
+			r.folderEvents[id] = &models.FolderEvent{Id: id, UserId: userId, FolderId: *event.DeletedFromFolderId, EventId: event.Id}
+			event.IsDeleted = nil
+			event.DeletedAt = nil
+			event.DeletedFromFolderId = nil
+		}
+	}
+	return true, nil
+}
+
+func (r *FolderRepository) Purge(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	r.mutex.Lock()
+	folder, ok := r.folders[folderId]
+	if !ok || folder.UserId != userId || !utils.Coalesce(folder.IsDeleted) {
+		r.mutex.Unlock()
+		return false, nil
+	}
+	deletedAt := folder.DeletedAt
+	folderIds := []primitive.ObjectID{folderId}
+	if deletedAt != nil {
+		folderIds = append(folderIds, r.deletedSubfolderIds(folderId, *deletedAt)...)
+	}
+	r.mutex.Unlock()
+
+	if deletedAt != nil {
+		r.events.purge(func(event *models.Event) bool {
+			return r.deletedFromFolders(event, folderIds, userId, *deletedAt)
+		})
+	}
+
+	r.deleteMappings(func(folderEvent *models.FolderEvent) bool {
+		return utils.Contains(folderIds, folderEvent.FolderId)
+	})
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, id := range folderIds {
+		delete(r.folders, id)
+	}
+	return true, nil
+}
+
+// Returns the _ids of the folders inside the folder, at any depth, that were deleted at the same time as it
+func (r *FolderRepository) deletedSubfolderIds(folderId primitive.ObjectID, deletedAt primitive.DateTime) []primitive.ObjectID {
+	subfolderIds := make([]primitive.ObjectID, 0)
+	parentIds := []primitive.ObjectID{folderId}
+	for i := 0; i < len(parentIds); i++ {
+		for _, subfolder := range r.folders {
+			if subfolder.ParentId != nil && *subfolder.ParentId == parentIds[i] &&
+				utils.Coalesce(subfolder.IsDeleted) && subfolder.DeletedAt != nil && *subfolder.DeletedAt == deletedAt {
+				subfolderIds = append(subfolderIds, subfolder.Id)
+				parentIds = append(parentIds, subfolder.Id)
+			}
+		}
+	}
+	return subfolderIds
+}
+
+// Returns whether the user's event was deleted at `deletedAt` along with one of the folders
+func (r *FolderRepository) deletedFromFolders(event *models.Event, folderIds []primitive.ObjectID, userId primitive.ObjectID, deletedAt primitive.DateTime) bool {
+	return event.OwnerId == userId && utils.Coalesce(event.IsDeleted) && event.DeletedAt != nil && *event.DeletedAt == deletedAt &&
+		event.DeletedFromFolderId != nil && utils.Contains(folderIds, *event.DeletedFromFolderId)
+}
+
+// Deletes the mappings of events to folders that match
+func (r *FolderRepository) deleteMappings(matches func(folderEvent *models.FolderEvent) bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, folderEvent := range r.folderEvents {
+		if matches(folderEvent) {
+			delete(r.folderEvents, id)
+		}
+	}
+}
+
+// Deletes the history of the events
+func (r *EventHistoryRepository) deleteForEvents(eventIds map[primitive.ObjectID]bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entries := make([]*models.EventHistoryEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		if !eventIds[entry.EventId] {
+			entries = append(entries, entry)
+		}
+	}
+	r.entries = entries
+}
+
+// Returns whether the first item was deleted after the second, breaking ties by _id like the Mongo sort
+func deletedAfter(deletedAt *primitive.DateTime, id primitive.ObjectID, otherDeletedAt *primitive.DateTime, otherId primitive.ObjectID) bool {
+	time, otherTime := utils.Coalesce(deletedAt), utils.Coalesce(otherDeletedAt)
+	if time != otherTime {
+		return time > otherTime
+	}
+	return id.Hex() > otherId.Hex()
+}
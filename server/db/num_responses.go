@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// An event whose stored number of responses doesn't match its actual number of responses
+type NumResponsesDrift struct {
+	EventId primitive.ObjectID
+	Stored  *int
+	Actual  int
+}
+
+/*
+Recomputes the number of responses of every event from the eventResponses collection and fixes the events
+where it has drifted. If `dryRun` is true, only returns the events that would be fixed.
+
+An event is only fixed if its stored number hasn't changed since it was read, so responses submitted while
+the repair is running aren't lost
+*/
+func RepairNumResponses(ctx context.Context, dryRun bool) ([]NumResponsesDrift, error) {
+	// Count the responses of each event
+	cursor, err := EventResponsesCollection.Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{"_id": "$eventId", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		EventId primitive.ObjectID `bson:"_id"`
+		Count   int                `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	actual := make(map[primitive.ObjectID]int, len(counts))
+	for _, count := range counts {
+		actual[count.EventId] = count.Count
+	}
+
+	// Compare them to the stored counts
+	cursor, err = EventsCollection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"numResponses": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	drifts := make([]NumResponsesDrift, 0)
+	for cursor.Next(ctx) {
+		var event struct {
+			Id           primitive.ObjectID `bson:"_id"`
+			NumResponses *int               `bson:"numResponses"`
+		}
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		if event.NumResponses == nil || *event.NumResponses != actual[event.Id] {
+			drifts = append(drifts, NumResponsesDrift{EventId: event.Id, Stored: event.NumResponses, Actual: actual[event.Id]})
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return drifts, nil
+	}
+
+	// Fix the drifted events in batches
+	batchSize := 1000
+	for start := 0; start < len(drifts); start += batchSize {
+		end := start + batchSize
+		if end > len(drifts) {
+			end = len(drifts)
+		}
+
+		updates := make([]mongo.WriteModel, 0, end-start)
+		for _, drift := range drifts[start:end] {
+			filter := bson.M{"_id": drift.EventId, "numResponses": bson.M{"$exists": false}}
+			if drift.Stored != nil {
+				filter["numResponses"] = *drift.Stored
+			}
+			updates = append(updates, mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(bson.M{"$set": bson.M{"numResponses": drift.Actual}}))
+		}
+		if _, err := EventsCollection.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false)); err != nil {
+			return nil, err
+		}
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,746 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/models"
+)
+
+// Repositories wrap the collections so that route handlers can be run against an in-memory database in tests.
+// See db/memory for the in-memory implementation
+type Repositories struct {
+	Events    EventRepository
+	Responses ResponseRepository
+	Users     UserRepository
+	Attendees AttendeeRepository
+	Folders   FolderRepository
+
+	EventHistory   EventHistoryRepository
+	Templates      TemplateRepository
+	FriendRequests FriendRequestRepository
+	Admin          AdminRepository
+	Accounts       AccountRepository
+}
+
+type EventRepository interface {
+	// Returns the event with the given _id, or nil if it doesn't exist or was deleted
+	GetById(eventId string) (*models.Event, error)
+	// Returns the event with the given _id or shortId, or nil if it doesn't exist or was deleted
+	GetByEitherId(id string) (*models.Event, error)
+	Insert(event *models.Event) error
+	// Returns a short id for the event that no other event has
+	GenerateShortId(eventId primitive.ObjectID) (string, error)
+	// Updates every field of the event except its sign up responses and number of responses
+	Update(event *models.Event) error
+	// Sets the given top level fields of the event
+	SetFields(eventId primitive.ObjectID, updates bson.M) error
+	// Sets the given top level fields of the event if the user owns it. Returns the event as it was before the
+	// update, or mongo.ErrNoDocuments if the user doesn't own an event with the given _id
+	UpdateOwned(eventId primitive.ObjectID, ownerId primitive.ObjectID, updates bson.M) (*models.Event, error)
+	// Adds the remindees to the event's remindees
+	AddRemindees(eventId primitive.ObjectID, remindees []models.Remindee) error
+	// Archives or unarchives the events the user owns out of the given ones, skipping deleted events. Returns the
+	// number of events that were changed
+	SetArchived(eventIds []primitive.ObjectID, ownerId primitive.ObjectID, archived bool) (int, error)
+	// Adds `delta` to the number of responses of the event and returns the new number of responses
+	IncrementNumResponses(eventId primitive.ObjectID, delta int) (int, error)
+	// Adds one to the number of guest responses of the event if it has fewer than `maxGuestResponses`, or if that's 0.
+	// Returns whether the guest response was counted
+	ClaimGuestResponse(eventId primitive.ObjectID, maxGuestResponses int) (bool, error)
+	// Subtracts one from the number of guest responses of the event
+	ReleaseGuestResponse(eventId primitive.ObjectID) error
+	// Sets sendEmailAfterXResponses to -1 if it's still `sendEmailAfterXResponses`, so that the owner is only emailed
+	// once. Returns whether it was set
+	ClaimSendEmailAfterXResponses(eventId primitive.ObjectID, sendEmailAfterXResponses int) (bool, error)
+	// Sets the user's sign up response if every claimed block still has room, and if the user is a new guest, fewer
+	// than `maxGuestResponses` guests have signed up. Returns whether the response was written
+	SetSignUpResponse(eventId primitive.ObjectID, userKey string, response *models.SignUpResponse, claimedBlocks []models.SignUpBlock, maxGuestResponses int) (bool, error)
+	// Moves the block from the user's waitlist to their sign ups if they're still on its waitlist and it still has
+	// room. Returns whether the user was promoted
+	PromoteFromSignUpWaitlist(eventId primitive.ObjectID, userKey string, block *models.SignUpBlock) (bool, error)
+	// Removes the user's sign up response
+	DeleteSignUpResponse(eventId primitive.ObjectID, userKey string) error
+	// Returns the number of events the user created this month
+	CountCreatedThisMonth(userId primitive.ObjectID) (int, error)
+	// Returns a page of the events the user owns, responded to, or was added to as an attendee that match the
+	// filters, most recently created first
+	Search(user *models.User, search EventSearch) (*EventSearchResult, error)
+
+	// Returns the events the user deleted, most recently deleted first
+	GetDeleted(userId primitive.ObjectID) ([]models.Event, error)
+	// Takes the user's event out of the trash. Returns whether the event was in the trash
+	Restore(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+	// Permanently deletes the user's event if it's in the trash, along with everything that belongs to it.
+	// Returns whether the event was in the trash
+	Purge(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+	// Returns the event with the given _id or shortId even if it's in the trash, or nil if it doesn't exist
+	GetIncludingDeleted(id string) (*models.Event, error)
+	// Gives the event to another user, taking it out of the previous owner's folders
+	TransferOwnership(event *models.Event, newOwnerId primitive.ObjectID) error
+	// Makes the user the owner of the event if it doesn't have one, which is the case for events created while
+	// signed out
+	ClaimUnowned(eventId primitive.ObjectID, ownerId primitive.ObjectID) error
+	// Permanently deletes the event whether or not it's in the trash, along with everything that belongs to it.
+	// Returns whether the event existed
+	HardDelete(eventId primitive.ObjectID) (bool, error)
+}
+
+type ResponseRepository interface {
+	// Returns every response to the event
+	GetByEventId(eventId primitive.ObjectID) ([]models.EventResponse, error)
+	Insert(eventResponse *models.EventResponse) error
+	// Inserts the responses all at once
+	InsertMany(eventResponses []models.EventResponse) error
+	// Sets the response of the user with the key `userId`, creating it if it doesn't exist. Returns whether it was created
+	Upsert(eventId primitive.ObjectID, userId string, response *models.Response) (bool, error)
+	// Renames the guest response with the key `oldName`. Returns whether it existed
+	RenameGuest(eventId primitive.ObjectID, oldName string, newName string) (bool, error)
+	// Deletes the event response with the given _id. Returns whether it existed
+	Delete(eventResponseId primitive.ObjectID) (bool, error)
+}
+
+type UserRepository interface {
+	// Returns the user with the given _id, or nil if they don't exist
+	GetById(userId string) (*models.User, error)
+	// Returns the user with the given email, or nil if they don't exist
+	GetByEmail(email string) (*models.User, error)
+	// Returns the user with the given Stripe customer id, or nil if there isn't one
+	GetByStripeCustomerId(stripeCustomerId string) (*models.User, error)
+	// Returns the users with the given _ids that exist
+	GetByIds(userIds []primitive.ObjectID) ([]models.User, error)
+	// Returns the users with the given _ids, with only the fields that other users can see, sorted by name
+	GetPublic(userIds []primitive.ObjectID) ([]models.User, error)
+	// Returns a page of users whose name or email contains the query, most recently signed up first, along with
+	// the cursor of the next page, which is nil on the last page
+	Search(query string, cursor *primitive.ObjectID, limit int) ([]models.User, *primitive.ObjectID, error)
+	// Adds the user, giving them an _id if they don't have one
+	Insert(user *models.User) error
+	// Sets the fields of the user that aren't empty in `user`
+	Update(userId primitive.ObjectID, user *models.User) error
+	// Sets the given top level fields of the user
+	SetFields(userId primitive.ObjectID, updates bson.M) error
+	// Sets the user's calendar accounts with the given keys, leaving their other calendar accounts as they are
+	SetCalendarAccounts(userId primitive.ObjectID, accounts map[string]models.CalendarAccount) error
+	// Removes the user's calendar account with the given key
+	RemoveCalendarAccount(userId primitive.ObjectID, calendarAccountKey string) error
+	// Adds one to the number of events the user created
+	IncrementNumEventsCreated(userId primitive.ObjectID) error
+	// Removes the users from each other's friends
+	RemoveFriends(userId primitive.ObjectID, otherUserId primitive.ObjectID) error
+	SetRole(userId primitive.ObjectID, role models.UserRole) error
+	// Signs the user out of their OAuth calendar accounts, or only the one with the given key if it isn't empty.
+	// Returns the number of accounts that were signed out
+	ForceCalendarReauth(user *models.User, calendarAccountKey string) (int, error)
+}
+
+type AttendeeRepository interface {
+	// Returns every attendee of the event
+	GetByEventId(eventId primitive.ObjectID) ([]models.Attendee, error)
+	// Returns the attendee of the event with the given email, or nil if there isn't one
+	GetByEmail(eventId primitive.ObjectID, email string) (*models.Attendee, error)
+	Insert(attendee *models.Attendee) error
+	// Inserts the attendees all at once
+	InsertMany(attendees []models.Attendee) error
+	// Sets whether the attendee with the given email declined the event
+	SetDeclined(eventId primitive.ObjectID, email string, declined bool) error
+	// Sets whether the attendee with the given email is required or optional
+	SetRole(eventId primitive.ObjectID, email string, role models.ParticipantRole) error
+	// Removes the attendee with the given email from the event
+	Delete(eventId primitive.ObjectID, email string) error
+}
+
+type FolderRepository interface {
+	Create(folder *models.Folder) (primitive.ObjectID, error)
+	// Returns the user's folder with the given _id, or an error if it doesn't exist or was deleted
+	GetById(folderId primitive.ObjectID, userId primitive.ObjectID) (*models.Folder, error)
+	// Returns all of the user's folders along with the ids of the events in them
+	GetAll(userId primitive.ObjectID) ([]models.Folder, error)
+	// Returns the ids of the events the user put in the folder
+	GetEventIds(folderId primitive.ObjectID, userId primitive.ObjectID) ([]primitive.ObjectID, error)
+	Update(folderId primitive.ObjectID, userId primitive.ObjectID, updates bson.M) error
+	// Moves the event into the folder, or out of every folder if `folderId` is nil
+	SetEventFolder(eventId primitive.ObjectID, folderId *primitive.ObjectID, userId primitive.ObjectID) error
+	// Deletes the folder along with its subfolders and the events in them that the user owns
+	Delete(folderId primitive.ObjectID, userId primitive.ObjectID) error
+	// Returns the folder with the given _id whoever owns it, or an error if it doesn't exist or was deleted
+	Get(folderId primitive.ObjectID) (*models.Folder, error)
+	// Returns the folders directly inside the folder
+	GetSubfolders(folderId primitive.ObjectID) ([]models.Folder, error)
+	// Returns the folders other users shared with the user, without the folders inside them
+	GetShared(userId primitive.ObjectID) ([]models.Folder, error)
+	// Shares the folder with a user, replacing their role if the folder was already shared with them
+	Share(folderId primitive.ObjectID, share models.FolderShare) error
+	// Stops sharing the folder with the user
+	Unshare(folderId primitive.ObjectID, userId primitive.ObjectID) error
+
+	// Returns the folders the user deleted, most recently deleted first
+	GetDeleted(userId primitive.ObjectID) ([]models.Folder, error)
+	// Takes the user's folder out of the trash, along with the subfolders and events that were deleted with it.
+	// Returns whether the folder was in the trash
+	Restore(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+	// Permanently deletes the user's folder if it's in the trash, along with the subfolders and events that were
+	// deleted with it. Returns whether the folder was in the trash
+	Purge(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+}
+
+type EventHistoryRepository interface {
+	// Records the change, setting its _id and createdAt
+	Insert(entry *models.EventHistoryEntry) error
+	// Returns a page of the event's history, most recent first, with the users that made the changes populated,
+	// along with the cursor of the next page, which is nil on the last page
+	GetByEventId(eventId primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.EventHistoryEntry, *primitive.ObjectID, error)
+}
+
+type TemplateRepository interface {
+	Create(template *models.EventTemplate) (primitive.ObjectID, error)
+	// Returns the user's template with the given _id, or nil if it doesn't exist
+	Get(templateId primitive.ObjectID, userId primitive.ObjectID) (*models.EventTemplate, error)
+	// Returns the user's templates sorted by name
+	GetAll(userId primitive.ObjectID) ([]models.EventTemplate, error)
+	// Returns the number of templates the user has
+	Count(userId primitive.ObjectID) (int, error)
+	// Replaces the user's template. Returns whether it existed
+	Replace(template *models.EventTemplate) (bool, error)
+	// Deletes the user's template. Returns whether it existed
+	Delete(templateId primitive.ObjectID, userId primitive.ObjectID) (bool, error)
+}
+
+type FriendRequestRepository interface {
+	// Creates a friend request from one user to another
+	Create(from primitive.ObjectID, to primitive.ObjectID) (*models.FriendRequest, error)
+	// Returns the friend request with the given _id, or nil if it doesn't exist
+	GetById(friendRequestId string) (*models.FriendRequest, error)
+	// Returns the friend request that either user sent the other, or nil if there isn't one
+	GetBetween(userId primitive.ObjectID, otherUserId primitive.ObjectID) (*models.FriendRequest, error)
+	// Returns the friend requests sent to and sent by the user, newest first, with the other user populated
+	GetForUser(userId primitive.ObjectID) ([]models.FriendRequest, []models.FriendRequest, error)
+	// Makes the users that the friend request is between friends and deletes the request
+	Accept(friendRequest *models.FriendRequest) error
+	Delete(friendRequestId primitive.ObjectID) error
+}
+
+type AdminRepository interface {
+	// Records the admin action, setting its _id and createdAt
+	InsertAuditLogEntry(entry *models.AdminAuditLogEntry) error
+	// Returns a page of audit log entries, most recent first, only about the user if `targetUserId` isn't nil,
+	// along with the cursor of the next page, which is nil on the last page
+	GetAuditLog(targetUserId *primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.AdminAuditLogEntry, *primitive.ObjectID, error)
+	// Returns counts of what's stored on this instance
+	GetInstanceStats(ctx context.Context) (*InstanceStats, error)
+}
+
+type AccountRepository interface {
+	// Deletes the user along with everything stored about them, giving their events to `transferTo` if it isn't
+	// nil. See DeleteUser
+	Delete(ctx context.Context, user *models.User, transferTo *primitive.ObjectID) (*DeletedUser, error)
+	// Returns everything stored about the user
+	GetData(ctx context.Context, user *models.User) (*UserData, error)
+}
+
+// Returns repositories backed by the Mongo collections. Init must be called before they're used
+func NewMongoRepositories() *Repositories {
+	return &Repositories{
+		Events:    mongoEventRepository{},
+		Responses: mongoResponseRepository{},
+		Users:     mongoUserRepository{},
+		Attendees: mongoAttendeeRepository{},
+		Folders:   mongoFolderRepository{},
+
+		EventHistory:   mongoEventHistoryRepository{},
+		Templates:      mongoTemplateRepository{},
+		FriendRequests: mongoFriendRequestRepository{},
+		Admin:          mongoAdminRepository{},
+		Accounts:       mongoAccountRepository{},
+	}
+}
+
+type repositoriesKey struct{}
+
+// Returns a copy of the context that carries the repositories
+func WithRepositories(ctx context.Context, repositories *Repositories) context.Context {
+	return context.WithValue(ctx, repositoriesKey{}, repositories)
+}
+
+// Returns the repositories the context carries, or nil if it doesn't carry any
+func RepositoriesFrom(ctx context.Context) *Repositories {
+	repositories, _ := ctx.Value(repositoriesKey{}).(*Repositories)
+	return repositories
+}
+
+type mongoEventRepository struct{}
+
+func (mongoEventRepository) GetById(eventId string) (*models.Event, error) {
+	return GetEventById(eventId)
+}
+
+func (mongoEventRepository) GetByEitherId(id string) (*models.Event, error) {
+	return GetEventByEitherId(id)
+}
+
+func (mongoEventRepository) Insert(event *models.Event) error {
+	result, err := EventsCollection.InsertOne(context.Background(), event)
+	if err != nil {
+		return err
+	}
+	event.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoEventRepository) GenerateShortId(eventId primitive.ObjectID) (string, error) {
+	return GenerateShortEventId(eventId)
+}
+
+func (mongoEventRepository) Update(event *models.Event) error {
+	return UpdateEvent(event)
+}
+
+func (mongoEventRepository) SetFields(eventId primitive.ObjectID, updates bson.M) error {
+	_, err := EventsCollection.UpdateByID(context.Background(), eventId, bson.M{"$set": updates})
+	return err
+}
+
+func (mongoEventRepository) UpdateOwned(eventId primitive.ObjectID, ownerId primitive.ObjectID, updates bson.M) (*models.Event, error) {
+	var event models.Event
+	err := EventsCollection.FindOneAndUpdate(context.Background(), bson.M{
+		"_id":     eventId,
+		"ownerId": ownerId,
+	}, bson.M{
+		"$set": updates,
+	}).Decode(&event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (mongoEventRepository) AddRemindees(eventId primitive.ObjectID, remindees []models.Remindee) error {
+	_, err := EventsCollection.UpdateByID(context.Background(), eventId, bson.M{
+		"$push": bson.M{"remindees": bson.M{"$each": remindees}},
+	})
+	return err
+}
+
+func (mongoEventRepository) SetArchived(eventIds []primitive.ObjectID, ownerId primitive.ObjectID, archived bool) (int, error) {
+	return SetEventsArchived(eventIds, ownerId, archived)
+}
+
+func (mongoEventRepository) IncrementNumResponses(eventId primitive.ObjectID, delta int) (int, error) {
+	return IncrementNumResponses(eventId, delta)
+}
+
+func (mongoEventRepository) ClaimGuestResponse(eventId primitive.ObjectID, maxGuestResponses int) (bool, error) {
+	return ClaimGuestResponse(eventId, maxGuestResponses)
+}
+
+func (mongoEventRepository) ReleaseGuestResponse(eventId primitive.ObjectID) error {
+	return ReleaseGuestResponse(eventId)
+}
+
+func (mongoEventRepository) ClaimSendEmailAfterXResponses(eventId primitive.ObjectID, sendEmailAfterXResponses int) (bool, error) {
+	result, err := EventsCollection.UpdateOne(context.Background(), bson.M{
+		"_id":                      eventId,
+		"sendEmailAfterXResponses": sendEmailAfterXResponses,
+	}, bson.M{
+		"$set": bson.M{"sendEmailAfterXResponses": -1},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (mongoEventRepository) SetSignUpResponse(eventId primitive.ObjectID, userKey string, response *models.SignUpResponse, claimedBlocks []models.SignUpBlock, maxGuestResponses int) (bool, error) {
+	return SetSignUpResponse(eventId, userKey, response, claimedBlocks, maxGuestResponses)
+}
+
+func (mongoEventRepository) PromoteFromSignUpWaitlist(eventId primitive.ObjectID, userKey string, block *models.SignUpBlock) (bool, error) {
+	return PromoteFromSignUpWaitlist(eventId, userKey, block)
+}
+
+func (mongoEventRepository) DeleteSignUpResponse(eventId primitive.ObjectID, userKey string) error {
+	return DeleteSignUpResponse(eventId, userKey)
+}
+
+func (mongoEventRepository) CountCreatedThisMonth(userId primitive.ObjectID) (int, error) {
+	return GetEventsCreatedThisMonth(userId)
+}
+
+func (mongoEventRepository) GetDeleted(userId primitive.ObjectID) ([]models.Event, error) {
+	return GetDeletedEvents(userId)
+}
+
+func (mongoEventRepository) Restore(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	return RestoreEvent(eventId, userId)
+}
+
+func (mongoEventRepository) Purge(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	return PurgeEvent(eventId, userId)
+}
+
+func (mongoEventRepository) GetIncludingDeleted(id string) (*models.Event, error) {
+	return GetEventIncludingDeleted(id)
+}
+
+func (mongoEventRepository) TransferOwnership(event *models.Event, newOwnerId primitive.ObjectID) error {
+	return TransferEventOwnership(event, newOwnerId)
+}
+
+func (mongoEventRepository) Search(user *models.User, search EventSearch) (*EventSearchResult, error) {
+	return SearchEvents(context.Background(), user, search)
+}
+
+func (mongoEventRepository) ClaimUnowned(eventId primitive.ObjectID, ownerId primitive.ObjectID) error {
+	_, err := EventsCollection.UpdateOne(context.Background(), bson.M{"_id": eventId, "ownerId": nil}, bson.M{
+		"$set": bson.M{"ownerId": ownerId},
+	})
+	return err
+}
+
+func (mongoEventRepository) HardDelete(eventId primitive.ObjectID) (bool, error) {
+	return HardDeleteEvent(eventId)
+}
+
+type mongoResponseRepository struct{}
+
+func (mongoResponseRepository) GetByEventId(eventId primitive.ObjectID) ([]models.EventResponse, error) {
+	return GetEventResponses(eventId.Hex())
+}
+
+func (mongoResponseRepository) Insert(eventResponse *models.EventResponse) error {
+	result, err := EventResponsesCollection.InsertOne(context.Background(), eventResponse)
+	if err != nil {
+		return err
+	}
+	eventResponse.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoResponseRepository) InsertMany(eventResponses []models.EventResponse) error {
+	if len(eventResponses) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, len(eventResponses))
+	for i := range eventResponses {
+		documents[i] = eventResponses[i]
+	}
+	_, err := EventResponsesCollection.InsertMany(context.Background(), documents)
+	return err
+}
+
+func (mongoResponseRepository) Upsert(eventId primitive.ObjectID, userId string, response *models.Response) (bool, error) {
+	return UpsertEventResponse(eventId, userId, response)
+}
+
+func (mongoResponseRepository) RenameGuest(eventId primitive.ObjectID, oldName string, newName string) (bool, error) {
+	return UpdateGuestResponseName(eventId.Hex(), oldName, newName)
+}
+
+func (mongoResponseRepository) Delete(eventResponseId primitive.ObjectID) (bool, error) {
+	return DeleteEventResponse(eventResponseId)
+}
+
+type mongoUserRepository struct{}
+
+func (mongoUserRepository) GetById(userId string) (*models.User, error) {
+	return GetUserById(userId)
+}
+
+func (mongoUserRepository) GetByEmail(email string) (*models.User, error) {
+	return GetUserByEmail(email)
+}
+
+func (mongoUserRepository) GetByStripeCustomerId(stripeCustomerId string) (*models.User, error) {
+	return GetUserByStripeCustomerId(stripeCustomerId)
+}
+
+func (mongoUserRepository) GetByIds(userIds []primitive.ObjectID) ([]models.User, error) {
+	return GetUsersByIds(userIds)
+}
+
+func (mongoUserRepository) GetPublic(userIds []primitive.ObjectID) ([]models.User, error) {
+	return GetPublicUsers(userIds)
+}
+
+func (mongoUserRepository) Search(query string, cursor *primitive.ObjectID, limit int) ([]models.User, *primitive.ObjectID, error) {
+	return SearchUsers(query, cursor, limit)
+}
+
+func (mongoUserRepository) Insert(user *models.User) error {
+	if user.Id.IsZero() {
+		user.Id = primitive.NewObjectID()
+	}
+	_, err := UsersCollection.InsertOne(context.Background(), user)
+	return err
+}
+
+func (mongoUserRepository) Update(userId primitive.ObjectID, user *models.User) error {
+	_, err := UsersCollection.UpdateByID(context.Background(), userId, bson.M{"$set": user})
+	return err
+}
+
+func (mongoUserRepository) SetFields(userId primitive.ObjectID, updates bson.M) error {
+	_, err := UsersCollection.UpdateByID(context.Background(), userId, bson.M{"$set": updates})
+	return err
+}
+
+func (mongoUserRepository) SetCalendarAccounts(userId primitive.ObjectID, accounts map[string]models.CalendarAccount) error {
+	return SetCalendarAccounts(context.Background(), userId, accounts)
+}
+
+func (mongoUserRepository) RemoveCalendarAccount(userId primitive.ObjectID, calendarAccountKey string) error {
+	// Calendar account keys contain the account's email, whose dots can't be used in a field path
+	_, err := UsersCollection.UpdateByID(context.Background(), userId, bson.A{
+		bson.M{"$set": bson.M{
+			"calendarAccounts": bson.M{
+				"$setField": bson.M{
+					"field": calendarAccountKey,
+					"input": "$$ROOT.calendarAccounts",
+					"value": "$$REMOVE",
+				},
+			},
+		}},
+	})
+	return err
+}
+
+func (mongoUserRepository) IncrementNumEventsCreated(userId primitive.ObjectID) error {
+	_, err := UsersCollection.UpdateByID(context.Background(), userId, bson.M{
+		"$inc": bson.M{"numEventsCreated": 1},
+	})
+	return err
+}
+
+func (mongoUserRepository) RemoveFriends(userId primitive.ObjectID, otherUserId primitive.ObjectID) error {
+	return RemoveFriends(userId, otherUserId)
+}
+
+func (mongoUserRepository) SetRole(userId primitive.ObjectID, role models.UserRole) error {
+	return SetUserRole(userId, role)
+}
+
+func (mongoUserRepository) ForceCalendarReauth(user *models.User, calendarAccountKey string) (int, error) {
+	return ForceCalendarReauth(user, calendarAccountKey)
+}
+
+type mongoAttendeeRepository struct{}
+
+func (mongoAttendeeRepository) GetByEventId(eventId primitive.ObjectID) ([]models.Attendee, error) {
+	return GetAttendees(eventId.Hex())
+}
+
+func (mongoAttendeeRepository) GetByEmail(eventId primitive.ObjectID, email string) (*models.Attendee, error) {
+	var attendee models.Attendee
+	err := AttendeesCollection.FindOne(context.Background(), bson.M{
+		"email":   email,
+		"eventId": eventId,
+	}).Decode(&attendee)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &attendee, nil
+}
+
+func (mongoAttendeeRepository) Insert(attendee *models.Attendee) error {
+	result, err := AttendeesCollection.InsertOne(context.Background(), attendee)
+	if err != nil {
+		return err
+	}
+	attendee.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (mongoAttendeeRepository) InsertMany(attendees []models.Attendee) error {
+	if len(attendees) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, len(attendees))
+	for i := range attendees {
+		documents[i] = attendees[i]
+	}
+	_, err := AttendeesCollection.InsertMany(context.Background(), documents)
+	return err
+}
+
+func (mongoAttendeeRepository) SetDeclined(eventId primitive.ObjectID, email string, declined bool) error {
+	_, err := AttendeesCollection.UpdateOne(context.Background(), bson.M{
+		"email":   email,
+		"eventId": eventId,
+	}, bson.M{
+		"$set": bson.M{
+			"declined": declined,
+		},
+	})
+	return err
+}
+
+func (mongoAttendeeRepository) SetRole(eventId primitive.ObjectID, email string, role models.ParticipantRole) error {
+	_, err := AttendeesCollection.UpdateOne(context.Background(), bson.M{
+		"email":   email,
+		"eventId": eventId,
+	}, bson.M{
+		"$set": bson.M{"role": role},
+	})
+	return err
+}
+
+func (mongoAttendeeRepository) Delete(eventId primitive.ObjectID, email string) error {
+	_, err := AttendeesCollection.DeleteOne(context.Background(), bson.M{
+		"email":   email,
+		"eventId": eventId,
+	})
+	return err
+}
+
+type mongoFolderRepository struct{}
+
+func (mongoFolderRepository) Create(folder *models.Folder) (primitive.ObjectID, error) {
+	return CreateFolder(folder)
+}
+
+func (mongoFolderRepository) GetById(folderId primitive.ObjectID, userId primitive.ObjectID) (*models.Folder, error) {
+	return GetFolderById(folderId, userId)
+}
+
+func (mongoFolderRepository) GetAll(userId primitive.ObjectID) ([]models.Folder, error) {
+	return GetAllFolders(userId)
+}
+
+func (mongoFolderRepository) GetEventIds(folderId primitive.ObjectID, userId primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return GetEventsInFolder(folderId, userId)
+}
+
+func (mongoFolderRepository) Update(folderId primitive.ObjectID, userId primitive.ObjectID, updates bson.M) error {
+	return UpdateFolder(folderId, userId, updates)
+}
+
+func (mongoFolderRepository) SetEventFolder(eventId primitive.ObjectID, folderId *primitive.ObjectID, userId primitive.ObjectID) error {
+	return SetEventFolder(eventId, folderId, userId)
+}
+
+func (mongoFolderRepository) Delete(folderId primitive.ObjectID, userId primitive.ObjectID) error {
+	return DeleteFolder(folderId, userId)
+}
+
+func (mongoFolderRepository) Get(folderId primitive.ObjectID) (*models.Folder, error) {
+	return GetFolder(folderId)
+}
+
+func (mongoFolderRepository) GetSubfolders(folderId primitive.ObjectID) ([]models.Folder, error) {
+	return GetSubfolders(folderId)
+}
+
+func (mongoFolderRepository) GetShared(userId primitive.ObjectID) ([]models.Folder, error) {
+	return GetSharedFolders(userId)
+}
+
+func (mongoFolderRepository) Share(folderId primitive.ObjectID, share models.FolderShare) error {
+	return ShareFolder(folderId, share)
+}
+
+func (mongoFolderRepository) Unshare(folderId primitive.ObjectID, userId primitive.ObjectID) error {
+	return UnshareFolder(folderId, userId)
+}
+
+func (mongoFolderRepository) GetDeleted(userId primitive.ObjectID) ([]models.Folder, error) {
+	return GetDeletedFolders(userId)
+}
+
+func (mongoFolderRepository) Restore(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	return RestoreFolder(folderId, userId)
+}
+
+func (mongoFolderRepository) Purge(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	return PurgeFolder(folderId, userId)
+}
+
+type mongoEventHistoryRepository struct{}
+
+func (mongoEventHistoryRepository) Insert(entry *models.EventHistoryEntry) error {
+	return CreateEventHistoryEntry(entry)
+}
+
+func (mongoEventHistoryRepository) GetByEventId(eventId primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.EventHistoryEntry, *primitive.ObjectID, error) {
+	return GetEventHistory(eventId, cursor, limit)
+}
+
+type mongoTemplateRepository struct{}
+
+func (mongoTemplateRepository) Create(template *models.EventTemplate) (primitive.ObjectID, error) {
+	return CreateEventTemplate(template)
+}
+
+func (mongoTemplateRepository) Get(templateId primitive.ObjectID, userId primitive.ObjectID) (*models.EventTemplate, error) {
+	return GetEventTemplate(templateId, userId)
+}
+
+func (mongoTemplateRepository) GetAll(userId primitive.ObjectID) ([]models.EventTemplate, error) {
+	return GetEventTemplates(userId)
+}
+
+func (mongoTemplateRepository) Count(userId primitive.ObjectID) (int, error) {
+	return CountEventTemplates(userId)
+}
+
+func (mongoTemplateRepository) Replace(template *models.EventTemplate) (bool, error) {
+	return ReplaceEventTemplate(template)
+}
+
+func (mongoTemplateRepository) Delete(templateId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	return DeleteEventTemplate(templateId, userId)
+}
+
+type mongoFriendRequestRepository struct{}
+
+func (mongoFriendRequestRepository) Create(from primitive.ObjectID, to primitive.ObjectID) (*models.FriendRequest, error) {
+	return CreateFriendRequest(from, to)
+}
+
+func (mongoFriendRequestRepository) GetById(friendRequestId string) (*models.FriendRequest, error) {
+	return GetFriendRequestById(friendRequestId)
+}
+
+func (mongoFriendRequestRepository) GetBetween(userId primitive.ObjectID, otherUserId primitive.ObjectID) (*models.FriendRequest, error) {
+	return GetFriendRequestBetween(userId, otherUserId)
+}
+
+func (mongoFriendRequestRepository) GetForUser(userId primitive.ObjectID) ([]models.FriendRequest, []models.FriendRequest, error) {
+	return GetFriendRequests(userId)
+}
+
+func (mongoFriendRequestRepository) Accept(friendRequest *models.FriendRequest) error {
+	return AcceptFriendRequest(friendRequest)
+}
+
+func (mongoFriendRequestRepository) Delete(friendRequestId primitive.ObjectID) error {
+	_, err := FriendRequestsCollection.DeleteOne(context.Background(), bson.M{"_id": friendRequestId})
+	return err
+}
+
+type mongoAdminRepository struct{}
+
+func (mongoAdminRepository) InsertAuditLogEntry(entry *models.AdminAuditLogEntry) error {
+	return CreateAdminAuditLogEntry(entry)
+}
+
+func (mongoAdminRepository) GetAuditLog(targetUserId *primitive.ObjectID, cursor *primitive.ObjectID, limit int) ([]models.AdminAuditLogEntry, *primitive.ObjectID, error) {
+	return GetAdminAuditLog(targetUserId, cursor, limit)
+}
+
+func (mongoAdminRepository) GetInstanceStats(ctx context.Context) (*InstanceStats, error) {
+	return GetInstanceStats(ctx)
+}
+
+type mongoAccountRepository struct{}
+
+func (mongoAccountRepository) Delete(ctx context.Context, user *models.User, transferTo *primitive.ObjectID) (*DeletedUser, error) {
+	return DeleteUser(ctx, user, transferTo)
+}
+
+func (mongoAccountRepository) GetData(ctx context.Context, user *models.User) (*UserData, error) {
+	return GetUserData(ctx, user)
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// Sets the sign up response of the user with the key `userKey`, but only if every block in `claimedBlocks`
+// still has room for them at the time of the write. If the response is from a guest that hasn't signed up yet,
+// fewer than `maxGuestResponses` guests must have signed up too, unless it's 0. Returns whether the response was
+// written
+func SetSignUpResponse(eventId primitive.ObjectID, userKey string, response *models.SignUpResponse, claimedBlocks []models.SignUpBlock, maxGuestResponses int) (bool, error) {
+	filter := bson.M{"_id": eventId}
+
+	// Only match the event if no claimed block has filled up in the meantime
+	conditions := bson.A{}
+	for _, block := range claimedBlocks {
+		if block.Capacity == nil {
+			continue
+		}
+		conditions = append(conditions, bson.M{
+			"$lt": bson.A{signUpCountExpression(block.Id, userKey), *block.Capacity},
+		})
+	}
+
+	// Or if too many other guests have signed up in the meantime
+	if response.UserId.IsZero() && maxGuestResponses > 0 {
+		existingResponse := bson.M{"$getField": bson.M{
+			"field": bson.M{"$literal": userKey},
+			"input": bson.M{"$ifNull": bson.A{"$signUpResponses", bson.M{}}},
+		}}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"$ne": bson.A{bson.M{"$type": existingResponse}, "missing"}},
+			bson.M{"$lt": bson.A{guestSignUpCountExpression(), maxGuestResponses}},
+		}})
+	}
+	if len(conditions) > 0 {
+		filter["$expr"] = bson.M{"$and": conditions}
+	}
+
+	result, err := EventsCollection.UpdateOne(context.Background(), filter, bson.A{
+		bson.M{"$set": bson.M{
+			"signUpResponses": bson.M{
+				"$setField": bson.M{
+					"field": bson.M{"$literal": userKey},                                   // Use $setField because userKey could have periods
+					"input": bson.M{"$ifNull": bson.A{"$$ROOT.signUpResponses", bson.M{}}}, // If sign up responses map is null, create it
+					"value": bson.M{"$literal": response},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// Moves the block from the waitlist of the user with the key `userKey` to their sign ups, but only if they're still
+// on its waitlist and it still has room for them at the time of the write. Returns whether the user was promoted
+func PromoteFromSignUpWaitlist(eventId primitive.ObjectID, userKey string, block *models.SignUpBlock) (bool, error) {
+	response := bson.M{"$getField": bson.M{
+		"field": bson.M{"$literal": userKey},
+		"input": bson.M{"$ifNull": bson.A{"$signUpResponses", bson.M{}}},
+	}}
+
+	conditions := bson.A{
+		bson.M{"$in": bson.A{block.Id, bson.M{"$let": bson.M{
+			"vars": bson.M{"response": response},
+			"in":   bson.M{"$ifNull": bson.A{"$$response.waitlist.signUpBlockId", bson.A{}}},
+		}}}},
+	}
+	if block.Capacity != nil {
+		conditions = append(conditions, bson.M{"$lt": bson.A{signUpCountExpression(block.Id, userKey), *block.Capacity}})
+	}
+
+	result, err := EventsCollection.UpdateOne(context.Background(), bson.M{
+		"_id":   eventId,
+		"$expr": bson.M{"$and": conditions},
+	}, bson.A{
+		bson.M{"$set": bson.M{
+			"signUpResponses": bson.M{
+				"$setField": bson.M{
+					"field": bson.M{"$literal": userKey},
+					"input": "$signUpResponses",
+					"value": bson.M{"$let": bson.M{
+						"vars": bson.M{"response": response},
+						"in": bson.M{"$mergeObjects": bson.A{"$$response", bson.M{
+							"signUpBlockIds": bson.M{"$concatArrays": bson.A{
+								bson.M{"$ifNull": bson.A{"$$response.signUpBlockIds", bson.A{}}},
+								bson.A{block.Id},
+							}},
+							"waitlist": bson.M{"$filter": bson.M{
+								"input": "$$response.waitlist",
+								"as":    "entry",
+								"cond":  bson.M{"$ne": bson.A{"$$entry.signUpBlockId", block.Id}},
+							}},
+						}}},
+					}},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// Removes the sign up response of the user with the key `userKey`
+func DeleteSignUpResponse(eventId primitive.ObjectID, userKey string) error {
+	_, err := EventsCollection.UpdateOne(context.Background(), bson.M{"_id": eventId}, bson.A{
+		bson.M{"$set": bson.M{
+			"signUpResponses": bson.M{
+				"$setField": bson.M{
+					"field": bson.M{"$literal": userKey},
+					"input": bson.M{"$ifNull": bson.A{"$$ROOT.signUpResponses", bson.M{}}},
+					"value": "$$REMOVE",
+				},
+			},
+		}},
+	})
+	return err
+}
+
+// Returns an aggregation expression that counts the guests with a sign up response
+func guestSignUpCountExpression() bson.M {
+	return bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$signUpResponses", bson.M{}}}},
+		"as":    "response",
+		"cond":  bson.M{"$eq": bson.A{bson.M{"$type": "$$response.v.userId"}, "missing"}},
+	}}}
+}
+
+// Returns an aggregation expression that counts the users signed up for the given block, excluding `excludeUserKey`
+func signUpCountExpression(blockId primitive.ObjectID, excludeUserKey string) bson.M {
+	return bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$signUpResponses", bson.M{}}}},
+		"as":    "response",
+		"cond": bson.M{"$and": bson.A{
+			bson.M{"$ne": bson.A{"$$response.k", bson.M{"$literal": excludeUserKey}}},
+			bson.M{"$in": bson.A{blockId, bson.M{"$ifNull": bson.A{"$$response.v.signUpBlockIds", bson.A{}}}}},
+		}},
+	}}}
+}
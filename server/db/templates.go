@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/models"
+)
+
+func CreateEventTemplate(template *models.EventTemplate) (primitive.ObjectID, error) {
+	result, err := EventTemplatesCollection.InsertOne(context.Background(), template)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Returns the user's template with the given _id, or nil if it doesn't exist
+func GetEventTemplate(templateId primitive.ObjectID, userId primitive.ObjectID) (*models.EventTemplate, error) {
+	var template models.EventTemplate
+	err := EventTemplatesCollection.FindOne(context.Background(), bson.M{
+		"_id":    templateId,
+		"userId": userId,
+	}).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Returns the user's templates sorted by name
+func GetEventTemplates(userId primitive.ObjectID) ([]models.EventTemplate, error) {
+	cursor, err := EventTemplatesCollection.Find(context.Background(), bson.M{"userId": userId}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]models.EventTemplate, 0)
+	if err := cursor.All(context.Background(), &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Returns the number of templates the user has
+func CountEventTemplates(userId primitive.ObjectID) (int, error) {
+	count, err := EventTemplatesCollection.CountDocuments(context.Background(), bson.M{"userId": userId})
+	return int(count), err
+}
+
+// Replaces the user's template. Returns whether it existed
+func ReplaceEventTemplate(template *models.EventTemplate) (bool, error) {
+	result, err := EventTemplatesCollection.ReplaceOne(context.Background(), bson.M{
+		"_id":    template.Id,
+		"userId": template.UserId,
+	}, template)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Deletes the user's template. Returns whether it existed
+func DeleteEventTemplate(templateId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	result, err := EventTemplatesCollection.DeleteOne(context.Background(), bson.M{
+		"_id":    templateId,
+		"userId": userId,
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
@@ -0,0 +1,397 @@
+package db
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/models"
+)
+
+// Returns the number of days deleted items are kept in the trash before they're permanently deleted.
+// 0 means they're kept forever, which is the default so that nothing is deleted unless TRASH_RETENTION_DAYS is set
+func TrashRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return 0
+	}
+	return days
+}
+
+// Name of the trash retention job in metrics
+const trashRetentionJob = "trash_retention"
+
+// Permanently deletes the items that have been in the trash for longer than the retention period now and then
+// every hour after that. Does nothing if there's no retention period. Returns a function that stops the job
+func StartTrashRetentionJob() func() {
+	retentionDays := TrashRetentionDays()
+	if retentionDays == 0 {
+		return func() {}
+	}
+
+	jobLogger := logger.With("subsystem", "jobs", "job", trashRetentionJob)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			start := time.Now()
+			deletedBefore := start.AddDate(0, 0, -retentionDays)
+			if due, err := countTrashDue(ctx, deletedBefore); err == nil {
+				metrics.JobQueueDepth.WithLabelValues(trashRetentionJob).Set(float64(due))
+			}
+			events, folders, err := PurgeTrash(ctx, deletedBefore)
+			metrics.ObserveJobRun(trashRetentionJob, start, err)
+			if err != nil && ctx.Err() == nil {
+				jobLogger.Error("couldn't empty the trash", "error", err)
+			} else if events > 0 || folders > 0 {
+				jobLogger.Info("permanently deleted events and folders from the trash", "events", events, "folders", folders)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
+
+// Returns the events the user deleted, most recently deleted first
+func GetDeletedEvents(userId primitive.ObjectID) ([]models.Event, error) {
+	cursor, err := EventsCollection.Find(context.Background(), bson.M{
+		"ownerId":   userId,
+		"isDeleted": true,
+	}, options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.Event, 0)
+	if err := cursor.All(context.Background(), &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Returns the folders the user deleted, most recently deleted first
+func GetDeletedFolders(userId primitive.ObjectID) ([]models.Folder, error) {
+	cursor, err := FoldersCollection.Find(context.Background(), bson.M{
+		"userId":    userId,
+		"isDeleted": true,
+	}, options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]models.Folder, 0)
+	if err := cursor.All(context.Background(), &folders); err != nil {
+		return nil, err
+	}
+	for i, folder := range folders {
+		events, err := findEventsDeletedFromFolders(context.Background(), []primitive.ObjectID{folder.Id}, userId, nil)
+		if err != nil {
+			return nil, err
+		}
+		folders[i].EventIds = make([]primitive.ObjectID, len(events))
+		for j, event := range events {
+			folders[i].EventIds[j] = event.Id
+		}
+	}
+	return folders, nil
+}
+
+// Takes the user's event out of the trash. Returns whether the event was in the trash
+func RestoreEvent(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	result, err := EventsCollection.UpdateOne(context.Background(), bson.M{
+		"_id":       eventId,
+		"ownerId":   userId,
+		"isDeleted": true,
+	}, bson.M{
+		"$unset": bson.M{"isDeleted": "", "deletedAt": "", "deletedFromFolderId": ""},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Takes the user's folder out of the trash, along with the subfolders and events that were deleted with it.
+// If the folder it was in is gone, it's moved to the top level. Returns whether the folder was in the trash
+func RestoreFolder(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	ctx := context.Background()
+
+	var folder models.Folder
+	err := FoldersCollection.FindOneAndUpdate(ctx, bson.M{
+		"_id":       folderId,
+		"userId":    userId,
+		"isDeleted": true,
+	}, bson.M{
+		"$unset": bson.M{"isDeleted": "", "deletedAt": ""},
+	}).Decode(&folder)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if folder.ParentId != nil {
+		if _, err := GetFolder(*folder.ParentId); err == mongo.ErrNoDocuments {
+			if _, err := FoldersCollection.UpdateOne(ctx, bson.M{"_id": folderId}, bson.M{
+				"$unset": bson.M{"parentId": ""},
+			}); err != nil {
+				return false, err
+			}
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	if folder.DeletedAt != nil {
+		deletedWithFolder := bson.M{"isDeleted": true, "deletedAt": *folder.DeletedAt}
+		subfolderIds, err := getSubfolderIds(ctx, folderId, deletedWithFolder)
+		if err != nil {
+			return false, err
+		}
+		if len(subfolderIds) > 0 {
+			if _, err := FoldersCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": subfolderIds}}, bson.M{
+				"$unset": bson.M{"isDeleted": "", "deletedAt": ""},
+			}); err != nil {
+				return false, err
+			}
+		}
+
+		// Put the events back into the folders they were in
+		events, err := findEventsDeletedFromFolders(ctx, append(subfolderIds, folderId), userId, folder.DeletedAt)
+		if err != nil {
+			return false, err
+		}
+		if len(events) > 0 {
+			eventIds := make([]primitive.ObjectID, len(events))
+			folderEvents := make([]interface{}, len(events))
+			for i, event := range events {
+				eventIds[i] = event.Id
+				folderEvents[i] = models.FolderEvent{UserId: userId, FolderId: *event.DeletedFromFolderId, EventId: event.Id}
+			}
+			if _, err := EventsCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": eventIds}}, bson.M{
+				"$unset": bson.M{"isDeleted": "", "deletedAt": "", "deletedFromFolderId": ""},
+			}); err != nil {
+				return false, err
+			}
+			if _, err := FolderEventsCollection.InsertMany(ctx, folderEvents); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// Permanently deletes the user's event if it's in the trash, along with its responses, attendees, and
+// folder mappings. Returns whether the event was in the trash
+func PurgeEvent(eventId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	purged, err := purgeEvents(context.Background(), bson.M{
+		"_id":       eventId,
+		"ownerId":   userId,
+		"isDeleted": true,
+	}, 0)
+	return purged > 0, err
+}
+
+// Permanently deletes the user's folder if it's in the trash, along with the subfolders and events that were
+// deleted with it. Returns whether the folder was in the trash
+func PurgeFolder(folderId primitive.ObjectID, userId primitive.ObjectID) (bool, error) {
+	ctx := context.Background()
+
+	var folder models.Folder
+	err := FoldersCollection.FindOne(ctx, bson.M{
+		"_id":       folderId,
+		"userId":    userId,
+		"isDeleted": true,
+	}).Decode(&folder)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	folderIds := []primitive.ObjectID{folderId}
+	if folder.DeletedAt != nil {
+		subfolderIds, err := getSubfolderIds(ctx, folderId, bson.M{"isDeleted": true, "deletedAt": *folder.DeletedAt})
+		if err != nil {
+			return false, err
+		}
+		folderIds = append(folderIds, subfolderIds...)
+
+		if _, err := purgeEvents(ctx, bson.M{
+			"ownerId":             userId,
+			"isDeleted":           true,
+			"deletedAt":           *folder.DeletedAt,
+			"deletedFromFolderId": bson.M{"$in": folderIds},
+		}, 0); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := purgeFolders(ctx, bson.M{"_id": bson.M{"$in": folderIds}}, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Permanently deletes the events and folders that were moved to the trash before `deletedBefore`.
+// Returns the number of events and folders that were deleted
+func PurgeTrash(ctx context.Context, deletedBefore time.Time) (int, int, error) {
+	filter := bson.M{
+		"isDeleted": true,
+		"deletedAt": bson.M{"$lt": primitive.NewDateTimeFromTime(deletedBefore)},
+	}
+
+	// Delete in batches so a large backlog doesn't build huge queries
+	purgedEvents := 0
+	for {
+		purged, err := purgeEvents(ctx, filter, purgeBatchSize)
+		purgedEvents += purged
+		if err != nil {
+			return purgedEvents, 0, err
+		}
+		if purged < purgeBatchSize {
+			break
+		}
+	}
+
+	purgedFolders := 0
+	for {
+		purged, err := purgeFolders(ctx, filter, purgeBatchSize)
+		purgedFolders += purged
+		if err != nil {
+			return purgedEvents, purgedFolders, err
+		}
+		if purged < purgeBatchSize {
+			break
+		}
+	}
+
+	return purgedEvents, purgedFolders, nil
+}
+
+// Returns the number of events and folders that were put in the trash before `deletedBefore`
+func countTrashDue(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	filter := bson.M{
+		"isDeleted": true,
+		"deletedAt": bson.M{"$lt": primitive.NewDateTimeFromTime(deletedBefore)},
+	}
+	events, err := EventsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	folders, err := FoldersCollection.CountDocuments(ctx, filter)
+	return events + folders, err
+}
+
+// Maximum number of events or folders to delete at once when emptying the trash
+const purgeBatchSize = 1000
+
+// Deletes the events matching the filter along with everything that belongs to them. If `limit` isn't 0, only
+// that many events are deleted. The event documents are deleted last, so that whatever is left over after a
+// failure is cleaned up the next time
+func purgeEvents(ctx context.Context, filter bson.M, limit int) (int, error) {
+	eventIds, err := findIds(ctx, EventsCollection, filter, limit)
+	if err != nil || len(eventIds) == 0 {
+		return 0, err
+	}
+
+	belongsToEvents := bson.M{"eventId": bson.M{"$in": eventIds}}
+	if _, err := EventResponsesCollection.DeleteMany(ctx, belongsToEvents); err != nil {
+		return 0, err
+	}
+	if _, err := AttendeesCollection.DeleteMany(ctx, belongsToEvents); err != nil {
+		return 0, err
+	}
+	if _, err := FolderEventsCollection.DeleteMany(ctx, belongsToEvents); err != nil {
+		return 0, err
+	}
+	if _, err := EventHistoryCollection.DeleteMany(ctx, belongsToEvents); err != nil {
+		return 0, err
+	}
+
+	result, err := EventsCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": eventIds}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+// Deletes the folders matching the filter and the mappings of the events that were in them.
+// If `limit` isn't 0, only that many folders are deleted
+func purgeFolders(ctx context.Context, filter bson.M, limit int) (int, error) {
+	folderIds, err := findIds(ctx, FoldersCollection, filter, limit)
+	if err != nil || len(folderIds) == 0 {
+		return 0, err
+	}
+
+	if _, err := FolderEventsCollection.DeleteMany(ctx, bson.M{"folderId": bson.M{"$in": folderIds}}); err != nil {
+		return 0, err
+	}
+	result, err := FoldersCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": folderIds}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+// Returns the _ids of the documents in the collection that match the filter, up to `limit` of them if it isn't 0
+func findIds(ctx context.Context, collection *mongo.Collection, filter bson.M, limit int) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(documents))
+	for i, document := range documents {
+		ids[i] = document.Id
+	}
+	return ids, nil
+}
+
+// Returns the _ids of the user's events that were deleted along with one of the folders, and the folder each was
+// in. Only returns the events deleted at `deletedAt` if it isn't nil
+func findEventsDeletedFromFolders(ctx context.Context, folderIds []primitive.ObjectID, userId primitive.ObjectID, deletedAt *primitive.DateTime) ([]models.Event, error) {
+	filter := bson.M{
+		"ownerId":             userId,
+		"isDeleted":           true,
+		"deletedFromFolderId": bson.M{"$in": folderIds},
+	}
+	if deletedAt != nil {
+		filter["deletedAt"] = *deletedAt
+	}
+	cursor, err := EventsCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "deletedFromFolderId": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.Event, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -2,34 +2,35 @@ package db
 
 import (
 	"context"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"schej.it/server/logger"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"schej.it/server/models"
 	"schej.it/server/utils"
 )
 
-// Returns a user based on their _id
-func GetUserById(userId string) *models.User {
+// Returns a user based on their _id, or nil if they don't exist
+func GetUserById(userId string) (*models.User, error) {
 	objectId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		// userId is malformatted
-		return nil
+		return nil, nil
 	}
 	result := UsersCollection.FindOne(context.Background(), bson.M{
 		"_id": objectId,
 	})
 	if result.Err() == mongo.ErrNoDocuments {
 		// User does not exist!
-		return nil
+		return nil, nil
 	}
 
 	// Decode result
 	var user models.User
 	if err := result.Decode(&user); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
 	// Override isPremium if self-hosted premium is enabled
@@ -37,23 +38,24 @@ func GetUserById(userId string) *models.User {
 		user.IsPremium = utils.TruePtr()
 	}
 
-	return &user
+	return &user, nil
 }
 
-func GetUserByStripeCustomerId(stripeCustomerId string) *models.User {
+// Returns the user with the given Stripe customer id, or nil if there isn't one
+func GetUserByStripeCustomerId(stripeCustomerId string) (*models.User, error) {
 	result := UsersCollection.FindOne(context.Background(), bson.M{
 		"stripeCustomerId": stripeCustomerId,
 	})
 
 	if result.Err() == mongo.ErrNoDocuments {
 		// User does not exist!
-		return nil
+		return nil, nil
 	}
 
 	// Decode result
 	var user models.User
 	if err := result.Decode(&user); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
 	// Override isPremium if self-hosted premium is enabled
@@ -61,22 +63,23 @@ func GetUserByStripeCustomerId(stripeCustomerId string) *models.User {
 		user.IsPremium = utils.TruePtr()
 	}
 
-	return &user
+	return &user, nil
 }
 
-func GetUserByEmail(email string) *models.User {
+// Returns the user with the given email, or nil if there isn't one
+func GetUserByEmail(email string) (*models.User, error) {
 	result := UsersCollection.FindOne(context.Background(), bson.M{
 		"email": email,
 	})
 	if result.Err() == mongo.ErrNoDocuments {
 		// User does not exist!
-		return nil
+		return nil, nil
 	}
 
 	// Decode result
 	var user models.User
 	if err := result.Decode(&user); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
 	// Override isPremium if self-hosted premium is enabled
@@ -84,5 +87,122 @@ func GetUserByEmail(email string) *models.User {
 		user.IsPremium = utils.TruePtr()
 	}
 
-	return &user
+	return &user, nil
+}
+
+// Returns the users with the given _ids that exist
+func GetUsersByIds(userIds []primitive.ObjectID) ([]models.User, error) {
+	users := make([]models.User, 0)
+	if len(userIds) == 0 {
+		return users, nil
+	}
+
+	cursor, err := UsersCollection.Find(context.Background(), bson.M{
+		"_id": bson.M{"$in": userIds},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.Background(), &users); err != nil {
+		return nil, err
+	}
+
+	// Override isPremium if self-hosted premium is enabled
+	if utils.IsSelfHostedPremiumEnabled() {
+		for i := range users {
+			users[i].IsPremium = utils.TruePtr()
+		}
+	}
+
+	return users, nil
+}
+
+/*
+Sets the user's calendar accounts with the given keys, leaving the rest of the user and their other calendar
+accounts as they are. Calendar account keys contain the account's email, whose dots can't be used in a field
+path, so each account is set with $setField
+*/
+func SetCalendarAccounts(ctx context.Context, userId primitive.ObjectID, accounts map[string]models.CalendarAccount) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	calendarAccounts := interface{}(bson.M{"$ifNull": bson.A{"$calendarAccounts", bson.M{}}})
+	for key, account := range accounts {
+		calendarAccounts = bson.M{"$setField": bson.M{
+			"field": bson.M{"$literal": key},
+			"input": calendarAccounts,
+			"value": bson.M{"$literal": account},
+		}}
+	}
+	_, err := UsersCollection.UpdateByID(ctx, userId, bson.A{
+		bson.M{"$set": bson.M{"calendarAccounts": calendarAccounts}},
+	})
+	return err
+}
+
+// Maximum number of other people's responses to look through when finding who a user responded to events with
+const maxCoRespondentResponses = 1000
+
+/*
+Returns the signed in users that responded to events the user owns or was invited to as an attendee, with only
+the fields that other users can see, sorted by name. Guests and events the user only responded to through a
+link are left out, since those respondents never chose to share their email with the user. Only the most recent
+responses are looked through
+*/
+func GetCoRespondents(user *models.User) ([]models.User, error) {
+	ctx := context.Background()
+	people := make([]models.User, 0)
+
+	ownedEventIds, err := findEventIds(ctx, EventsCollection, bson.M{"ownerId": user.Id, "isDeleted": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	attendeeEventIds, err := findEventIds(ctx, AttendeesCollection, bson.M{"email": user.Email, "declined": false})
+	if err != nil {
+		return nil, err
+	}
+	eventIds := append(ownedEventIds, attendeeEventIds...)
+	if len(eventIds) == 0 {
+		return people, nil
+	}
+
+	// Guests have no user id
+	cursor, err := EventResponsesCollection.Find(ctx, bson.M{
+		"eventId": bson.M{"$in": eventIds},
+		"userId":  bson.M{"$nin": bson.A{user.Id.Hex(), "", nil}},
+	}, options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(maxCoRespondentResponses).
+		SetProjection(bson.M{"userId": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var responses []models.EventResponse
+	if err := cursor.All(ctx, &responses); err != nil {
+		return nil, err
+	}
+
+	userIds := make([]primitive.ObjectID, 0)
+	for _, response := range responses {
+		if respondentId, err := primitive.ObjectIDFromHex(response.UserId); err == nil {
+			userIds = append(userIds, respondentId)
+		}
+	}
+	users, err := GetPublicUsers(userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	seenEmails := make(models.Set[string])
+	for _, person := range users {
+		email := strings.ToLower(person.Email)
+		if _, ok := seenEmails[email]; ok || len(email) == 0 {
+			continue
+		}
+		seenEmails[email] = struct{}{}
+		people = append(people, person)
+	}
+	return people, nil
 }
@@ -2,52 +2,49 @@ package db
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"schej.it/server/logger"
 	"schej.it/server/models"
 	"schej.it/server/utils"
 )
 
-func GetFriendRequestById(friendRequestId string) *models.FriendRequest {
+// Returns the friend request with the given id, or nil if it doesn't exist
+func GetFriendRequestById(friendRequestId string) (*models.FriendRequest, error) {
 	objectId, err := primitive.ObjectIDFromHex(friendRequestId)
 	if err != nil {
 		// friendRequestId is malformatted
-		return nil
+		return nil, nil
 	}
 	result := FriendRequestsCollection.FindOne(context.Background(), bson.M{
 		"_id": objectId,
 	})
 	if result.Err() == mongo.ErrNoDocuments {
 		// Friend request does not exist!
-		return nil
+		return nil, nil
 	}
 
 	// Decode result
 	var friendRequest models.FriendRequest
 	if err := result.Decode(&friendRequest); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
-	return &friendRequest
+	return &friendRequest, nil
 }
 
-func DeleteFriendRequestById(friendRequestId string) {
+func DeleteFriendRequestById(friendRequestId string) error {
 	objectId, err := primitive.ObjectIDFromHex(friendRequestId)
 	if err != nil {
 		// friendRequestId is malformatted
-		logger.StdErr.Panicln(err)
+		return err
 	}
 	_, err = FriendRequestsCollection.DeleteOne(context.Background(), bson.M{
 		"_id": objectId,
 	})
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
+	return err
 }
 
 /*
@@ -59,11 +56,18 @@ Note: we find the log localized to the user's month/day/year in order to track i
 own timezone, rather than the server's timezone. For example, if a user signed in at 11pm on Monday, then signed in at 8am on Tuesday,
 it could theoretically count as the same day if we were to use server time
 */
-func GetDailyUserLogByDate(date time.Time, timezoneOffset int) *models.DailyUserLog {
-	timezoneOffsetDuration, _ := time.ParseDuration(fmt.Sprintf("%dm", timezoneOffset))
-	adjustedDate := date.Add(timezoneOffsetDuration)
-	startDate := utils.GetDateAtTime(adjustedDate, "00:00:00")
-	endDate := utils.GetDateAtTime(adjustedDate, "23:59:59")
+func GetDailyUserLogByDate(date time.Time, timezoneOffset int) (*models.DailyUserLog, error) {
+	adjustedDate := date.Add(time.Duration(timezoneOffset) * time.Minute)
+	return GetDailyUserLog(adjustedDate, time.UTC)
+}
+
+// Finds or creates the daily user log for the day that the given date falls on in the location
+func GetDailyUserLog(date time.Time, location *time.Location) (*models.DailyUserLog, error) {
+	// Daily user logs are stored at the start of the day in UTC, so move the local day to UTC
+	year, month, day := date.In(location).Date()
+	localDate := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	startDate := utils.GetDateAtTime(localDate, "00:00:00")
+	endDate := utils.GetDateAtTime(localDate, "23:59:59")
 
 	// Find a log for the current date
 	result := DailyUserLogCollection.FindOne(context.Background(), bson.M{
@@ -82,30 +86,32 @@ func GetDailyUserLogByDate(date time.Time, timezoneOffset int) *models.DailyUser
 		}
 		result, err := DailyUserLogCollection.InsertOne(context.Background(), log)
 		if err != nil {
-			logger.StdErr.Panicln(err)
+			return nil, err
 		}
 		log.Id = result.InsertedID.(primitive.ObjectID)
 	} else {
 		// Parse daily user log object
 		if err := result.Decode(&log); err != nil {
-			logger.StdErr.Panicln(err)
+			return nil, err
 		}
 	}
 
-	return &log
+	return &log, nil
 }
 
-func UpdateDailyUserLog(user *models.User) {
-	log := GetDailyUserLogByDate(time.Now(), user.TimezoneOffset)
+// Adds the user to the daily user log for the current day in their timezone
+func UpdateDailyUserLog(user *models.User) error {
+	log, err := GetDailyUserLog(time.Now(), utils.GetUserLocation(user))
+	if err != nil {
+		return err
+	}
 	for _, id := range log.UserIds {
 		if id == user.Id {
-			return
+			return nil
 		}
 	}
 
 	log.UserIds = append(log.UserIds, user.Id)
-	_, err := DailyUserLogCollection.UpdateByID(context.Background(), log.Id, bson.M{"$set": log})
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
+	_, err = DailyUserLogCollection.UpdateByID(context.Background(), log.Id, bson.M{"$set": log})
+	return err
 }
@@ -1,12 +1,18 @@
 package errs
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
 )
 
-// Errors enum
-// TODO: make these an actual type (i.e. Errors.NotSignedIn)
+// Error codes, sent to clients in the error field of responses.Error
 const (
 	NotSignedIn           string = "not-signed-in"
 	UserDoesNotExist      string = "user-does-not-exist"
@@ -18,8 +24,130 @@ const (
 	AttendeeEmailNotFound string = "attendee-email-not-found"
 	EventNotGroup         string = "event-not-group"
 	InvalidCredentials    string = "invalid-credentials"
+	SignUpBlockNotFound   string = "sign-up-block-not-found"
+	FolderNotFound        string = "folder-not-found"
+	EventTemplateNotFound string = "event-template-not-found"
+	UserNotAdmin          string = "user-not-admin"
+	Impersonating         string = "impersonating"
+	InvalidAuthCode       string = "invalid-auth-code"
+	CalendarTokenExpired  string = "calendar-token-expired"
+	DatabaseError         string = "database-error"
+	UpstreamError         string = "upstream-error"
+	Timeout               string = "timeout"
+	RequestCanceled       string = "request-canceled"
+	RateLimited           string = "rate-limited"
+	CaptchaFailed         string = "captcha-failed"
+	TooManyGuestResponses string = "too-many-guest-responses"
+	InternalError         string = "internal-error"
 )
 
+// Status of requests that fail because the client disconnected, which nginx also uses. The client won't see it, but
+// it's logged and recorded in the metrics
+const StatusClientClosedRequest = 499
+
+// An error with the code and HTTP status that clients are sent when it causes a request to fail.
+// Err is the underlying cause, which is logged but not sent
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Marshals the error the same way as responses.Error, without the cause
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}{e.Code, e.Message})
+}
+
+// Returns an error with the given status, code, and message
+func New(status int, code string, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// Returns an error with the given status, code, and message, caused by err
+func Wrap(err error, status int, code string, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message, Err: err}
+}
+
+// Returns an error caused by not being able to reach the given service, i.e. "Google"
+func Upstream(err error, service string) *Error {
+	return Wrap(err, http.StatusBadGateway, UpstreamError, fmt.Sprintf("Couldn't reach %s", service))
+}
+
+// Returns an error caused by a calendar account's access token having expired or been revoked
+func TokenExpired(err error) *Error {
+	return Wrap(err, http.StatusUnauthorized, CalendarTokenExpired, "The calendar account's access has expired or been revoked, sign in to it again")
+}
+
+// Returns err as an *Error, classifying errors that aren't one by their cause. Database outages and timeouts
+// are 503 database-errors, errors from calling other services are 502 upstream-errors, deadlines that were
+// exceeded are 504 timeouts, and anything else is a 500 internal-error
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var googleError *GoogleAPIError
+	if errors.As(err, &googleError) {
+		if googleError.Code == http.StatusUnauthorized {
+			return TokenExpired(err)
+		}
+		return Upstream(err, "Google")
+	}
+
+	// Calls to other services fail with the request's context's error when the client disconnects, so this has to
+	// be checked before they are
+	if errors.Is(err, context.Canceled) {
+		return Wrap(err, StatusClientClosedRequest, RequestCanceled, "The request was canceled")
+	}
+
+	// The http client returns *url.Errors, which have to be checked before the database errors since
+	// mongo.IsTimeout is true for any network timeout
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		service := urlError.URL
+		if u, parseErr := url.Parse(urlError.URL); parseErr == nil {
+			service = u.Host
+		}
+		return Upstream(err, service)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Wrap(err, http.StatusGatewayTimeout, Timeout, "The request timed out")
+	}
+
+	var serverSelectionError topology.ServerSelectionError
+	if errors.As(err, &serverSelectionError) || errors.Is(err, mongo.ErrClientDisconnected) ||
+		mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return Wrap(err, http.StatusServiceUnavailable, DatabaseError, "The database is unavailable, try again later")
+	}
+	var serverError mongo.ServerError
+	if errors.As(err, &serverError) {
+		return Wrap(err, http.StatusInternalServerError, DatabaseError, "Couldn't read or write to the database")
+	}
+
+	return Wrap(err, http.StatusInternalServerError, InternalError, "Something went wrong")
+}
+
 type GoogleAPIError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
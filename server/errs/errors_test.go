@@ -0,0 +1,61 @@
+package errs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestFrom(t *testing.T) {
+	tokenExpired := TokenExpired(errors.New("invalid_grant"))
+
+	tests := []struct {
+		name   string
+		err    error
+		code   string
+		status int
+	}{
+		{"typed error", fmt.Errorf("wrapped: %w", tokenExpired), CalendarTokenExpired, http.StatusUnauthorized},
+		{"expired Google token", &GoogleAPIError{Code: http.StatusUnauthorized}, CalendarTokenExpired, http.StatusUnauthorized},
+		{"other Google error", &GoogleAPIError{Code: http.StatusInternalServerError}, UpstreamError, http.StatusBadGateway},
+		{"unreachable service", &url.Error{Op: "Get", URL: "https://graph.microsoft.com/v1.0/me", Err: errors.New("connection refused")}, UpstreamError, http.StatusBadGateway},
+		{"unreachable database", mongo.ErrClientDisconnected, DatabaseError, http.StatusServiceUnavailable},
+		{"failed query", mongo.CommandError{Code: 2, Message: "bad query"}, DatabaseError, http.StatusInternalServerError},
+		{"deadline", context.DeadlineExceeded, Timeout, http.StatusGatewayTimeout},
+		{"canceled request", &url.Error{Op: "Get", URL: "https://caldav.icloud.com/", Err: context.Canceled}, RequestCanceled, StatusClientClosedRequest},
+		{"anything else", errors.New("boom"), InternalError, http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		e := From(test.err)
+		if e.Code != test.code || e.Status != test.status {
+			t.Errorf("%s: got %s %d, want %s %d", test.name, e.Code, e.Status, test.code, test.status)
+		}
+		if e != tokenExpired && !reflect.DeepEqual(e.Err, test.err) {
+			t.Errorf("%s: the cause isn't wrapped", test.name)
+		}
+	}
+
+	if e := From(&url.Error{Op: "Get", URL: "https://graph.microsoft.com/v1.0/me?$select=mail", Err: errors.New("timeout")}); e.Message != "Couldn't reach graph.microsoft.com" {
+		t.Errorf("message = %q, want the host of the url", e.Message)
+	}
+	if From(nil) != nil {
+		t.Error("From(nil) should be nil")
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	data, err := json.Marshal(Wrap(errors.New("secret cause"), http.StatusServiceUnavailable, DatabaseError, "The database is unavailable"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"error":"database-error","message":"The database is unavailable"}` {
+		t.Errorf("got %s", data)
+	}
+}
@@ -21,7 +21,21 @@ require (
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
 )
 
-require github.com/stripe/stripe-go/v82 v82.0.0
+require (
+	github.com/redis/go-redis/v9 v9.7.3
+	github.com/stripe/stripe-go/v82 v82.0.0
+)
+
+require github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_golang v1.19.1
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+)
 
 require (
 	cloud.google.com/go/compute v1.23.3 // indirect
@@ -1,17 +1,223 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
+// StdOut and StdErr log plain messages at the info and error levels, in the same format as the structured loggers
 var StdOut *log.Logger
 var StdErr *log.Logger
 
+// Severity of a log entry
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	}
+	return "info"
+}
+
+// Returns the level with the given name, i.e. "debug", or the info level if the name isn't a level
+func ParseLevel(name string) Level {
+	for _, level := range []Level{DebugLevel, WarnLevel, ErrorLevel} {
+		if strings.EqualFold(name, level.String()) {
+			return level
+		}
+	}
+	return InfoLevel
+}
+
+var (
+	mutex    sync.Mutex
+	out      io.Writer = os.Stdout
+	errOut   io.Writer = os.Stderr
+	minLevel           = InfoLevel
+	// Whether entries are written as plain text instead of JSON
+	textFormat bool
+)
+
+// Writes log entries to the log file as well as stdout, or stderr for errors. LOG_LEVEL sets the lowest level
+// that's logged (info by default), and LOG_FORMAT=text logs plain text instead of one JSON object per line
 func Init(logFile io.Writer) {
-	StdOut = log.New(io.MultiWriter(logFile, os.Stdout), "[INFO] ", log.Ldate|log.Ltime|log.Lmsgprefix|log.Llongfile)
-	StdErr = log.New(io.MultiWriter(logFile, os.Stderr), "[ERROR] ", log.Ldate|log.Ltime|log.Lmsgprefix|log.Llongfile)
+	mutex.Lock()
+	out = io.MultiWriter(logFile, os.Stdout)
+	errOut = io.MultiWriter(logFile, os.Stderr)
+	minLevel = ParseLevel(os.Getenv("LOG_LEVEL"))
+	textFormat = os.Getenv("LOG_FORMAT") == "text"
+	mutex.Unlock()
+
+	StdOut = log.New(stdWriter{level: InfoLevel}, "", log.Llongfile)
+	StdErr = log.New(stdWriter{level: ErrorLevel}, "", log.Llongfile)
 
 	StdOut.Println("######### Server Restarted #########")
 }
+
+// Logs entries with fields attached to all of them, i.e. the subsystem or the ID of the request being handled
+type Logger struct {
+	// Alternating keys and values
+	fields []interface{}
+}
+
+// Returns a logger that adds the given alternating keys and values to every entry
+func With(args ...interface{}) *Logger {
+	return (&Logger{}).With(args...)
+}
+
+// Returns a logger that adds the given alternating keys and values to every entry, after this logger's fields
+func (l *Logger) With(args ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(args))
+	fields = append(fields, l.fields...)
+	fields = append(fields, args...)
+	return &Logger{fields: fields}
+}
+
+// The methods below log the message along with the given alternating keys and values
+
+func (l *Logger) Debug(msg string, args ...interface{}) {
+	l.log(DebugLevel, msg, args)
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.log(InfoLevel, msg, args)
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	l.log(WarnLevel, msg, args)
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.log(ErrorLevel, msg, args)
+}
+
+func (l *Logger) log(level Level, msg string, args []interface{}) {
+	caller := ""
+	// Skip log and the exported method that called it
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = fmt.Sprintf("%s:%d", file, line)
+	}
+	fields := l.fields
+	if len(args) > 0 {
+		fields = append(append(make([]interface{}, 0, len(fields)+len(args)), fields...), args...)
+	}
+	write(level, caller, msg, fields)
+}
+
+// Receives the output of StdOut and StdErr, i.e. "/path/to/file.go:12: message\n"
+type stdWriter struct {
+	level Level
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	caller, msg := "", line
+	if i := strings.Index(line, ".go:"); i >= 0 {
+		if j := strings.Index(line[i:], ": "); j >= 0 {
+			caller, msg = line[:i+j], line[i+j+2:]
+		}
+	}
+	write(w.level, caller, msg, nil)
+	return len(p), nil
+}
+
+func write(level Level, caller string, msg string, fields []interface{}) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if level < minLevel {
+		return
+	}
+	w := out
+	if level == ErrorLevel {
+		w = errOut
+	}
+
+	now := time.Now()
+	if textFormat {
+		w.Write(formatText(now, level, caller, msg, fields))
+	} else {
+		w.Write(formatJSON(now, level, caller, msg, fields))
+	}
+}
+
+// Formats the entry as a line like the one the standard logger writes
+func formatText(now time.Time, level Level, caller string, msg string, fields []interface{}) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s [%s] ", now.Format("2006/01/02 15:04:05"), strings.ToUpper(level.String()))
+	if len(caller) > 0 {
+		fmt.Fprintf(&buf, "%s: ", caller)
+	}
+	buf.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := field(fields, i)
+		fmt.Fprintf(&buf, " %s=%v", key, value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// Formats the entry as a JSON object on a single line, with the fields after the time, level, message, and caller
+func formatJSON(now time.Time, level Level, caller string, msg string, fields []interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, now.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, msg)
+	if len(caller) > 0 {
+		buf.WriteString(`,"caller":`)
+		writeJSON(&buf, caller)
+	}
+	for i := 0; i < len(fields); i += 2 {
+		key, value := field(fields, i)
+		buf.WriteByte(',')
+		writeJSON(&buf, key)
+		buf.WriteByte(':')
+		writeJSON(&buf, value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// Returns the key and value of the field starting at index i. Errors are logged as their message
+func field(fields []interface{}, i int) (string, interface{}) {
+	key := fmt.Sprint(fields[i])
+	if i+1 >= len(fields) {
+		return key, nil
+	}
+	value := fields[i+1]
+	if err, ok := value.(error); ok && err != nil {
+		value = err.Error()
+	}
+	return key, value
+}
+
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(data)
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStructuredLogging(t *testing.T) {
+	var logFile bytes.Buffer
+	t.Setenv("LOG_LEVEL", "info")
+	Init(&logFile)
+	logFile.Reset()
+
+	requestLogger := With("subsystem", "test").With("requestId", "abc")
+	requestLogger.Debug("not logged")
+	requestLogger.Error("request failed", "status", 500, "error", errors.New("boom"))
+	StdOut.Printf("plain %s", "message")
+
+	lines := strings.Split(strings.TrimSpace(logFile.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), logFile.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"level":     "error",
+		"msg":       "request failed",
+		"subsystem": "test",
+		"requestId": "abc",
+		"status":    float64(500),
+		"error":     "boom",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "logger_test.go:") {
+		t.Errorf("caller = %q, want this file", caller)
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "info" || entry["msg"] != "plain message" || !strings.Contains(entry["caller"].(string), "logger_test.go:") {
+		t.Errorf("StdOut entry = %v, want an info entry with the caller split from the message", entry)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -8,9 +9,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -21,8 +25,15 @@ import (
 	"github.com/stripe/stripe-go/v82"
 	"schej.it/server/db"
 	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/middleware"
+	"schej.it/server/migrations"
+	"schej.it/server/ratelimit"
 	"schej.it/server/routes"
+	"schej.it/server/services/calendar"
+	"schej.it/server/services/captcha"
 	"schej.it/server/services/gcloud"
+	"schej.it/server/services/httpclient"
 	"schej.it/server/slackbot"
 	"schej.it/server/utils"
 
@@ -38,7 +49,19 @@ import (
 
 // @host localhost:3002/api
 
+// How long to wait for requests to finish when shutting down if SHUTDOWN_TIMEOUT isn't set
+const defaultShutdownTimeout = 25 * time.Second
+
 func main() {
+	// Exit with this code once everything deferred below has been cleaned up, which has to be deferred first so
+	// that it runs last
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Set release flag
 	release := flag.Bool("release", false, "Whether this is the release version of the server")
 	flag.Parse()
@@ -56,36 +79,42 @@ func main() {
 	}
 	gin.DefaultWriter = io.MultiWriter(logFile, os.Stdout)
 
+	// Load .env variables before initializing the logger, which is configured by them
+	dotEnvLoaded := loadDotEnv()
+
 	// Init logger
 	logger.Init(logFile)
+	if !dotEnvLoaded {
+		// In Docker or production environments, .env file might not exist
+		// Environment variables should be set directly
+		logger.StdOut.Println("No .env file found, using environment variables directly")
+	}
 
-	// Load .env variables
-	loadDotEnv()
+	// Configure the client used to call other services
+	httpclient.Init()
+	calendar.InitLimits()
+
+	// Rate limits and CAPTCHAs of the routes that can be called without signing in
+	closeRateLimits := ratelimit.Init()
+	defer closeRateLimits()
+	captcha.Init()
 
 	// Init router
 	router := gin.New()
-	router.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		var statusColor, methodColor, resetColor string
-		if param.IsOutputColor() {
-			statusColor = param.StatusCodeColor()
-			methodColor = param.MethodColor()
-			resetColor = param.ResetColor()
-		}
 
-		if param.Latency > time.Minute {
-			param.Latency = param.Latency.Truncate(time.Second)
-		}
-		return fmt.Sprintf("%v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
-			param.TimeStamp.Format("2006/01/02 15:04:05"),
-			statusColor, param.StatusCode, resetColor,
-			param.Latency,
-			param.ClientIP,
-			methodColor, param.Method, resetColor,
-			param.Path,
-			param.ErrorMessage,
-		)
-	}))
-	router.Use(gin.Recovery())
+	// Only trust the client IP forwarded by these proxies, since it's what requests are rate limited by. Without
+	// any, X-Forwarded-For is ignored
+	var trustedProxies []string
+	if envProxies := os.Getenv("TRUSTED_PROXIES"); envProxies != "" {
+		trustedProxies = strings.Split(strings.ReplaceAll(envProxies, " ", ""), ",")
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		logger.StdErr.Printf("Couldn't parse TRUSTED_PROXIES, not trusting any proxies: %v\n", err)
+		router.SetTrustedProxies(nil)
+	}
+
+	router.Use(middleware.RequestLogger())
+	router.Use(middleware.Recovery())
 
 	// Cors
 	// Get allowed origins from environment variable or use defaults
@@ -107,8 +136,8 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PATCH", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Content-Type", "X-Captcha-Token"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-Id"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -117,6 +146,22 @@ func main() {
 	closeConnection := db.Init()
 	defer closeConnection()
 
+	// Apply pending database migrations
+	if os.Getenv("MIGRATE_ON_STARTUP") == "true" {
+		if _, err := migrations.Up(context.Background(), false, logger.StdOut); err != nil {
+			logger.StdErr.Printf("Couldn't apply database migrations, fix the error and restart: %v\n", err)
+			closeConnection()
+			os.Exit(1)
+		}
+	}
+
+	// Make sure the collections have the indexes the queries rely on, now that the migrations have been applied
+	db.EnsureIndexes()
+
+	// Permanently delete items that have been in the trash for too long
+	stopTrashRetentionJob := db.StartTrashRetentionJob()
+	defer stopTrashRetentionJob()
+
 	// Init google cloud stuff
 	closeTasks := gcloud.InitTasks()
 	defer closeTasks()
@@ -127,12 +172,18 @@ func main() {
 
 	// Init routes
 	apiRouter := router.Group("/api")
+	apiRouter.Use(middleware.Repositories(db.NewMongoRepositories()))
+	apiRouter.Use(middleware.Impersonation())
 	routes.InitAuth(apiRouter)
 	routes.InitUser(apiRouter)
 	routes.InitEvents(apiRouter)
 	routes.InitAnalytics(apiRouter)
 	routes.InitStripe(apiRouter)
 	routes.InitFolders(apiRouter)
+	routes.InitTrash(apiRouter)
+	routes.InitTemplates(apiRouter)
+	routes.InitFriends(apiRouter)
+	routes.InitAdmin(apiRouter)
 	slackbot.InitSlackbot(apiRouter)
 
 	// Serve frontend static files only if the directory exists
@@ -170,22 +221,65 @@ func main() {
 	// Init swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
+	// Prometheus metrics, only served when METRICS_TOKEN is set
+	if metricsHandler := metrics.Handler(); metricsHandler != nil {
+		router.GET("/metrics", gin.WrapH(metricsHandler))
+	}
+
+	// Health checks
+	routes.InitHealth(&router.RouterGroup)
+
 	// Run server
-	router.Run(":3002")
+	listenAddr := os.Getenv("LISTEN_ADDR")
+	if listenAddr == "" {
+		listenAddr = ":3002"
+	}
+	server := &http.Server{Addr: listenAddr, Handler: router}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.StdOut.Printf("Listening on %s\n", listenAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Wait until the server is told to stop or can't listen
+	stop, stopNotifying := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopNotifying()
+	select {
+	case err := <-serverErr:
+		logger.StdErr.Println(err)
+		exitCode = 1
+		return
+	case <-stop.Done():
+	}
+
+	// Stop accepting requests and finish the ones in flight. The deferred functions then stop the jobs and close
+	// the connections
+	drainTimeout := getShutdownTimeout()
+	logger.StdOut.Printf("Shutting down, waiting up to %v for requests to finish\n", drainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.StdErr.Printf("Couldn't finish handling requests before shutting down: %v\n", err)
+	}
 }
 
-// Load .env variables
-func loadDotEnv() {
+// Returns how long to wait for requests to finish when shutting down, set in seconds by SHUTDOWN_TIMEOUT
+func getShutdownTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil || seconds < 0 {
+		return defaultShutdownTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Load .env variables. Returns whether there was a .env file
+func loadDotEnv() bool {
 	err := godotenv.Load(".env")
 
 	// Load stripe key
 	stripe.Key = os.Getenv("STRIPE_API_KEY")
 
-	if err != nil {
-		// In Docker or production environments, .env file might not exist
-		// Environment variables should be set directly
-		logger.StdOut.Println("No .env file found, using environment variables directly")
-	}
+	return err == nil
 }
 
 func noRouteHandler() gin.HandlerFunc {
@@ -197,7 +291,10 @@ func noRouteHandler() gin.HandlerFunc {
 		if match := regexp.MustCompile(`\/e\/(\w+)`).FindStringSubmatchIndex(path); match != nil {
 			// /e/:eventId
 			eventId := path[match[2]:match[3]]
-			event := db.GetEventByEitherId(eventId)
+			event, err := db.GetEventByEitherId(eventId)
+			if err != nil {
+				utils.GetLogger(c).Error("couldn't get the event for its meta tags", "eventId", eventId, "error", err)
+			}
 
 			if event != nil {
 				title := fmt.Sprintf("%s - Timeful (formerly Schej)", event.Name)
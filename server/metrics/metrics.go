@@ -0,0 +1,130 @@
+/* Prometheus metrics of the server, exposed at /metrics */
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "schej"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by route and status code",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CalendarRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "calendar_provider_requests_total",
+		Help:      "Calls to calendar providers, by calendar type and operation",
+	}, []string{"calendar_type", "operation"})
+	CalendarErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "calendar_provider_errors_total",
+		Help:      "Calls to calendar providers that failed, by calendar type and operation",
+	}, []string{"calendar_type", "operation"})
+	CalendarDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "calendar_provider_request_duration_seconds",
+		Help:      "Time taken by calls to calendar providers, by calendar type and operation",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"calendar_type", "operation"})
+
+	EmailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_sent_total",
+		Help:      "Emails sent or scheduled, by provider and whether sending succeeded",
+	}, []string{"provider", "result"})
+
+	RateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected because a rate limit was reached, by rate limit",
+	}, []string{"rule"})
+
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Runs of background jobs, by job and whether the run succeeded",
+	}, []string{"job", "result"})
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Time taken by runs of background jobs",
+		Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+	JobQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_queue_depth",
+		Help:      "Items waiting to be processed by background jobs, as of the start of their last run",
+	}, []string{"job"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		CalendarRequests,
+		CalendarErrors,
+		CalendarDuration,
+		EmailsSent,
+		RateLimitedRequests,
+		JobRuns,
+		JobDuration,
+		JobQueueDepth,
+	)
+}
+
+// Returns "success" or "error" depending on whether err is nil, for the result label
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// Records a call to a calendar provider that started at `start`
+func ObserveCalendarRequest(calendarType string, operation string, start time.Time, err error) {
+	CalendarRequests.WithLabelValues(calendarType, operation).Inc()
+	if err != nil {
+		CalendarErrors.WithLabelValues(calendarType, operation).Inc()
+	}
+	CalendarDuration.WithLabelValues(calendarType, operation).Observe(time.Since(start).Seconds())
+}
+
+// Records a run of a background job that started at `start`
+func ObserveJobRun(job string, start time.Time, err error) {
+	JobRuns.WithLabelValues(job, Result(err)).Inc()
+	JobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+}
+
+// Serves the metrics in the Prometheus text format to scrapers that send METRICS_TOKEN as a bearer token. Returns
+// nil if METRICS_TOKEN isn't set, since the metrics shouldn't be public
+func Handler() http.Handler {
+	token := os.Getenv("METRICS_TOKEN")
+	if len(token) == 0 {
+		return nil
+	}
+
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/errs"
+	"schej.it/server/responses"
+	"schej.it/server/utils"
+)
+
+// Only lets admins through. Has to come after AuthRequired
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.IsAdmin(utils.GetAuthUser(c)) {
+			c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotAdmin})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Stops admins impersonating a user from doing what only the user should be able to, like deleting their account
+// or exporting their data. Has to come after Impersonation
+func NotImpersonating() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.GetImpersonatorId(c) != nil {
+			c.JSON(http.StatusForbidden, responses.Error{Error: errs.Impersonating})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
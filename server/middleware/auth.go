@@ -7,7 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"schej.it/server/db"
 	"schej.it/server/errs"
+	"schej.it/server/models"
 	"schej.it/server/responses"
+	"schej.it/server/utils"
 )
 
 func AuthRequired() gin.HandlerFunc {
@@ -22,7 +24,17 @@ func AuthRequired() gin.HandlerFunc {
 		}
 
 		// Check if user with user id exists
-		user := db.GetUserById(session.Get("userId").(string))
+		var user *models.User
+		var err error
+		if repositories := db.RepositoriesFrom(c.Request.Context()); repositories != nil {
+			user, err = repositories.Users.GetById(session.Get("userId").(string))
+		} else {
+			user, err = db.GetUserById(session.Get("userId").(string))
+		}
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 
 		if user == nil {
 			c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.UserDoesNotExist})
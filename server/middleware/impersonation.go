@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Methods of the requests that don't change anything, which aren't recorded while impersonating a user
+var readOnlyMethods = map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true}
+
+// Sets the admin impersonating the signed in user, if any, adds them to the request's log entries, and records
+// every request that could change something in the admin audit log. Has to come after the session and
+// Repositories middleware
+func Impersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		impersonatorIdString, _ := session.Get("impersonatorId").(string)
+		impersonatorId, err := primitive.ObjectIDFromHex(impersonatorIdString)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Set(utils.ImpersonatorIdKey, &impersonatorId)
+		c.Set(utils.LoggerKey, utils.GetLogger(c).With("impersonatorId", impersonatorId.Hex()))
+		userId, _ := session.Get("userId").(string)
+
+		c.Next()
+
+		// Stopping impersonating and signing out are recorded by their handlers
+		if readOnlyMethods[c.Request.Method] || session.Get("impersonatorId") == nil {
+			return
+		}
+		entry := &models.AdminAuditLogEntry{
+			AdminId: impersonatorId,
+			Action:  models.ImpersonatedRequestAction,
+			Details: map[string]interface{}{
+				"method": c.Request.Method,
+				"route":  c.FullPath(),
+				"path":   c.Request.URL.Path,
+				"status": c.Writer.Status(),
+			},
+		}
+		if targetUserId, err := primitive.ObjectIDFromHex(userId); err == nil {
+			entry.TargetUserId = &targetUserId
+		}
+		repositories := db.RepositoriesFrom(c.Request.Context())
+		if repositories == nil {
+			repositories = db.NewMongoRepositories()
+		}
+		if err := repositories.Admin.InsertAuditLogEntry(entry); err != nil {
+			utils.GetLogger(c).Error("couldn't record the impersonated request", "error", err)
+		}
+	}
+}
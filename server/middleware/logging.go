@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/utils"
+)
+
+// Routes that are polled, whose successful requests are only logged at the debug level
+var polledRoutes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// Request IDs sent by clients or proxies are reused if they look like this
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Gives every request an ID, returned in the X-Request-Id header, and a logger that adds it to every entry.
+// Logs each request when it's done and records its latency
+func RequestLogger() gin.HandlerFunc {
+	requestLogger := logger.With("subsystem", "http")
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestId := c.GetHeader("X-Request-Id")
+		if !validRequestId.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		c.Header("X-Request-Id", requestId)
+		c.Set(utils.LoggerKey, requestLogger.With("requestId", requestId))
+
+		c.Next()
+
+		// Use the route instead of the path so that there's a bounded number of label values
+		route := c.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		latency := time.Since(start)
+		metrics.RequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(status)).Observe(latency.Seconds())
+
+		args := []interface{}{
+			"method", c.Request.Method,
+			"route", route,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latencyMs", float64(latency.Microseconds()) / 1000,
+			"clientIp", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			args = append(args, "errors", c.Errors.String())
+		}
+		log := utils.GetLogger(c)
+		if status >= http.StatusInternalServerError {
+			log.Error("request failed", args...)
+		} else if polledRoutes[route] && status < http.StatusBadRequest {
+			log.Debug("request handled", args...)
+		} else {
+			log.Info("request handled", args...)
+		}
+	}
+}
+
+// Responds with an error when a handler panics, logging the panic along with the request ID. Panics with an
+// error are responded to like utils.AbortWithError, and other panics with a 500 internal-error
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		utils.GetLogger(c).Error("panic recovered", "error", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+
+		err, ok := recovered.(error)
+		if !ok {
+			err = fmt.Errorf("%v", recovered)
+		}
+		utils.AbortWithError(c, err)
+	})
+}
+
+func newRequestId() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(bytes)
+}
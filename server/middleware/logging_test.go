@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/responses"
+	"schej.it/server/utils"
+)
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+
+	router := gin.New()
+	router.Use(RequestLogger(), Recovery())
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	tests := []struct {
+		path          string
+		requestId     string
+		status        int
+		keepRequestId bool
+	}{
+		{"/ok", "", http.StatusOK, false},
+		{"/ok", "from-proxy.123", http.StatusOK, true},
+		{"/ok", "not a valid id", http.StatusOK, false},
+		{"/panic", "", http.StatusInternalServerError, false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if len(test.requestId) > 0 {
+			req.Header.Set("X-Request-Id", test.requestId)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("GET %s = %d, want %d", test.path, w.Code, test.status)
+		}
+		requestId := w.Header().Get("X-Request-Id")
+		if len(requestId) == 0 || (requestId == test.requestId) != test.keepRequestId {
+			t.Errorf("GET %s with request ID %q responded with request ID %q", test.path, test.requestId, requestId)
+		}
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+
+	router := gin.New()
+	router.Use(RequestLogger(), Recovery())
+	router.GET("/database", func(c *gin.Context) { utils.AbortWithError(c, mongo.ErrClientDisconnected) })
+	router.GET("/token", func(c *gin.Context) { panic(errs.TokenExpired(errors.New("invalid_grant"))) })
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/database", http.StatusServiceUnavailable, errs.DatabaseError},
+		{"/token", http.StatusUnauthorized, errs.CalendarTokenExpired},
+		{"/panic", http.StatusInternalServerError, errs.InternalError},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		var body responses.Error
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET %s: %v", test.path, err)
+		}
+		if w.Code != test.status || body.Error != test.code {
+			t.Errorf("GET %s = %d %v, want %d %s", test.path, w.Code, body.Error, test.status, test.code)
+		}
+		if len(body.Message) == 0 || body.RequestId != w.Header().Get("X-Request-Id") {
+			t.Errorf("GET %s = %+v, want a message and the request ID", test.path, body)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/metrics"
+	"schej.it/server/ratelimit"
+	"schej.it/server/services/captcha"
+	"schej.it/server/utils"
+)
+
+// Replaced in tests
+var (
+	captchaEnabled = captcha.Enabled
+	verifyCaptcha  = captcha.Verify
+)
+
+// Responds with 429 rate-limited and a Retry-After header once any of the rules' limits has been reached.
+// Rules scoped to users are skipped for users that aren't signed in, and rules scoped to events for routes
+// without an :eventId
+func RateLimit(rules ...ratelimit.Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signedIn := sessions.Default(c).Get("userId") != nil
+		for _, rule := range rules {
+			if rule.GuestsOnly && signedIn {
+				continue
+			}
+
+			var subject string
+			switch rule.Scope {
+			case ratelimit.PerIp:
+				subject = c.ClientIP()
+			case ratelimit.PerEvent:
+				subject = getEventSubject(c)
+			case ratelimit.PerUser:
+				if userId, ok := sessions.Default(c).Get("userId").(string); ok {
+					subject = userId
+				}
+			}
+			if len(subject) == 0 {
+				continue
+			}
+
+			if allowed, retryAfter := ratelimit.Allow(c.Request.Context(), rule, subject); !allowed {
+				metrics.RateLimitedRequests.WithLabelValues(rule.Name).Inc()
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				utils.AbortWithError(c, errs.New(http.StatusTooManyRequests, errs.RateLimited, "Too many requests, try again later"))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Returns the _id of the route's event, so that requests by its shortId and by its _id share a count. Ids that
+// don't belong to an event are counted as they are
+func getEventSubject(c *gin.Context) string {
+	eventId := c.Param("eventId")
+	if len(eventId) == 0 {
+		return ""
+	}
+	if objectId, err := primitive.ObjectIDFromHex(eventId); err == nil {
+		return objectId.Hex()
+	}
+
+	repositories := db.RepositoriesFrom(c.Request.Context())
+	if repositories == nil {
+		repositories = db.NewMongoRepositories()
+	}
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.GetLogger(c).Error("couldn't get the event to rate limit", "eventId", eventId, "error", err)
+	}
+	if event == nil {
+		return eventId
+	}
+	return event.Id.Hex()
+}
+
+// Verifies the CAPTCHA token in the X-Captcha-Token header of requests from users that aren't signed in, if
+// CAPTCHA_PROVIDER is set, responding with 403 captcha-failed if it's missing or invalid
+func Captcha() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !captchaEnabled() || sessions.Default(c).Get("userId") != nil {
+			c.Next()
+			return
+		}
+
+		if err := verifyCaptcha(c.Request.Context(), c.GetHeader("X-Captcha-Token"), c.ClientIP()); err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"schej.it/server/db/memory"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/models"
+	"schej.it/server/ratelimit"
+)
+
+// Returns a router where requests with an X-User-Id header are treated as coming from that signed in user
+func newSessionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+	router.Use(func(c *gin.Context) {
+		if userId := c.GetHeader("X-User-Id"); len(userId) > 0 {
+			sessions.Default(c).Set("userId", userId)
+		}
+	})
+	return router
+}
+
+func sendPost(router *gin.Engine, path string, userId string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if len(userId) > 0 {
+		req.Header.Set("X-User-Id", userId)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	ratelimit.SetStore(ratelimit.NewMemoryStore())
+	database := memory.New()
+	shortId := "abc123"
+	event := &models.Event{Name: "Standup", ShortId: &shortId}
+	database.Events.Insert(event)
+	router := newSessionRouter()
+	router.Use(Repositories(database.Repositories()))
+	perEvent := ratelimit.Rule{Name: "test-per-event", Scope: ratelimit.PerEvent, Limit: 2, Window: time.Hour, GuestsOnly: true}
+	perUser := ratelimit.Rule{Name: "test-per-user", Scope: ratelimit.PerUser, Limit: 1, Window: time.Hour}
+	router.POST("/events/:eventId", RateLimit(perEvent, perUser), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Guests are limited per event, whether the event is given by its shortId or its _id
+	for i, path := range []string{"/events/" + shortId, "/events/" + event.Id.Hex(), "/events/" + shortId} {
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if w := sendPost(router, path, "", nil); w.Code != want {
+			t.Fatalf("guest request %d = %d, want %d", i, w.Code, want)
+		} else if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "3600" {
+			t.Errorf("Retry-After = %q, want 3600", w.Header().Get("Retry-After"))
+		}
+	}
+	if w := sendPost(router, "/events/b", "", nil); w.Code != http.StatusOK {
+		t.Errorf("guest request to another event = %d, want 200", w.Code)
+	}
+
+	// Signed in users aren't counted per event, but are per user
+	if w := sendPost(router, "/events/"+shortId, "user1", nil); w.Code != http.StatusOK {
+		t.Errorf("signed in request = %d, want 200", w.Code)
+	}
+	if w := sendPost(router, "/events/"+shortId, "user1", nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second signed in request = %d, want 429", w.Code)
+	}
+}
+
+func TestCaptcha(t *testing.T) {
+	router := newSessionRouter()
+	router.POST("/events", Captcha(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	defer func(originalEnabled func() bool, originalVerify func(context.Context, string, string) error) {
+		captchaEnabled = originalEnabled
+		verifyCaptcha = originalVerify
+	}(captchaEnabled, verifyCaptcha)
+	enabled := false
+	captchaEnabled = func() bool { return enabled }
+	verifyCaptcha = func(ctx context.Context, token string, remoteIp string) error {
+		if token != "valid" {
+			return errs.New(http.StatusForbidden, errs.CaptchaFailed, "Complete the CAPTCHA and try again")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		userId  string
+		token   string
+		status  int
+	}{
+		{"not configured", false, "", "", http.StatusCreated},
+		{"no token", true, "", "", http.StatusForbidden},
+		{"invalid token", true, "", "invalid", http.StatusForbidden},
+		{"valid token", true, "", "valid", http.StatusCreated},
+		{"signed in", true, "user1", "", http.StatusCreated},
+	}
+	for _, test := range tests {
+		enabled = test.enabled
+		header := http.Header{}
+		if len(test.token) > 0 {
+			header.Set("X-Captcha-Token", test.token)
+		}
+		if w := sendPost(router, "/events", test.userId, header); w.Code != test.status {
+			t.Errorf("%s: got %d, want %d", test.name, w.Code, test.status)
+		}
+	}
+}
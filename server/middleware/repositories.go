@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"schej.it/server/db"
+)
+
+// Makes the repositories available to the route handlers through the request context, so that tests can swap in
+// an in-memory database
+func Repositories(repositories *db.Repositories) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(db.WithRepositories(c.Request.Context(), repositories))
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+)
+
+var addCalendarAccounts = Migration{
+	Id:          "20230812_add_calendar_accounts",
+	Description: "Move each user's google tokens into a calendar account",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		cursor, err := db.UsersCollection.Find(ctx, bson.M{"refreshToken": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var user bson.M
+			if err := cursor.Decode(&user); err != nil {
+				logger.Printf("Couldn't decode user, skipping: %v\n", err)
+				continue
+			}
+
+			email, _ := user["email"].(string)
+			calendarAccounts, ok := asM(user["calendarAccounts"])
+			if !ok {
+				calendarAccounts = bson.M{}
+			}
+			if _, ok := calendarAccounts[email]; ok {
+				continue
+			}
+
+			// Uses the calendar account format from before multiple calendar types were supported
+			calendarAccounts[email] = bson.M{
+				"email":                 email,
+				"picture":               user["picture"],
+				"enabled":               true,
+				"accessToken":           user["accessToken"],
+				"accessTokenExpireDate": user["accessTokenExpireDate"],
+				"refreshToken":          user["refreshToken"],
+			}
+			if _, err := db.UsersCollection.UpdateByID(ctx, user["_id"], bson.M{
+				"$set": bson.M{
+					"calendarAccounts": calendarAccounts,
+				},
+				"$unset": bson.M{
+					"accessToken":           "",
+					"accessTokenExpireDate": "",
+					"refreshToken":          "",
+				},
+			}); err != nil {
+				return err
+			}
+		}
+
+		return cursor.Err()
+	},
+}
@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+)
+
+var fifteenMinuteIncrements = Migration{
+	Id:          "20230914_15_minute_increments",
+	Description: "Convert availability from hourly date strings to dates in 15 minute increments",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		// Responses were stored in a map on the event at the time
+		cursor, err := db.EventsCollection.Find(ctx, bson.M{"responses": bson.M{"$type": "object"}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var event bson.M
+			if err := cursor.Decode(&event); err != nil {
+				logger.Printf("Couldn't decode event, skipping: %v\n", err)
+				continue
+			}
+			responses, _ := asM(event["responses"])
+
+			updated := false
+			for userId, value := range responses {
+				response, ok := asM(value)
+				if !ok {
+					continue
+				}
+				availability, _ := asA(response["availability"])
+				if len(availability) == 0 {
+					continue
+				}
+				if _, isString := availability[0].(string); !isString {
+					// Already converted
+					continue
+				}
+
+				newAvailability := make([]primitive.DateTime, 0)
+				for _, dateString := range availability {
+					s, _ := dateString.(string)
+					parsedTime, err := time.Parse(time.RFC3339, s)
+					if err != nil {
+						return err
+					}
+					newAvailability = append(newAvailability, primitive.NewDateTimeFromTime(parsedTime))
+					newAvailability = append(newAvailability, primitive.NewDateTimeFromTime(parsedTime.Add(15*time.Minute)))
+				}
+				response["availability"] = newAvailability
+				responses[userId] = response
+				updated = true
+			}
+
+			if updated {
+				if _, err := db.EventsCollection.UpdateByID(ctx, event["_id"], bson.M{
+					"$set": bson.M{"responses": responses},
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return cursor.Err()
+	},
+}
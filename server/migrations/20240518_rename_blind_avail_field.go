@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+)
+
+var renameBlindAvailField = Migration{
+	Id:          "20240518_rename_blind_avail_field",
+	Description: "Rename the blindavailabilityenabled event field to blindAvailabilityEnabled",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		result, err := db.EventsCollection.UpdateMany(ctx, bson.M{"blindavailabilityenabled": bson.M{"$exists": true}}, bson.M{
+			"$rename": bson.M{"blindavailabilityenabled": "blindAvailabilityEnabled"},
+		})
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Renamed the field on %d events\n", result.ModifiedCount)
+		return nil
+	},
+}
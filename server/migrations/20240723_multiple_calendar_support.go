@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+var multipleCalendarSupport = Migration{
+	Id:          "20240723_multiple_calendar_support",
+	Description: "Convert calendar accounts to google calendar accounts keyed by email and calendar type",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		return updateUserCalendarAccounts(ctx, logger, func(user bson.M, calendarAccounts bson.M) (bson.M, bool) {
+			newCalendarAccounts := bson.M{}
+			updated := false
+			for key, value := range calendarAccounts {
+				calendarAccount, ok := asM(value)
+				if !ok || calendarAccount["calendarType"] != nil {
+					// Already in the new format
+					newCalendarAccounts[key] = value
+					continue
+				}
+
+				email, _ := calendarAccount["email"].(string)
+				newCalendarAccounts[utils.GetCalendarAccountKey(email, models.GoogleCalendarType)] = bson.M{
+					"calendarType": models.GoogleCalendarType,
+					"oAuth2CalendarAuth": bson.M{
+						"accessToken":           calendarAccount["accessToken"],
+						"accessTokenExpireDate": calendarAccount["accessTokenExpireDate"],
+						"refreshToken":          calendarAccount["refreshToken"],
+					},
+					"email":        email,
+					"picture":      calendarAccount["picture"],
+					"enabled":      calendarAccount["enabled"],
+					"subCalendars": calendarAccount["subCalendars"],
+				}
+				updated = true
+			}
+			return newCalendarAccounts, updated
+		})
+	},
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var googleCalendarAuthRename = Migration{
+	Id:          "20240823_google_calendar_auth_rename",
+	Description: "Rename googleCalendarAuth to oAuth2CalendarAuth on calendar accounts",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		return updateUserCalendarAccounts(ctx, logger, func(user bson.M, calendarAccounts bson.M) (bson.M, bool) {
+			updated := false
+			for key, value := range calendarAccounts {
+				calendarAccount, ok := asM(value)
+				if !ok {
+					continue
+				}
+				if googleCalendarAuth, ok := calendarAccount["googleCalendarAuth"]; ok {
+					calendarAccount["oAuth2CalendarAuth"] = googleCalendarAuth
+					delete(calendarAccount, "googleCalendarAuth")
+					calendarAccounts[key] = calendarAccount
+					updated = true
+				}
+			}
+			return calendarAccounts, updated
+		})
+	},
+}
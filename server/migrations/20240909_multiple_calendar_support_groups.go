@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+var multipleCalendarSupportGroups = Migration{
+	Id:          "20240909_multiple_calendar_support_groups",
+	Description: "Add the calendar type to the enabled calendars of availability group responses",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		// Responses were stored in a map on the event at the time
+		cursor, err := db.EventsCollection.Find(ctx, bson.M{
+			"type":      models.GROUP,
+			"responses": bson.M{"$type": "object"},
+		})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var event bson.M
+			if err := cursor.Decode(&event); err != nil {
+				logger.Printf("Couldn't decode event, skipping: %v\n", err)
+				continue
+			}
+			responses, _ := asM(event["responses"])
+
+			updated := false
+			for userId, value := range responses {
+				response, ok := asM(value)
+				if !ok {
+					continue
+				}
+				enabledCalendars, ok := asM(response["enabledCalendars"])
+				if !ok {
+					continue
+				}
+
+				newEnabledCalendars := bson.M{}
+				for calendarEmail, calendarIds := range enabledCalendars {
+					if !strings.HasSuffix(calendarEmail, "_google") && !strings.HasSuffix(calendarEmail, "_apple") {
+						calendarEmail += "_google"
+						updated = true
+					}
+					newEnabledCalendars[calendarEmail] = calendarIds
+				}
+				response["enabledCalendars"] = newEnabledCalendars
+				responses[userId] = response
+			}
+
+			if updated {
+				if _, err := db.EventsCollection.UpdateByID(ctx, event["_id"], bson.M{
+					"$set": bson.M{"responses": responses},
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return cursor.Err()
+	},
+}
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+var eventResponsesRestructure = Migration{
+	Id:          "20250201_event_responses_restructure",
+	Description: "Convert the responses on events from a map to an array",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		cursor, err := db.EventsCollection.Find(ctx, bson.M{"responses": bson.M{"$type": "object"}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		batchSize := 1000
+		totalUpdated := 0
+		operations := make([]mongo.WriteModel, 0)
+		flush := func() error {
+			if len(operations) == 0 {
+				return nil
+			}
+			result, err := db.EventsCollection.BulkWrite(ctx, operations)
+			if err != nil {
+				return err
+			}
+			totalUpdated += int(result.ModifiedCount)
+			operations = operations[:0]
+			return nil
+		}
+
+		for cursor.Next(ctx) {
+			var event bson.M
+			if err := cursor.Decode(&event); err != nil {
+				logger.Printf("Couldn't decode event, skipping: %v\n", err)
+				continue
+			}
+			responses, _ := asM(event["responses"])
+
+			responsesList := bson.A{}
+			for userId, response := range responses {
+				responsesList = append(responsesList, bson.M{
+					"userId":   userId,
+					"response": response,
+				})
+			}
+
+			operations = append(operations, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": event["_id"]}).
+				SetUpdate(bson.M{
+					"$set":   bson.M{"responses": responsesList},
+					"$unset": bson.M{"responsesMap": ""},
+				}))
+			if len(operations) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		logger.Printf("Updated %d events\n", totalUpdated)
+		return nil
+	},
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"schej.it/server/db"
+)
+
+var optimizeEventIndexes = Migration{
+	Id:          "20250201_optimize_event_indexes",
+	Description: "Drop the event responses and attendees indexes that were replaced with compound indexes",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		// This used to also create responses_userId_id_1 and attendees_email_declined_id_1, but responses and
+		// attendees have since moved to their own collections, whose indexes are declared in db/indexes.go
+		for _, name := range []string{"responses_userId_1", "attendees_email_1"} {
+			if _, err := db.EventsCollection.Indexes().DropOne(ctx, name); err != nil {
+				logger.Printf("Couldn't drop index %s, it probably doesn't exist: %v\n", name, err)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,82 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"schej.it/server/db"
+)
+
+var responsesCollection = Migration{
+	Id:          "20250417_responses_collection",
+	Description: "Move event responses and attendees into their own collections",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		cursor, err := db.EventsCollection.Find(ctx, bson.M{
+			"$or": bson.A{
+				bson.M{"responses": bson.M{"$type": "array"}},
+				bson.M{"attendees": bson.M{"$type": "array"}},
+			},
+		}, options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		// Upsert so that responses written to the new collections since the server was upgraded aren't overwritten
+		upsert := options.Update().SetUpsert(true)
+		numEvents := 0
+		for cursor.Next(ctx) {
+			var event bson.M
+			if err := cursor.Decode(&event); err != nil {
+				logger.Printf("Couldn't decode event, skipping: %v\n", err)
+				continue
+			}
+			eventId := event["_id"]
+
+			attendees, _ := asA(event["attendees"])
+			for _, value := range attendees {
+				attendee, ok := asM(value)
+				if !ok {
+					continue
+				}
+				if _, err := db.AttendeesCollection.UpdateOne(ctx, bson.M{
+					"eventId": eventId,
+					"email":   attendee["email"],
+				}, bson.M{
+					"$setOnInsert": bson.M{"declined": attendee["declined"]},
+				}, upsert); err != nil {
+					return err
+				}
+			}
+
+			responses, _ := asA(event["responses"])
+			for _, value := range responses {
+				response, ok := asM(value)
+				if !ok {
+					continue
+				}
+				if _, err := db.EventResponsesCollection.UpdateOne(ctx, bson.M{
+					"eventId": eventId,
+					"userId":  response["userId"],
+				}, bson.M{
+					"$setOnInsert": bson.M{"response": response["response"]},
+				}, upsert); err != nil {
+					return err
+				}
+			}
+
+			// Responses and attendees are read from the collections now
+			if _, err := db.EventsCollection.UpdateByID(ctx, eventId, bson.M{
+				"$unset": bson.M{"responses": "", "attendees": ""},
+			}); err != nil {
+				return err
+			}
+			numEvents++
+		}
+
+		logger.Printf("Moved the responses and attendees of %d events\n", numEvents)
+		return cursor.Err()
+	},
+}
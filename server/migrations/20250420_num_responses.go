@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+var numResponses = Migration{
+	Id:          "20250420_num_responses",
+	Description: "Store the number of responses on each event",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		// Create a pipeline to get event IDs and their response counts
+		cursor, err := db.EventsCollection.Aggregate(ctx, []bson.M{
+			{
+				"$match": bson.M{"numResponses": bson.M{"$exists": false}},
+			},
+			{
+				"$lookup": bson.M{
+					"from":         "eventResponses",
+					"localField":   "_id",
+					"foreignField": "eventId",
+					"as":           "responses",
+				},
+			},
+			{
+				"$project": bson.M{
+					"_id":          1,
+					"numResponses": bson.M{"$size": "$responses"},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		// Process in batches of 1000
+		batchSize := 1000
+		updates := make([]mongo.WriteModel, 0)
+		processedCount := 0
+		flush := func() error {
+			if len(updates) == 0 {
+				return nil
+			}
+			if _, err := db.EventsCollection.BulkWrite(ctx, updates); err != nil {
+				return err
+			}
+			processedCount += len(updates)
+			updates = updates[:0]
+			return nil
+		}
+
+		for cursor.Next(ctx) {
+			var result struct {
+				Id           primitive.ObjectID `bson:"_id"`
+				NumResponses int                `bson:"numResponses"`
+			}
+			if err := cursor.Decode(&result); err != nil {
+				logger.Printf("Couldn't decode event, skipping: %v\n", err)
+				continue
+			}
+
+			updates = append(updates, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": result.Id}).
+				SetUpdate(bson.M{"$set": bson.M{"numResponses": result.NumResponses}}))
+			if len(updates) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		logger.Printf("Set the number of responses of %d events\n", processedCount)
+		return nil
+	},
+}
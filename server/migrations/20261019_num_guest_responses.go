@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+var numGuestResponses = Migration{
+	Id:          "20261019_num_guest_responses",
+	Description: "Store the number of guest responses on each event, which is what the guest response limit checks",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		// Count the responses without a signed in user for each event
+		cursor, err := db.EventResponsesCollection.Aggregate(ctx, []bson.M{
+			{
+				"$match": bson.M{"response.userId": bson.M{"$exists": false}},
+			},
+			{
+				"$group": bson.M{
+					"_id":               "$eventId",
+					"numGuestResponses": bson.M{"$sum": 1},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		// Process in batches of 1000
+		batchSize := 1000
+		updates := make([]mongo.WriteModel, 0)
+		processedCount := 0
+		flush := func() error {
+			if len(updates) == 0 {
+				return nil
+			}
+			if _, err := db.EventsCollection.BulkWrite(ctx, updates); err != nil {
+				return err
+			}
+			processedCount += len(updates)
+			updates = updates[:0]
+			return nil
+		}
+
+		for cursor.Next(ctx) {
+			var result struct {
+				EventId           primitive.ObjectID `bson:"_id"`
+				NumGuestResponses int                `bson:"numGuestResponses"`
+			}
+			if err := cursor.Decode(&result); err != nil {
+				logger.Printf("Couldn't decode guest response count, skipping: %v\n", err)
+				continue
+			}
+
+			updates = append(updates, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": result.EventId, "numGuestResponses": bson.M{"$exists": false}}).
+				SetUpdate(bson.M{"$set": bson.M{"numGuestResponses": result.NumGuestResponses}}))
+			if len(updates) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		logger.Printf("Set the number of guest responses of %d events\n", processedCount)
+		return nil
+	},
+}
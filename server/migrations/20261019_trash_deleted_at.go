@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+)
+
+var trashDeletedAt = Migration{
+	Id:          "20261019_trash_deleted_at",
+	Description: "Record when deleted events and folders were moved to the trash, so they're kept for the full retention period",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		// The actual deletion times weren't recorded, so start the retention period now
+		now := primitive.NewDateTimeFromTime(time.Now())
+		filter := bson.M{"isDeleted": true, "deletedAt": bson.M{"$exists": false}}
+		update := bson.M{"$set": bson.M{"deletedAt": now}}
+
+		events, err := db.EventsCollection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		folders, err := db.FoldersCollection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Set the deletion time of %d events and %d folders\n", events.ModifiedCount, folders.ModifiedCount)
+		return nil
+	},
+}
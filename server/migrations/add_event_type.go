@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+	"schej.it/server/models"
+)
+
+var addEventType = Migration{
+	Id:          "add_event_type",
+	Description: "Set the type of events created before event types existed to specific dates",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		result, err := db.EventsCollection.UpdateMany(ctx, bson.M{"type": nil}, bson.M{
+			"$set": bson.M{"type": models.SPECIFIC_DATES},
+		})
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Set the type of %d events\n", result.ModifiedCount)
+		return nil
+	},
+}
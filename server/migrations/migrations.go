@@ -0,0 +1,153 @@
+/* Versioned database migrations, recorded in the schema_migrations collection as they are applied */
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/db"
+)
+
+// A change to the database. Migrations must be safe to run on a database that is already in the new format,
+// since deployments that were created after a migration was written never had the old format to begin with
+type Migration struct {
+	Id          string
+	Description string
+	Up          func(ctx context.Context, logger *log.Logger) error
+}
+
+// All migrations, in the order they are applied. Only ever append to this list
+var migrations = []Migration{
+	newDateRepresentation,
+	addCalendarAccounts,
+	fifteenMinuteIncrements,
+	addEventType,
+	renameBlindAvailField,
+	multipleCalendarSupport,
+	googleCalendarAuthRename,
+	multipleCalendarSupportGroups,
+	eventResponsesRestructure,
+	optimizeEventIndexes,
+	responsesCollection,
+	numResponses,
+	trashDeletedAt,
+	numGuestResponses,
+}
+
+// A migration that was applied, as stored in the schema_migrations collection
+type AppliedMigration struct {
+	Id         string    `bson:"_id"`
+	AppliedAt  time.Time `bson:"appliedAt"`
+	DurationMs int64     `bson:"durationMs"`
+}
+
+// The status of a registered migration
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Id of the document that is used to make sure only one server runs migrations at a time
+const lockId = "lock"
+
+// Locks older than this are assumed to belong to a server that crashed mid migration
+const lockTimeout = time.Hour
+
+// Returns every registered migration along with whether it has been applied
+func Status(ctx context.Context) ([]MigrationStatus, error) {
+	cursor, err := db.SchemaMigrationsCollection.Find(ctx, bson.M{"_id": bson.M{"$ne": lockId}})
+	if err != nil {
+		return nil, err
+	}
+	var applied []AppliedMigration
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[string]time.Time)
+	for _, migration := range applied {
+		appliedAt[migration.Id] = migration.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		t, ok := appliedAt[migration.Id]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: t})
+	}
+	return statuses, nil
+}
+
+// Applies every pending migration in order and returns the ids of the migrations that were applied.
+// If `dryRun` is true, returns the pending migrations without applying them
+func Up(ctx context.Context, dryRun bool, logger *log.Logger) ([]string, error) {
+	if !dryRun {
+		unlock, err := lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	statuses, err := Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]string, 0)
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if dryRun {
+			logger.Printf("Would apply migration %s: %s\n", status.Id, status.Description)
+			applied = append(applied, status.Id)
+			continue
+		}
+
+		logger.Printf("Applying migration %s: %s\n", status.Id, status.Description)
+		start := time.Now()
+		if err := status.Up(ctx, logger); err != nil {
+			return applied, fmt.Errorf("migration %s failed: %w", status.Id, err)
+		}
+
+		if _, err := db.SchemaMigrationsCollection.InsertOne(ctx, AppliedMigration{
+			Id:         status.Id,
+			AppliedAt:  time.Now(),
+			DurationMs: time.Since(start).Milliseconds(),
+		}); err != nil {
+			return applied, err
+		}
+		applied = append(applied, status.Id)
+		logger.Printf("Applied migration %s in %v\n", status.Id, time.Since(start).Truncate(time.Millisecond))
+	}
+
+	return applied, nil
+}
+
+// Takes the migration lock so that servers starting at the same time don't run migrations twice.
+// Returns a function that releases the lock
+func lock(ctx context.Context) (func(), error) {
+	// Clear out locks left behind by crashed servers
+	if _, err := db.SchemaMigrationsCollection.DeleteOne(ctx, bson.M{
+		"_id":      lockId,
+		"lockedAt": bson.M{"$lt": time.Now().Add(-lockTimeout)},
+	}); err != nil {
+		return nil, err
+	}
+
+	_, err := db.SchemaMigrationsCollection.InsertOne(ctx, bson.M{"_id": lockId, "lockedAt": time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errors.New("migrations are already being applied by another process")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		db.SchemaMigrationsCollection.DeleteOne(context.Background(), bson.M{"_id": lockId})
+	}, nil
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import "testing"
+
+func TestMigrationsAreWellFormed(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, migration := range migrations {
+		if migration.Id == "" || migration.Id == lockId {
+			t.Errorf("migration has an invalid id %q", migration.Id)
+		}
+		if ids[migration.Id] {
+			t.Errorf("migration id %q is used more than once", migration.Id)
+		}
+		ids[migration.Id] = true
+
+		if migration.Up == nil {
+			t.Errorf("migration %s has no Up function", migration.Id)
+		}
+	}
+}
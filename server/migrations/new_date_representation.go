@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+)
+
+var newDateRepresentation = Migration{
+	Id:          "new_date_representation",
+	Description: "Replace event start / end dates and times with a list of dates and a duration",
+	Up: func(ctx context.Context, logger *log.Logger) error {
+		type OldEvent struct {
+			Id        primitive.ObjectID  `bson:"_id,omitempty"`
+			StartDate *primitive.DateTime `bson:"startDate,omitempty"`
+			EndDate   *primitive.DateTime `bson:"endDate,omitempty"`
+
+			// StartTime and EndTime are UTC hours, dates are an array of utc dates
+			StartTime *float32 `bson:"startTime,omitempty"`
+			EndTime   *float32 `bson:"endTime,omitempty"`
+			Dates     []string `bson:"dates,omitempty"`
+		}
+
+		cursor, err := db.EventsCollection.Find(ctx, bson.M{
+			"$or": bson.A{
+				bson.M{"startDate": bson.M{"$exists": true}},
+				bson.M{"startTime": bson.M{"$exists": true}},
+			},
+			"duration": bson.M{"$exists": false},
+		})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var oldEvent OldEvent
+			if err := cursor.Decode(&oldEvent); err != nil {
+				logger.Printf("Couldn't decode event, skipping: %v\n", err)
+				continue
+			}
+
+			var dates []primitive.DateTime
+			var duration float32
+
+			if oldEvent.StartDate != nil && oldEvent.EndDate != nil {
+				startTime := oldEvent.StartDate.Time().UTC().Hour()
+				endTime := oldEvent.EndDate.Time().UTC().Hour()
+				duration = float32(endTime - startTime)
+				if duration < 0 {
+					duration += 24
+				}
+
+				curDate := oldEvent.StartDate.Time()
+				for curDate.Before(oldEvent.EndDate.Time()) {
+					dates = append(dates, primitive.NewDateTimeFromTime(curDate))
+					curDate = curDate.Add(24 * time.Hour)
+				}
+			} else if oldEvent.StartTime != nil && oldEvent.EndTime != nil {
+				duration = *oldEvent.EndTime - *oldEvent.StartTime
+				if duration < 0 {
+					duration += 24
+				}
+
+				for _, dateString := range oldEvent.Dates {
+					split := strings.Split(dateString, "-")
+					if len(split) != 3 {
+						continue
+					}
+					year, _ := strconv.Atoi(split[0])
+					month, _ := strconv.Atoi(split[1])
+					day, _ := strconv.Atoi(split[2])
+
+					date := time.Date(year, time.Month(month), day, int(*oldEvent.StartTime), 0, 0, 0, time.UTC)
+					dates = append(dates, primitive.NewDateTimeFromTime(date))
+				}
+			}
+
+			if dates != nil && duration > 0 {
+				if _, err := db.EventsCollection.UpdateByID(ctx, oldEvent.Id, bson.M{
+					"$set": bson.M{
+						"dates":    dates,
+						"duration": duration,
+					},
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return cursor.Err()
+	},
+}
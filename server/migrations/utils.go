@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/db"
+)
+
+// Returns the value as a map if it is an embedded document
+func asM(value interface{}) (bson.M, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		return v, true
+	case bson.D:
+		return v.Map(), true
+	}
+	return nil, false
+}
+
+// Returns the value as a slice if it is an array
+func asA(value interface{}) (bson.A, bool) {
+	switch v := value.(type) {
+	case bson.A:
+		return v, true
+	case []interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// Calls `transform` with the calendar accounts of every user that has them, and saves the returned
+// calendar accounts for the users where `transform` returns true
+func updateUserCalendarAccounts(ctx context.Context, logger *log.Logger, transform func(user bson.M, calendarAccounts bson.M) (bson.M, bool)) error {
+	cursor, err := db.UsersCollection.Find(ctx, bson.M{"calendarAccounts": bson.M{"$type": "object"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	numUpdated := 0
+	for cursor.Next(ctx) {
+		var user bson.M
+		if err := cursor.Decode(&user); err != nil {
+			logger.Printf("Couldn't decode user, skipping: %v\n", err)
+			continue
+		}
+		calendarAccounts, _ := asM(user["calendarAccounts"])
+
+		newCalendarAccounts, updated := transform(user, calendarAccounts)
+		if !updated {
+			continue
+		}
+
+		// Set the whole map at once because calendar account keys contain periods
+		if _, err := db.UsersCollection.UpdateByID(ctx, user["_id"], bson.M{
+			"$set": bson.M{"calendarAccounts": newCalendarAccounts},
+		}); err != nil {
+			return err
+		}
+		numUpdated++
+	}
+
+	logger.Printf("Updated the calendar accounts of %d users\n", numUpdated)
+	return cursor.Err()
+}
@@ -0,0 +1,36 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// An action taken from the admin console
+type AdminAction string
+
+const (
+	SearchUsersAction       AdminAction = "search_users"
+	ViewUserAction          AdminAction = "view_user"
+	SetUserRoleAction       AdminAction = "set_user_role"
+	ImpersonateAction       AdminAction = "impersonate"
+	StopImpersonatingAction AdminAction = "stop_impersonating"
+	ForceReauthAction       AdminAction = "force_reauth"
+	TransferEventAction     AdminAction = "transfer_event"
+	DeleteEventAction       AdminAction = "delete_event"
+	ViewStatsAction         AdminAction = "view_stats"
+
+	// A request that changed something, made while impersonating the target user
+	ImpersonatedRequestAction AdminAction = "impersonated_request"
+)
+
+// Record of an admin action, kept even after the users and events it was taken on are deleted
+type AdminAuditLogEntry struct {
+	Id      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	AdminId primitive.ObjectID `json:"adminId" bson:"adminId"`
+	Action  AdminAction        `json:"action" bson:"action"`
+
+	TargetUserId  *primitive.ObjectID `json:"targetUserId,omitempty" bson:"targetUserId,omitempty"`
+	TargetEventId *primitive.ObjectID `json:"targetEventId,omitempty" bson:"targetEventId,omitempty"`
+
+	// Parameters of the action, i.e. the search query or the new owner of an event
+	Details map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+
+	CreatedAt primitive.DateTime `json:"createdAt" bson:"createdAt"`
+}
@@ -2,10 +2,20 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+// Whether a participant has to be able to make it to the event
+type ParticipantRole string
+
+const (
+	REQUIRED_PARTICIPANT ParticipantRole = "required"
+	OPTIONAL_PARTICIPANT ParticipantRole = "optional"
+)
+
 type Attendee struct {
 	Id      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
 	EventId primitive.ObjectID `json:"eventId" bson:"eventId,omitempty"`
 
-	Email    string `json:"email" bson:"email,omitempty"`
-	Declined *bool  `json:"declined" bson:"declined,omitempty"`
+	Name     string          `json:"name" bson:"name,omitempty"`
+	Email    string          `json:"email" bson:"email,omitempty"`
+	Role     ParticipantRole `json:"role" bson:"role,omitempty"` // Required if empty
+	Declined *bool           `json:"declined" bson:"declined,omitempty"`
 }
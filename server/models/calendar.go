@@ -54,6 +54,9 @@ type BufferTimeOptions struct {
 	Enabled bool `json:"enabled" bson:"enabled"`
 	Time    int  `json:"time" bson:"time"`
 }
+
+// The start and end times are hours of the day in the user's timezone, so they stay the same local time across
+// daylight saving time changes
 type WorkingHoursOptions struct {
 	Enabled   bool    `json:"enabled" bson:"enabled"`
 	StartTime float32 `json:"startTime" bson:"startTime"`
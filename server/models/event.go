@@ -14,9 +14,11 @@ const (
 
 // Object containing information associated with the remindee
 type Remindee struct {
-	Email     string   `json:"email" bson:"email,omitempty"`
-	TaskIds   []string `json:"-" bson:"taskIds,omitempty"` // Task IDs of the scheduled emails
-	Responded *bool    `json:"responded" bson:"responded,omitempty"`
+	Name      string          `json:"name" bson:"name,omitempty"`
+	Email     string          `json:"email" bson:"email,omitempty"`
+	Role      ParticipantRole `json:"role" bson:"role,omitempty"` // Required if empty
+	TaskIds   []string        `json:"-" bson:"taskIds,omitempty"` // Task IDs of the scheduled emails
+	Responded *bool           `json:"responded" bson:"responded,omitempty"`
 }
 
 type SignUpBlock struct {
@@ -27,10 +29,55 @@ type SignUpBlock struct {
 	EndDate   *primitive.DateTime `json:"endDate" bson:"endDate,omitempty"`
 }
 
+type SignUpQuestionType string
+
+const (
+	TEXT_QUESTION       SignUpQuestionType = "text"
+	PHONE_QUESTION      SignUpQuestionType = "phone"
+	SELECT_QUESTION     SignUpQuestionType = "select"     // Pick exactly one of the options
+	CHECKBOX_QUESTION   SignUpQuestionType = "checkbox"   // A single yes / no checkbox
+	CHECKBOXES_QUESTION SignUpQuestionType = "checkboxes" // Pick any number of the options
+)
+
+// A custom question that the event owner asks users when they sign up
+type SignUpQuestion struct {
+	Id       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Label    string             `json:"label" bson:"label,omitempty"`
+	Type     SignUpQuestionType `json:"type" bson:"type,omitempty"`
+	Required *bool              `json:"required" bson:"required,omitempty"`
+
+	// The choices for select and checkboxes questions
+	Options []string `json:"options" bson:"options,omitempty"`
+
+	// Only ask users who sign up for one of these blocks. Ask everybody if empty
+	SignUpBlockIds []primitive.ObjectID `json:"signUpBlockIds" bson:"signUpBlockIds,omitempty"`
+}
+
+// A user's answer to a sign up question. Which field is set depends on the question type
+type SignUpAnswer struct {
+	QuestionId primitive.ObjectID `json:"questionId" bson:"questionId"`
+
+	Text     *string  `json:"text,omitempty" bson:"text,omitempty"`         // text, phone, and select questions
+	Checked  *bool    `json:"checked,omitempty" bson:"checked,omitempty"`   // checkbox questions
+	Selected []string `json:"selected,omitempty" bson:"selected,omitempty"` // checkboxes questions
+}
+
+// A spot on the waitlist of a sign up block that was full when the user tried to sign up
+type SignUpWaitlistEntry struct {
+	SignUpBlockId primitive.ObjectID `json:"signUpBlockId" bson:"signUpBlockId"`
+	JoinedAt      primitive.DateTime `json:"joinedAt" bson:"joinedAt"`
+}
+
 type SignUpResponse struct {
 	// The IDs of the sign up blocks that the user has signed up for
 	SignUpBlockIds []primitive.ObjectID `json:"signUpBlockIds" bson:"signUpBlockIds,omitempty"`
 
+	// The sign up blocks that the user is waiting on a spot for
+	Waitlist []SignUpWaitlistEntry `json:"waitlist" bson:"waitlist,omitempty"`
+
+	// Answers to the event's sign up questions
+	Answers []SignUpAnswer `json:"answers" bson:"answers,omitempty"`
+
 	// Guest information
 	Name  string `json:"name" bson:"name,omitempty"`
 	Email string `json:"email" bson:"email,omitempty"`
@@ -42,14 +89,17 @@ type SignUpResponse struct {
 
 // Representation of an Event in the mongoDB database
 type Event struct {
-	Id          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-	ShortId     *string            `json:"shortId" bson:"shortId,omitempty"`
-	OwnerId     primitive.ObjectID `json:"ownerId" bson:"ownerId,omitempty"`
-	Name        string             `json:"name" bson:"name,omitempty"`
-	Description *string            `json:"description" bson:"description,omitempty"`
-	Location    *string            `json:"location" bson:"location,omitempty"`
-	IsArchived  *bool              `json:"isArchived" bson:"isArchived,omitempty"`
-	IsDeleted   *bool              `json:"isDeleted" bson:"isDeleted,omitempty"`
+	Id          primitive.ObjectID  `json:"_id" bson:"_id,omitempty"`
+	ShortId     *string             `json:"shortId" bson:"shortId,omitempty"`
+	OwnerId     primitive.ObjectID  `json:"ownerId" bson:"ownerId,omitempty"`
+	Name        string              `json:"name" bson:"name,omitempty"`
+	Description *string             `json:"description" bson:"description,omitempty"`
+	Location    *string             `json:"location" bson:"location,omitempty"`
+	IsArchived  *bool               `json:"isArchived" bson:"isArchived,omitempty"`
+	IsDeleted   *bool               `json:"isDeleted" bson:"isDeleted,omitempty"`
+	DeletedAt   *primitive.DateTime `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	// The folder the event was in when it was deleted along with that folder, so restoring the folder puts it back
+	DeletedFromFolderId *primitive.ObjectID `json:"-" bson:"deletedFromFolderId,omitempty"`
 
 	Duration                 *float32             `json:"duration" bson:"duration,omitempty"`
 	Dates                    []primitive.DateTime `json:"dates" bson:"dates,omitempty"`
@@ -59,6 +109,10 @@ type Event struct {
 	CollectEmails            *bool                `json:"collectEmails" bson:"collectEmails,omitempty"`
 	TimeIncrement            *int                 `json:"timeIncrement" bson:"timeIncrement,omitempty"`
 
+	// IANA timezone the event was created in (i.e. America/New_York). Dates start at the same local time in
+	// this timezone on every day, including across daylight saving time changes
+	Timezone string `json:"timezone" bson:"timezone,omitempty"`
+
 	// Used for specific times for specific dates feature
 	HasSpecificTimes *bool                `json:"hasSpecificTimes" bson:"hasSpecificTimes,omitempty"`
 	Times            []primitive.DateTime `json:"times" bson:"times,omitempty"`
@@ -72,6 +126,7 @@ type Event struct {
 	IsSignUpForm    *bool                      `json:"isSignUpForm" bson:"isSignUpForm,omitempty"`
 	SignUpBlocks    *[]SignUpBlock             `json:"signUpBlocks" bson:"signUpBlocks,omitempty"`
 	SignUpResponses map[string]*SignUpResponse `json:"signUpResponses" bson:"signUpResponses"`
+	SignUpQuestions *[]SignUpQuestion          `json:"signUpQuestions" bson:"signUpQuestions,omitempty"`
 
 	// Whether to start the event on Monday (as opposed to Sunday, used for DOW events)
 	StartOnMonday *bool `json:"startOnMonday" bson:"startOnMonday,omitempty"`
@@ -88,6 +143,9 @@ type Event struct {
 	// Used to store the number of responses for the event
 	NumResponses *int `json:"numResponses" bson:"numResponses,omitempty"`
 
+	// The number of availability responses from guests, which is capped per event
+	NumGuestResponses *int `json:"-" bson:"numGuestResponses,omitempty"`
+
 	// Scheduled event
 	ScheduledEvent  *CalendarEvent `json:"scheduledEvent" bson:"scheduledEvent,omitempty"`
 	CalendarEventId string         `json:"calendarEventId" bson:"calendarEventId,omitempty"`
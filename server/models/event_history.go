@@ -0,0 +1,34 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// A change made to an event or its responses
+type EventHistoryAction string
+
+const (
+	EditEventAction      EventHistoryAction = "edit_event"
+	RenameResponseAction EventHistoryAction = "rename_response"
+	DeleteResponseAction EventHistoryAction = "delete_response"
+	AddAttendeeAction    EventHistoryAction = "add_attendee"
+	RemoveAttendeeAction EventHistoryAction = "remove_attendee"
+)
+
+// Record of a change made to an event. Entries are never modified, and are only deleted along with the event
+type EventHistoryEntry struct {
+	Id      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	EventId primitive.ObjectID `json:"eventId" bson:"eventId"`
+	Action  EventHistoryAction `json:"action" bson:"action"`
+
+	// The signed in user that made the change, nil if it was made by a guest
+	ActorId *primitive.ObjectID `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	Actor   *User               `json:"actor,omitempty" bson:",omitempty"`
+
+	// The admin that made the change while impersonating the actor
+	ImpersonatorId *primitive.ObjectID `json:"impersonatorId,omitempty" bson:"impersonatorId,omitempty"`
+
+	// The fields that changed, with their values before and after the change
+	Before map[string]interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty" bson:"after,omitempty"`
+
+	CreatedAt primitive.DateTime `json:"createdAt" bson:"createdAt"`
+}
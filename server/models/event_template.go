@@ -0,0 +1,47 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// A reusable event configuration that new events can be created from. Times are stored relative to the event's
+// dates, so that the template can be used with any dates
+type EventTemplate struct {
+	Id     primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	UserId primitive.ObjectID `json:"userId" bson:"userId"`
+	Name   string             `json:"name" bson:"name"`
+
+	Type          EventType `json:"type" bson:"type"`
+	Duration      *float32  `json:"duration" bson:"duration,omitempty"`
+	TimeIncrement *int      `json:"timeIncrement" bson:"timeIncrement,omitempty"`
+	DaysOnly      *bool     `json:"daysOnly" bson:"daysOnly,omitempty"`
+	StartOnMonday *bool     `json:"startOnMonday" bson:"startOnMonday,omitempty"`
+
+	// Used for specific times for specific dates events. The times of day are minutes after the start of each date
+	HasSpecificTimes *bool `json:"hasSpecificTimes" bson:"hasSpecificTimes,omitempty"`
+	TimesOfDay       []int `json:"timesOfDay" bson:"timesOfDay,omitempty"`
+
+	// Sign up form details
+	IsSignUpForm    *bool                 `json:"isSignUpForm" bson:"isSignUpForm,omitempty"`
+	SignUpBlocks    []SignUpBlockTemplate `json:"signUpBlocks" bson:"signUpBlocks,omitempty"`
+	SignUpQuestions *[]SignUpQuestion     `json:"signUpQuestions" bson:"signUpQuestions,omitempty"`
+
+	CollectEmails            *bool `json:"collectEmails" bson:"collectEmails,omitempty"`
+	BlindAvailabilityEnabled *bool `json:"blindAvailabilityEnabled" bson:"blindAvailabilityEnabled,omitempty"`
+
+	// People to remind to respond to events created from the template
+	Remindees []TemplateRemindee `json:"remindees" bson:"remindees,omitempty"`
+}
+
+// The shape of a sign up block, relative to the first date of the event
+type SignUpBlockTemplate struct {
+	Name     string `json:"name" bson:"name,omitempty"`
+	Capacity *int   `json:"capacity" bson:"capacity,omitempty"`
+	// Minutes after the start of the event's first date that the block starts
+	StartOffset int `json:"startOffset" bson:"startOffset"`
+	// Length of the block in minutes
+	Length int `json:"length" bson:"length"`
+}
+
+type TemplateRemindee struct {
+	Email string          `json:"email" bson:"email"`
+	Role  ParticipantRole `json:"role" bson:"role,omitempty"` // Required if empty
+}
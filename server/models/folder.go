@@ -6,9 +6,39 @@ type Folder struct {
 	Id     primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
 	UserId primitive.ObjectID `json:"userId" bson:"userId"`
 
-	Name      string  `json:"name,omitempty" bson:"name,omitempty"`
-	Color     *string `json:"color,omitempty" bson:"color,omitempty"`
-	IsDeleted *bool   `json:"isDeleted,omitempty" bson:"isDeleted,omitempty"`
+	// The folder this folder is in, nil if it's at the top level. Subfolders belong to the owner of their parent
+	ParentId *primitive.ObjectID `json:"parentId" bson:"parentId,omitempty"`
+
+	Name      string              `json:"name,omitempty" bson:"name,omitempty"`
+	Color     *string             `json:"color,omitempty" bson:"color,omitempty"`
+	IsDeleted *bool               `json:"isDeleted,omitempty" bson:"isDeleted,omitempty"`
+	DeletedAt *primitive.DateTime `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+
+	// Users the folder is shared with. Sharing a folder also shares the folders inside it
+	SharedWith []FolderShare `json:"sharedWith,omitempty" bson:"sharedWith,omitempty"`
 
 	EventIds []primitive.ObjectID `json:"eventIds" bson:"-"`
+	// The current user's role in the folder, one of the FolderRole constants
+	Role FolderRole `json:"role,omitempty" bson:"-"`
+}
+
+type FolderRole string
+
+const (
+	FolderOwner FolderRole = "owner"
+	// Editors can rename the folder, add and remove events and subfolders, and run bulk actions on the events
+	FolderEditor FolderRole = "editor"
+	// Viewers can see the folder and the events in it
+	FolderViewer FolderRole = "viewer"
+)
+
+// Returns whether the role has at least the permissions of `other`
+func (role FolderRole) Includes(other FolderRole) bool {
+	rank := map[FolderRole]int{FolderViewer: 1, FolderEditor: 2, FolderOwner: 3}
+	return rank[role] >= rank[other] && rank[role] > 0
+}
+
+type FolderShare struct {
+	UserId primitive.ObjectID `json:"userId" bson:"userId"`
+	Role   FolderRole         `json:"role" bson:"role"`
 }
@@ -20,6 +20,9 @@ type Response struct {
 	UserId primitive.ObjectID `json:"userId" bson:"userId,omitempty"`
 	User   *User              `json:"user" bson:",omitempty"`
 
+	// IANA timezone the response was given in, so other respondents can see the local times it was made for
+	Timezone string `json:"timezone" bson:"timezone,omitempty"`
+
 	// Availability
 	Availability []primitive.DateTime `json:"availability" bson:"availability"`
 	IfNeeded     []primitive.DateTime `json:"ifNeeded" bson:"ifNeeded"`
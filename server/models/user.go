@@ -7,6 +7,8 @@ import (
 // Representation of a User in the mongoDB database
 type User struct {
 	TimezoneOffset int `json:"timezoneOffset" bson:"timezoneOffset"`
+	// IANA timezone of the user (i.e. America/New_York), which unlike the offset accounts for daylight saving time
+	Timezone string `json:"timezone" bson:"timezone,omitempty"`
 
 	// Profile info
 	Id        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
@@ -15,6 +17,9 @@ type User struct {
 	LastName  string             `json:"lastName" bson:"lastName,omitempty"`
 	Picture   string             `json:"picture" bson:"picture,omitempty"`
 
+	// Admins can use the admin console. Users listed in ADMIN_EMAILS are admins regardless of their role
+	Role UserRole `json:"role,omitempty" bson:"role,omitempty"`
+
 	// Whether the user has set a custom name for themselves, i.e. don't change their name when they sign in
 	HasCustomName *bool `json:"hasCustomName" bson:"hasCustomName,omitempty"`
 
@@ -28,6 +33,9 @@ type User struct {
 	// Google OAuth stuff
 	TokenOrigin TokenOriginType `json:"-" bson:"tokenOrigin,omitempty"`
 
+	// The users that accepted a friend request from or sent one to this user
+	FriendIds []primitive.ObjectID `json:"-" bson:"friendIds,omitempty"`
+
 	// Calendar options
 	CalendarOptions *CalendarOptions `json:"calendarOptions" bson:"calendarOptions,omitempty"`
 
@@ -47,6 +55,13 @@ const (
 	WEB       TokenOriginType = "web"
 )
 
+type UserRole string
+
+const (
+	RegularUser UserRole = ""
+	AdminUser   UserRole = "admin"
+)
+
 type UserStatus string
 
 const (
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How often windows that have ended are removed
+const sweepInterval = time.Minute
+
+type window struct {
+	count   int64
+	resetAt time.Time
+}
+
+// Counts requests in this instance's memory, so each instance of the server has its own limits
+type MemoryStore struct {
+	mutex     sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: make(map[string]*window), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, duration time.Duration) (int64, time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, w := range s.windows {
+			if !now.Before(w.resetAt) {
+				delete(s.windows, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(duration)}
+		s.windows[key] = w
+	}
+	w.count++
+	return w.count, w.resetAt, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
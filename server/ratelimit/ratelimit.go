@@ -0,0 +1,133 @@
+/* Rate limits for the routes anyone can call, counted in memory or, if RATE_LIMIT_REDIS_URL is set, in Redis so that they're shared by every instance of the server */
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"schej.it/server/logger"
+)
+
+// What requests are counted together
+type Scope string
+
+const (
+	PerIp    Scope = "ip"
+	PerEvent Scope = "event"
+	PerUser  Scope = "user"
+)
+
+// Allows at most Limit requests in each Window for each IP address, event, or signed in user, depending on Scope
+type Rule struct {
+	// Used in the keys of the counts and to override the limit with RATE_LIMITS
+	Name   string
+	Scope  Scope
+	Limit  int
+	Window time.Duration
+
+	// Only counts requests from users that aren't signed in
+	GuestsOnly bool
+}
+
+// Counts requests in fixed windows
+type Store interface {
+	// Increments the key's count in the current window, which starts when the key is first incremented, and
+	// returns the count and when the window ends
+	Increment(ctx context.Context, key string, window time.Duration) (int64, time.Time, error)
+	Close() error
+}
+
+var rateLimitLogger = logger.With("subsystem", "ratelimit")
+
+var (
+	store     Store = NewMemoryStore()
+	enabled         = true
+	overrides       = map[string]Rule{}
+)
+
+// Configures rate limiting from the environment, and returns a function that closes the connection to Redis.
+// Rate limiting is turned off if RATE_LIMIT_ENABLED is false. RATE_LIMITS overrides the limits of rules by name,
+// e.g. "create-event-per-ip=10/1h,guest-response-per-event=100/10m"
+func Init() func() {
+	enabled = os.Getenv("RATE_LIMIT_ENABLED") != "false"
+
+	var err error
+	overrides, err = ParseOverrides(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		rateLimitLogger.Error("invalid RATE_LIMITS, using the default limits", "error", err)
+	}
+
+	if redisUrl := os.Getenv("RATE_LIMIT_REDIS_URL"); len(redisUrl) > 0 {
+		redisStore, err := NewRedisStore(redisUrl)
+		if err != nil {
+			rateLimitLogger.Error("couldn't connect to Redis, counting requests in memory", "error", err)
+		} else {
+			store = redisStore
+		}
+	}
+
+	return func() {
+		if err := store.Close(); err != nil {
+			rateLimitLogger.Warn("couldn't close the rate limit store", "error", err)
+		}
+	}
+}
+
+// Replaces the store, e.g. with a new MemoryStore so that tests don't share counts
+func SetStore(s Store) {
+	store = s
+}
+
+// Parses comma separated name=limit/window pairs, where window is a Go duration like 10m or 1h
+func ParseOverrides(value string) (map[string]Rule, error) {
+	result := make(map[string]Rule)
+	for _, override := range strings.Split(value, ",") {
+		override = strings.TrimSpace(override)
+		if len(override) == 0 {
+			continue
+		}
+
+		name, limitAndWindow, ok := strings.Cut(override, "=")
+		limitString, windowString, ok2 := strings.Cut(limitAndWindow, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%q isn't in the form name=limit/window", override)
+		}
+		limit, err := strconv.Atoi(limitString)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("%q has an invalid limit", override)
+		}
+		window, err := time.ParseDuration(windowString)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("%q has an invalid window", override)
+		}
+		result[name] = Rule{Name: name, Limit: limit, Window: window}
+	}
+	return result, nil
+}
+
+// Counts a request against the rule for the given IP address, event ID, or user ID, and returns whether it's
+// within the limit and, if it isn't, how long until it would be. Requests are allowed if the count fails, so that
+// an unreachable Redis doesn't take the routes down with it
+func Allow(ctx context.Context, rule Rule, subject string) (bool, time.Duration) {
+	if !enabled {
+		return true, 0
+	}
+	if override, ok := overrides[rule.Name]; ok {
+		rule.Limit = override.Limit
+		rule.Window = override.Window
+	}
+
+	count, resetAt, err := store.Increment(ctx, fmt.Sprintf("ratelimit:%s:%s", rule.Name, subject), rule.Window)
+	if err != nil {
+		rateLimitLogger.Warn("couldn't count request, allowing it", "rule", rule.Name, "error", err)
+		return true, 0
+	}
+	if count <= int64(rule.Limit) {
+		return true, 0
+	}
+	return false, time.Until(resetAt)
+}
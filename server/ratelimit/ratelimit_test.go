@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		if count, _, _ := s.Increment(ctx, "a", time.Hour); count != want {
+			t.Errorf("count = %d, want %d", count, want)
+		}
+	}
+	if count, _, _ := s.Increment(ctx, "b", time.Hour); count != 1 {
+		t.Errorf("count of another key = %d, want 1", count)
+	}
+
+	// The count starts over once the window ends
+	if count, _, _ := s.Increment(ctx, "c", 10*time.Millisecond); count != 1 {
+		t.Fatalf("count = %d, want 1", count)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if count, _, _ := s.Increment(ctx, "c", 10*time.Millisecond); count != 1 {
+		t.Errorf("count after the window = %d, want 1", count)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	SetStore(NewMemoryStore())
+	defer func() { overrides = map[string]Rule{} }()
+	rule := Rule{Name: "test", Scope: PerIp, Limit: 2, Window: time.Hour}
+	ctx := context.Background()
+
+	for i, want := range []bool{true, true, false} {
+		allowed, retryAfter := Allow(ctx, rule, "192.0.2.1")
+		if allowed != want {
+			t.Fatalf("request %d allowed = %v, want %v", i, allowed, want)
+		}
+		if !allowed && (retryAfter <= 0 || retryAfter > time.Hour) {
+			t.Errorf("retryAfter = %v, want the rest of the window", retryAfter)
+		}
+	}
+	if allowed, _ := Allow(ctx, rule, "192.0.2.2"); !allowed {
+		t.Error("another IP wasn't allowed")
+	}
+
+	// Limits can be raised by name
+	var err error
+	overrides, err = ParseOverrides("test=5/1h, other=1/10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := Allow(ctx, rule, "192.0.2.1"); !allowed {
+		t.Error("request within the overridden limit wasn't allowed")
+	}
+
+	for _, invalid := range []string{"test", "test=5", "test=many/1h", "test=5/soon"} {
+		if _, err := ParseOverrides(invalid); err == nil {
+			t.Errorf("ParseOverrides(%q) succeeded, want an error", invalid)
+		}
+	}
+}
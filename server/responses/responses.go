@@ -1,5 +1,9 @@
 package responses
 
+// Body of every error response. Error is usually one of the codes in errs. Message and RequestId are set
+// for errors that are responded to with utils.AbortWithError, i.e. an unreachable database
 type Error struct {
-	Error interface{} `json:"error" binding:"required"`
+	Error     interface{} `json:"error" binding:"required"`
+	Message   string      `json:"message,omitempty"`
+	RequestId string      `json:"requestId,omitempty"`
 }
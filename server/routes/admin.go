@@ -0,0 +1,422 @@
+/* The /admin group contains the routes of the admin console for running an instance */
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/responses"
+	"schej.it/server/services/gcloud"
+	"schej.it/server/utils"
+)
+
+func InitAdmin(router *gin.RouterGroup) {
+	// Stopping impersonation is done while signed in as the impersonated user, who isn't an admin
+	router.POST("/admin/impersonation/stop", stopImpersonating)
+
+	adminRouter := router.Group("/admin")
+	adminRouter.Use(middleware.AuthRequired(), middleware.AdminRequired())
+
+	adminRouter.GET("/stats", getInstanceStats)
+	adminRouter.GET("/audit-log", getAdminAuditLog)
+	adminRouter.GET("/users", searchUsers)
+	adminRouter.GET("/users/:userId", getAdminUser)
+	adminRouter.PUT("/users/:userId/role", setUserRole)
+	adminRouter.POST("/users/:userId/impersonate", impersonateUser)
+	adminRouter.POST("/users/:userId/force-reauth", forceCalendarReauth)
+	adminRouter.POST("/events/:eventId/transfer", transferEvent)
+	adminRouter.DELETE("/events/:eventId", hardDeleteEvent)
+}
+
+// @Summary Gets counts of the users, events, and other data on this instance
+// @Tags admin
+// @Produce json
+// @Success 200 {object} db.InstanceStats
+// @Router /admin/stats [get]
+func getInstanceStats(c *gin.Context) {
+	stats, err := getRepositories(c).Admin.GetInstanceStats(c.Request.Context())
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	if err := writeAdminAuditLog(c, models.ViewStatsAction, nil, nil, nil); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// @Summary Gets the admin audit log, most recent first
+// @Tags admin
+// @Produce json
+// @Param userId query string false "Only entries about this user"
+// @Param cursor query string false "The nextCursor of the previous page"
+// @Param limit query int false "Maximum number of entries to return, 50 by default and at most 200"
+// @Success 200 {object} object{entries=[]models.AdminAuditLogEntry,nextCursor=string}
+// @Router /admin/audit-log [get]
+func getAdminAuditLog(c *gin.Context) {
+	cursor, limit, ok := getPage(c)
+	if !ok {
+		return
+	}
+	var targetUserId *primitive.ObjectID
+	if userIdString := c.Query("userId"); len(userIdString) > 0 {
+		userId, err := primitive.ObjectIDFromHex(userIdString)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: "invalid userId"})
+			return
+		}
+		targetUserId = &userId
+	}
+
+	entries, nextCursor, err := getRepositories(c).Admin.GetAuditLog(targetUserId, cursor, limit)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "nextCursor": nextCursor})
+}
+
+// @Summary Searches users by name and email, most recently signed up first
+// @Tags admin
+// @Produce json
+// @Param query query string false "Text that the user's name or email contains, lists every user if empty"
+// @Param cursor query string false "The nextCursor of the previous page"
+// @Param limit query int false "Maximum number of users to return, 50 by default and at most 200"
+// @Success 200 {object} object{users=[]models.User,nextCursor=string}
+// @Router /admin/users [get]
+func searchUsers(c *gin.Context) {
+	cursor, limit, ok := getPage(c)
+	if !ok {
+		return
+	}
+	query := strings.TrimSpace(c.Query("query"))
+
+	users, nextCursor, err := getRepositories(c).Users.Search(query, cursor, limit)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	if err := writeAdminAuditLog(c, models.SearchUsersAction, nil, nil, map[string]interface{}{"query": query}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "nextCursor": nextCursor})
+}
+
+// @Summary Gets a user along with the number of events they created this month
+// @Tags admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} models.User
+// @Router /admin/users/{userId} [get]
+func getAdminUser(c *gin.Context) {
+	user := getAdminTargetUser(c)
+	if user == nil {
+		return
+	}
+	numEventsCreated, err := getRepositories(c).Events.CountCreatedThisMonth(user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	user.NumEventsCreated = numEventsCreated
+
+	if err := writeAdminAuditLog(c, models.ViewUserAction, &user.Id, nil, nil); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Makes a user an admin or a regular user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param payload body object{role=string} true "Either admin or an empty string for a regular user"
+// @Success 200
+// @Router /admin/users/{userId}/role [put]
+func setUserRole(c *gin.Context) {
+	payload := struct {
+		Role models.UserRole `json:"role"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+	if payload.Role != models.RegularUser && payload.Role != models.AdminUser {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "Role must be either admin or empty"})
+		return
+	}
+	user := getAdminTargetUser(c)
+	if user == nil {
+		return
+	}
+	if user.Id == utils.GetAuthUser(c).Id && payload.Role != models.AdminUser {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "Cannot remove your own admin role"})
+		return
+	}
+
+	if err := getRepositories(c).Users.SetRole(user.Id, payload.Role); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	if err := writeAdminAuditLog(c, models.SetUserRoleAction, &user.Id, nil, map[string]interface{}{
+		"previousRole": user.Role,
+		"role":         payload.Role,
+	}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Signs the admin in as the user to help them with support requests
+// @Description Sign out or call /admin/impersonation/stop to go back to the admin's account. Admins can't be impersonated
+// @Tags admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200
+// @Router /admin/users/{userId}/impersonate [post]
+func impersonateUser(c *gin.Context) {
+	user := getAdminTargetUser(c)
+	if user == nil {
+		return
+	}
+	if utils.IsAdmin(user) {
+		c.JSON(http.StatusForbidden, responses.Error{Error: "Cannot impersonate an admin"})
+		return
+	}
+	admin := utils.GetAuthUser(c)
+
+	if err := writeAdminAuditLog(c, models.ImpersonateAction, &user.Id, nil, nil); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("userId", user.Id.Hex())
+	session.Set("impersonatorId", admin.Id.Hex())
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Signs the admin back in to their own account after impersonating a user
+// @Tags admin
+// @Produce json
+// @Success 200
+// @Router /admin/impersonation/stop [post]
+func stopImpersonating(c *gin.Context) {
+	session := sessions.Default(c)
+	impersonatorId, impersonating := session.Get("impersonatorId").(string)
+	userId, _ := session.Get("userId").(string)
+	if !impersonating {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "Not impersonating a user"})
+		return
+	}
+	repositories := getRepositories(c)
+	admin, err := repositories.Users.GetById(impersonatorId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if admin == nil || !utils.IsAdmin(admin) {
+		// The admin was deleted or demoted in the meantime, so sign out completely
+		session.Delete("userId")
+		session.Delete("impersonatorId")
+		session.Save()
+		c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.UserNotAdmin})
+		return
+	}
+
+	var targetUserId *primitive.ObjectID
+	if objectId, err := primitive.ObjectIDFromHex(userId); err == nil {
+		targetUserId = &objectId
+	}
+	// Admins can always go back to their own account, even if it can't be recorded
+	if err := createAdminAuditLogEntry(repositories.Admin, admin.Id, models.StopImpersonatingAction, targetUserId, nil, nil); err != nil {
+		utils.GetLogger(c).Error("couldn't record that the admin stopped impersonating", "adminId", admin.Id.Hex(), "error", err)
+	}
+
+	session.Set("userId", impersonatorId)
+	session.Delete("impersonatorId")
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Signs the user out of their calendar accounts so that they have to sign in to them again
+// @Tags admin
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param accountKey query string false "Only sign out of this calendar account, i.e. email_google"
+// @Success 200 {object} object{numAccounts=int}
+// @Router /admin/users/{userId}/force-reauth [post]
+func forceCalendarReauth(c *gin.Context) {
+	user := getAdminTargetUser(c)
+	if user == nil {
+		return
+	}
+	accountKey := c.Query("accountKey")
+	if _, ok := user.CalendarAccounts[accountKey]; len(accountKey) > 0 && !ok {
+		c.JSON(http.StatusNotFound, responses.Error{Error: "Calendar account not found"})
+		return
+	}
+
+	numAccounts, err := getRepositories(c).Users.ForceCalendarReauth(user, accountKey)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	if err := writeAdminAuditLog(c, models.ForceReauthAction, &user.Id, nil, map[string]interface{}{
+		"accountKey":  accountKey,
+		"numAccounts": numAccounts,
+	}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"numAccounts": numAccounts})
+}
+
+// @Summary Gives an event to another user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Param payload body object{email=string} true "Email of the new owner"
+// @Success 200
+// @Router /admin/events/{eventId}/transfer [post]
+func transferEvent(c *gin.Context) {
+	payload := struct {
+		Email string `json:"email" binding:"required"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(c.Param("eventId"))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+	newOwner, err := repositories.Users.GetByEmail(strings.ToLower(strings.TrimSpace(payload.Email)))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if newOwner == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.UserDoesNotExist})
+		return
+	}
+
+	if err := repositories.Events.TransferOwnership(event, newOwner.Id); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	if err := writeAdminAuditLog(c, models.TransferEventAction, &newOwner.Id, &event.Id, map[string]interface{}{
+		"previousOwnerId": event.OwnerId,
+		"eventName":       event.Name,
+	}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Permanently deletes an event, i.e. spam, along with its responses, without moving it to the trash
+// @Tags admin
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Success 200
+// @Router /admin/events/{eventId} [delete]
+func hardDeleteEvent(c *gin.Context) {
+	// Deleted events are looked up too, so that spam can be removed from the trash
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetIncludingDeleted(c.Param("eventId"))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	// Cancel the reminder emails
+	for _, remindee := range utils.Coalesce(event.Remindees) {
+		for _, taskId := range remindee.TaskIds {
+			gcloud.DeleteEmailTask(c.Request.Context(), taskId)
+		}
+	}
+	if _, err := repositories.Events.HardDelete(event.Id); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	var ownerId *primitive.ObjectID
+	if event.OwnerId != primitive.NilObjectID {
+		ownerId = &event.OwnerId
+	}
+	if err := writeAdminAuditLog(c, models.DeleteEventAction, ownerId, &event.Id, map[string]interface{}{
+		"eventName": event.Name,
+	}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// Returns the user with the userId in the url, otherwise responds with a 404 and returns nil
+func getAdminTargetUser(c *gin.Context) *models.User {
+	user, err := getRepositories(c).Users.GetById(c.Param("userId"))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return nil
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.UserDoesNotExist})
+		return nil
+	}
+	return user
+}
+
+// Records that the signed in admin took the action
+func writeAdminAuditLog(c *gin.Context, action models.AdminAction, targetUserId *primitive.ObjectID, targetEventId *primitive.ObjectID, details map[string]interface{}) error {
+	return createAdminAuditLogEntry(getRepositories(c).Admin, utils.GetAuthUser(c).Id, action, targetUserId, targetEventId, details)
+}
+
+func createAdminAuditLogEntry(admin db.AdminRepository, adminId primitive.ObjectID, action models.AdminAction, targetUserId *primitive.ObjectID, targetEventId *primitive.ObjectID, details map[string]interface{}) error {
+	return admin.InsertAuditLogEntry(&models.AdminAuditLogEntry{
+		AdminId:       adminId,
+		Action:        action,
+		TargetUserId:  targetUserId,
+		TargetEventId: targetEventId,
+		Details:       details,
+	})
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db/memory"
+	"schej.it/server/models"
+)
+
+func TestImpersonatedRequests(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	admin := &models.User{Email: "admin@example.com", FirstName: "Admin", Role: models.AdminUser}
+	database.Users.Insert(admin)
+	user := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(user)
+	duration := float32(1)
+	event := &models.Event{
+		OwnerId:  user.Id,
+		Name:     "Standup",
+		Type:     models.SPECIFIC_DATES,
+		Duration: &duration,
+		Dates:    []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 3, 4, 14, 0, 0, 0, time.UTC))},
+	}
+	database.Events.Insert(event)
+
+	impersonate := func(method string, path string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", user.Id.Hex())
+		req.Header.Set("X-Impersonator-Id", admin.Id.Hex())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only the user can delete their account or export their data
+	if w := impersonate(http.MethodDelete, "/api/user", nil); w.Code != http.StatusForbidden {
+		t.Errorf("DELETE /user while impersonating = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := impersonate(http.MethodGet, "/api/user/export", nil); w.Code != http.StatusForbidden {
+		t.Errorf("GET /user/export while impersonating = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if mustGetUser(t, database, user.Id) == nil {
+		t.Fatal("user was deleted while impersonating")
+	}
+
+	// Changes are recorded along with the admin that made them
+	w := impersonate(http.MethodPut, "/api/events/"+event.Id.Hex(), gin.H{
+		"name":     "Daily standup",
+		"duration": duration,
+		"dates":    event.Dates,
+		"type":     event.Type,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT /events/%s = %d %s", event.Id.Hex(), w.Code, w.Body.String())
+	}
+	history, _, _ := database.EventHistory.GetByEventId(event.Id, nil, 10)
+	if len(history) != 1 || history[0].ImpersonatorId == nil || *history[0].ImpersonatorId != admin.Id {
+		t.Errorf("event history = %+v, want the edit made by the admin", history)
+	}
+	auditLog, _, _ := database.Admin.GetAuditLog(&user.Id, nil, 10)
+	requests := make([]string, 0)
+	for _, entry := range auditLog {
+		if entry.Action != models.ImpersonatedRequestAction || entry.AdminId != admin.Id {
+			t.Errorf("audit log entry = %+v, want an impersonated request by the admin", entry)
+			continue
+		}
+		requests = append(requests, entry.Details["method"].(string)+" "+entry.Details["path"].(string))
+	}
+	want := []string{"PUT /api/events/" + event.Id.Hex(), "DELETE /api/user"}
+	if strings.Join(requests, ",") != strings.Join(want, ",") {
+		t.Errorf("recorded requests = %v, want %v", requests, want)
+	}
+
+	// Requests the user makes themselves aren't recorded
+	sendRequest(t, router, http.MethodPut, "/api/events/"+event.Id.Hex(), user.Id.Hex(), gin.H{
+		"name":     "Standup",
+		"duration": duration,
+		"dates":    event.Dates,
+		"type":     event.Type,
+	})
+	if auditLog, _, _ := database.Admin.GetAuditLog(&user.Id, nil, 10); len(auditLog) != len(want) {
+		t.Errorf("audit log has %d entries, want %d", len(auditLog), len(want))
+	}
+}
@@ -2,7 +2,6 @@
 package routes
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -12,8 +11,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"schej.it/server/db"
+	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/slackbot"
+	"schej.it/server/utils"
 )
 
 // BasicAuth middleware for analytics routes
@@ -35,7 +36,7 @@ func AnalyticsBasicAuth() gin.HandlerFunc {
 func InitAnalytics(router *gin.RouterGroup) {
 	analyticsRouter := router.Group("/analytics")
 
-	analyticsRouter.POST("/scanned-poster", scannedPoster)
+	analyticsRouter.POST("/scanned-poster", middleware.RateLimit(scannedPosterPerIp), scannedPoster)
 	analyticsRouter.POST("/upgrade-dialog-viewed", upgradeDialogViewed)
 	analyticsRouter.GET("/monthly-active-event-creators", AnalyticsBasicAuth(), getMonthlyActiveEventCreators)
 	analyticsRouter.GET("/monthly-active-event-creators-with-more-than-x-events", AnalyticsBasicAuth(), getMonthlyActiveEventCreatorsWithMoreThanXEvents)
@@ -96,7 +97,11 @@ func upgradeDialogViewed(c *gin.Context) {
 	}
 
 	var message string
-	user := db.GetUserById(payload.UserId)
+	user, err := getRepositories(c).Users.GetById(payload.UserId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if user == nil {
 		message = fmt.Sprintf(":eyes: %s viewed the upgrade dialog (%s), type: %s", payload.UserId, payload.Price, payload.Type)
 	} else {
@@ -275,7 +280,11 @@ func upgradeUser(c *gin.Context) {
 		return
 	}
 
-	user := db.GetUserByEmail(payload.Email)
+	user, err := getRepositories(c).Users.GetByEmail(payload.Email)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if user == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -283,7 +292,10 @@ func upgradeUser(c *gin.Context) {
 
 	stripeCustomerId := "premium"
 	user.StripeCustomerId = &stripeCustomerId
-	db.UsersCollection.UpdateOne(context.Background(), bson.M{"_id": user.Id}, bson.M{"$set": user})
+	if err := getRepositories(c).Users.SetFields(user.Id, bson.M{"stripeCustomerId": stripeCustomerId}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -303,13 +315,20 @@ func downgradeUser(c *gin.Context) {
 		return
 	}
 
-	user := db.GetUserByEmail(payload.Email)
+	user, err := getRepositories(c).Users.GetByEmail(payload.Email)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if user == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	db.UsersCollection.UpdateOne(context.Background(), bson.M{"_id": user.Id}, bson.M{"$unset": bson.M{"stripeCustomerId": ""}})
+	if err := getRepositories(c).Users.SetFields(user.Id, bson.M{"stripeCustomerId": nil}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -323,6 +342,10 @@ func downgradeUser(c *gin.Context) {
 // @Router /analytics/user/{email} [get]
 func getUserByEmail(c *gin.Context) {
 	email := c.Param("email")
-	user := db.GetUserByEmail(email)
+	user, err := getRepositories(c).Users.GetByEmail(email)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, user)
 }
@@ -2,16 +2,11 @@
 package routes
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"schej.it/server/db"
-	"schej.it/server/logger"
 	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/services/auth"
@@ -35,8 +30,9 @@ func InitAuth(router *gin.RouterGroup) {
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param payload body object{code=string,scope=string,calendarType=string,timezoneOffset=int} true "Object containing the Google authorization code, scope, calendar type, and the user's timezone offset"
+// @Param payload body object{code=string,scope=string,calendarType=string,timezoneOffset=int,timezone=string} true "Object containing the Google authorization code, scope, calendar type, and the user's timezone offset and IANA timezone"
 // @Success 200
+// @Failure 400 {object} responses.Error "Error object with the invalid-auth-code code if the code has expired"
 // @Router /auth/sign-in [post]
 func signIn(c *gin.Context) {
 	payload := struct {
@@ -44,21 +40,33 @@ func signIn(c *gin.Context) {
 		Scope          string              `json:"scope" binding:"required"`
 		CalendarType   models.CalendarType `json:"calendarType" binding:"required"`
 		TimezoneOffset *int                `json:"timezoneOffset" binding:"required"`
+		Timezone       string              `json:"timezone"`
 		EventsToLink   []string            `json:"eventsToLink"`
 	}{}
 	if err := c.BindJSON(&payload); err != nil {
 		return
 	}
 
-	tokens := auth.GetTokensFromAuthCode(payload.Code, payload.Scope, utils.GetOrigin(c), payload.CalendarType)
+	tokens, err := auth.GetTokensFromAuthCode(c.Request.Context(), payload.Code, payload.Scope, utils.GetOrigin(c), payload.CalendarType)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
-	user := signInHelper(c, tokens, models.WEB, payload.CalendarType, *payload.TimezoneOffset)
+	user, err := signInHelper(c, tokens, models.WEB, payload.CalendarType, *payload.TimezoneOffset, payload.Timezone)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	// Link events to user
+	events := getRepositories(c).Events
 	for _, eventIdString := range payload.EventsToLink {
 		eventId, err := primitive.ObjectIDFromHex(eventIdString)
 		if err == nil {
-			db.EventsCollection.UpdateOne(context.Background(), bson.M{"_id": eventId, "ownerId": nil}, bson.M{"$set": bson.M{"ownerId": user.Id}})
+			if err := events.ClaimUnowned(eventId, user.Id); err != nil {
+				utils.GetLogger(c).Error("couldn't link the event to the user", "eventId", eventIdString, "userId", user.Id.Hex(), "error", err)
+			}
 		}
 	}
 
@@ -70,7 +78,7 @@ func signIn(c *gin.Context) {
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param payload body object{timezoneOffset=int,accessToken=string,scope=string,idToken=string,expiresIn=int,refreshToken=string,tokenOrigin=string,calendarType=string} true "Object containing the Google authorization code, calendar type, and the user's timezone offset"
+// @Param payload body object{timezoneOffset=int,timezone=string,accessToken=string,scope=string,idToken=string,expiresIn=int,refreshToken=string,tokenOrigin=string,calendarType=string} true "Object containing the Google authorization code, calendar type, and the user's timezone offset"
 // @Success 200
 // @Router /auth/sign-in-mobile [post]
 func signInMobile(c *gin.Context) {
@@ -83,12 +91,13 @@ func signInMobile(c *gin.Context) {
 		TokenOrigin    models.TokenOriginType `json:"tokenOrigin" binding:"required"`
 		CalendarType   models.CalendarType    `json:"calendarType" binding:"required"`
 		TimezoneOffset int                    `json:"timezoneOffset" binding:"required"`
+		Timezone       string                 `json:"timezone"`
 	}{}
 	if err := c.BindJSON(&payload); err != nil {
 		return
 	}
 
-	signInHelper(
+	_, err := signInHelper(
 		c,
 		auth.TokenResponse{
 			AccessToken:  payload.AccessToken,
@@ -100,13 +109,18 @@ func signInMobile(c *gin.Context) {
 		payload.TokenOrigin,
 		payload.CalendarType,
 		payload.TimezoneOffset,
+		payload.Timezone,
 	)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
 // Helper function to sign user in with the given parameters from the google oauth route
-func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.TokenOriginType, calendarType models.CalendarType, timezoneOffset int) models.User {
+func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.TokenOriginType, calendarType models.CalendarType, timezoneOffset int, timezone string) (models.User, error) {
 	// Get access token expire time
 	accessTokenExpireDate := utils.GetAccessTokenExpireDate(token.ExpiresIn)
 
@@ -128,7 +142,10 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		picture, _ = claims.GetStr("picture")
 	} else if calendarType == models.OutlookCalendarType {
 		// Get user info from microsoft graph
-		userInfo := microsoftgraph.GetUserInfo(nil, &calendarAuth)
+		userInfo, err := microsoftgraph.GetUserInfo(c.Request.Context(), nil, &calendarAuth)
+		if err != nil {
+			return models.User{}, err
+		}
 		email = userInfo.Email
 		firstName = userInfo.FirstName
 		lastName = userInfo.LastName
@@ -150,6 +167,11 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		TokenOrigin:    tokenOrigin,
 	}
 
+	// Only store valid timezones so that clients that don't send one keep the user's existing timezone
+	if _, err := utils.LoadTimezone(timezone); err == nil {
+		userData.Timezone = timezone
+	}
+
 	// Set IsPremium if self-hosted premium is enabled
 	if utils.IsSelfHostedPremiumEnabled() {
 		userData.IsPremium = utils.TruePtr()
@@ -165,12 +187,16 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 	}
 	calendarAccountKey := utils.GetCalendarAccountKey(email, calendarType)
 
+	users := getRepositories(c).Users
 	var userId primitive.ObjectID
-	findResult := db.UsersCollection.FindOne(context.Background(), bson.M{"email": email})
+	user, err := users.GetByEmail(email)
+	if err != nil {
+		return models.User{}, err
+	}
 	// If user doesn't exist, create a new user
-	if findResult.Err() == mongo.ErrNoDocuments {
+	if user == nil {
 		// Fetch subcalendars
-		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList()
+		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList(c.Request.Context())
 		if err == nil {
 			calendarAccount.SubCalendars = &subCalendars
 		}
@@ -181,19 +207,14 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		}
 
 		// Create user
-		res, err := db.UsersCollection.InsertOne(context.Background(), userData)
-		if err != nil {
-			logger.StdErr.Panicln(err)
+		if err := users.Insert(&userData); err != nil {
+			return models.User{}, err
 		}
 
-		userId = res.InsertedID.(primitive.ObjectID)
+		userId = userData.Id
 
 		// slackbot.SendTextMessage(fmt.Sprintf(":wave: %s %s (%s) has joined schej.it!", firstName, lastName, email))
 	} else {
-		var user models.User
-		if err := findResult.Decode(&user); err != nil {
-			logger.StdErr.Panicln(err)
-		}
 		userId = user.Id
 
 		// If user has custom name, do not override first name and last name
@@ -206,7 +227,7 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		if oldCalendarAccount, ok := user.CalendarAccounts[calendarAccountKey]; ok && oldCalendarAccount.SubCalendars != nil {
 			calendarAccount.SubCalendars = oldCalendarAccount.SubCalendars
 		} else {
-			subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList()
+			subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList(c.Request.Context())
 			if err == nil {
 				calendarAccount.SubCalendars = &subCalendars
 			}
@@ -217,29 +238,25 @@ func signInHelper(c *gin.Context, token auth.TokenResponse, tokenOrigin models.T
 		userData.CalendarAccounts[calendarAccountKey] = calendarAccount
 
 		// Update user if exists
-		_, err := db.UsersCollection.UpdateByID(
-			context.Background(),
-			userId,
-			bson.M{"$set": userData},
-		)
-		if err != nil {
-			logger.StdErr.Panicln(err)
+		if err := users.Update(userId, &userData); err != nil {
+			return models.User{}, err
 		}
 	}
 
-	if exists, userId := listmonk.DoesUserExist(email); exists {
-		listmonk.AddUserToListmonk(email, firstName, lastName, picture, userId, true)
+	if exists, userId := listmonk.DoesUserExist(c.Request.Context(), email); exists {
+		listmonk.AddUserToListmonk(c.Request.Context(), email, firstName, lastName, picture, userId, true)
 	} else {
-		listmonk.AddUserToListmonk(email, firstName, lastName, picture, nil, true)
+		listmonk.AddUserToListmonk(c.Request.Context(), email, firstName, lastName, picture, nil, true)
 	}
 
 	// Set session variables
 	session := sessions.Default(c)
 	session.Set("userId", userId.Hex())
+	session.Delete("impersonatorId")
 	session.Save()
 
 	userData.Id = userId
-	return userData
+	return userData, nil
 }
 
 // @Summary Signs user out
@@ -253,6 +270,7 @@ func signOut(c *gin.Context) {
 	// Delete session
 	session := sessions.Default(c)
 	session.Delete("userId")
+	session.Delete("impersonatorId")
 	session.Save()
 
 	c.JSON(http.StatusOK, gin.H{})
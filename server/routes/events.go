@@ -3,8 +3,15 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -25,11 +32,18 @@ import (
 
 // CreateEventRequest represents the request body for creating a new event
 type CreateEventRequest struct {
-	// Required parameters
+	// Required parameters. Duration and type can be left out when creating the event from a template
 	Name     string               `json:"name" binding:"required"`
-	Duration *float32             `json:"duration" binding:"required"`
+	Duration *float32             `json:"duration"`
 	Dates    []primitive.DateTime `json:"dates" binding:"required"`
-	Type     models.EventType     `json:"type" binding:"required"`
+	Type     models.EventType     `json:"type"`
+
+	// The user's template to fill in the parameters that aren't given from
+	TemplateId *string `json:"templateId"`
+
+	// IANA timezone of the event creator (i.e. America/New_York). If given, dates are moved to the same local
+	// time in this timezone on every day
+	Timezone string `json:"timezone"`
 
 	// Only for specific times for specific dates events
 	HasSpecificTimes *bool                `json:"hasSpecificTimes"`
@@ -43,8 +57,9 @@ type CreateEventRequest struct {
 	Location    *string `json:"location"`
 
 	// Only for sign up form events
-	IsSignUpForm *bool                 `json:"isSignUpForm"`
-	SignUpBlocks *[]models.SignUpBlock `json:"signUpBlocks"`
+	IsSignUpForm    *bool                    `json:"isSignUpForm"`
+	SignUpBlocks    *[]models.SignUpBlock    `json:"signUpBlocks"`
+	SignUpQuestions *[]models.SignUpQuestion `json:"signUpQuestions"`
 
 	// Only for events (not groups)
 	StartOnMonday            *bool    `json:"startOnMonday"`
@@ -59,6 +74,9 @@ type CreateEventRequest struct {
 
 	// Only for availability groups
 	Attendees []string `json:"attendees"`
+
+	// Maps remindee or attendee emails to whether they are required or optional. Required if not specified
+	ParticipantRoles map[string]models.ParticipantRole `json:"participantRoles"`
 }
 
 // EditEventRequest represents the request body for editing an event
@@ -69,6 +87,9 @@ type EditEventRequest struct {
 	Dates    []primitive.DateTime `json:"dates" binding:"required"`
 	Type     models.EventType     `json:"type" binding:"required"`
 
+	// IANA timezone of the event (i.e. America/New_York). Keeps the event's timezone if not given
+	Timezone string `json:"timezone"`
+
 	// Only for specific times for specific dates events
 	HasSpecificTimes *bool                `json:"hasSpecificTimes"`
 	Times            []primitive.DateTime `json:"times"`
@@ -78,7 +99,8 @@ type EditEventRequest struct {
 	Location    *string `json:"location"`
 
 	// Only for sign up form events
-	SignUpBlocks *[]models.SignUpBlock `json:"signUpBlocks"`
+	SignUpBlocks    *[]models.SignUpBlock    `json:"signUpBlocks"`
+	SignUpQuestions *[]models.SignUpQuestion `json:"signUpQuestions"`
 
 	// Only for events (not groups)
 	StartOnMonday            *bool    `json:"startOnMonday"`
@@ -91,34 +113,45 @@ type EditEventRequest struct {
 
 	// Only for availability groups
 	Attendees []string `json:"attendees"`
+
+	// Maps remindee or attendee emails to whether they are required or optional. Required if not specified
+	ParticipantRoles map[string]models.ParticipantRole `json:"participantRoles"`
 }
 
 func InitEvents(router *gin.RouterGroup) {
 	eventRouter := router.Group("/events")
 
-	eventRouter.POST("", createEvent)
+	eventRouter.POST("", middleware.RateLimit(createEventPerIp, createEventPerUser), middleware.Captcha(), createEvent)
 	eventRouter.PUT("/:eventId", editEvent)
 	eventRouter.GET("/:eventId", getEvent)
 	eventRouter.GET("/:eventId/responses", getResponses)
-	eventRouter.POST("/:eventId/response", updateEventResponse)
+	eventRouter.GET("/:eventId/aggregates", getAggregates)
+	eventRouter.GET("/:eventId/export", exportEvent)
+	eventRouter.POST("/:eventId/import", importParticipants)
+	eventRouter.POST("/:eventId/response", middleware.RateLimit(guestResponsePerIp, guestResponsePerEvent), middleware.Captcha(), updateEventResponse)
 	eventRouter.DELETE("/:eventId/response", deleteEventResponse)
-	eventRouter.POST("/:eventId/rename-user", renameUser)
-	eventRouter.POST("/:eventId/responded", userResponded)
+	eventRouter.POST("/:eventId/rename-user", middleware.RateLimit(renameUserPerIp, renameUserPerEvent), renameUser)
+	eventRouter.POST("/:eventId/responded", middleware.RateLimit(userRespondedPerIp), userResponded)
 	eventRouter.POST("/:eventId/decline", middleware.AuthRequired(), declineInvite)
 	eventRouter.GET("/:eventId/calendar-availabilities", middleware.AuthRequired(), getCalendarAvailabilities)
 	eventRouter.DELETE("/:eventId", middleware.AuthRequired(), deleteEvent)
 	eventRouter.POST("/:eventId/duplicate", middleware.AuthRequired(), duplicateEvent)
 	eventRouter.POST("/:eventId/archive", middleware.AuthRequired(), archiveEvent)
+	eventRouter.GET("/:eventId/history", getEventHistory)
 	// Note: scheduleEvent does not require auth to allow anyone with the link to schedule
 	eventRouter.POST("/:eventId/schedule-event", scheduleEvent)
 }
 
 // @Summary Creates a new event
+// @Description If templateId is given, the parameters that are left out are filled in from that template of the signed in user
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param payload body CreateEventRequest true "Object containing info about the event to create"
+// @Param X-Captcha-Token header string false "CAPTCHA token, required from users that aren't signed in if CAPTCHA_PROVIDER is set"
 // @Success 201 {object} object{eventId=string}
+// @Failure 403 {object} responses.Error "Error object with the captcha-failed code"
+// @Failure 429 {object} responses.Error "Error object with the rate-limited code"
 // @Router /events [post]
 func createEvent(c *gin.Context) {
 	var payload CreateEventRequest
@@ -127,6 +160,32 @@ func createEvent(c *gin.Context) {
 		return
 	}
 
+	repositories := getRepositories(c)
+
+	// Fill in the event from the template
+	if payload.TemplateId != nil {
+		userId, signedIn := sessions.Default(c).Get("userId").(string)
+		templateId, err := primitive.ObjectIDFromHex(*payload.TemplateId)
+		if !signedIn || err != nil {
+			c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+			return
+		}
+		template, err := repositories.Templates.Get(templateId, utils.StringToObjectID(userId))
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if template == nil {
+			c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+			return
+		}
+		applyEventTemplate(&payload, template)
+	}
+	if payload.Duration == nil || len(payload.Type) == 0 {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "Duration and type are required"})
+		return
+	}
+
 	// Validate field lengths
 	if payload.Description != nil && len(*payload.Description) > 5000 {
 		c.JSON(http.StatusBadRequest, responses.Error{Error: "Description must be less than 5000 characters"})
@@ -136,6 +195,21 @@ func createEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, responses.Error{Error: "Location must be less than 500 characters"})
 		return
 	}
+	if err := utils.ValidateSignUpQuestions(utils.Coalesce(payload.SignUpQuestions)); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+	for _, role := range payload.ParticipantRoles {
+		if role != models.REQUIRED_PARTICIPANT && role != models.OPTIONAL_PARTICIPANT {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: "Participant roles must be either required or optional"})
+			return
+		}
+	}
+	dates, err := normalizeEventDates(payload.Dates, payload.Timezone, payload.DaysOnly, payload.HasSpecificTimes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
 
 	session := sessions.Default(c)
 
@@ -146,7 +220,12 @@ func createEvent(c *gin.Context) {
 	var ownerId primitive.ObjectID
 	if signedIn {
 		ownerId = utils.StringToObjectID(userId)
-		user = db.GetUserById(userId)
+		var err error
+		user, err = repositories.Users.GetById(userId)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 	} else {
 		ownerId = primitive.NilObjectID
 	}
@@ -161,11 +240,13 @@ func createEvent(c *gin.Context) {
 		Description:              payload.Description,
 		Location:                 payload.Location,
 		Duration:                 payload.Duration,
-		Dates:                    payload.Dates,
+		Dates:                    dates,
+		Timezone:                 payload.Timezone,
 		HasSpecificTimes:         payload.HasSpecificTimes,
 		Times:                    payload.Times,
 		IsSignUpForm:             payload.IsSignUpForm,
 		SignUpBlocks:             payload.SignUpBlocks,
+		SignUpQuestions:          payload.SignUpQuestions,
 		StartOnMonday:            payload.StartOnMonday,
 		NotificationsEnabled:     payload.NotificationsEnabled,
 		BlindAvailabilityEnabled: payload.BlindAvailabilityEnabled,
@@ -180,7 +261,11 @@ func createEvent(c *gin.Context) {
 	}
 
 	// Generate short id
-	shortId := db.GenerateShortEventId(event.Id)
+	shortId, err := repositories.Events.GenerateShortId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	event.ShortId = &shortId
 
 	// Schedule reminder emails if remindees array is not empty
@@ -196,9 +281,14 @@ func createEvent(c *gin.Context) {
 		// Schedule email reminders for each of the remindees' emails
 		remindees := make([]models.Remindee, 0)
 		for _, email := range payload.Remindees {
-			taskIds := gcloud.CreateEmailTask(email, ownerName, payload.Name, event.GetId())
+			taskIds, err := gcloud.CreateEmailTask(c.Request.Context(), email, ownerName, payload.Name, event.GetId())
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 			remindees = append(remindees, models.Remindee{
 				Email:     email,
+				Role:      payload.ParticipantRoles[email],
 				TaskIds:   taskIds,
 				Responded: utils.FalsePtr(),
 			})
@@ -244,36 +334,38 @@ func createEvent(c *gin.Context) {
 			}
 
 			// Add attendees to attendees array and send invite emails
-			availabilityGroupInviteEmailId := 9
+			availabilityGroupInviteEmailId := listmonk.GroupInviteEmailId()
 			for _, email := range payload.Attendees {
-				listmonk.SendEmailAddSubscriberIfNotExist(email, availabilityGroupInviteEmailId, bson.M{
+				listmonk.SendEmailAddSubscriberIfNotExist(c.Request.Context(), email, availabilityGroupInviteEmailId, bson.M{
 					"ownerName": ownerName,
 					"groupName": event.Name,
 					"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
 				}, false)
-				attendees = append(attendees, models.Attendee{Email: email, Declined: utils.FalsePtr(), EventId: event.Id})
+				attendees = append(attendees, models.Attendee{Email: email, Role: payload.ParticipantRoles[email], Declined: utils.FalsePtr(), EventId: event.Id})
 			}
 
 		}
 
-		for _, attendee := range attendees {
-			db.AttendeesCollection.InsertOne(context.Background(), attendee)
+		if err := repositories.Attendees.InsertMany(attendees); err != nil {
+			utils.AbortWithError(c, err)
+			return
 		}
 	}
 
 	// Insert event
-	result, err := db.EventsCollection.InsertOne(context.Background(), event)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := repositories.Events.Insert(&event); err != nil {
+		utils.AbortWithError(c, err)
+		return
 	}
-	insertedId := result.InsertedID.(primitive.ObjectID).Hex()
+	insertedId := event.Id.Hex()
 
 	// Send slackbot message
 	// var creator string
 	if signedIn {
 		// creator = fmt.Sprintf("%s %s (%s)", user.FirstName, user.LastName, user.Email)
-		user.NumEventsCreated++
-		db.UsersCollection.UpdateOne(context.Background(), bson.M{"_id": ownerId}, bson.M{"$set": user})
+		if err := repositories.Users.IncrementNumEventsCreated(ownerId); err != nil {
+			utils.GetLogger(c).Error("couldn't count the event the user created", "userId", userId, "error", err)
+		}
 	} else {
 		// creator = "Guest :face_with_open_eyes_and_hand_over_mouth:"
 	}
@@ -292,7 +384,7 @@ func createEvent(c *gin.Context) {
 func editEvent(c *gin.Context) {
 	var payload EditEventRequest
 	if err := c.Bind(&payload); err != nil {
-		logger.StdErr.Println(err)
+		utils.GetLogger(c).Warn("couldn't bind the event", "error", err)
 		return
 	}
 
@@ -305,9 +397,24 @@ func editEvent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, responses.Error{Error: "Location must be less than 500 characters"})
 		return
 	}
+	if err := utils.ValidateSignUpQuestions(utils.Coalesce(payload.SignUpQuestions)); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+	for _, role := range payload.ParticipantRoles {
+		if role != models.REQUIRED_PARTICIPANT && role != models.OPTIONAL_PARTICIPANT {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: "Participant roles must be either required or optional"})
+			return
+		}
+	}
 
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
@@ -331,16 +438,27 @@ func editEvent(c *gin.Context) {
 			return
 		}
 	}
+	original := *event
+
+	if len(payload.Timezone) > 0 {
+		event.Timezone = payload.Timezone
+	}
+	dates, err := normalizeEventDates(payload.Dates, event.Timezone, payload.DaysOnly, payload.HasSpecificTimes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
 
 	// Update event
 	event.Name = payload.Name
 	event.Description = payload.Description
 	event.Location = payload.Location
 	event.Duration = payload.Duration
-	event.Dates = payload.Dates
+	event.Dates = dates
 	event.Times = payload.Times
 	event.HasSpecificTimes = payload.HasSpecificTimes
 	event.SignUpBlocks = payload.SignUpBlocks
+	event.SignUpQuestions = payload.SignUpQuestions
 	event.StartOnMonday = payload.StartOnMonday
 	event.NotificationsEnabled = payload.NotificationsEnabled
 	event.BlindAvailabilityEnabled = payload.BlindAvailabilityEnabled
@@ -360,19 +478,32 @@ func editEvent(c *gin.Context) {
 		if event.OwnerId == primitive.NilObjectID {
 			ownerName = "Somebody"
 		} else {
-			owner := db.GetUserById(event.OwnerId.Hex())
+			owner, err := repositories.Users.GetById(event.OwnerId.Hex())
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 			ownerName = owner.FirstName
 		}
 
 		for _, keptEmail := range kept {
-			updatedRemindees = append(updatedRemindees, origRemindees[keptEmail.Index])
+			remindee := origRemindees[keptEmail.Index]
+			if role, ok := payload.ParticipantRoles[keptEmail.Value]; ok {
+				remindee.Role = role
+			}
+			updatedRemindees = append(updatedRemindees, remindee)
 		}
 
 		for _, addedEmail := range added {
 			// Schedule email tasks
-			taskIds := gcloud.CreateEmailTask(addedEmail.Value, ownerName, event.Name, event.GetId())
+			taskIds, err := gcloud.CreateEmailTask(c.Request.Context(), addedEmail.Value, ownerName, event.Name, event.GetId())
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 			updatedRemindees = append(updatedRemindees, models.Remindee{
 				Email:     addedEmail.Value,
+				Role:      payload.ParticipantRoles[addedEmail.Value],
 				TaskIds:   taskIds,
 				Responded: utils.FalsePtr(),
 			})
@@ -381,7 +512,7 @@ func editEvent(c *gin.Context) {
 		for _, removedEmail := range removed {
 			// Delete email tasks
 			for _, taskId := range origRemindees[removedEmail.Index].TaskIds {
-				gcloud.DeleteEmailTask(taskId)
+				gcloud.DeleteEmailTask(c.Request.Context(), taskId)
 			}
 		}
 
@@ -390,71 +521,110 @@ func editEvent(c *gin.Context) {
 
 	// Update attendees
 	if event.Type == models.GROUP {
-		origAttendees := db.GetAttendees(event.Id.Hex())
+		origAttendees, err := repositories.Attendees.GetByEventId(event.Id)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 		added, removed, kept := utils.FindAddedRemovedKept(payload.Attendees, utils.Map(origAttendees, func(a models.Attendee) string { return a.Email }))
 
 		// Determine owner name
 		var ownerName string
 		var owner *models.User
 		if event.OwnerId != primitive.NilObjectID {
-			owner = db.GetUserById(event.OwnerId.Hex())
+			owner, err = repositories.Users.GetById(event.OwnerId.Hex())
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 			ownerName = owner.FirstName
 		} else {
 			ownerName = "Somebody"
 		}
 
 		if len(removed) > 0 {
-			eventResponses := db.GetEventResponses(event.Id.Hex())
+			eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 
 			// Remove user from responses map
 			for _, removedEmail := range removed {
 				// Only delete response if it isn't the owner of the group
 				if removedEmail.Value != utils.Coalesce(owner).Email {
-					removedUser := db.GetUserByEmail(removedEmail.Value)
+					removedUser, err := repositories.Users.GetByEmail(removedEmail.Value)
+					if err != nil {
+						utils.AbortWithError(c, err)
+						return
+					}
 					if removedUser != nil {
 						// Remove response from array
 						for i := range eventResponses {
 							if eventResponses[i].UserId == removedUser.Id.Hex() {
-								db.EventResponsesCollection.DeleteOne(context.Background(), bson.M{
-									"_id": eventResponses[i].Id,
-								})
-								*event.NumResponses--
+								if _, err := deleteEventResponseAndCount(repositories, event, &eventResponses[i]); err != nil {
+									utils.AbortWithError(c, err)
+									return
+								}
 								break
 							}
 						}
 					}
 
 					// Remove attendee from attendees collection
-					db.AttendeesCollection.DeleteOne(context.Background(), bson.M{
-						"email":   removedEmail.Value,
-						"eventId": event.Id,
-					})
+					if err := repositories.Attendees.Delete(event.Id, removedEmail.Value); err != nil {
+						utils.AbortWithError(c, err)
+						return
+					}
+					recordEventHistory(c, event.Id, models.RemoveAttendeeAction, map[string]interface{}{
+						"email": removedEmail.Value,
+						"role":  origAttendees[removedEmail.Index].Role,
+					}, nil)
 				}
 			}
 		}
 
 		for _, addedEmail := range added {
 			// Send invite email
-			availabilityGroupInviteEmailId := 9
-			listmonk.SendEmailAddSubscriberIfNotExist(addedEmail.Value, availabilityGroupInviteEmailId, bson.M{
+			availabilityGroupInviteEmailId := listmonk.GroupInviteEmailId()
+			listmonk.SendEmailAddSubscriberIfNotExist(c.Request.Context(), addedEmail.Value, availabilityGroupInviteEmailId, bson.M{
 				"ownerName": ownerName,
 				"groupName": event.Name,
 				"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
 			}, false)
-			db.AttendeesCollection.InsertOne(context.Background(), models.Attendee{
+			if err := repositories.Attendees.Insert(&models.Attendee{
 				Email:    addedEmail.Value,
+				Role:     payload.ParticipantRoles[addedEmail.Value],
 				Declined: utils.FalsePtr(),
 				EventId:  event.Id,
+			}); err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+			recordEventHistory(c, event.Id, models.AddAttendeeAction, nil, map[string]interface{}{
+				"email": addedEmail.Value,
+				"role":  payload.ParticipantRoles[addedEmail.Value],
 			})
 		}
 
+		// Update the roles of attendees that were already in the group
+		for _, keptEmail := range kept {
+			role, ok := payload.ParticipantRoles[keptEmail.Value]
+			if ok && role != origAttendees[keptEmail.Index].Role {
+				if err := repositories.Attendees.SetRole(event.Id, keptEmail.Value, role); err != nil {
+					utils.AbortWithError(c, err)
+					return
+				}
+			}
+		}
+
 		// Send group update emails
 		if len(added) > 0 {
 			emails := utils.Map(added, func(a utils.ElementWithIndex[string]) string { return a.Value })
 			addedAttendeeEmailId := 11
 
 			for _, keptEmail := range kept {
-				listmonk.SendEmailAddSubscriberIfNotExist(keptEmail.Value, addedAttendeeEmailId, bson.M{
+				listmonk.SendEmailAddSubscriberIfNotExist(c.Request.Context(), keptEmail.Value, addedAttendeeEmailId, bson.M{
 					"ownerName": ownerName,
 					"groupName": event.Name,
 					"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
@@ -465,18 +635,35 @@ func editEvent(c *gin.Context) {
 	}
 
 	// Update event object
-	_, err := db.EventsCollection.UpdateOne(
-		context.Background(),
-		bson.M{
-			"_id": event.Id,
-		},
-		bson.M{
-			"$set": event,
-		},
-	)
+	if err := repositories.Events.Update(event); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	// Keep the availability in the same slots of the days that were moved to the event's timezone
+	if err := shiftResponsesWithDates(repositories, event.Id, original.Dates, payload.Dates, dates); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
+	before, after, err := utils.DiffFields(&original, event, eventHistoryFields)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		utils.AbortWithError(c, err)
+		return
+	}
+	origRemindeeEmails := getRemindeeEmails(&original)
+	remindeeEmails := getRemindeeEmails(event)
+	if !reflect.DeepEqual(origRemindeeEmails, remindeeEmails) {
+		before["remindees"] = origRemindeeEmails
+		after["remindees"] = remindeeEmails
+	}
+	if len(before) > 0 {
+		recordEventHistory(c, event.Id, models.EditEventAction, before, after)
+	}
+
+	// Capacities might have gone up, so give open spots to users on the waitlist
+	if utils.Coalesce(event.IsSignUpForm) {
+		promoteSignUpWaitlists(c, repositories, event.Id, utils.Map(utils.Coalesce(event.SignUpBlocks), func(b models.SignUpBlock) primitive.ObjectID { return b.Id }))
 	}
 
 	c.Status(http.StatusOK)
@@ -490,13 +677,22 @@ func editEvent(c *gin.Context) {
 // @Router /events/{eventId} [get]
 func getEvent(c *gin.Context) {
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
 	}
-	eventResponses := db.GetEventResponses(event.Id.Hex())
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	// Convert to old format for backward compatibility
 	utils.ConvertEventToOldFormat(event, eventResponses)
@@ -506,7 +702,11 @@ func getEvent(c *gin.Context) {
 
 	// Populate user fields
 	for userId, response := range responsesMap {
-		user := db.GetUserById(userId)
+		user, err := repositories.Users.GetById(userId)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 		if user == nil {
 			if len(response.Name) == 0 {
 				// User was deleted
@@ -523,6 +723,12 @@ func getEvent(c *gin.Context) {
 		} else {
 			response.User = user
 			response.User.CalendarAccounts = nil
+			response.User.Role = models.RegularUser
+
+			// Responses from before timezones were stored are shown in the user's current timezone
+			if len(response.Timezone) == 0 {
+				response.Timezone = user.Timezone
+			}
 		}
 		responsesMap[userId] = response
 
@@ -534,7 +740,11 @@ func getEvent(c *gin.Context) {
 
 	// Populate sign up form fields
 	for userId, response := range event.SignUpResponses {
-		user := db.GetUserById(userId)
+		user, err := repositories.Users.GetById(userId)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 		if user == nil {
 			if len(response.Name) == 0 {
 				// User was deleted
@@ -550,12 +760,17 @@ func getEvent(c *gin.Context) {
 			}
 		} else {
 			response.User = user
+			response.User.Role = models.RegularUser
 		}
 		event.SignUpResponses[userId] = response
 	}
 
 	if event.Type == models.GROUP {
-		attendees := db.GetAttendees(event.Id.Hex())
+		attendees, err := repositories.Attendees.GetByEventId(event.Id)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 		event.Attendees = &attendees
 	}
 
@@ -583,14 +798,23 @@ func getResponses(c *gin.Context) {
 
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
 	}
 
 	// Convert to map format and filter availability
-	eventResponses := db.GetEventResponses(event.Id.Hex())
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	responsesMap := getResponsesMap(eventResponses)
 
 	// Filter availability slice based on timeMin and timeMax
@@ -624,13 +848,138 @@ func getResponses(c *gin.Context) {
 	c.JSON(http.StatusOK, responsesMap)
 }
 
+// @Summary Gets the number of required and optional respondents available at each time slot of an event
+// @Description Required remindees (for events) or attendees (for groups) that haven't responded count as missing. The ranking only has the slots where no required participant is missing, with the most available respondents first
+// @Tags events
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Param timeMin query string true "Lower bound for the time slots"
+// @Param timeMax query string true "Upper bound for the time slots"
+// @Success 200 {object} object{aggregates=[]utils.SlotAggregate,ranking=[]utils.SlotAggregate}
+// @Router /events/{eventId}/aggregates [get]
+func getAggregates(c *gin.Context) {
+	payload := struct {
+		TimeMin time.Time `form:"timeMin" binding:"required"`
+		TimeMax time.Time `form:"timeMax" binding:"required"`
+	}{}
+	if err := c.Bind(&payload); err != nil {
+		return
+	}
+
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(c.Param("eventId"))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	slots := make([]primitive.DateTime, 0)
+	for _, slot := range utils.GetEventTimeSlots(event) {
+		if slot.Time().Compare(payload.TimeMin) >= 0 && slot.Time().Compare(payload.TimeMax) <= 0 {
+			slots = append(slots, slot)
+		}
+	}
+
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	responsesMap := getResponsesMap(eventResponses)
+	roles, numRequiredNotResponded, err := getRespondentRoles(repositories, event, responsesMap)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	aggregates := utils.AggregateAvailability(slots, responsesMap, roles, numRequiredNotResponded)
+	c.JSON(http.StatusOK, gin.H{
+		"aggregates": aggregates,
+		"ranking":    utils.RankSlots(aggregates),
+	})
+}
+
+// Returns the role of each respondent based on the remindees (for events) or attendees (for groups) with the same email,
+// along with the number of required remindees or attendees that haven't responded. Respondents that weren't invited are
+// optional
+func getRespondentRoles(repositories *db.Repositories, event *models.Event, responsesMap map[string]*models.Response) (map[string]models.ParticipantRole, int, error) {
+	invitedRoles := make(map[string]models.ParticipantRole)
+	if event.Type == models.GROUP {
+		attendees, err := repositories.Attendees.GetByEventId(event.Id)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, attendee := range attendees {
+			invitedRoles[strings.ToLower(attendee.Email)] = attendee.Role
+		}
+	} else {
+		for _, remindee := range utils.Coalesce(event.Remindees) {
+			invitedRoles[strings.ToLower(remindee.Email)] = remindee.Role
+		}
+	}
+
+	// Look up the emails of the signed in respondents all at once
+	userIds := make([]primitive.ObjectID, 0)
+	for userId := range responsesMap {
+		if objectId, err := primitive.ObjectIDFromHex(userId); err == nil {
+			userIds = append(userIds, objectId)
+		}
+	}
+	users, err := repositories.Users.GetByIds(userIds)
+	if err != nil {
+		return nil, 0, err
+	}
+	userEmails := make(map[string]string)
+	for _, user := range users {
+		userEmails[user.Id.Hex()] = user.Email
+	}
+
+	roles := make(map[string]models.ParticipantRole)
+	responded := make(models.Set[string])
+	for userId, response := range responsesMap {
+		email := response.Email
+		if userEmail, ok := userEmails[userId]; ok {
+			email = userEmail
+		}
+		email = strings.ToLower(email)
+
+		role, invited := invitedRoles[email]
+		if !invited || len(email) == 0 {
+			roles[userId] = models.OPTIONAL_PARTICIPANT
+			continue
+		}
+		responded[email] = struct{}{}
+		if len(role) == 0 {
+			roles[userId] = models.REQUIRED_PARTICIPANT
+		} else {
+			roles[userId] = role
+		}
+	}
+
+	numRequiredNotResponded := 0
+	for email, role := range invitedRoles {
+		if _, ok := responded[email]; !ok && role != models.OPTIONAL_PARTICIPANT {
+			numRequiredNotResponded++
+		}
+	}
+
+	return roles, numRequiredNotResponded, nil
+}
+
 // @Summary Updates the current user's availability
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param eventId path string true "Event ID"
-// @Param payload body object{availability=[]string,ifNeeded=[]string,guest=bool,name=string,useCalendarAvailability=bool,enabledCalendars=map[string][]string,manualAvailability=map[string][]string,calendarOptions=models.CalendarOptions,signUpBlockIds=[]string} true "Object containing info about the event response to update"
+// @Param payload body object{availability=[]string,ifNeeded=[]string,guest=bool,name=string,useCalendarAvailability=bool,enabledCalendars=map[string][]string,manualAvailability=map[string][]string,calendarOptions=models.CalendarOptions,signUpBlockIds=[]string,answers=[]models.SignUpAnswer,timezone=string} true "Object containing info about the event response to update"
+// @Param X-Captcha-Token header string false "CAPTCHA token, required from guests if CAPTCHA_PROVIDER is set"
 // @Success 200
+// @Failure 403 {object} responses.Error "Error object with the captcha-failed code, or too-many-guest-responses if MAX_GUEST_RESPONSES_PER_EVENT guests have responded"
+// @Failure 429 {object} responses.Error "Error object with the rate-limited code"
 // @Router /events/{eventId}/response [post]
 func updateEventResponse(c *gin.Context) {
 	payload := struct {
@@ -649,31 +998,68 @@ func updateEventResponse(c *gin.Context) {
 		CalendarOptions         *models.CalendarOptions                      `json:"calendarOptions"`
 
 		// Sign up form variables
-		SignUpBlockIds []primitive.ObjectID `json:"signUpBlockIds"`
+		SignUpBlockIds []primitive.ObjectID  `json:"signUpBlockIds"`
+		Answers        []models.SignUpAnswer `json:"answers"`
+
+		// IANA timezone the availability was given in. Defaults to the signed in user's timezone
+		Timezone string `json:"timezone"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
 		return
 	}
+	if len(payload.Timezone) > 0 {
+		if _, err := utils.LoadTimezone(payload.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+			return
+		}
+	}
+	repositories := getRepositories(c)
 	session := sessions.Default(c)
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
 	}
-	eventResponses := db.GetEventResponses(event.Id.Hex())
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	var userIdString string
 	var userHasResponded bool
+	var numResponses int // The number of responses after this one is saved
 	if !utils.Coalesce(event.IsSignUpForm) {
 		// Populate response differently if guest vs signed in user
 		var response models.Response
+		var guestResponseClaimed bool
 		if *payload.Guest {
 			userIdString = payload.Name
 
+			// Limit how many guests can respond, so that an event can't be flooded with responses. New guests are
+			// counted before their response is saved, so that concurrent guests can't go past the limit
+			if _, existingResponse := findResponse(eventResponses, userIdString); existingResponse == nil {
+				claimed, err := repositories.Events.ClaimGuestResponse(event.Id, getMaxGuestResponses())
+				if err != nil {
+					utils.AbortWithError(c, err)
+					return
+				}
+				if !claimed {
+					utils.AbortWithError(c, errTooManyGuestResponses)
+					return
+				}
+				guestResponseClaimed = true
+			}
+
 			response = models.Response{
 				Name:         payload.Name,
 				Email:        payload.Email,
+				Timezone:     payload.Timezone,
 				Availability: payload.Availability,
 				IfNeeded:     payload.IfNeeded,
 			}
@@ -696,19 +1082,25 @@ func updateEventResponse(c *gin.Context) {
 				CalendarOptions:         payload.CalendarOptions,
 			}
 
+			user, err := repositories.Users.GetById(userIdString)
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+			if len(payload.Timezone) > 0 {
+				response.Timezone = payload.Timezone
+			} else if user != nil {
+				response.Timezone = user.Timezone
+			}
+
 			if event.Type == models.GROUP {
-				user := db.GetUserById(userIdString)
 
 				// Set declined to false (in case user declined group in the past)
 				if user != nil {
-					db.AttendeesCollection.UpdateOne(context.Background(), bson.M{
-						"email":   user.Email,
-						"eventId": event.Id,
-					}, bson.M{
-						"$set": bson.M{
-							"declined": false,
-						},
-					})
+					if err := repositories.Attendees.SetDeclined(event.Id, user.Email, false); err != nil {
+						utils.AbortWithError(c, err)
+						return
+					}
 				}
 
 				// Update manual availability
@@ -748,26 +1140,29 @@ func updateEventResponse(c *gin.Context) {
 			}
 		}
 
-		// Check if user has responded to event before (edit response) or not (new response)
-		idx, _ := findResponse(eventResponses, userIdString)
-		userHasResponded = idx != -1
-
-		// Update event responses
-		if userHasResponded {
-			db.EventResponsesCollection.UpdateOne(context.Background(), bson.M{
-				"_id": eventResponses[idx].Id,
-			}, bson.M{
-				"$set": bson.M{
-					"response": &response,
-				},
-			})
-		} else {
-			db.EventResponsesCollection.InsertOne(context.Background(), models.EventResponse{
-				UserId:   userIdString,
-				Response: &response,
-				EventId:  event.Id,
-			})
-			*event.NumResponses++
+		// Update event responses, keeping track of whether this is a new response or an edited one
+		created, err := repositories.Responses.Upsert(event.Id, userIdString, &response)
+		if guestResponseClaimed && !created {
+			// The response wasn't saved, or a guest with the same name saved theirs first
+			if err := repositories.Events.ReleaseGuestResponse(event.Id); err != nil {
+				utils.GetLogger(c).Error("couldn't uncount the guest response", "eventId", event.Id.Hex(), "error", err)
+			}
+		}
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		userHasResponded = !created
+
+		// Count the response with an atomic increment so that concurrent responses aren't lost
+		numResponses = utils.Coalesce(event.NumResponses)
+		if created {
+			numResponses, err = repositories.Events.IncrementNumResponses(event.Id, 1)
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+			event.NumResponses = &numResponses
 		}
 	} else {
 		var response models.SignUpResponse
@@ -796,28 +1191,55 @@ func updateEventResponse(c *gin.Context) {
 			}
 		}
 
+		// Make sure all the sign up blocks exist
+		for _, signUpBlockId := range payload.SignUpBlockIds {
+			if utils.FindSignUpBlock(event, signUpBlockId) == nil {
+				c.JSON(http.StatusBadRequest, responses.Error{Error: errs.SignUpBlockNotFound})
+				return
+			}
+		}
+
+		// Validate the answers to the sign up questions
+		answers, err := utils.ValidateSignUpAnswers(event, payload.SignUpBlockIds, payload.Answers)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+			return
+		}
+		response.Answers = answers
+
 		// Check if user has responded to event before (edit response) or not (new response)
 		_, userHasResponded = event.SignUpResponses[userIdString]
+		numResponses = len(event.SignUpResponses)
+		if !userHasResponded {
+			numResponses++
+		}
 
-		// Update event responses
-		if event.SignUpResponses == nil {
-			event.SignUpResponses = make(map[string]*models.SignUpResponse)
+		// Sign user up for the blocks that have room, and waitlist them for the rest
+		freedSignUpBlockIds, err := signUpForBlocks(repositories, event, userIdString, &response, getMaxGuestResponses())
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
 		}
-		event.SignUpResponses[userIdString] = &response
+		promoteSignUpWaitlists(c, repositories, event.Id, freedSignUpBlockIds)
 	}
 
 	// Send notification emails
 	if (utils.Coalesce(event.NotificationsEnabled) || event.Type == models.GROUP) && !userHasResponded && userIdString != event.OwnerId.Hex() {
 		// Send email asynchronously
+		log := utils.GetLogger(c)
 		go func() {
 			// Recover from panics
 			defer func() {
 				if err := recover(); err != nil {
-					logger.StdErr.Println(err)
+					log.Error("panic while sending the notification email", "eventId", event.Id.Hex(), "error", fmt.Sprint(err))
 				}
 			}()
 
-			creator := db.GetUserById(event.OwnerId.Hex())
+			creator, err := repositories.Users.GetById(event.OwnerId.Hex())
+			if err != nil {
+				log.Error("couldn't get the event creator to send the notification email to", "eventId", event.Id.Hex(), "error", err)
+				return
+			}
 			if creator == nil {
 				return
 			}
@@ -826,13 +1248,17 @@ func updateEventResponse(c *gin.Context) {
 			if *payload.Guest {
 				respondentName = payload.Name
 			} else {
-				respondent := db.GetUserById(userIdString)
+				respondent, err := repositories.Users.GetById(userIdString)
+				if err != nil {
+					log.Error("couldn't get the respondent to send the notification email about", "eventId", event.Id.Hex(), "userId", userIdString, "error", err)
+					return
+				}
 				respondentName = fmt.Sprintf("%s %s", respondent.FirstName, respondent.LastName)
 			}
 
 			if event.Type == models.GROUP {
 				someoneRespondedEmailId := 13
-				listmonk.SendEmail(creator.Email, someoneRespondedEmailId, bson.M{
+				listmonk.SendEmail(context.Background(), creator.Email, someoneRespondedEmailId, bson.M{
 					"groupName":      event.Name,
 					"ownerName":      creator.FirstName,
 					"respondentName": respondentName,
@@ -840,7 +1266,7 @@ func updateEventResponse(c *gin.Context) {
 				})
 			} else {
 				someoneRespondedEmailId := 10
-				listmonk.SendEmail(creator.Email, someoneRespondedEmailId, bson.M{
+				listmonk.SendEmail(context.Background(), creator.Email, someoneRespondedEmailId, bson.M{
 					"eventName":      event.Name,
 					"ownerName":      creator.FirstName,
 					"respondentName": respondentName,
@@ -852,42 +1278,44 @@ func updateEventResponse(c *gin.Context) {
 
 	// Send email after X responses
 	sendEmailAfterXResponses := utils.Coalesce(event.SendEmailAfterXResponses)
-	if sendEmailAfterXResponses > 0 && !userHasResponded && sendEmailAfterXResponses == len(eventResponses)+1 { // We add 1 because eventResponses is the old event responses before the current user is added
-		// Set SendEmailAfterXResponses variable to -1 to prevent additional emails from being sent
-		*event.SendEmailAfterXResponses = -1
-
-		// Send email asynchronously
-		go func() {
-			// Recover from panics
-			defer func() {
-				if err := recover(); err != nil {
-					logger.StdErr.Println(err)
-				}
-			}()
+	if sendEmailAfterXResponses > 0 && !userHasResponded && sendEmailAfterXResponses == numResponses {
+		// Set sendEmailAfterXResponses to -1 to prevent additional emails from being sent. Only the request that sets it
+		// sends the email, in case several people respond at once
+		claimed, err := repositories.Events.ClaimSendEmailAfterXResponses(event.Id, sendEmailAfterXResponses)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 
-			creator := db.GetUserById(event.OwnerId.Hex())
-			if creator == nil {
-				return
-			}
+		if claimed {
+			// Send email asynchronously
+			log := utils.GetLogger(c)
+			go func() {
+				// Recover from panics
+				defer func() {
+					if err := recover(); err != nil {
+						log.Error("panic while sending the notification email", "eventId", event.Id.Hex(), "error", fmt.Sprint(err))
+					}
+				}()
 
-			sendEmailAfterXResponsesEmailId := 14
-			listmonk.SendEmail(creator.Email, sendEmailAfterXResponsesEmailId, bson.M{
-				"eventName":    event.Name,
-				"ownerName":    creator.FirstName,
-				"eventUrl":     fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
-				"numResponses": len(eventResponses) + 1, // We add 1 because eventResponses is the old event responses before the current user is added
-			})
-		}()
-	}
+				creator, err := repositories.Users.GetById(event.OwnerId.Hex())
+				if err != nil {
+					log.Error("couldn't get the event creator to send the notification email to", "eventId", event.Id.Hex(), "error", err)
+					return
+				}
+				if creator == nil {
+					return
+				}
 
-	// Update event in mongodb
-	_, err := db.EventsCollection.UpdateByID(
-		context.Background(),
-		event.Id,
-		bson.M{"$set": event},
-	)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+				sendEmailAfterXResponsesEmailId := 14
+				listmonk.SendEmail(context.Background(), creator.Email, sendEmailAfterXResponsesEmailId, bson.M{
+					"eventName":    event.Name,
+					"ownerName":    creator.FirstName,
+					"eventUrl":     fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
+					"numResponses": numResponses,
+				})
+			}()
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
@@ -910,26 +1338,38 @@ func deleteEventResponse(c *gin.Context) {
 	if err := c.Bind(&payload); err != nil {
 		return
 	}
+	repositories := getRepositories(c)
 	session := sessions.Default(c)
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
 	}
-	eventResponses := db.GetEventResponses(event.Id.Hex())
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	if *payload.Guest {
 		if utils.Coalesce(event.IsSignUpForm) {
-			delete(event.SignUpResponses, payload.Name)
+			if err := withdrawSignUpResponse(c, repositories, event, payload.Name); err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 		} else {
 			// Remove response from array
 			for i := range eventResponses {
 				if eventResponses[i].Response.Name == payload.Name {
-					db.EventResponsesCollection.DeleteOne(context.Background(), bson.M{
-						"_id": eventResponses[i].Id,
-					})
-					*event.NumResponses--
+					if err := deleteEventResponseAndRecord(c, repositories, event, &eventResponses[i]); err != nil {
+						utils.AbortWithError(c, err)
+						return
+					}
 					break
 				}
 			}
@@ -951,15 +1391,18 @@ func deleteEventResponse(c *gin.Context) {
 		}
 
 		if utils.Coalesce(event.IsSignUpForm) {
-			delete(event.SignUpResponses, payload.UserId)
+			if err := withdrawSignUpResponse(c, repositories, event, payload.UserId); err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 		} else {
 			// Remove response from array
 			for i := range eventResponses {
 				if eventResponses[i].UserId == payload.UserId {
-					db.EventResponsesCollection.DeleteOne(context.Background(), bson.M{
-						"_id": eventResponses[i].Id,
-					})
-					*event.NumResponses--
+					if err := deleteEventResponseAndRecord(c, repositories, event, &eventResponses[i]); err != nil {
+						utils.AbortWithError(c, err)
+						return
+					}
 					break
 				}
 			}
@@ -967,32 +1410,20 @@ func deleteEventResponse(c *gin.Context) {
 
 		// If this event is a Group, also make the attendee "leave the group" by setting "declined" to true
 		if event.Type == models.GROUP {
-			user := db.GetUserById(userIdString)
+			user, err := repositories.Users.GetById(userIdString)
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 			if user != nil {
-				db.AttendeesCollection.UpdateOne(context.Background(), bson.M{
-					"email":   user.Email,
-					"eventId": event.Id,
-				}, bson.M{
-					"$set": bson.M{
-						"declined": true,
-					},
-				})
+				if err := repositories.Attendees.SetDeclined(event.Id, user.Email, true); err != nil {
+					utils.AbortWithError(c, err)
+					return
+				}
 			}
 		}
 	}
 
-	// Update responses in mongodb
-	_, err := db.EventsCollection.UpdateByID(
-		context.Background(),
-		event.Id,
-		bson.M{
-			"$set": event,
-		},
-	)
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-
 	c.JSON(http.StatusOK, gin.H{})
 }
 
@@ -1013,14 +1444,26 @@ func renameUser(c *gin.Context) {
 		return
 	}
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
 	}
 
 	// Check if old name is a guest response
-	db.UpdateGuestResponseName(event.Id.Hex(), payload.OldName, payload.NewName)
+	renamed, err := repositories.Responses.RenameGuest(event.Id, payload.OldName, payload.NewName)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if renamed {
+		recordEventHistory(c, event.Id, models.RenameResponseAction, map[string]interface{}{"name": payload.OldName}, map[string]interface{}{"name": payload.NewName})
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -1043,7 +1486,12 @@ func userResponded(c *gin.Context) {
 
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
@@ -1070,13 +1518,14 @@ func userResponded(c *gin.Context) {
 
 	// Delete the reminder email tasks
 	for _, taskId := range (*event.Remindees)[index].TaskIds {
-		gcloud.DeleteEmailTask(taskId)
+		gcloud.DeleteEmailTask(c.Request.Context(), taskId)
 	}
 
 	// Update event in database
-	db.EventsCollection.UpdateByID(context.Background(), event.Id, bson.M{
-		"$set": event,
-	})
+	if err := repositories.Events.SetFields(event.Id, bson.M{"remindees": event.Remindees}); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	// Email owner of event if all remindees have responded
 	everyoneResponded := true
@@ -1088,7 +1537,11 @@ func userResponded(c *gin.Context) {
 	}
 	if everyoneResponded {
 		// Get owner
-		owner := db.GetUserById(event.OwnerId.Hex())
+		owner, err := repositories.Users.GetById(event.OwnerId.Hex())
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 
 		// Get event url
 		baseUrl := utils.GetBaseUrl()
@@ -1096,7 +1549,7 @@ func userResponded(c *gin.Context) {
 
 		// Send email
 		everyoneRespondedEmailTemplateId := 8
-		listmonk.SendEmail(owner.Email, everyoneRespondedEmailTemplateId, bson.M{
+		listmonk.SendEmail(c.Request.Context(), owner.Email, everyoneRespondedEmailTemplateId, bson.M{
 			"eventName": event.Name,
 			"eventUrl":  eventUrl,
 		})
@@ -1115,7 +1568,12 @@ func userResponded(c *gin.Context) {
 func declineInvite(c *gin.Context) {
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventById(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetById(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
@@ -1132,10 +1590,11 @@ func declineInvite(c *gin.Context) {
 	user := userInterface.(*models.User)
 
 	// Check if user is in attendees array
-	attendee := db.AttendeesCollection.FindOne(context.Background(), bson.M{
-		"email":   user.Email,
-		"eventId": event.Id,
-	})
+	attendee, err := repositories.Attendees.GetByEmail(event.Id, user.Email)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if attendee == nil {
 		// User not in attendees array
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.AttendeeEmailNotFound})
@@ -1143,32 +1602,31 @@ func declineInvite(c *gin.Context) {
 	}
 
 	// Decline invite
-	db.AttendeesCollection.UpdateOne(context.Background(), bson.M{
-		"email":   user.Email,
-		"eventId": event.Id,
-	}, bson.M{
-		"$set": bson.M{
-			"declined": true,
-		},
-	})
+	if err := repositories.Attendees.SetDeclined(event.Id, user.Email, true); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
 // @Summary Return a map mapping user id to their calendar events that they have enabled for the given time range
+// @Description Responds with the calendar events fetched within 20 seconds. Users whose calendar events weren't fetched in time are left out, with a timeout error for each of their accounts
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param eventId path string true "Event ID"
 // @Param timeMin query string true "Lower bound for event's start time to filter by"
 // @Param timeMax query string true "Upper bound for event's end time to filter by"
-// @Success 200 {object} map[string]map[string]calendar.CalendarEventsWithError
+// @Param includeErrors query bool false "Whether to respond with {calendarEvents, errors}, where errors maps user id to the errors of the calendar accounts whose events couldn't all be fetched"
+// @Success 200 {object} map[string][]models.CalendarEvent
 // @Router /events/{eventId}/calendar-availabilities [get]
 func getCalendarAvailabilities(c *gin.Context) {
 	// Bind query parameters
 	payload := struct {
-		TimeMin time.Time `form:"timeMin" binding:"required"`
-		TimeMax time.Time `form:"timeMax" binding:"required"`
+		TimeMin       time.Time `form:"timeMin" binding:"required"`
+		TimeMax       time.Time `form:"timeMax" binding:"required"`
+		IncludeErrors bool      `form:"includeErrors"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
 		return
@@ -1176,7 +1634,12 @@ func getCalendarAvailabilities(c *gin.Context) {
 
 	// Fetch event
 	eventId := c.Param("eventId")
-	event := db.GetEventById(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetById(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
@@ -1188,55 +1651,115 @@ func getCalendarAvailabilities(c *gin.Context) {
 		return
 	}
 
-	// Get calendar events for each response that has calendar availability enabled
-	numCalendarEventsRequests := 0
-	calendarEventsChan := make(chan struct {
+	// Get calendar events for each response that has calendar availability enabled, a few users at a time. The
+	// requests are canceled if the client disconnects, or once calendarAvailabilitiesTimeout is up
+	ctx, cancel := context.WithTimeout(c.Request.Context(), calendarAvailabilitiesTimeout)
+	defer cancel()
+	type calendarAvailabilityRequest struct {
+		UserId          string
+		User            *models.User
+		EnabledAccounts []string
+	}
+	type userCalendarEvents struct {
 		UserId string
 		Events map[string]calendar.CalendarEventsWithError
-	})
+	}
 
-	eventResponses := db.GetEventResponses(event.Id.Hex())
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	requests := make([]calendarAvailabilityRequest, 0)
 	for _, eventResponse := range eventResponses {
 		if utils.Coalesce(eventResponse.Response.UseCalendarAvailability) {
-			user := db.GetUserById(eventResponse.UserId)
+			user, err := repositories.Users.GetById(eventResponse.UserId)
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
 			if user != nil {
-				numCalendarEventsRequests++
-
 				// Construct enabled accounts set
 				enabledAccounts := make([]string, 0)
 				for calendarAccountKey := range utils.Coalesce(eventResponse.Response.EnabledCalendars) {
 					enabledAccounts = append(enabledAccounts, calendarAccountKey)
 				}
 
-				// Fetch calendar events
-				go func(userId string) {
-					// Recover from panics
-					defer func() {
-						if err := recover(); err != nil {
-							logger.StdErr.Println(err)
-						}
-					}()
-
-					calendarEvents, _ := calendar.GetUsersCalendarEvents(user, utils.ArrayToSet(enabledAccounts), payload.TimeMin, payload.TimeMax)
-					calendarEventsChan <- struct {
-						UserId string
-						Events map[string]calendar.CalendarEventsWithError
-					}{
-						UserId: userId,
-						Events: calendarEvents,
-					}
-				}(eventResponse.UserId)
+				requests = append(requests, calendarAvailabilityRequest{UserId: eventResponse.UserId, User: user, EnabledAccounts: enabledAccounts})
 			}
 		}
 	}
 
-	// Create a map mapping user id to the calendar events of that user
+	// Fetch calendar events
+	requestsChan := make(chan calendarAvailabilityRequest)
+	calendarEventsChan := make(chan userCalendarEvents)
+	go func() {
+		defer close(requestsChan)
+		for _, request := range requests {
+			select {
+			case requestsChan <- request:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	log := utils.GetLogger(c)
+	for i := 0; i < maxConcurrentCalendarAvailabilities && i < len(requests); i++ {
+		go func() {
+			for request := range requestsChan {
+				events := fetchCalendarAvailability(ctx, log, request.User, request.EnabledAccounts, payload.TimeMin, payload.TimeMax)
+				select {
+				case calendarEventsChan <- userCalendarEvents{UserId: request.UserId, Events: events}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Create a map mapping user id to the calendar events of that user, and one mapping user id to the errors of
+	// the calendar accounts whose events couldn't all be fetched
 	userIdToCalendarEvents := make(map[string][]models.CalendarEvent)
-	for i := 0; i < numCalendarEventsRequests; i++ {
-		calendarEvents := <-calendarEventsChan
+	userIdToErrors := make(map[string]map[string]*errs.Error)
+	timedOut := false
+	for range requests {
+		var calendarEvents userCalendarEvents
+		select {
+		case calendarEvents = <-calendarEventsChan:
+		case <-ctx.Done():
+			if err := c.Request.Context().Err(); err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+			timedOut = true
+		}
+		if timedOut {
+			break
+		}
 		userIdToCalendarEvents[calendarEvents.UserId] = make([]models.CalendarEvent, 0)
-		for _, events := range calendarEvents.Events {
+		for calendarAccountKey, events := range calendarEvents.Events {
 			userIdToCalendarEvents[calendarEvents.UserId] = append(userIdToCalendarEvents[calendarEvents.UserId], events.CalendarEvents...)
+			if events.Error != nil {
+				if _, ok := userIdToErrors[calendarEvents.UserId]; !ok {
+					userIdToErrors[calendarEvents.UserId] = make(map[string]*errs.Error)
+				}
+				userIdToErrors[calendarEvents.UserId][calendarAccountKey] = events.Error
+			}
+		}
+	}
+
+	// Respond with the calendar events that were fetched in time, and a timeout error for each account of the
+	// users whose events weren't
+	if timedOut {
+		log.Warn("timed out fetching calendar availabilities", "eventId", event.Id.Hex(), "fetched", len(userIdToCalendarEvents), "total", len(requests))
+		for _, request := range requests {
+			if _, ok := userIdToCalendarEvents[request.UserId]; ok {
+				continue
+			}
+			userIdToErrors[request.UserId] = make(map[string]*errs.Error)
+			for _, calendarAccountKey := range request.EnabledAccounts {
+				userIdToErrors[request.UserId][calendarAccountKey] = errs.From(ctx.Err())
+			}
 		}
 	}
 
@@ -1274,10 +1797,41 @@ func getCalendarAvailabilities(c *gin.Context) {
 		userIdToCalendarEvents[userId] = updatedCalendarEvents
 	}
 
+	if payload.IncludeErrors {
+		c.JSON(http.StatusOK, gin.H{"calendarEvents": userIdToCalendarEvents, "errors": userIdToErrors})
+		return
+	}
 	c.JSON(http.StatusOK, userIdToCalendarEvents)
 }
 
-// @Summary Deletes an event based on its id
+// The most users whose calendar events getCalendarAvailabilities fetches at once
+const maxConcurrentCalendarAvailabilities = 8
+
+// The longest getCalendarAvailabilities waits for calendar events before responding with the ones it has
+const calendarAvailabilitiesTimeout = 20 * time.Second
+
+// Fetches the user's calendar events from the given accounts for getCalendarAvailabilities. If fetching panics,
+// each of the accounts is returned with the error
+func fetchCalendarAvailability(ctx context.Context, log *logger.Logger, user *models.User, accounts []string, timeMin time.Time, timeMax time.Time) (calendarEvents map[string]calendar.CalendarEventsWithError) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error("panic while fetching calendar events", "userId", user.Id.Hex(), "error", fmt.Sprint(err))
+			calendarEvents = make(map[string]calendar.CalendarEventsWithError)
+			for _, calendarAccountKey := range accounts {
+				calendarEvents[calendarAccountKey] = calendar.CalendarEventsWithError{
+					CalendarEvents: make([]models.CalendarEvent, 0),
+					Error:          errs.From(fmt.Errorf("%v", err)),
+				}
+			}
+		}
+	}()
+
+	// The error is ctx's, which getCalendarAvailabilities handles itself
+	calendarEvents, _, _ = calendar.GetUsersCalendarEvents(ctx, user, utils.ArrayToSet(accounts), timeMin, timeMax)
+	return calendarEvents
+}
+
+// @Summary Moves an event to the trash, where it can be restored until the trash retention period is over
 // @Tags events
 // @Produce json
 // @Param eventId path string true "Event ID"
@@ -1296,55 +1850,14 @@ func deleteEvent(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	// Check if the current user responded
-	eventResponses := db.GetEventResponses(eventId)
-	hasCurrentUserResponded := false
-	for _, resp := range eventResponses {
-		if resp.UserId == user.Id.Hex() {
-			hasCurrentUserResponded = true
-			break
-		}
-	}
-	hasResponses := len(eventResponses) > 0
-	if hasCurrentUserResponded {
-		// Only set hasResponses to true if there are responses other than the current user's
-		hasResponses = len(eventResponses) > 1
-	}
-
-	var event models.Event
-
-	if hasResponses {
-		// If event has responses, just set isDeleted flag
-		result := db.EventsCollection.FindOneAndUpdate(context.Background(), bson.M{
-			"_id":     objectId,
-			"ownerId": user.Id,
-		}, bson.M{
-			"$set": bson.M{
-				"isDeleted": true,
-			},
-		})
-		err = result.Decode(&event)
-		if err != nil {
-			logger.StdErr.Panicln(err)
-		}
-	} else {
-		// If event has no responses, actually delete the event object
-		result := db.EventsCollection.FindOneAndDelete(context.Background(), bson.M{
-			"_id":     objectId,
-			"ownerId": user.Id,
-		})
-		err = result.Decode(&event)
-		if err != nil {
-			logger.StdErr.Panicln(err)
-		}
-
-		// Delete folder associations
-		_, err = db.FolderEventsCollection.DeleteMany(context.Background(), bson.M{
-			"eventId": objectId,
-		})
-		if err != nil {
-			logger.StdErr.Panicln(err)
-		}
+	// Move the event to the trash, it's permanently deleted after the retention period
+	event, err := getRepositories(c).Events.UpdateOwned(objectId, user.Id, bson.M{
+		"isDeleted": true,
+		"deletedAt": primitive.NewDateTimeFromTime(time.Now()),
+	})
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
 	}
 
 	// Delete gcloud tasks
@@ -1352,7 +1865,7 @@ func deleteEvent(c *gin.Context) {
 		for _, remindee := range *event.Remindees {
 			// Delete email tasks
 			for _, taskId := range remindee.TaskIds {
-				gcloud.DeleteEmailTask(taskId)
+				gcloud.DeleteEmailTask(c.Request.Context(), taskId)
 			}
 		}
 	}
@@ -1381,7 +1894,12 @@ func duplicateEvent(c *gin.Context) {
 	user := userInterface.(*models.User)
 
 	// Get event
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.Status(http.StatusBadRequest)
 		return
@@ -1393,36 +1911,55 @@ func duplicateEvent(c *gin.Context) {
 		return
 	}
 
+	duplicate, err := copyEvent(repositories, event, payload.EventName, *payload.CopyAvailability)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"eventId": duplicate.Id.Hex(), "shortId": *duplicate.ShortId})
+}
+
+// Inserts a copy of the event with the given name, along with copies of its responses if `copyAvailability`
+// is true. Returns the copy
+func copyEvent(repositories *db.Repositories, event *models.Event, name string, copyAvailability bool) (*models.Event, error) {
+	duplicate := *event
+
 	// Update event
-	event.Id = primitive.NewObjectID()
-	event.Name = payload.EventName
+	duplicate.Id = primitive.NewObjectID()
+	duplicate.Name = name
 	numResponses := 0
-	event.NumResponses = &numResponses
-	if *payload.CopyAvailability {
-		eventResponses := db.GetEventResponses(eventId)
-		for _, eventResponse := range eventResponses {
-			eventResponse.Id = primitive.NewObjectID()
-			eventResponse.EventId = event.Id
-			_, err := db.EventResponsesCollection.InsertOne(context.Background(), eventResponse)
-			if err != nil {
-				logger.StdErr.Panicln(err)
-			}
-			*event.NumResponses++
+	duplicate.NumResponses = &numResponses
+	numGuestResponses := 0
+	duplicate.NumGuestResponses = &numGuestResponses
+	if copyAvailability {
+		eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+		if err != nil {
+			return nil, err
+		}
+		for i := range eventResponses {
+			eventResponses[i].Id = primitive.NewObjectID()
+			eventResponses[i].EventId = duplicate.Id
 		}
+		if err := repositories.Responses.InsertMany(eventResponses); err != nil {
+			return nil, err
+		}
+		numResponses = len(eventResponses)
+		numGuestResponses = countGuestResponses(eventResponses)
 	}
 
 	// Generate short id
-	shortId := db.GenerateShortEventId(event.Id)
-	event.ShortId = &shortId
+	shortId, err := repositories.Events.GenerateShortId(duplicate.Id)
+	if err != nil {
+		return nil, err
+	}
+	duplicate.ShortId = &shortId
 
 	// Insert new event
-	result, err := db.EventsCollection.InsertOne(context.Background(), event)
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	if err := repositories.Events.Insert(&duplicate); err != nil {
+		return nil, err
 	}
 
-	insertedId := result.InsertedID.(primitive.ObjectID).Hex()
-	c.JSON(http.StatusCreated, gin.H{"eventId": insertedId, "shortId": shortId})
+	return &duplicate, nil
 }
 
 // @Summary Archive an event
@@ -1454,23 +1991,72 @@ func archiveEvent(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	result := db.EventsCollection.FindOneAndUpdate(context.Background(), bson.M{
-		"_id":     objectId,
-		"ownerId": user.Id,
-	}, bson.M{
-		"$set": bson.M{
-			"isArchived": payload.Archive,
-		},
+	_, err = getRepositories(c).Events.UpdateOwned(objectId, user.Id, bson.M{
+		"isArchived": payload.Archive,
 	})
-	var event models.Event
-	err = result.Decode(&event)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		utils.AbortWithError(c, err)
+		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
+// @Summary Gets the changes made to an event, most recent first
+// @Description Only the owner of the event can see its history
+// @Tags events
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Param cursor query string false "The nextCursor of the previous page"
+// @Param limit query int false "Maximum number of entries to return, 50 by default and at most 200"
+// @Success 200 {object} object{entries=[]models.EventHistoryEntry,nextCursor=string}
+// @Router /events/{eventId}/history [get]
+func getEventHistory(c *gin.Context) {
+	cursor, limit, ok := getPage(c)
+	if !ok {
+		return
+	}
+	repositories := getRepositories(c)
+	userId, signedIn := sessions.Default(c).Get("userId").(string)
+	if !signedIn {
+		c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.NotSignedIn})
+		return
+	}
+
+	event, err := repositories.Events.GetByEitherId(c.Param("eventId"))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+	if event.OwnerId.Hex() != userId {
+		c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
+		return
+	}
+
+	entries, nextCursor, err := repositories.EventHistory.GetByEventId(event.Id, cursor, limit)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "nextCursor": nextCursor})
+}
+
+// Returns the number of responses from guests rather than signed in users
+func countGuestResponses(responses []models.EventResponse) int {
+	numGuestResponses := 0
+	for _, resp := range responses {
+		if resp.Response != nil && resp.Response.UserId.IsZero() {
+			numGuestResponses++
+		}
+	}
+	return numGuestResponses
+}
+
 // Helper function to find a response by userId
 func findResponse(responses []models.EventResponse, userId string) (int, *models.Response) {
 	for i, resp := range responses {
@@ -1513,7 +2099,12 @@ func scheduleEvent(c *gin.Context) {
 	}
 
 	eventId := c.Param("eventId")
-	event := db.GetEventByEitherId(eventId)
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	if event == nil {
 		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
 		return
@@ -1527,19 +2118,770 @@ func scheduleEvent(c *gin.Context) {
 	}
 
 	// Update the event with the scheduled event details
-	result := db.EventsCollection.FindOneAndUpdate(context.Background(), bson.M{
-		"_id": event.Id,
-	}, bson.M{
-		"$set": bson.M{
-			"scheduledEvent": scheduledEvent,
-		},
+	err = repositories.Events.SetFields(event.Id, bson.M{
+		"scheduledEvent": scheduledEvent,
 	})
-	err := result.Err()
 	if err != nil {
-		logger.StdErr.Println(err)
+		utils.GetLogger(c).Error("couldn't schedule the event", "eventId", event.Id.Hex(), "error", err)
 		c.JSON(http.StatusInternalServerError, responses.Error{Error: "Failed to update event"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
+
+// Deletes the event response if it still exists, and decrements the event's number of responses, along with its
+// number of guest responses if it was a guest's. Returns whether it was deleted
+func deleteEventResponseAndCount(repositories *db.Repositories, event *models.Event, eventResponse *models.EventResponse) (bool, error) {
+	deleted, err := repositories.Responses.Delete(eventResponse.Id)
+	if err != nil {
+		return false, err
+	}
+	if !deleted {
+		// Another request already deleted it
+		return false, nil
+	}
+
+	numResponses, err := repositories.Events.IncrementNumResponses(event.Id, -1)
+	if err != nil {
+		return false, err
+	}
+	event.NumResponses = &numResponses
+	if eventResponse.Response != nil && eventResponse.Response.UserId.IsZero() {
+		if err := repositories.Events.ReleaseGuestResponse(event.Id); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// The fields of an event whose changes are recorded in its history when it's edited
+var eventHistoryFields = []string{
+	"name", "description", "location", "type", "timezone", "duration", "dates", "times", "hasSpecificTimes",
+	"daysOnly", "startOnMonday", "signUpBlocks", "signUpQuestions", "notificationsEnabled",
+	"blindAvailabilityEnabled", "sendEmailAfterXResponses", "collectEmails",
+}
+
+// Records a change made to the event by the signed in user, or by a guest if no one is signed in. The change
+// has already been made, so failing to record it is logged rather than failing the request
+func recordEventHistory(c *gin.Context, eventId primitive.ObjectID, action models.EventHistoryAction, before map[string]interface{}, after map[string]interface{}) {
+	entry := models.EventHistoryEntry{
+		EventId: eventId,
+		Action:  action,
+		Before:  before,
+		After:   after,
+	}
+	if userId, signedIn := sessions.Default(c).Get("userId").(string); signedIn {
+		actorId := utils.StringToObjectID(userId)
+		entry.ActorId = &actorId
+		entry.ImpersonatorId = utils.GetImpersonatorId(c)
+	}
+	if err := getRepositories(c).EventHistory.Insert(&entry); err != nil {
+		utils.GetLogger(c).Error("couldn't record event history", "eventId", eventId.Hex(), "action", action, "error", err)
+	}
+}
+
+// Returns the emails of the people that get reminded to respond to the event
+func getRemindeeEmails(event *models.Event) []string {
+	return utils.Map(utils.Coalesce(event.Remindees), func(r models.Remindee) string { return r.Email })
+}
+
+// Deletes the event response like deleteEventResponseAndCount, and records the deleted response in the event's history
+func deleteEventResponseAndRecord(c *gin.Context, repositories *db.Repositories, event *models.Event, eventResponse *models.EventResponse) error {
+	deleted, err := deleteEventResponseAndCount(repositories, event, eventResponse)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		recordEventHistory(c, event.Id, models.DeleteResponseAction, map[string]interface{}{
+			"userId":   eventResponse.UserId,
+			"response": eventResponse.Response,
+		}, nil)
+	}
+	return nil
+}
+
+// Number of times to retry signing up when another user takes a spot at the same time
+const maxSignUpAttempts = 5
+
+// Returned when a new guest responds to an event that already has as many guest responses as it can get
+var errTooManyGuestResponses = errs.New(http.StatusForbidden, errs.TooManyGuestResponses, "This event can't get any more responses from guests")
+
+// Signs the user up for the blocks in `response.SignUpBlockIds` that have room, and puts them on the waitlist for the
+// blocks that are full. New guests can't sign up once `maxGuestResponses` guests have, unless it's 0. Returns the ids
+// of the blocks the user gave up, whose waitlists should be promoted
+func signUpForBlocks(repositories *db.Repositories, event *models.Event, userKey string, response *models.SignUpResponse, maxGuestResponses int) ([]primitive.ObjectID, error) {
+	requestedBlockIds := response.SignUpBlockIds
+
+	for attempt := 0; attempt < maxSignUpAttempts; attempt++ {
+		if _, ok := event.SignUpResponses[userKey]; !ok && response.UserId.IsZero() && maxGuestResponses > 0 && utils.CountGuestSignUps(event) >= maxGuestResponses {
+			return nil, errTooManyGuestResponses
+		}
+
+		var heldBlockIds []primitive.ObjectID
+		waitlistJoinedAt := make(map[primitive.ObjectID]primitive.DateTime)
+		if existingResponse, ok := event.SignUpResponses[userKey]; ok && existingResponse != nil {
+			heldBlockIds = existingResponse.SignUpBlockIds
+			for _, entry := range existingResponse.Waitlist {
+				waitlistJoinedAt[entry.SignUpBlockId] = entry.JoinedAt
+			}
+		}
+
+		response.SignUpBlockIds = make([]primitive.ObjectID, 0)
+		response.Waitlist = make([]models.SignUpWaitlistEntry, 0)
+		claimedBlocks := make([]models.SignUpBlock, 0)
+		for _, blockId := range requestedBlockIds {
+			if utils.Contains(response.SignUpBlockIds, blockId) || utils.Find(response.Waitlist, func(e models.SignUpWaitlistEntry) bool { return e.SignUpBlockId == blockId }) != -1 {
+				// Ignore duplicate block ids
+				continue
+			}
+			if utils.Contains(heldBlockIds, blockId) {
+				// User already has a spot in this block
+				response.SignUpBlockIds = append(response.SignUpBlockIds, blockId)
+				continue
+			}
+
+			block := utils.FindSignUpBlock(event, blockId)
+			if utils.SignUpBlockHasRoom(event, block, userKey) {
+				response.SignUpBlockIds = append(response.SignUpBlockIds, blockId)
+				claimedBlocks = append(claimedBlocks, *block)
+			} else {
+				// Keep the user's place in line if they were already waitlisted
+				joinedAt, ok := waitlistJoinedAt[blockId]
+				if !ok {
+					joinedAt = primitive.NewDateTimeFromTime(time.Now())
+				}
+				response.Waitlist = append(response.Waitlist, models.SignUpWaitlistEntry{
+					SignUpBlockId: blockId,
+					JoinedAt:      joinedAt,
+				})
+			}
+		}
+
+		written, err := repositories.Events.SetSignUpResponse(event.Id, userKey, response, claimedBlocks, maxGuestResponses)
+		if err != nil {
+			return nil, err
+		}
+		if written {
+			freedBlockIds := make([]primitive.ObjectID, 0)
+			for _, blockId := range heldBlockIds {
+				if !utils.Contains(response.SignUpBlockIds, blockId) {
+					freedBlockIds = append(freedBlockIds, blockId)
+				}
+			}
+			return freedBlockIds, nil
+		}
+
+		// Another user took one of the spots first, or another guest the last guest response, so try again with the
+		// latest sign ups
+		event, err = repositories.Events.GetById(event.Id.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if event == nil {
+			return nil, fmt.Errorf("event was deleted while signing up")
+		}
+	}
+
+	return nil, fmt.Errorf("could not sign up after %d attempts", maxSignUpAttempts)
+}
+
+// Removes the user's sign up response, gives their spots to the users on the waitlist, and records the removed
+// response in the event's history
+func withdrawSignUpResponse(c *gin.Context, repositories *db.Repositories, event *models.Event, userKey string) error {
+	response, ok := event.SignUpResponses[userKey]
+	if !ok {
+		return nil
+	}
+
+	if err := repositories.Events.DeleteSignUpResponse(event.Id, userKey); err != nil {
+		return err
+	}
+	delete(event.SignUpResponses, userKey)
+	recordEventHistory(c, event.Id, models.DeleteResponseAction, map[string]interface{}{
+		"userId":         userKey,
+		"signUpResponse": response,
+	}, nil)
+
+	if response != nil {
+		promoteSignUpWaitlists(c, repositories, event.Id, response.SignUpBlockIds)
+	}
+	return nil
+}
+
+// Moves users from the waitlists of the given blocks into the open spots, in the order they joined the waitlist,
+// and emails each user that gets a spot
+func promoteSignUpWaitlists(c *gin.Context, repositories *db.Repositories, eventId primitive.ObjectID, blockIds []primitive.ObjectID) {
+	for _, blockId := range blockIds {
+		for attempt := 0; attempt < maxSignUpAttempts; {
+			event, err := repositories.Events.GetById(eventId.Hex())
+			if err != nil {
+				utils.GetLogger(c).Error("couldn't get the event to promote its sign up waitlists", "eventId", eventId.Hex(), "error", err)
+				return
+			}
+			if event == nil {
+				return
+			}
+			block := utils.FindSignUpBlock(event, blockId)
+			if block == nil {
+				break
+			}
+
+			waitlist := utils.GetSignUpWaitlist(event, blockId)
+			if len(waitlist) == 0 || !utils.SignUpBlockHasRoom(event, block, waitlist[0]) {
+				break
+			}
+
+			// Move the block from the user's waitlist to their sign ups, unless they left the waitlist in the meantime
+			userKey := waitlist[0]
+			promoted, err := repositories.Events.PromoteFromSignUpWaitlist(eventId, userKey, block)
+			if err != nil {
+				utils.GetLogger(c).Error("couldn't move up the sign up waitlist", "eventId", eventId.Hex(), "signUpBlockId", blockId.Hex(), "error", err)
+				break
+			}
+			if !promoted {
+				// Someone else took the spot or the user left the waitlist first, check again
+				attempt++
+				continue
+			}
+
+			sendWaitlistPromotedEmail(c, repositories, event, block, userKey, event.SignUpResponses[userKey])
+		}
+	}
+}
+
+// Lets the user know asynchronously that they got a spot in the given block
+func sendWaitlistPromotedEmail(c *gin.Context, repositories *db.Repositories, event *models.Event, block *models.SignUpBlock, userKey string, response *models.SignUpResponse) {
+	waitlistPromotedEmailId, err := strconv.Atoi(os.Getenv("LISTMONK_WAITLIST_PROMOTED_EMAIL_ID"))
+	if err != nil {
+		// No template configured for this email
+		return
+	}
+
+	log := utils.GetLogger(c)
+	go func() {
+		// Recover from panics
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error("panic while sending the waitlist email", "eventId", event.Id.Hex(), "error", fmt.Sprint(err))
+			}
+		}()
+
+		email := response.Email
+		name := response.Name
+		if !response.UserId.IsZero() {
+			user, err := repositories.Users.GetById(userKey)
+			if err != nil {
+				log.Error("couldn't get the user to send the waitlist email to", "eventId", event.Id.Hex(), "userId", userKey, "error", err)
+				return
+			}
+			if user == nil {
+				return
+			}
+			email = user.Email
+			name = user.FirstName
+		}
+		if len(email) == 0 {
+			return
+		}
+
+		listmonk.SendEmailAddSubscriberIfNotExist(context.Background(), email, waitlistPromotedEmailId, bson.M{
+			"name":      name,
+			"eventName": event.Name,
+			"blockName": block.Name,
+			"eventUrl":  fmt.Sprintf("%s/e/%s", utils.GetBaseUrl(), event.GetId()),
+		}, false)
+	}()
+}
+
+// @Summary Exports the responses to an event as a spreadsheet
+// @Description Availability polls get one row per respondent per time slot, sign up forms get one row per sign up block
+// @Tags events
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param eventId path string true "Event ID"
+// @Param format query string false "Either csv (default) or xlsx"
+// @Param timezone query string false "IANA timezone to show times in (i.e. America/New_York), takes precedence over timezoneOffset"
+// @Param timezoneOffset query int false "Minutes to subtract from UTC to get the client's local time (i.e. the result of Date.getTimezoneOffset())"
+// @Success 200 {file} file
+// @Router /events/{eventId}/export [get]
+func exportEvent(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "format must be either csv or xlsx"})
+		return
+	}
+	location, err := getExportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+
+	eventId := c.Param("eventId")
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	// Only the owner of the event can export its responses
+	if event.OwnerId != primitive.NilObjectID {
+		userIdInterface := sessions.Default(c).Get("userId")
+		if userId, signedIn := userIdInterface.(string); !signedIn || event.OwnerId.Hex() != userId {
+			c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
+			return
+		}
+	}
+
+	fileName := getExportFileName(event.Name)
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, fileName))
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, fileName))
+	}
+	if err := writeEventExport(repositories, c.Writer, event, format, location); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+}
+
+// Returns the location to show times in an export in, from either the timezone or timezoneOffset query parameter
+func getExportLocation(c *gin.Context) (*time.Location, error) {
+	if timezone, ok := c.GetQuery("timezone"); ok {
+		return utils.LoadTimezone(timezone)
+	}
+	timezoneOffset, err := strconv.Atoi(c.DefaultQuery("timezoneOffset", "0"))
+	if err != nil {
+		return nil, errors.New("timezoneOffset must be an integer")
+	}
+	return time.FixedZone("", -timezoneOffset*60), nil
+}
+
+// Moves the dates of an event to the same local time on every day in its timezone. Dates are kept as is if the
+// event has no timezone, or only polls for days or has specific times for each day
+func normalizeEventDates(dates []primitive.DateTime, timezone string, daysOnly *bool, hasSpecificTimes *bool) ([]primitive.DateTime, error) {
+	if len(timezone) == 0 {
+		return dates, nil
+	}
+	location, err := utils.LoadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	if utils.Coalesce(daysOnly) || utils.Coalesce(hasSpecificTimes) {
+		return dates, nil
+	}
+	return utils.NormalizeEventDates(dates, location)
+}
+
+// Moves the times of the event's responses on the days that normalizeEventDates moved, from the `stored` date they
+// were given against to the normalized date, given the requested `dates` and the `normalized` dates in the same order.
+// Clients compute the dates with a fixed offset on every edit, so days whose stored date is already the normalized
+// one aren't moved again
+func shiftResponsesWithDates(repositories *db.Repositories, eventId primitive.ObjectID, stored []primitive.DateTime, dates []primitive.DateTime, normalized []primitive.DateTime) error {
+	type dayShift struct {
+		start primitive.DateTime
+		shift time.Duration
+	}
+	shifts := make([]dayShift, 0)
+	for i := range dates {
+		if normalized[i] == dates[i] {
+			continue
+		}
+
+		// The stored date of the same day is the one within half a day of the normalized date
+		for _, storedDate := range stored {
+			shift := normalized[i].Time().Sub(storedDate.Time())
+			if shift > -12*time.Hour && shift < 12*time.Hour {
+				if shift != 0 {
+					shifts = append(shifts, dayShift{storedDate, shift})
+				}
+				break
+			}
+		}
+	}
+	if len(shifts) == 0 {
+		return nil
+	}
+
+	// Times belong to the day that starts at most a day before them
+	shiftTime := func(date primitive.DateTime) primitive.DateTime {
+		for _, dayShift := range shifts {
+			if date >= dayShift.start && date.Time().Before(dayShift.start.Time().Add(24*time.Hour)) {
+				return primitive.NewDateTimeFromTime(date.Time().Add(dayShift.shift))
+			}
+		}
+		return date
+	}
+	shiftTimes := func(dates []primitive.DateTime) []primitive.DateTime {
+		if dates == nil {
+			return nil
+		}
+		return utils.Map(dates, shiftTime)
+	}
+
+	eventResponses, err := repositories.Responses.GetByEventId(eventId)
+	if err != nil {
+		return err
+	}
+	for _, eventResponse := range eventResponses {
+		if eventResponse.Response == nil {
+			continue
+		}
+		original := *eventResponse.Response
+		original.User = nil
+		response := original
+		response.Availability = shiftTimes(response.Availability)
+		response.IfNeeded = shiftTimes(response.IfNeeded)
+		if response.ManualAvailability != nil {
+			manualAvailability := make(map[primitive.DateTime][]primitive.DateTime, len(*response.ManualAvailability))
+			for day, times := range *response.ManualAvailability {
+				manualAvailability[shiftTime(day)] = shiftTimes(times)
+			}
+			response.ManualAvailability = &manualAvailability
+		}
+		if reflect.DeepEqual(response, original) {
+			continue
+		}
+		if _, err := repositories.Responses.Upsert(eventId, eventResponse.UserId, &response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the responses to the event as a spreadsheet in the given format, either csv or xlsx
+func writeEventExport(repositories *db.Repositories, w io.Writer, event *models.Event, format string, location *time.Location) error {
+	var rows [][]string
+	var err error
+	if utils.Coalesce(event.IsSignUpForm) {
+		rows, err = getSignUpExportRows(repositories, event, location)
+	} else {
+		rows, err = getAvailabilityExportRows(repositories, event, location)
+	}
+	if err != nil {
+		return err
+	}
+
+	if format == "xlsx" {
+		return utils.WriteXLSX(w, event.Name, rows)
+	}
+	return utils.WriteCSV(w, rows)
+}
+
+// Returns the name to give an exported file, without its extension
+func getExportFileName(name string) string {
+	fileName := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`"\/:*?<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(strings.TrimSpace(fileName)) == 0 {
+		fileName = "export"
+	}
+	return fileName
+}
+
+// Returns the name and email of the user that left a response, or false if the user has been deleted
+func getExportRespondent(users db.UserRepository, userId string, name string, email string) (string, string, bool, error) {
+	user, err := users.GetById(userId)
+	if err != nil {
+		return "", "", false, err
+	}
+	if user == nil {
+		// Guests are keyed by their name, users that were deleted have no name
+		return name, email, len(name) > 0, nil
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName), user.Email, true, nil
+}
+
+// Returns how a time slot is displayed in an export. Days of the week events don't take place on specific dates
+func formatExportTime(event *models.Event, date primitive.DateTime, location *time.Location) string {
+	t := date.Time().In(location)
+	if event.Type == models.DOW || event.Type == models.GROUP {
+		if utils.Coalesce(event.DaysOnly) {
+			return t.Format("Monday")
+		}
+		return t.Format("Monday 15:04")
+	}
+	if utils.Coalesce(event.DaysOnly) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// Returns one row per respondent per time slot, marking whether they are available at that time
+func getAvailabilityExportRows(repositories *db.Repositories, event *models.Event, location *time.Location) ([][]string, error) {
+	rows := [][]string{{"Name", "Email", "Time", "Status"}}
+
+	slots := utils.GetEventTimeSlots(event)
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		return nil, err
+	}
+	responsesMap := getResponsesMap(eventResponses)
+
+	// Sort respondents so the export is stable
+	userIds := make([]string, 0, len(responsesMap))
+	for userId := range responsesMap {
+		userIds = append(userIds, userId)
+	}
+	sort.Strings(userIds)
+
+	for _, userId := range userIds {
+		response := responsesMap[userId]
+		name, email, ok, err := getExportRespondent(repositories.Users, userId, response.Name, response.Email)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		available := utils.GetAvailableTimes(response)
+		ifNeeded := utils.ArrayToSet(response.IfNeeded)
+
+		for _, slot := range slots {
+			status := "unavailable"
+			if _, ok := available[slot]; ok {
+				status = "available"
+			} else if _, ok := ifNeeded[slot]; ok {
+				status = "if needed"
+			}
+			rows = append(rows, []string{name, email, formatExportTime(event, slot, location), status})
+		}
+	}
+
+	return rows, nil
+}
+
+// Returns one row per sign up block listing who signed up, who is waitlisted, and their answers to each question
+func getSignUpExportRows(repositories *db.Repositories, event *models.Event, location *time.Location) ([][]string, error) {
+	questions := utils.Coalesce(event.SignUpQuestions)
+
+	header := []string{"Block", "Start", "End", "Capacity", "Signed up", "Waitlist"}
+	for _, question := range questions {
+		header = append(header, question.Label)
+	}
+	rows := [][]string{header}
+
+	// Resolve every respondent once
+	type respondent struct {
+		name     string
+		email    string
+		response *models.SignUpResponse
+	}
+	respondents := make(map[string]respondent)
+	for userKey, response := range event.SignUpResponses {
+		if response == nil {
+			continue
+		}
+		name, email, ok, err := getExportRespondent(repositories.Users, userKey, response.Name, response.Email)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			respondents[userKey] = respondent{name, email, response}
+		}
+	}
+	formatRespondent := func(r respondent) string {
+		if len(r.email) > 0 {
+			return fmt.Sprintf("%s <%s>", r.name, r.email)
+		}
+		return r.name
+	}
+
+	for _, block := range utils.Coalesce(event.SignUpBlocks) {
+		signedUp := make([]respondent, 0)
+		for _, r := range respondents {
+			if utils.Contains(r.response.SignUpBlockIds, block.Id) {
+				signedUp = append(signedUp, r)
+			}
+		}
+		sort.Slice(signedUp, func(i, j int) bool { return signedUp[i].name < signedUp[j].name })
+
+		waitlist := make([]string, 0)
+		for _, userKey := range utils.GetSignUpWaitlist(event, block.Id) {
+			if r, ok := respondents[userKey]; ok {
+				waitlist = append(waitlist, formatRespondent(r))
+			}
+		}
+
+		row := []string{block.Name, "", "", "", strings.Join(utils.Map(signedUp, formatRespondent), "; "), strings.Join(waitlist, "; ")}
+		if block.StartDate != nil {
+			row[1] = formatExportTime(event, *block.StartDate, location)
+		}
+		if block.EndDate != nil {
+			row[2] = formatExportTime(event, *block.EndDate, location)
+		}
+		if block.Capacity != nil {
+			row[3] = strconv.Itoa(*block.Capacity)
+		}
+
+		// List each assignee's answer, prefixed with their name
+		for _, question := range questions {
+			answers := make([]string, 0)
+			for _, r := range signedUp {
+				index := utils.Find(r.response.Answers, func(a models.SignUpAnswer) bool { return a.QuestionId == question.Id })
+				if index != -1 {
+					answers = append(answers, fmt.Sprintf("%s: %s", r.name, utils.FormatSignUpAnswer(r.response.Answers[index])))
+				}
+			}
+			row = append(row, strings.Join(answers, "; "))
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// @Summary Imports attendees (for groups) or remindees (for events) from a CSV file of names, emails, and roles
+// @Description Only the owner of an event can import into it. Returns a report of who would be invited, who was already invited, and which lines are invalid. Nobody is invited if dryRun is true
+// @Tags events
+// @Accept multipart/form-data
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Param file formData file true "CSV file with name, email, and role (required or optional) columns"
+// @Param dryRun query bool false "Only return the report without inviting anybody"
+// @Success 200 {object} object{dryRun=bool,added=[]utils.ImportRow,alreadyInvited=[]utils.ImportRow,invalid=[]utils.ImportError}
+// @Router /events/{eventId}/import [post]
+func importParticipants(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+
+	eventId := c.Param("eventId")
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(eventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	// Only the owner of the event can import participants, and events without an owner can't be imported into so
+	// that guests can't send invites in bulk
+	userIdInterface := sessions.Default(c).Get("userId")
+	if userId, signedIn := userIdInterface.(string); !signedIn || event.OwnerId == primitive.NilObjectID || event.OwnerId.Hex() != userId {
+		c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
+		return
+	}
+
+	// Parse the uploaded file
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "A CSV file is required"})
+		return
+	}
+	if fileHeader.Size > 1<<20 {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "File must be smaller than 1MB"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	defer file.Close()
+	rows, invalid, err := utils.ParseImportCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+
+	// Find the emails that have already been invited
+	var existingEmails []string
+	if event.Type == models.GROUP {
+		attendees, err := repositories.Attendees.GetByEventId(event.Id)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		existingEmails = utils.Map(attendees, func(a models.Attendee) string { return a.Email })
+	} else {
+		existingEmails = utils.Map(utils.Coalesce(event.Remindees), func(r models.Remindee) string { return r.Email })
+	}
+	existing := utils.ArrayToSet(utils.Map(existingEmails, strings.ToLower))
+
+	added := make([]utils.ImportRow, 0)
+	alreadyInvited := make([]utils.ImportRow, 0)
+	for _, row := range rows {
+		if _, ok := existing[row.Email]; ok {
+			alreadyInvited = append(alreadyInvited, row)
+		} else {
+			added = append(added, row)
+		}
+	}
+
+	if !dryRun && len(added) > 0 {
+		// Determine owner name
+		ownerName := "Somebody"
+		owner, err := repositories.Users.GetById(event.OwnerId.Hex())
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if owner != nil {
+			ownerName = owner.FirstName
+		}
+
+		if event.Type == models.GROUP {
+			availabilityGroupInviteEmailId := listmonk.GroupInviteEmailId()
+			attendees := make([]models.Attendee, 0)
+			for _, row := range added {
+				listmonk.SendEmailAddSubscriberIfNotExist(c.Request.Context(), row.Email, availabilityGroupInviteEmailId, bson.M{
+					"ownerName": ownerName,
+					"groupName": event.Name,
+					"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
+				}, false)
+				attendees = append(attendees, models.Attendee{
+					Name:     row.Name,
+					Email:    row.Email,
+					Role:     row.Role,
+					Declined: utils.FalsePtr(),
+					EventId:  event.Id,
+				})
+			}
+			if err := repositories.Attendees.InsertMany(attendees); err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+		} else {
+			remindees := make([]models.Remindee, 0)
+			for _, row := range added {
+				taskIds, err := gcloud.CreateEmailTask(c.Request.Context(), row.Email, ownerName, event.Name, event.GetId())
+				if err != nil {
+					utils.AbortWithError(c, err)
+					return
+				}
+				remindees = append(remindees, models.Remindee{
+					Name:      row.Name,
+					Email:     row.Email,
+					Role:      row.Role,
+					TaskIds:   taskIds,
+					Responded: utils.FalsePtr(),
+				})
+			}
+			if err := repositories.Events.AddRemindees(event.Id, remindees); err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dryRun":         dryRun,
+		"added":          added,
+		"alreadyInvited": alreadyInvited,
+		"invalid":        invalid,
+	})
+}
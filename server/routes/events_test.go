@@ -0,0 +1,580 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db/memory"
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/ratelimit"
+	"schej.it/server/utils"
+)
+
+// Returns a router with the event, user, folder, and trash routes backed by the in-memory database. Requests with an X-User-Id header
+// are treated as coming from that signed in user
+func newTestRouter(database *memory.Database) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger.Init(io.Discard)
+
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("secret"))))
+	router.Use(func(c *gin.Context) {
+		if userId := c.GetHeader("X-User-Id"); len(userId) > 0 {
+			sessions.Default(c).Set("userId", userId)
+		}
+		if impersonatorId := c.GetHeader("X-Impersonator-Id"); len(impersonatorId) > 0 {
+			sessions.Default(c).Set("impersonatorId", impersonatorId)
+		}
+	})
+	router.Use(middleware.Repositories(database.Repositories()))
+	router.Use(middleware.Impersonation())
+	InitEvents(router.Group("/api"))
+	InitUser(router.Group("/api"))
+	InitFolders(router.Group("/api"))
+	InitTrash(router.Group("/api"))
+	InitFriends(router.Group("/api"))
+
+	// Don't share rate limit counts between tests
+	ratelimit.SetStore(ratelimit.NewMemoryStore())
+	return router
+}
+
+func sendRequest(t *testing.T, router *gin.Engine, method string, path string, userId string, payload interface{}) *httptest.ResponseRecorder {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if len(userId) > 0 {
+		req.Header.Set("X-User-Id", userId)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Returns the event with the given id, failing the test if it couldn't be read
+func mustGetEvent(t *testing.T, database *memory.Database, id primitive.ObjectID) *models.Event {
+	event, err := database.Events.GetById(id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// Returns the user with the given id, failing the test if it couldn't be read
+func mustGetUser(t *testing.T, database *memory.Database, id primitive.ObjectID) *models.User {
+	user, err := database.Users.GetById(id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// Returns the responses to the event, failing the test if they couldn't be read
+func mustGetResponses(t *testing.T, database *memory.Database, eventId primitive.ObjectID) []models.EventResponse {
+	eventResponses, err := database.Responses.GetByEventId(eventId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eventResponses
+}
+
+// Returns the attendees of the event, failing the test if they couldn't be read
+func mustGetAttendees(t *testing.T, database *memory.Database, eventId primitive.ObjectID) []models.Attendee {
+	attendees, err := database.Attendees.GetByEventId(eventId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return attendees
+}
+
+func TestUpdateEventResponseGuest(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	numResponses := 0
+	lunchDuration := float32(1)
+	event := &models.Event{
+		OwnerId:      primitive.NewObjectID(),
+		Name:         "Team lunch",
+		Type:         models.SPECIFIC_DATES,
+		Duration:     &lunchDuration,
+		Dates:        []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))},
+		NumResponses: &numResponses,
+	}
+	database.Events.Insert(event)
+	path := "/api/events/" + event.Id.Hex() + "/response"
+
+	availability := []primitive.DateTime{event.Dates[0]}
+	w := sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": "Alice", "availability": availability})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST response = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	// Editing the response shouldn't count as another response
+	availability = append(availability, primitive.NewDateTimeFromTime(event.Dates[0].Time().Add(15*time.Minute)))
+	w = sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": "Alice", "availability": availability})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST edited response = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	eventResponses := mustGetResponses(t, database, event.Id)
+	if len(eventResponses) != 1 {
+		t.Fatalf("got %d responses, want 1", len(eventResponses))
+	}
+	if got := eventResponses[0].Response.Availability; len(got) != 2 {
+		t.Errorf("availability = %v, want 2 times", got)
+	}
+	if got := *mustGetEvent(t, database, event.Id).NumResponses; got != 1 {
+		t.Errorf("numResponses = %d, want 1", got)
+	}
+
+	w = sendRequest(t, router, http.MethodDelete, path, "", gin.H{"guest": true, "name": "Alice"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE response = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if got := len(mustGetResponses(t, database, event.Id)); got != 0 {
+		t.Errorf("got %d responses after deleting, want 0", got)
+	}
+	if got := *mustGetEvent(t, database, event.Id).NumResponses; got != 0 {
+		t.Errorf("numResponses after deleting = %d, want 0", got)
+	}
+}
+
+func TestUpdateEventResponseMaxGuestResponses(t *testing.T) {
+	t.Setenv("MAX_GUEST_RESPONSES_PER_EVENT", "2")
+	database := memory.New()
+	router := newTestRouter(database)
+
+	numResponses := 0
+	event := &models.Event{
+		OwnerId:      primitive.NewObjectID(),
+		Name:         "Book club",
+		Type:         models.SPECIFIC_DATES,
+		Dates:        []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC))},
+		NumResponses: &numResponses,
+	}
+	database.Events.Insert(event)
+	path := "/api/events/" + event.Id.Hex() + "/response"
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if w := sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": name, "availability": event.Dates}); w.Code != http.StatusOK {
+			t.Fatalf("POST response of %s = %d %s, want 200", name, w.Code, w.Body.String())
+		}
+	}
+
+	// A third guest can't respond, but the guests that already did can still edit their responses
+	w := sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": "Carol", "availability": event.Dates})
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), errs.TooManyGuestResponses) {
+		t.Errorf("POST response of a third guest = %d %s, want 403 %s", w.Code, w.Body.String(), errs.TooManyGuestResponses)
+	}
+	if w := sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": "Alice", "availability": []primitive.DateTime{}}); w.Code != http.StatusOK {
+		t.Errorf("POST edited response = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if got := len(mustGetResponses(t, database, event.Id)); got != 2 {
+		t.Errorf("got %d responses, want 2", got)
+	}
+
+	// Deleting a guest's response lets another guest respond
+	if w := sendRequest(t, router, http.MethodDelete, path, "", gin.H{"guest": true, "name": "Bob"}); w.Code != http.StatusOK {
+		t.Fatalf("DELETE response = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if w := sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": "Carol", "availability": event.Dates}); w.Code != http.StatusOK {
+		t.Errorf("POST response of a guest after one was deleted = %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateEventResponseConcurrentMaxGuestResponses(t *testing.T) {
+	t.Setenv("MAX_GUEST_RESPONSES_PER_EVENT", "5")
+	database := memory.New()
+	router := newTestRouter(database)
+
+	numResponses := 0
+	event := &models.Event{
+		OwnerId:      primitive.NewObjectID(),
+		Name:         "Book club",
+		Type:         models.SPECIFIC_DATES,
+		Dates:        []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC))},
+		NumResponses: &numResponses,
+	}
+	database.Events.Insert(event)
+	block := models.SignUpBlock{Id: primitive.NewObjectID(), Name: "Morning shift"}
+	numSignUpResponses := 0
+	signUpForm := &models.Event{
+		OwnerId:      primitive.NewObjectID(),
+		Name:         "Volunteer shifts",
+		Type:         models.SPECIFIC_DATES,
+		IsSignUpForm: utils.TruePtr(),
+		SignUpBlocks: &[]models.SignUpBlock{block},
+		NumResponses: &numSignUpResponses,
+	}
+	database.Events.Insert(signUpForm)
+
+	// More guests than the limit respond at the same time, to an event and to a sign up form
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(name string) {
+			defer wg.Done()
+			sendRequest(t, router, http.MethodPost, "/api/events/"+event.Id.Hex()+"/response", "", gin.H{"guest": true, "name": name, "availability": event.Dates})
+		}(fmt.Sprintf("Guest %d", i))
+		go func(name string) {
+			defer wg.Done()
+			sendRequest(t, router, http.MethodPost, "/api/events/"+signUpForm.Id.Hex()+"/response", "", gin.H{"guest": true, "name": name, "signUpBlockIds": []primitive.ObjectID{block.Id}})
+		}(fmt.Sprintf("Guest %d", i))
+	}
+	wg.Wait()
+
+	if got := len(mustGetResponses(t, database, event.Id)); got != 5 {
+		t.Errorf("got %d responses to the event, want 5", got)
+	}
+	if got := len(mustGetEvent(t, database, signUpForm.Id).SignUpResponses); got != 5 {
+		t.Errorf("got %d responses to the sign up form, want 5", got)
+	}
+}
+
+func TestUpdateEventResponseConcurrentNumResponses(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	numResponses := 0
+	sendEmailAfterXResponses := 10
+	event := &models.Event{
+		OwnerId:                  primitive.NewObjectID(),
+		Name:                     "Board game night",
+		Type:                     models.SPECIFIC_DATES,
+		Dates:                    []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC))},
+		NumResponses:             &numResponses,
+		SendEmailAfterXResponses: &sendEmailAfterXResponses,
+	}
+	database.Events.Insert(event)
+	path := "/api/events/" + event.Id.Hex() + "/response"
+
+	// Each guest responds twice at the same time as everyone else
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": name, "availability": event.Dates})
+		}(fmt.Sprintf("Guest %d", i%10))
+	}
+	wg.Wait()
+
+	if got := len(mustGetResponses(t, database, event.Id)); got != 10 {
+		t.Fatalf("got %d responses, want 10", got)
+	}
+	updated := mustGetEvent(t, database, event.Id)
+	if got := *updated.NumResponses; got != 10 {
+		t.Errorf("numResponses = %d, want 10", got)
+	}
+	if got := *updated.SendEmailAfterXResponses; got != -1 {
+		t.Errorf("sendEmailAfterXResponses = %d, want -1 after the email was sent", got)
+	}
+}
+
+func TestUpdateEventResponseGroup(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	user := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(user)
+
+	numResponses := 0
+	groupDuration := float32(8)
+	event := &models.Event{
+		OwnerId:      primitive.NewObjectID(),
+		Name:         "Study group",
+		Type:         models.GROUP,
+		Duration:     &groupDuration,
+		Dates:        []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))},
+		NumResponses: &numResponses,
+	}
+	database.Events.Insert(event)
+	database.Attendees.Insert(&models.Attendee{EventId: event.Id, Email: user.Email, Declined: utils.TruePtr()})
+	path := "/api/events/" + event.Id.Hex() + "/response"
+
+	// Responding should rejoin a group the user declined
+	w := sendRequest(t, router, http.MethodPost, path, user.Id.Hex(), gin.H{"guest": false, "availability": event.Dates})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST response = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if attendees := mustGetAttendees(t, database, event.Id); utils.Coalesce(attendees[0].Declined) {
+		t.Errorf("attendee is still declined after responding")
+	}
+
+	// Only the owner can delete other users' responses
+	w = sendRequest(t, router, http.MethodDelete, path, primitive.NewObjectID().Hex(), gin.H{"guest": false, "userId": user.Id.Hex()})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("DELETE someone else's response = %d, want 403", w.Code)
+	}
+
+	// Deleting their response makes the user leave the group
+	w = sendRequest(t, router, http.MethodDelete, path, user.Id.Hex(), gin.H{"guest": false, "userId": user.Id.Hex()})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE response = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if got := len(mustGetResponses(t, database, event.Id)); got != 0 {
+		t.Errorf("got %d responses after deleting, want 0", got)
+	}
+	if attendees := mustGetAttendees(t, database, event.Id); !utils.Coalesce(attendees[0].Declined) {
+		t.Errorf("attendee isn't declined after deleting their response")
+	}
+}
+
+func TestUpdateEventResponseSignUpWaitlist(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	capacity := 1
+	block := models.SignUpBlock{Id: primitive.NewObjectID(), Name: "Morning shift", Capacity: &capacity}
+	numResponses := 0
+	event := &models.Event{
+		OwnerId:      primitive.NewObjectID(),
+		Name:         "Volunteer shifts",
+		Type:         models.SPECIFIC_DATES,
+		IsSignUpForm: utils.TruePtr(),
+		SignUpBlocks: &[]models.SignUpBlock{block},
+		NumResponses: &numResponses,
+	}
+	database.Events.Insert(event)
+	path := "/api/events/" + event.Id.Hex() + "/response"
+
+	for _, name := range []string{"Alice", "Bob"} {
+		w := sendRequest(t, router, http.MethodPost, path, "", gin.H{"guest": true, "name": name, "signUpBlockIds": []primitive.ObjectID{block.Id}})
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST response for %s = %d %s, want 200", name, w.Code, w.Body.String())
+		}
+	}
+
+	signUpResponses := mustGetEvent(t, database, event.Id).SignUpResponses
+	if got := signUpResponses["Alice"].SignUpBlockIds; len(got) != 1 {
+		t.Errorf("Alice's sign ups = %v, want the block", got)
+	}
+	if got := signUpResponses["Bob"]; len(got.SignUpBlockIds) != 0 || len(got.Waitlist) != 1 {
+		t.Errorf("Bob's response = %+v, want them on the waitlist", got)
+	}
+
+	// Bob should get Alice's spot once Alice withdraws
+	w := sendRequest(t, router, http.MethodDelete, path, "", gin.H{"guest": true, "name": "Alice"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE response = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	signUpResponses = mustGetEvent(t, database, event.Id).SignUpResponses
+	if _, ok := signUpResponses["Alice"]; ok {
+		t.Errorf("Alice's response wasn't deleted")
+	}
+	if got := signUpResponses["Bob"]; len(got.SignUpBlockIds) != 1 || len(got.Waitlist) != 0 {
+		t.Errorf("Bob's response = %+v, want them promoted off the waitlist", got)
+	}
+}
+
+func TestEventHistory(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	owner := &models.User{Email: "carol@example.com", FirstName: "Carol"}
+	database.Users.Insert(owner)
+
+	numResponses := 0
+	event := &models.Event{
+		OwnerId:      owner.Id,
+		Name:         "Retro",
+		Type:         models.SPECIFIC_DATES,
+		Dates:        []primitive.DateTime{primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC))},
+		NumResponses: &numResponses,
+	}
+	database.Events.Insert(event)
+	responsePath := "/api/events/" + event.Id.Hex() + "/response"
+	historyPath := "/api/events/" + event.Id.Hex() + "/history"
+
+	for _, name := range []string{"Alice", "Bob"} {
+		w := sendRequest(t, router, http.MethodPost, responsePath, "", gin.H{"guest": true, "name": name, "availability": event.Dates})
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST response = %d %s, want 200", w.Code, w.Body.String())
+		}
+	}
+
+	// Responding isn't a change to the event, but the owner deleting a guest's response is
+	w := sendRequest(t, router, http.MethodDelete, responsePath, owner.Id.Hex(), gin.H{"guest": true, "name": "Alice"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE response = %d %s, want 200", w.Code, w.Body.String())
+	}
+	w = sendRequest(t, router, http.MethodDelete, responsePath, "", gin.H{"guest": true, "name": "Bob"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE response = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	if w := sendRequest(t, router, http.MethodGet, historyPath, "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET history signed out = %d, want 401", w.Code)
+	}
+	if w := sendRequest(t, router, http.MethodGet, historyPath, primitive.NewObjectID().Hex(), nil); w.Code != http.StatusForbidden {
+		t.Errorf("GET history as someone else = %d, want 403", w.Code)
+	}
+
+	w = sendRequest(t, router, http.MethodGet, historyPath+"?limit=1", owner.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET history = %d %s, want 200", w.Code, w.Body.String())
+	}
+	var page struct {
+		Entries    []models.EventHistoryEntry `json:"entries"`
+		NextCursor *string                    `json:"nextCursor"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 1 || page.NextCursor == nil {
+		t.Fatalf("got %d entries and cursor %v, want 1 entry and a cursor", len(page.Entries), page.NextCursor)
+	}
+	if entry := page.Entries[0]; entry.Action != models.DeleteResponseAction || entry.ActorId != nil || entry.Before["userId"] != "Bob" {
+		t.Errorf("most recent entry = %+v, want Bob deleting their own response", entry)
+	}
+
+	w = sendRequest(t, router, http.MethodGet, historyPath+"?limit=1&cursor="+*page.NextCursor, owner.Id.Hex(), nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 1 || page.NextCursor != nil {
+		t.Fatalf("got %d entries and cursor %v on the last page, want 1 entry and no cursor", len(page.Entries), page.NextCursor)
+	}
+	if entry := page.Entries[0]; entry.Action != models.DeleteResponseAction || entry.Actor == nil || entry.Actor.Email != owner.Email || entry.Before["userId"] != "Alice" {
+		t.Errorf("oldest entry = %+v, want the owner deleting Alice's response", entry)
+	}
+}
+
+func TestGetAggregates(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(alice)
+
+	noon := primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	one := primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC))
+	event := &models.Event{
+		Name:             "Planning",
+		Type:             models.SPECIFIC_DATES,
+		HasSpecificTimes: utils.TruePtr(),
+		Times:            []primitive.DateTime{noon, one},
+		Remindees: &[]models.Remindee{
+			{Email: "Alice@example.com"},
+			{Email: "bob@example.com", Role: models.REQUIRED_PARTICIPANT},
+			{Email: "carol@example.com", Role: models.OPTIONAL_PARTICIPANT},
+		},
+	}
+	database.Events.Insert(event)
+	database.Responses.Insert(&models.EventResponse{EventId: event.Id, UserId: alice.Id.Hex(), Response: &models.Response{Availability: []primitive.DateTime{noon}}})
+	database.Responses.Insert(&models.EventResponse{EventId: event.Id, UserId: "Dave", Response: &models.Response{Name: "Dave", Availability: []primitive.DateTime{noon, one}}})
+
+	w := sendRequest(t, router, http.MethodGet, "/api/events/"+event.Id.Hex()+"/aggregates?timeMin=2024-01-01T00:00:00Z&timeMax=2024-01-02T00:00:00Z", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET aggregates = %d %s, want 200", w.Code, w.Body.String())
+	}
+	var body struct {
+		Aggregates []utils.SlotAggregate `json:"aggregates"`
+		Ranking    []utils.SlotAggregate `json:"ranking"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob is required and hasn't responded, so he's missing at every slot
+	expected := []utils.SlotAggregate{
+		{Time: noon, RequiredAvailable: 1, OptionalAvailable: 1, RequiredMissing: 1},
+		{Time: one, RequiredAvailable: 0, OptionalAvailable: 1, RequiredMissing: 2},
+	}
+	if !reflect.DeepEqual(body.Aggregates, expected) {
+		t.Errorf("aggregates = %+v, want %+v", body.Aggregates, expected)
+	}
+	if len(body.Ranking) != 0 {
+		t.Errorf("ranking = %+v, want no slots since Bob is missing", body.Ranking)
+	}
+}
+
+func TestEditEventShiftsAvailabilityWithDates(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	owner := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(owner)
+
+	// 9am in New York on both days, but computed with the offset before daylight saving time started on March 10
+	at := func(day int, hour int, minute int) primitive.DateTime {
+		return primitive.NewDateTimeFromTime(time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC))
+	}
+	duration := float32(2)
+	event := &models.Event{
+		OwnerId:  owner.Id,
+		Name:     "Planning",
+		Type:     models.SPECIFIC_DATES,
+		Duration: &duration,
+		Dates:    []primitive.DateTime{at(9, 14, 0), at(11, 14, 0)},
+	}
+	database.Events.Insert(event)
+	database.Responses.Insert(&models.EventResponse{EventId: event.Id, UserId: "Alice", Response: &models.Response{
+		Name:         "Alice",
+		Availability: []primitive.DateTime{at(9, 14, 0), at(11, 14, 0), at(11, 14, 15)},
+		IfNeeded:     []primitive.DateTime{at(11, 15, 0)},
+	}})
+
+	w := sendRequest(t, router, http.MethodPut, "/api/events/"+event.Id.Hex(), owner.Id.Hex(), gin.H{
+		"name":     event.Name,
+		"duration": duration,
+		"dates":    event.Dates,
+		"type":     event.Type,
+		"timezone": "America/New_York",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT /events/%s = %d %s", event.Id.Hex(), w.Code, w.Body.String())
+	}
+
+	// The second day moves an hour earlier, and so do the times on it
+	edited := mustGetEvent(t, database, event.Id)
+	if want := []primitive.DateTime{at(9, 14, 0), at(11, 13, 0)}; !reflect.DeepEqual(edited.Dates, want) {
+		t.Errorf("Dates = %v, want %v", edited.Dates, want)
+	}
+	response := mustGetResponses(t, database, event.Id)[0].Response
+	if want := []primitive.DateTime{at(9, 14, 0), at(11, 13, 0), at(11, 13, 15)}; !reflect.DeepEqual(response.Availability, want) {
+		t.Errorf("Availability = %v, want %v", response.Availability, want)
+	}
+	if want := []primitive.DateTime{at(11, 14, 0)}; !reflect.DeepEqual(response.IfNeeded, want) {
+		t.Errorf("IfNeeded = %v, want %v", response.IfNeeded, want)
+	}
+
+	// Clients send the dates computed with the same offset on every edit, which doesn't move the times again
+	w = sendRequest(t, router, http.MethodPut, "/api/events/"+event.Id.Hex(), owner.Id.Hex(), gin.H{
+		"name":     event.Name,
+		"duration": duration,
+		"dates":    event.Dates,
+		"type":     event.Type,
+		"timezone": "America/New_York",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("second PUT /events/%s = %d %s", event.Id.Hex(), w.Code, w.Body.String())
+	}
+	if got := mustGetEvent(t, database, event.Id).Dates; !reflect.DeepEqual(got, edited.Dates) {
+		t.Errorf("Dates after the second edit = %v, want %v", got, edited.Dates)
+	}
+	if got := mustGetResponses(t, database, event.Id)[0].Response; !reflect.DeepEqual(got.Availability, response.Availability) || !reflect.DeepEqual(got.IfNeeded, response.IfNeeded) {
+		t.Errorf("response after the second edit = %v %v, want %v %v", got.Availability, got.IfNeeded, response.Availability, response.IfNeeded)
+	}
+}
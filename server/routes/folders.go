@@ -1,15 +1,21 @@
 package routes
 
 import (
+	"archive/zip"
+	"bytes"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"schej.it/server/db"
+	"schej.it/server/errs"
 	"schej.it/server/middleware"
 	"schej.it/server/models"
+	"schej.it/server/utils"
 )
 
 func InitFolders(router *gin.RouterGroup) {
@@ -18,11 +24,22 @@ func InitFolders(router *gin.RouterGroup) {
 
 	folderRouter.GET("", GetAllFolders)
 	folderRouter.POST("", CreateFolder)
+	folderRouter.GET("/shared", GetSharedFolders)
 	folderRouter.GET("/:folderId", GetFolder)
 	folderRouter.PATCH("/:folderId", UpdateFolder)
 	folderRouter.DELETE("/:folderId", DeleteFolder)
+	folderRouter.PUT("/:folderId/shares", ShareFolder)
+	folderRouter.DELETE("/:folderId/shares/:userId", UnshareFolder)
+	folderRouter.DELETE("/:folderId/events/:eventId", RemoveEventFromFolder)
+	folderRouter.POST("/:folderId/move", MoveFolderEvents)
+	folderRouter.POST("/:folderId/archive", ArchiveFolderEvents)
+	folderRouter.POST("/:folderId/duplicate", DuplicateFolder)
+	folderRouter.GET("/:folderId/export", ExportFolder)
 }
 
+// Maximum number of levels folders can be nested
+const maxFolderDepth = 10
+
 // @Summary Get all folders
 // @Tags folders
 // @Produce json
@@ -39,7 +56,7 @@ func GetAllFolders(c *gin.Context) {
 		return
 	}
 
-	folders, err := db.GetAllFolders(userId)
+	folders, err := getRepositories(c).Folders.GetAll(userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get folders"})
 		return
@@ -53,10 +70,11 @@ func GetAllFolders(c *gin.Context) {
 }
 
 // @Summary Get a folder by its ID and its contents
+// @Description Works for the user's own folders and the folders shared with them
 // @Tags folders
 // @Produce json
 // @Param folderId path string true "Folder ID"
-// @Success 200 {object} models.Folder "The folder object with events"
+// @Success 200 {object} models.Folder "The folder object with events and the user's role in it"
 // @Failure 400 {object} map[string]string "Invalid user ID or folder ID"
 // @Failure 404 {object} map[string]string "Folder not found"
 // @Failure 500 {object} map[string]string "Failed to get events in folder"
@@ -76,18 +94,19 @@ func GetFolder(c *gin.Context) {
 		return
 	}
 
-	folder, err := db.GetFolderById(folderId, userId)
-	if err != nil {
+	folder, role := getFolderAccess(getRepositories(c).Folders, folderId, userId)
+	if folder == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 		return
 	}
 
-	events, err := db.GetEventsInFolder(folderId, userId)
+	events, err := getRepositories(c).Folders.GetEventIds(folderId, folder.UserId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events in folder"})
 		return
 	}
 	folder.EventIds = events
+	folder.Role = role
 
 	c.JSON(http.StatusOK, folder)
 }
@@ -100,15 +119,17 @@ type CreateFolderResponse struct {
 // @Tags folders
 // @Accept json
 // @Produce json
-// @Param payload body object{name=string,color=string} true "Folder name and optional color"
+// @Param payload body object{name=string,color=string,parentId=string} true "Folder name, optional color, and optional folder to create it in"
 // @Success 201 {object} CreateFolderResponse "The ID of the created folder"
 // @Failure 400 {object} map[string]string "Invalid user ID or request body"
+// @Failure 404 {object} map[string]string "Parent folder not found"
 // @Failure 500 {object} map[string]string "Failed to create folder"
 // @Router /user/folders [post]
 func CreateFolder(c *gin.Context) {
 	var body struct {
-		Name  string  `json:"name" binding:"required"`
-		Color *string `json:"color"`
+		Name     string  `json:"name" binding:"required"`
+		Color    *string `json:"color"`
+		ParentId *string `json:"parentId"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -130,7 +151,27 @@ func CreateFolder(c *gin.Context) {
 		Color:  body.Color,
 	}
 
-	id, err := db.CreateFolder(&folder)
+	// Subfolders belong to the owner of the folder they're in, which editors can add to
+	if body.ParentId != nil {
+		parentId, err := primitive.ObjectIDFromHex(*body.ParentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent folder ID"})
+			return
+		}
+		parent, role := getFolderAccess(getRepositories(c).Folders, parentId, userId)
+		if parent == nil || !role.Includes(models.FolderEditor) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent folder not found"})
+			return
+		}
+		if getFolderDepth(getRepositories(c).Folders, parent) >= maxFolderDepth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folders can't be nested that deeply"})
+			return
+		}
+		folder.UserId = parent.UserId
+		folder.ParentId = &parent.Id
+	}
+
+	id, err := getRepositories(c).Folders.Create(&folder)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
 		return
@@ -139,14 +180,17 @@ func CreateFolder(c *gin.Context) {
 	c.JSON(http.StatusCreated, CreateFolderResponse{Id: id.Hex()})
 }
 
-// @Summary Update a folder's name or color
+// @Summary Update a folder's name, color, or the folder it's in
+// @Description Editors can change the name and color. Only the owner can move the folder, and only into another
+// @Description one of their folders. An empty parentId moves the folder to the top level
 // @Tags folders
 // @Accept json
 // @Produce json
 // @Param folderId path string true "Folder ID"
-// @Param payload body object{name=string,color=string} true "New folder name and/or color"
+// @Param payload body object{name=string,color=string,parentId=string} true "New folder name, color, and/or parent folder"
 // @Success 200
-// @Failure 400 {object} map[string]string "Invalid user ID or folder ID"
+// @Failure 400 {object} map[string]string "Invalid user ID, folder ID, or parent folder"
+// @Failure 404 {object} map[string]string "Folder not found"
 // @Failure 500 {object} map[string]string "Failed to update folder"
 // @Router /user/folders/{folderId} [patch]
 func UpdateFolder(c *gin.Context) {
@@ -156,8 +200,9 @@ func UpdateFolder(c *gin.Context) {
 		return
 	}
 	var body struct {
-		Name  *string `json:"name"`
-		Color *string `json:"color"`
+		Name     *string `json:"name"`
+		Color    *string `json:"color"`
+		ParentId *string `json:"parentId"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -173,6 +218,13 @@ func UpdateFolder(c *gin.Context) {
 		return
 	}
 
+	folders := getRepositories(c).Folders
+	folder, role := getFolderAccess(folders, folderId, userId)
+	if folder == nil || !role.Includes(models.FolderEditor) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
 	updates := bson.M{}
 	if body.Name != nil {
 		updates["name"] = body.Name
@@ -180,8 +232,45 @@ func UpdateFolder(c *gin.Context) {
 	if body.Color != nil {
 		updates["color"] = body.Color
 	}
+	if body.ParentId != nil {
+		if role != models.FolderOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can move a folder"})
+			return
+		}
+
+		if len(*body.ParentId) == 0 {
+			updates["parentId"] = nil
+		} else {
+			parentId, err := primitive.ObjectIDFromHex(*body.ParentId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent folder ID"})
+				return
+			}
+			parent, err := folders.Get(parentId)
+			if err != nil || parent.UserId != folder.UserId {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found"})
+				return
+			}
+
+			// Make sure the folder isn't moved into itself and the result isn't nested too deeply
+			subfolders, height, err := getNestedSubfolders(folders, folderId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+				return
+			}
+			if parentId == folderId || utils.Find(subfolders, func(subfolder models.Folder) bool { return subfolder.Id == parentId }) != -1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A folder can't be moved into itself"})
+				return
+			}
+			if getFolderDepth(folders, parent)+height+1 > maxFolderDepth {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Folders can't be nested that deeply"})
+				return
+			}
+			updates["parentId"] = parentId
+		}
+	}
 
-	err = db.UpdateFolder(folderId, userId, updates)
+	err = folders.Update(folderId, folder.UserId, updates)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
 		return
@@ -190,7 +279,8 @@ func UpdateFolder(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
-// @Summary Delete a folder
+// @Summary Delete a folder along with its subfolders
+// @Description Moves the folder, its subfolders, and the events in them that the user owns to the trash
 // @Tags folders
 // @Produce json
 // @Param folderId path string true "Folder ID"
@@ -212,10 +302,602 @@ func DeleteFolder(c *gin.Context) {
 		return
 	}
 
-	err = db.DeleteFolder(folderId, userId)
+	err = getRepositories(c).Folders.Delete(folderId, userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
 		return
 	}
 	c.Status(http.StatusOK)
 }
+
+// @Summary Get the folders shared with the user
+// @Description Returns the folders other users shared with the user along with every folder inside them
+// @Tags folders
+// @Produce json
+// @Success 200 {array} models.Folder "The shared folders with events and the user's role in them"
+// @Failure 500 {object} map[string]string "Failed to get folders"
+// @Router /user/folders/shared [get]
+func GetSharedFolders(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	folders := getRepositories(c).Folders
+
+	sharedFolders, err := folders.GetShared(user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get folders"})
+		return
+	}
+
+	result := make([]models.Folder, 0)
+	added := make(models.Set[primitive.ObjectID])
+	for _, sharedFolder := range sharedFolders {
+		subfolders, _, err := getNestedSubfolders(folders, sharedFolder.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get folders"})
+			return
+		}
+
+		// Folders shared inside other shared folders are only listed once
+		for _, folder := range append([]models.Folder{sharedFolder}, subfolders...) {
+			if _, ok := added[folder.Id]; ok || folder.UserId == user.Id {
+				continue
+			}
+			added[folder.Id] = struct{}{}
+
+			_, role := getFolderAccess(folders, folder.Id, user.Id)
+			eventIds, err := folders.GetEventIds(folder.Id, folder.UserId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get folders"})
+				return
+			}
+			folder.EventIds = eventIds
+			folder.Role = role
+			result = append(result, folder)
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// @Summary Share a folder with another user
+// @Description Shares the folder and every folder inside it. Sharing it again with the same user changes their role
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Param payload body object{email=string,role=string} true "Email of the user to share the folder with and their role, either viewer or editor"
+// @Success 200
+// @Failure 400 {object} map[string]string "Invalid folder ID, role, or user"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Failure 500 {object} map[string]string "Failed to share folder"
+// @Router /user/folders/{folderId}/shares [put]
+func ShareFolder(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	var body struct {
+		Email string            `json:"email" binding:"required"`
+		Role  models.FolderRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.Role != models.FolderViewer && body.Role != models.FolderEditor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be either viewer or editor"})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	repositories := getRepositories(c)
+	folder, role := getFolderAccess(repositories.Folders, folderId, user.Id)
+	if folder == nil || role != models.FolderOwner {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	sharedWith, err := repositories.Users.GetByEmail(strings.ToLower(strings.TrimSpace(body.Email)))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if sharedWith == nil || sharedWith.Id == user.Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errs.UserDoesNotExist})
+		return
+	}
+
+	err = repositories.Folders.Share(folderId, models.FolderShare{UserId: sharedWith.Id, Role: body.Role})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share folder"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Stop sharing a folder with a user
+// @Description The owner can remove anyone, and users can remove themselves to leave a folder shared with them
+// @Tags folders
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Param userId path string true "ID of the user to stop sharing the folder with"
+// @Success 200
+// @Failure 400 {object} map[string]string "Invalid folder ID or user ID"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Failure 500 {object} map[string]string "Failed to stop sharing folder"
+// @Router /user/folders/{folderId}/shares/{userId} [delete]
+func UnshareFolder(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	sharedWithId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	folders := getRepositories(c).Folders
+	folder, role := getFolderAccess(folders, folderId, user.Id)
+	if folder == nil || (role != models.FolderOwner && sharedWithId != user.Id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	if err := folders.Unshare(folderId, sharedWithId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop sharing folder"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Remove an event from a folder
+// @Tags folders
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Param eventId path string true "Event ID"
+// @Success 200
+// @Failure 400 {object} map[string]string "Invalid folder ID or event ID"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Failure 500 {object} map[string]string "Failed to remove event from folder"
+// @Router /user/folders/{folderId}/events/{eventId} [delete]
+func RemoveEventFromFolder(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	eventId, err := primitive.ObjectIDFromHex(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	folders := getRepositories(c).Folders
+	folder, role := getFolderAccess(folders, folderId, user.Id)
+	if folder == nil || !role.Includes(models.FolderEditor) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	// Events are in at most one of the owner's folders, so this only removes it from this one
+	eventIds, err := folders.GetEventIds(folderId, folder.UserId)
+	if err == nil && utils.Contains(eventIds, eventId) {
+		err = folders.SetEventFolder(eventId, nil, folder.UserId)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove event from folder"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Move every event in a folder to another folder
+// @Description Editors of a shared folder only move the events they own
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Param payload body object{folderId=string} true "The folder to move the events to, or null to take them out of the folder"
+// @Success 200
+// @Failure 400 {object} map[string]string "Invalid folder ID"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Failure 500 {object} map[string]string "Failed to move events"
+// @Router /user/folders/{folderId}/move [post]
+func MoveFolderEvents(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	var body struct {
+		FolderId *string `json:"folderId"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	folders := getRepositories(c).Folders
+	folder, role := getFolderAccess(folders, folderId, user.Id)
+	if folder == nil || !role.Includes(models.FolderEditor) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	var target *models.Folder
+	if body.FolderId != nil {
+		targetId, err := primitive.ObjectIDFromHex(*body.FolderId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+		var targetRole models.FolderRole
+		target, targetRole = getFolderAccess(folders, targetId, user.Id)
+		if target == nil || !targetRole.Includes(models.FolderEditor) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+	}
+
+	eventIds, err := folders.GetEventIds(folderId, folder.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move events"})
+		return
+	}
+	for _, eventId := range eventIds {
+		// Editors can only move their own events out of a folder shared with them
+		if role != models.FolderOwner {
+			event, err := getRepositories(c).Events.GetById(eventId.Hex())
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+			if event == nil || event.OwnerId != user.Id {
+				continue
+			}
+		}
+
+		// Mappings belong to the owner of the folder, so moving between owners takes two steps
+		if target == nil || target.UserId != folder.UserId {
+			err = folders.SetEventFolder(eventId, nil, folder.UserId)
+		}
+		if err == nil && target != nil {
+			err = folders.SetEventFolder(eventId, &target.Id, target.UserId)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move events"})
+			return
+		}
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Archive or unarchive every event in a folder
+// @Description Only the events the user owns are changed
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Param payload body object{archive=bool} true "Archive status"
+// @Success 200 {object} object{updated=int} "The number of events that were changed"
+// @Failure 400 {object} map[string]string "Invalid folder ID or request body"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Failure 500 {object} map[string]string "Failed to archive events"
+// @Router /user/folders/{folderId}/archive [post]
+func ArchiveFolderEvents(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	var body struct {
+		Archive *bool `json:"archive" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	folders := getRepositories(c).Folders
+	folder, _ := getFolderAccess(folders, folderId, user.Id)
+	if folder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	eventIds, err := folders.GetEventIds(folderId, folder.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive events"})
+		return
+	}
+	updated, err := getRepositories(c).Events.SetArchived(eventIds, user.Id, *body.Archive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// @Summary Duplicate a folder along with the events in it
+// @Description Creates a new folder owned by the user next to the original one, or at the top level if the user
+// @Description doesn't own the original, and copies the events in the folder that the user owns into it
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Param payload body object{name=string,copyAvailability=bool} true "Name of the new folder and whether to copy the events' responses"
+// @Success 201 {object} object{folderId=string,eventIds=[]string} "The ID of the new folder and the IDs of the copied events"
+// @Failure 400 {object} map[string]string "Invalid folder ID or request body"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Failure 500 {object} map[string]string "Failed to duplicate folder"
+// @Router /user/folders/{folderId}/duplicate [post]
+func DuplicateFolder(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	var body struct {
+		Name             string `json:"name" binding:"required"`
+		CopyAvailability bool   `json:"copyAvailability"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	repositories := getRepositories(c)
+	folders := repositories.Folders
+	folder, role := getFolderAccess(folders, folderId, user.Id)
+	if folder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	duplicate := models.Folder{
+		UserId: user.Id,
+		Name:   body.Name,
+		Color:  folder.Color,
+	}
+	if role == models.FolderOwner {
+		duplicate.ParentId = folder.ParentId
+	}
+	duplicateId, err := folders.Create(&duplicate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to duplicate folder"})
+		return
+	}
+
+	eventIds, err := folders.GetEventIds(folderId, folder.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to duplicate folder"})
+		return
+	}
+	copiedEventIds := make([]string, 0)
+	for _, eventId := range eventIds {
+		// Like duplicating a single event, only the owner can copy an event
+		event, err := repositories.Events.GetById(eventId.Hex())
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if event == nil || event.OwnerId != user.Id {
+			continue
+		}
+
+		copiedEvent, err := copyEvent(repositories, event, event.Name, body.CopyAvailability)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if err := folders.SetEventFolder(copiedEvent.Id, &duplicateId, user.Id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to duplicate folder"})
+			return
+		}
+		copiedEventIds = append(copiedEventIds, copiedEvent.Id.Hex())
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"folderId": duplicateId.Hex(), "eventIds": copiedEventIds})
+}
+
+// @Summary Export the responses to every event in a folder
+// @Description Returns a zip file with a spreadsheet for each event in the folder that the user owns
+// @Tags folders
+// @Produce application/zip
+// @Param folderId path string true "Folder ID"
+// @Param format query string false "Format of the spreadsheets, either csv (default) or xlsx"
+// @Param timezone query string false "IANA timezone to show times in (i.e. America/New_York), takes precedence over timezoneOffset"
+// @Param timezoneOffset query int false "Minutes to subtract from UTC to get the client's local time (i.e. the result of Date.getTimezoneOffset())"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string "Invalid folder ID or query"
+// @Failure 404 {object} map[string]string "Folder not found"
+// @Router /user/folders/{folderId}/export [get]
+func ExportFolder(c *gin.Context) {
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be either csv or xlsx"})
+		return
+	}
+	location, err := getExportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	repositories := getRepositories(c)
+	folders := repositories.Folders
+	folder, _ := getFolderAccess(folders, folderId, user.Id)
+	if folder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	eventIds, err := folders.GetEventIds(folderId, folder.UserId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	fileNames := make(models.Set[string])
+	for _, eventId := range eventIds {
+		// Only the owner of an event can export its responses
+		event, err := repositories.Events.GetById(eventId.Hex())
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if event == nil || event.OwnerId != user.Id {
+			continue
+		}
+
+		// Events with the same name get numbered files
+		baseName := getExportFileName(event.Name)
+		fileName := fmt.Sprintf("%s.%s", baseName, format)
+		for i := 2; ; i++ {
+			if _, ok := fileNames[fileName]; !ok {
+				break
+			}
+			fileName = fmt.Sprintf("%s (%d).%s", baseName, i, format)
+		}
+		fileNames[fileName] = struct{}{}
+
+		writer, err := archive.Create(fileName)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if err := writeEventExport(repositories, writer, event, format, location); err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, getExportFileName(folder.Name)))
+	c.Data(http.StatusOK, "application/zip", buffer.Bytes())
+}
+
+// Returns whether the user owns the event, responded to it, or is an attendee of it, which are the events they can
+// put in their folders
+func canAccessEvent(repositories *db.Repositories, event *models.Event, user *models.User) (bool, error) {
+	if event.OwnerId == user.Id {
+		return true, nil
+	}
+	if _, ok := event.SignUpResponses[user.Id.Hex()]; ok {
+		return true, nil
+	}
+	eventResponses, err := repositories.Responses.GetByEventId(event.Id)
+	if err != nil {
+		return false, err
+	}
+	for _, eventResponse := range eventResponses {
+		if eventResponse.UserId == user.Id.Hex() {
+			return true, nil
+		}
+	}
+	if len(user.Email) > 0 {
+		attendee, err := repositories.Attendees.GetByEmail(event.Id, user.Email)
+		return attendee != nil, err
+	}
+	return false, nil
+}
+
+// Returns the folder and the user's role in it, or nil if it doesn't exist or the user can't see it. Users get
+// the highest role they were given on the folder or any of the folders it's in
+func getFolderAccess(folders db.FolderRepository, folderId primitive.ObjectID, userId primitive.ObjectID) (*models.Folder, models.FolderRole) {
+	folder, err := folders.Get(folderId)
+	if err != nil {
+		return nil, ""
+	}
+	if folder.UserId == userId {
+		return folder, models.FolderOwner
+	}
+
+	role := models.FolderRole("")
+	current := folder
+	for depth := 0; depth <= maxFolderDepth; depth++ {
+		for _, share := range current.SharedWith {
+			if share.UserId == userId && share.Role.Includes(role) {
+				role = share.Role
+			}
+		}
+		if current.ParentId == nil {
+			break
+		}
+		if current, err = folders.Get(*current.ParentId); err != nil {
+			break
+		}
+	}
+
+	if len(role) == 0 {
+		return nil, ""
+	}
+	return folder, role
+}
+
+// Returns the number of levels the folder is nested at, where folders at the top level are at level 1
+func getFolderDepth(folders db.FolderRepository, folder *models.Folder) int {
+	depth := 1
+	for folder.ParentId != nil && depth <= maxFolderDepth {
+		parent, err := folders.Get(*folder.ParentId)
+		if err != nil {
+			break
+		}
+		folder = parent
+		depth++
+	}
+	return depth
+}
+
+// Returns every folder nested inside the folder and the number of levels they span
+func getNestedSubfolders(folders db.FolderRepository, folderId primitive.ObjectID) ([]models.Folder, int, error) {
+	subfolders := make([]models.Folder, 0)
+	visited := models.Set[primitive.ObjectID]{folderId: struct{}{}}
+	level := []primitive.ObjectID{folderId}
+	height := 0
+	for len(level) > 0 && height <= maxFolderDepth {
+		nextLevel := make([]primitive.ObjectID, 0)
+		for _, parentId := range level {
+			children, err := folders.GetSubfolders(parentId)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, child := range children {
+				if _, ok := visited[child.Id]; ok {
+					continue
+				}
+				visited[child.Id] = struct{}{}
+				subfolders = append(subfolders, child)
+				nextLevel = append(nextLevel, child.Id)
+			}
+		}
+		if len(nextLevel) > 0 {
+			height++
+		}
+		level = nextLevel
+	}
+	return subfolders, height, nil
+}
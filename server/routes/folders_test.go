@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db/memory"
+	"schej.it/server/models"
+)
+
+func TestGetFolderAccess(t *testing.T) {
+	folders := memory.New().Folders
+	owner := primitive.NewObjectID()
+	editor := primitive.NewObjectID()
+	viewer := primitive.NewObjectID()
+
+	// Work (shared with the viewer) > Projects (shared with the editor and the viewer as an editor) > Q3
+	workId, _ := folders.Create(&models.Folder{UserId: owner, Name: "Work", SharedWith: []models.FolderShare{{UserId: viewer, Role: models.FolderViewer}}})
+	projectsId, _ := folders.Create(&models.Folder{UserId: owner, Name: "Projects", ParentId: &workId, SharedWith: []models.FolderShare{{UserId: editor, Role: models.FolderEditor}}})
+	q3Id, _ := folders.Create(&models.Folder{UserId: owner, Name: "Q3", ParentId: &projectsId})
+	folders.Share(projectsId, models.FolderShare{UserId: viewer, Role: models.FolderEditor})
+
+	tests := []struct {
+		folderId primitive.ObjectID
+		userId   primitive.ObjectID
+		role     models.FolderRole
+	}{
+		{q3Id, owner, models.FolderOwner},
+		{workId, editor, ""},
+		{q3Id, editor, models.FolderEditor},
+		{workId, viewer, models.FolderViewer},
+		{q3Id, viewer, models.FolderEditor},
+		{q3Id, primitive.NewObjectID(), ""},
+	}
+	for _, test := range tests {
+		folder, role := getFolderAccess(folders, test.folderId, test.userId)
+		if role != test.role || (folder == nil) != (len(test.role) == 0) {
+			t.Errorf("getFolderAccess(%s, %s) = %v, %q, want role %q", test.folderId.Hex(), test.userId.Hex(), folder, role, test.role)
+		}
+	}
+
+	subfolders, height, err := getNestedSubfolders(folders, workId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subfolders) != 2 || height != 2 {
+		t.Errorf("getNestedSubfolders() = %d folders spanning %d levels, want 2 spanning 2", len(subfolders), height)
+	}
+	if depth := getFolderDepth(folders, &models.Folder{ParentId: &q3Id}); depth != 4 {
+		t.Errorf("getFolderDepth() = %d, want 4", depth)
+	}
+
+	// Deleting a folder deletes the folders inside it
+	folders.Delete(workId, owner)
+	if _, role := getFolderAccess(folders, q3Id, owner); role != "" {
+		t.Errorf("getFolderAccess() of a folder in a deleted folder = %q, want no access", role)
+	}
+}
+
+func TestSetEventFolderAccess(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+	workId, _ := database.Folders.Create(&models.Folder{UserId: bob.Id, Name: "Work"})
+	private := &models.Event{OwnerId: primitive.NewObjectID(), Name: "Board meeting", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(private)
+	lunch := &models.Event{OwnerId: primitive.NewObjectID(), Name: "Lunch", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(lunch)
+	database.Attendees.Insert(&models.Attendee{EventId: lunch.Id, Email: bob.Email})
+
+	payload := map[string]interface{}{"folderId": workId.Hex()}
+	if w := sendRequest(t, router, http.MethodPost, "/api/user/events/"+private.Id.Hex()+"/set-folder", bob.Id.Hex(), payload); w.Code != http.StatusNotFound {
+		t.Errorf("putting someone else's event in a folder = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := sendRequest(t, router, http.MethodPost, "/api/user/events/"+lunch.Id.Hex()+"/set-folder", bob.Id.Hex(), payload); w.Code != http.StatusOK {
+		t.Errorf("putting an event the user is invited to in a folder = %d %s, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+	if eventIds, _ := database.Folders.GetEventIds(workId, bob.Id); len(eventIds) != 1 || eventIds[0] != lunch.Id {
+		t.Errorf("folder has events %v, want [%s]", eventIds, lunch.Id.Hex())
+	}
+}
+
+func TestMoveFolderEventsAsEditor(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	owner := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(owner)
+	editor := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(editor)
+
+	sharedId, _ := database.Folders.Create(&models.Folder{UserId: owner.Id, Name: "Team", SharedWith: []models.FolderShare{{UserId: editor.Id, Role: models.FolderEditor}}})
+	mineId, _ := database.Folders.Create(&models.Folder{UserId: editor.Id, Name: "Mine"})
+	ownersEvent := &models.Event{OwnerId: owner.Id, Name: "Planning", Type: models.SPECIFIC_DATES}
+	editorsEvent := &models.Event{OwnerId: editor.Id, Name: "Standup", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(ownersEvent)
+	database.Events.Insert(editorsEvent)
+	database.Folders.SetEventFolder(ownersEvent.Id, &sharedId, owner.Id)
+	database.Folders.SetEventFolder(editorsEvent.Id, &sharedId, owner.Id)
+
+	// Only the editor's own event leaves the shared folder
+	w := sendRequest(t, router, http.MethodPost, "/api/user/folders/"+sharedId.Hex()+"/move", editor.Id.Hex(), map[string]interface{}{"folderId": mineId.Hex()})
+	if w.Code != http.StatusOK {
+		t.Fatalf("moving the events = %d %s", w.Code, w.Body.String())
+	}
+	if eventIds, _ := database.Folders.GetEventIds(sharedId, owner.Id); len(eventIds) != 1 || eventIds[0] != ownersEvent.Id {
+		t.Errorf("shared folder has events %v, want [%s]", eventIds, ownersEvent.Id.Hex())
+	}
+	if eventIds, _ := database.Folders.GetEventIds(mineId, editor.Id); len(eventIds) != 1 || eventIds[0] != editorsEvent.Id {
+		t.Errorf("editor's folder has events %v, want [%s]", eventIds, editorsEvent.Id.Hex())
+	}
+}
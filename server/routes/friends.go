@@ -0,0 +1,344 @@
+/* The /user/friends group contains the routes to manage a user's friends, which they can add to groups */
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"schej.it/server/errs"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/responses"
+	"schej.it/server/services/listmonk"
+	"schej.it/server/utils"
+)
+
+func InitFriends(router *gin.RouterGroup) {
+	friendRouter := router.Group("/user/friends")
+	friendRouter.Use(middleware.AuthRequired())
+
+	friendRouter.GET("", getFriends)
+	friendRouter.GET("/search", searchFriends)
+	friendRouter.DELETE("/:userId", removeFriend)
+	friendRouter.POST("/add-to-group", addFriendsToGroup)
+	friendRouter.GET("/requests", getFriendRequests)
+	friendRouter.POST("/requests", sendFriendRequest)
+	friendRouter.POST("/requests/:requestId/accept", acceptFriendRequest)
+	friendRouter.POST("/requests/:requestId/decline", declineFriendRequest)
+	friendRouter.DELETE("/requests/:requestId", cancelFriendRequest)
+}
+
+// @Summary Gets the user's friends
+// @Tags friends
+// @Produce json
+// @Success 200 {object} []models.User
+// @Router /user/friends [get]
+func getFriends(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	friends, err := getRepositories(c).Users.GetPublic(user.FriendIds)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, friends)
+}
+
+// @Summary Searches the user's friends by name and email
+// @Tags friends
+// @Produce json
+// @Param query query string false "Words that the friend's name or email has to contain"
+// @Success 200 {object} []models.User
+// @Router /user/friends/search [get]
+func searchFriends(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	friends, err := getRepositories(c).Users.GetPublic(user.FriendIds)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.FilterUsers(friends, c.Query("query")))
+}
+
+// @Summary Removes a user from the user's friends
+// @Tags friends
+// @Produce json
+// @Param userId path string true "User ID of the friend"
+// @Success 200
+// @Router /user/friends/{userId} [delete]
+func removeFriend(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	friendId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil || !utils.Contains(user.FriendIds, friendId) {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.UserNotFriends})
+		return
+	}
+
+	if err := getRepositories(c).Users.RemoveFriends(user.Id, friendId); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Gets the friend requests sent to and sent by the user
+// @Tags friends
+// @Produce json
+// @Success 200 {object} object{incoming=[]models.FriendRequest,outgoing=[]models.FriendRequest}
+// @Router /user/friends/requests [get]
+func getFriendRequests(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	incoming, outgoing, err := getRepositories(c).FriendRequests.GetForUser(user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"incoming": incoming, "outgoing": outgoing})
+}
+
+// @Summary Sends a friend request to the user with the given email
+// @Description Responds the same whether or not the email belongs to an account, so it can't be used to find out who has one.
+// @Description If that user already sent the signed in user a friend request, it is accepted instead
+// @Tags friends
+// @Accept json
+// @Produce json
+// @Param payload body object{email=string} true "Email of the user to send the friend request to"
+// @Success 200
+// @Router /user/friends/requests [post]
+func sendFriendRequest(c *gin.Context) {
+	payload := struct {
+		Email string `json:"email" binding:"required"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+	user := utils.GetAuthUser(c)
+
+	repositories := getRepositories(c)
+	to, err := repositories.Users.GetByEmail(strings.ToLower(strings.TrimSpace(payload.Email)))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if to == nil || to.Id == user.Id || utils.Contains(user.FriendIds, to.Id) {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	friendRequest, err := repositories.FriendRequests.GetBetween(user.Id, to.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if friendRequest == nil {
+		friendRequest, err = repositories.FriendRequests.Create(user.Id, to.Id)
+		if mongo.IsDuplicateKeyError(err) {
+			// The same request was sent at the same time
+			friendRequest, err = repositories.FriendRequests.GetBetween(user.Id, to.Id)
+		}
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+	}
+
+	// Both users want to be friends, so there's no need to wait for the other user to accept
+	if friendRequest.To == user.Id {
+		if err := repositories.FriendRequests.Accept(friendRequest); err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Accepts a friend request sent to the user
+// @Tags friends
+// @Produce json
+// @Param requestId path string true "Friend request ID"
+// @Success 200
+// @Router /user/friends/requests/{requestId}/accept [post]
+func acceptFriendRequest(c *gin.Context) {
+	friendRequest := getUserFriendRequest(c, func(user *models.User, friendRequest *models.FriendRequest) bool {
+		return friendRequest.To == user.Id
+	})
+	if friendRequest == nil {
+		return
+	}
+
+	if err := getRepositories(c).FriendRequests.Accept(friendRequest); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Declines a friend request sent to the user
+// @Tags friends
+// @Produce json
+// @Param requestId path string true "Friend request ID"
+// @Success 200
+// @Router /user/friends/requests/{requestId}/decline [post]
+func declineFriendRequest(c *gin.Context) {
+	friendRequest := getUserFriendRequest(c, func(user *models.User, friendRequest *models.FriendRequest) bool {
+		return friendRequest.To == user.Id
+	})
+	if friendRequest == nil {
+		return
+	}
+
+	if err := getRepositories(c).FriendRequests.Delete(friendRequest.Id); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Cancels a friend request sent by the user
+// @Tags friends
+// @Produce json
+// @Param requestId path string true "Friend request ID"
+// @Success 200
+// @Router /user/friends/requests/{requestId} [delete]
+func cancelFriendRequest(c *gin.Context) {
+	friendRequest := getUserFriendRequest(c, func(user *models.User, friendRequest *models.FriendRequest) bool {
+		return friendRequest.From == user.Id
+	})
+	if friendRequest == nil {
+		return
+	}
+
+	if err := getRepositories(c).FriendRequests.Delete(friendRequest.Id); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Adds friends of the user to an availability group they own
+// @Description Friends that are already attendees of the group are skipped
+// @Tags friends
+// @Accept json
+// @Produce json
+// @Param payload body object{eventId=string,friendIds=[]string,role=string} true "The group, the user IDs of the friends to add, and whether they are required (default) or optional"
+// @Success 200 {object} object{added=[]string,alreadyInvited=[]string}
+// @Router /user/friends/add-to-group [post]
+func addFriendsToGroup(c *gin.Context) {
+	payload := struct {
+		EventId   string                 `json:"eventId" binding:"required"`
+		FriendIds []primitive.ObjectID   `json:"friendIds" binding:"required"`
+		Role      models.ParticipantRole `json:"role"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
+	if len(payload.Role) > 0 && payload.Role != models.REQUIRED_PARTICIPANT && payload.Role != models.OPTIONAL_PARTICIPANT {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "Role must be either required or optional"})
+		return
+	}
+	user := utils.GetAuthUser(c)
+	for _, friendId := range payload.FriendIds {
+		if !utils.Contains(user.FriendIds, friendId) {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: errs.UserNotFriends})
+			return
+		}
+	}
+
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetByEitherId(payload.EventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+	if event.OwnerId != user.Id {
+		c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
+		return
+	}
+	if event.Type != models.GROUP {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: errs.EventNotGroup})
+		return
+	}
+
+	friends, err := repositories.Users.GetPublic(payload.FriendIds)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	existingAttendees, err := repositories.Attendees.GetByEventId(event.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	existing := utils.ArrayToSet(utils.Map(existingAttendees, func(a models.Attendee) string { return strings.ToLower(a.Email) }))
+
+	added := make([]string, 0)
+	alreadyInvited := make([]string, 0)
+	attendees := make([]models.Attendee, 0)
+	for _, friend := range friends {
+		if _, ok := existing[strings.ToLower(friend.Email)]; ok {
+			alreadyInvited = append(alreadyInvited, friend.Email)
+			continue
+		}
+
+		attendees = append(attendees, models.Attendee{
+			Name:     strings.TrimSpace(friend.FirstName + " " + friend.LastName),
+			Email:    friend.Email,
+			Role:     payload.Role,
+			Declined: utils.FalsePtr(),
+			EventId:  event.Id,
+		})
+		added = append(added, friend.Email)
+	}
+	if len(attendees) > 0 {
+		if err := repositories.Attendees.InsertMany(attendees); err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+	}
+
+	// Only email the friends once they've been added as attendees
+	for _, email := range added {
+		listmonk.SendEmailAddSubscriberIfNotExist(c.Request.Context(), email, listmonk.GroupInviteEmailId(), bson.M{
+			"ownerName": user.FirstName,
+			"groupName": event.Name,
+			"groupUrl":  fmt.Sprintf("%s/g/%s", utils.GetBaseUrl(), event.GetId()),
+		}, false)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"added": added, "alreadyInvited": alreadyInvited})
+}
+
+// Returns the friend request with the requestId in the url if `canAccess` returns true for the signed in user,
+// otherwise responds with a 404 and returns nil
+func getUserFriendRequest(c *gin.Context, canAccess func(*models.User, *models.FriendRequest) bool) *models.FriendRequest {
+	user := utils.GetAuthUser(c)
+	friendRequest, err := getRepositories(c).FriendRequests.GetById(c.Param("requestId"))
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return nil
+	}
+	if friendRequest == nil || !canAccess(user, friendRequest) {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.FriendRequestNotFound})
+		return nil
+	}
+	return friendRequest
+}
@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db/memory"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func TestSendFriendRequest(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(alice)
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+
+	// Whether or not the email has an account can't be told from the response
+	unknown := sendRequest(t, router, http.MethodPost, "/api/user/friends/requests", alice.Id.Hex(), map[string]string{"email": "nobody@example.com"})
+	known := sendRequest(t, router, http.MethodPost, "/api/user/friends/requests", alice.Id.Hex(), map[string]string{"email": "Bob@example.com"})
+	if unknown.Code != known.Code || unknown.Body.String() != known.Body.String() {
+		t.Errorf("unknown email = %d %s, known email = %d %s, want the same response", unknown.Code, unknown.Body.String(), known.Code, known.Body.String())
+	}
+	self := sendRequest(t, router, http.MethodPost, "/api/user/friends/requests", alice.Id.Hex(), map[string]string{"email": "alice@example.com"})
+	if self.Code != known.Code || self.Body.String() != known.Body.String() {
+		t.Errorf("own email = %d %s, want %d %s", self.Code, self.Body.String(), known.Code, known.Body.String())
+	}
+
+	friendRequest, _ := database.FriendRequests.GetBetween(alice.Id, bob.Id)
+	if friendRequest == nil || friendRequest.From != alice.Id || friendRequest.To != bob.Id {
+		t.Fatalf("friend request = %+v, want one from Alice to Bob", friendRequest)
+	}
+
+	// Bob sending a request back accepts Alice's
+	w := sendRequest(t, router, http.MethodPost, "/api/user/friends/requests", bob.Id.Hex(), map[string]string{"email": "alice@example.com"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /user/friends/requests = %d %s", w.Code, w.Body.String())
+	}
+	if friend := mustGetUser(t, database, alice.Id); !utils.Contains(friend.FriendIds, bob.Id) {
+		t.Errorf("Alice's friends = %v, want Bob", friend.FriendIds)
+	}
+	if friend := mustGetUser(t, database, bob.Id); !utils.Contains(friend.FriendIds, alice.Id) {
+		t.Errorf("Bob's friends = %v, want Alice", friend.FriendIds)
+	}
+
+	// Friend ids aren't sent along with the user
+	body, _ := json.Marshal(mustGetUser(t, database, alice.Id))
+	if strings.Contains(string(body), "friendIds") {
+		t.Errorf("user json = %s, want no friendIds", body)
+	}
+}
+
+func TestAcceptFriendRequest(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(alice)
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+	friendRequest, _ := database.FriendRequests.Create(alice.Id, bob.Id)
+
+	// Only the user the request was sent to can accept it
+	w := sendRequest(t, router, http.MethodPost, "/api/user/friends/requests/"+friendRequest.Id.Hex()+"/accept", alice.Id.Hex(), nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("accepting own friend request = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w = sendRequest(t, router, http.MethodPost, "/api/user/friends/requests/"+friendRequest.Id.Hex()+"/accept", bob.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("accepting friend request = %d %s", w.Code, w.Body.String())
+	}
+
+	w = sendRequest(t, router, http.MethodGet, "/api/user/friends", alice.Id.Hex(), nil)
+	var friends []models.User
+	json.Unmarshal(w.Body.Bytes(), &friends)
+	if len(friends) != 1 || friends[0].Id != bob.Id {
+		t.Errorf("GET /user/friends = %s, want Bob", w.Body.String())
+	}
+}
+
+func TestAddFriendsToGroup(t *testing.T) {
+	t.Setenv("LISTMONK_ENABLED", "false")
+	database := memory.New()
+	router := newTestRouter(database)
+
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob", LastName: "Smith"}
+	database.Users.Insert(bob)
+	sam := &models.User{Email: "sam@example.com", FirstName: "Sam"}
+	database.Users.Insert(sam)
+	stranger := &models.User{Email: "stranger@example.com", FirstName: "Stranger"}
+	database.Users.Insert(stranger)
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice", FriendIds: []primitive.ObjectID{bob.Id, sam.Id}}
+	database.Users.Insert(alice)
+
+	group := &models.Event{OwnerId: alice.Id, Name: "Book club", Type: models.GROUP}
+	database.Events.Insert(group)
+	database.Attendees.Insert(&models.Attendee{EventId: group.Id, Email: "sam@example.com", Declined: utils.FalsePtr()})
+
+	// Only friends can be added
+	w := sendRequest(t, router, http.MethodPost, "/api/user/friends/add-to-group", alice.Id.Hex(), map[string]interface{}{
+		"eventId":   group.Id.Hex(),
+		"friendIds": []primitive.ObjectID{stranger.Id},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("adding a user that isn't a friend = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = sendRequest(t, router, http.MethodPost, "/api/user/friends/add-to-group", alice.Id.Hex(), map[string]interface{}{
+		"eventId":   group.Id.Hex(),
+		"friendIds": []primitive.ObjectID{bob.Id, sam.Id},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /user/friends/add-to-group = %d %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Added          []string `json:"added"`
+		AlreadyInvited []string `json:"alreadyInvited"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Added) != 1 || response.Added[0] != bob.Email || len(response.AlreadyInvited) != 1 || response.AlreadyInvited[0] != sam.Email {
+		t.Errorf("POST /user/friends/add-to-group = %s, want Bob added and Sam already invited", w.Body.String())
+	}
+	if attendee, _ := database.Attendees.GetByEmail(group.Id, bob.Email); attendee == nil || attendee.Name != "Bob Smith" {
+		t.Errorf("Bob's attendee = %+v", attendee)
+	}
+}
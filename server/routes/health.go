@@ -0,0 +1,63 @@
+/* The /healthz and /readyz routes are polled by container runtimes and load balancers */
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/db"
+	"schej.it/server/utils"
+)
+
+// How long to wait for the database to respond to a health check
+const healthCheckTimeout = 2 * time.Second
+
+// Replaced in tests
+var pingDatabase = db.Ping
+
+func InitHealth(router *gin.RouterGroup) {
+	router.GET("/healthz", healthz)
+	router.GET("/readyz", readyz)
+}
+
+// @Summary Checks whether the server is running, and reports whether it can reach the database
+// @Description Succeeds even if the database is unreachable, so that the server isn't restarted while the database is down
+// @Tags health
+// @Produce json
+// @Success 200 {object} object{status=string,database=string}
+// @Router /healthz [get]
+func healthz(c *gin.Context) {
+	database := "ok"
+	if err := pingDatabaseWithTimeout(c); err != nil {
+		database = "unreachable"
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "database": database})
+}
+
+// @Summary Checks whether the server can handle requests, i.e. whether it can reach the database
+// @Tags health
+// @Produce json
+// @Success 200 {object} object{status=string}
+// @Failure 503 {object} object{status=string,error=string}
+// @Router /readyz [get]
+func readyz(c *gin.Context) {
+	if err := pingDatabaseWithTimeout(c); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "database unreachable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+func pingDatabaseWithTimeout(c *gin.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+	err := pingDatabase(ctx)
+	if err != nil {
+		utils.GetLogger(c).Warn("couldn't reach the database", "error", err)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"schej.it/server/db/memory"
+)
+
+func TestHealthChecks(t *testing.T) {
+	router := newTestRouter(memory.New())
+	InitHealth(&router.RouterGroup)
+
+	var pingErr error
+	defer func(original func(context.Context) error) { pingDatabase = original }(pingDatabase)
+	pingDatabase = func(ctx context.Context) error { return pingErr }
+
+	tests := []struct {
+		pingErr       error
+		path          string
+		status        int
+		expectedField string
+		expectedValue string
+	}{
+		{nil, "/healthz", http.StatusOK, "database", "ok"},
+		{nil, "/readyz", http.StatusOK, "status", "ready"},
+		{errors.New("server selection timeout"), "/healthz", http.StatusOK, "database", "unreachable"},
+		{errors.New("server selection timeout"), "/readyz", http.StatusServiceUnavailable, "status", "unavailable"},
+	}
+	for _, test := range tests {
+		pingErr = test.pingErr
+		w := sendRequest(t, router, http.MethodGet, test.path, "", nil)
+		if w.Code != test.status {
+			t.Errorf("GET %s with ping error %v = %d, want %d", test.path, test.pingErr, w.Code, test.status)
+		}
+		var body gin.H
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body[test.expectedField] != test.expectedValue {
+			t.Errorf("GET %s with ping error %v returned %v, want %s %q", test.path, test.pingErr, body, test.expectedField, test.expectedValue)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/responses"
+)
+
+// Default and maximum number of items on a page
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// Returns the cursor and limit query parameters of a paginated route. Responds with a 400 and returns
+// false if they're invalid
+func getPage(c *gin.Context) (*primitive.ObjectID, int, bool) {
+	limit := defaultPageLimit
+	if limitString := c.Query("limit"); len(limitString) > 0 {
+		var err error
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: "limit must be a positive integer"})
+			return nil, 0, false
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+
+	var cursor *primitive.ObjectID
+	if cursorString := c.Query("cursor"); len(cursorString) > 0 {
+		objectId, err := primitive.ObjectIDFromHex(cursorString)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: "invalid cursor"})
+			return nil, 0, false
+		}
+		cursor = &objectId
+	}
+	return cursor, limit, true
+}
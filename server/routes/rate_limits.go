@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"schej.it/server/ratelimit"
+)
+
+// Limits of the routes that can be called without signing in. They can be overridden by name with RATE_LIMITS.
+// Signed in users are limited by their own counts rather than sharing their IP's
+var (
+	createEventPerIp   = ratelimit.Rule{Name: "create-event-per-ip", Scope: ratelimit.PerIp, Limit: 30, Window: time.Hour, GuestsOnly: true}
+	createEventPerUser = ratelimit.Rule{Name: "create-event-per-user", Scope: ratelimit.PerUser, Limit: 60, Window: time.Hour}
+
+	guestResponsePerIp    = ratelimit.Rule{Name: "guest-response-per-ip", Scope: ratelimit.PerIp, Limit: 60, Window: 10 * time.Minute, GuestsOnly: true}
+	guestResponsePerEvent = ratelimit.Rule{Name: "guest-response-per-event", Scope: ratelimit.PerEvent, Limit: 300, Window: time.Hour, GuestsOnly: true}
+
+	renameUserPerIp    = ratelimit.Rule{Name: "rename-user-per-ip", Scope: ratelimit.PerIp, Limit: 30, Window: 10 * time.Minute}
+	renameUserPerEvent = ratelimit.Rule{Name: "rename-user-per-event", Scope: ratelimit.PerEvent, Limit: 60, Window: time.Hour}
+
+	userRespondedPerIp = ratelimit.Rule{Name: "user-responded-per-ip", Scope: ratelimit.PerIp, Limit: 30, Window: 10 * time.Minute}
+
+	scannedPosterPerIp = ratelimit.Rule{Name: "scanned-poster-per-ip", Scope: ratelimit.PerIp, Limit: 30, Window: time.Hour}
+)
+
+// Default number of guests that can respond to an event
+const defaultMaxGuestResponses = 500
+
+// Returns the number of guests that can respond to an event, which is MAX_GUEST_RESPONSES_PER_EVENT if it's set.
+// 0 means there's no limit
+func getMaxGuestResponses() int {
+	if maxGuestResponses, err := strconv.Atoi(os.Getenv("MAX_GUEST_RESPONSES_PER_EVENT")); err == nil && maxGuestResponses >= 0 {
+		return maxGuestResponses
+	}
+	return defaultMaxGuestResponses
+}
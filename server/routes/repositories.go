@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"schej.it/server/db"
+)
+
+// Returns the repositories set by middleware.Repositories, falling back to the Mongo collections
+func getRepositories(c *gin.Context) *db.Repositories {
+	if repositories := db.RepositoriesFrom(c.Request.Context()); repositories != nil {
+		return repositories
+	}
+	return db.NewMongoRepositories()
+}
@@ -1,11 +1,9 @@
 package routes
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,9 +15,6 @@ import (
 	"github.com/stripe/stripe-go/v82/price"
 	"github.com/stripe/stripe-go/v82/webhook"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"schej.it/server/db"
-	"schej.it/server/logger"
 	"schej.it/server/slackbot"
 	"schej.it/server/utils"
 )
@@ -55,7 +50,7 @@ func createCheckoutSession(c *gin.Context) {
 	baseURL := utils.GetBaseUrl()
 	intermediateRedirectBase, err := url.Parse(baseURL)
 	if err != nil {
-		logger.StdErr.Printf("Error parsing Base URL '%s': %v. Cannot construct redirect URLs.", baseURL, err)
+		utils.GetLogger(c).Error("couldn't parse the base url to construct the redirect urls", "baseUrl", baseURL, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error configuring redirect"})
 		return
 	}
@@ -93,6 +88,7 @@ func createCheckoutSession(c *gin.Context) {
 		// Provide the Customer ID (for example, cus_1234) for an existing customer to associate it with this session
 		// Customer: "cus_RnhPlBnbBbXapY",
 	}
+	params.Context = c.Request.Context()
 	if *payload.IsSubscription {
 		params.Mode = stripe.String(string(stripe.CheckoutSessionModeSubscription))
 	} else {
@@ -104,7 +100,7 @@ func createCheckoutSession(c *gin.Context) {
 	s, err := session.New(params)
 
 	if err != nil {
-		log.Printf("session.New: %v", err)
+		utils.GetLogger(c).Error("couldn't create the checkout session", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkout session"})
 		return
 	}
@@ -150,44 +146,45 @@ func getPrice(c *gin.Context) {
 	}
 
 	params := &stripe.PriceParams{}
+	params.Context = c.Request.Context()
 	monthlyResult, err := price.Get(monthlyPriceId, params)
 	if err != nil {
-		log.Printf("price.Get error: %v", err)
+		utils.GetLogger(c).Error("couldn't get the price", "priceId", monthlyPriceId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
 		return
 	}
 
 	lifetimeResult, err := price.Get(lifetimePriceId, params)
 	if err != nil {
-		log.Printf("price.Get error: %v", err)
+		utils.GetLogger(c).Error("couldn't get the price", "priceId", lifetimePriceId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
 		return
 	}
 
 	monthlyStudentResult, err := price.Get(monthlyStudentPriceId, params)
 	if err != nil {
-		log.Printf("price.Get error: %v", err)
+		utils.GetLogger(c).Error("couldn't get the price", "priceId", monthlyStudentPriceId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
 		return
 	}
 
 	lifetimeStudentResult, err := price.Get(lifetimeStudentPriceId, params)
 	if err != nil {
-		log.Printf("price.Get error: %v", err)
+		utils.GetLogger(c).Error("couldn't get the price", "priceId", lifetimeStudentPriceId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
 		return
 	}
 
 	yearlyResult, err := price.Get(yearlyPriceId, params)
 	if err != nil {
-		log.Printf("price.Get error: %v", err)
+		utils.GetLogger(c).Error("couldn't get the price", "priceId", yearlyPriceId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
 		return
 	}
 
 	yearlyStudentResult, err := price.Get(yearlyStudentPriceId, params)
 	if err != nil {
-		log.Printf("price.Get error: %v", err)
+		utils.GetLogger(c).Error("couldn't get the price", "priceId", yearlyStudentPriceId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
 		return
 	}
@@ -213,10 +210,13 @@ func fulfillCheckout(c *gin.Context) {
 		return
 	}
 
-	_fulfillCheckout(payload.SessionID)
+	_fulfillCheckout(c, payload.SessionID)
 }
 
-func _fulfillCheckout(sessionId string) {
+func _fulfillCheckout(c *gin.Context, sessionId string) {
+	ctx := c.Request.Context()
+	log := utils.GetLogger(c).With("checkoutSessionId", sessionId)
+
 	// TODO: Make this function safe to run multiple times,
 	// even concurrently, with the same session ID
 
@@ -226,26 +226,31 @@ func _fulfillCheckout(sessionId string) {
 	// Retrieve the Checkout Session from the API with line_items expanded
 	params := &stripe.CheckoutSessionParams{}
 	params.AddExpand("line_items")
+	params.Context = ctx
 
-	cs, _ := session.Get(sessionId, params)
+	cs, err := session.Get(sessionId, params)
+	if err != nil {
+		log.Error("couldn't get the checkout session", "error", err)
+		return
+	}
 
 	// Check the Checkout Session's payment_status property
 	// to determine if fulfillment should be performed
 	if cs.PaymentStatus != stripe.CheckoutSessionPaymentStatusUnpaid {
-		logger.StdOut.Println("Fulfilling Checkout Session " + sessionId)
+		log.Info("fulfilling checkout session")
 		if cs.Customer != nil {
-			logger.StdOut.Println("Setting stripe customer ID", cs.Customer.ID)
+			log.Info("setting the stripe customer id", "customerId", cs.Customer.ID)
 
 			// Fetch user from database
 			userId := cs.ClientReferenceID
-			userIdObj, err := primitive.ObjectIDFromHex(userId)
+			repositories := getRepositories(c)
+			user, err := repositories.Users.GetById(userId)
 			if err != nil {
-				logger.StdErr.Printf("Error parsing user ID: %v", err)
+				log.Error("couldn't get the user", "userId", userId, "error", err)
 				return
 			}
-			user := db.GetUserById(userId)
 			if user == nil {
-				logger.StdErr.Printf("Error getting user: %v", err)
+				log.Error("couldn't find the user", "userId", userId)
 				return
 			}
 
@@ -272,9 +277,13 @@ func _fulfillCheckout(sessionId string) {
 					slackbot.SendTextMessageWithType(message, slackbot.MONETIZATION)
 				}
 
-				user.StripeCustomerId = &cs.Customer.ID
-				user.IsPremium = utils.TruePtr()
-				db.UsersCollection.UpdateOne(context.Background(), bson.M{"_id": userIdObj}, bson.M{"$set": user})
+				err := repositories.Users.SetFields(user.Id, bson.M{
+					"stripeCustomerId": cs.Customer.ID,
+					"isPremium":        true,
+				})
+				if err != nil {
+					log.Error("couldn't upgrade the user", "userId", userId, "error", err)
+				}
 			}
 		}
 	}
@@ -286,7 +295,7 @@ func stripeWebhook(c *gin.Context) {
 
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		logger.StdErr.Printf("Error reading request body: %v", err)
+		utils.GetLogger(c).Error("couldn't read the webhook body", "error", err)
 		c.AbortWithStatus(http.StatusServiceUnavailable)
 		return
 	}
@@ -295,14 +304,14 @@ func stripeWebhook(c *gin.Context) {
 	// Use the secret provided by your webhook endpoint settings or Stripe CLI.
 	endpointSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
 	if endpointSecret == "" {
-		logger.StdErr.Println("STRIPE_WEBHOOK_SECRET not set")
+		utils.GetLogger(c).Error("STRIPE_WEBHOOK_SECRET isn't set")
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 	event, err := webhook.ConstructEvent(body, c.GetHeader("Stripe-Signature"), endpointSecret)
 
 	if err != nil {
-		logger.StdErr.Printf("Error verifying webhook signature: %v", err)
+		utils.GetLogger(c).Warn("couldn't verify the webhook signature", "error", err)
 		c.AbortWithStatus(http.StatusBadRequest) // Return a 400 error on a bad signature
 		return
 	}
@@ -312,37 +321,58 @@ func stripeWebhook(c *gin.Context) {
 		var cs stripe.CheckoutSession
 		err := json.Unmarshal(event.Data.Raw, &cs)
 		if err != nil {
-			logger.StdErr.Printf("Error parsing webhook JSON: %v\n", err)
+			utils.GetLogger(c).Warn("couldn't parse the webhook event", "eventType", event.Type, "error", err)
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
-		logger.StdOut.Printf("Checkout Session %s completed!\n", cs.ID)
-		_fulfillCheckout(cs.ID) // Call fulfillCheckout when session is completed
+		utils.GetLogger(c).Info("checkout session completed", "checkoutSessionId", cs.ID)
+		_fulfillCheckout(c, cs.ID) // Call fulfillCheckout when session is completed
 	} else if event.Type == stripe.EventTypeInvoicePaid {
 		var inv stripe.Invoice
 		err := json.Unmarshal(event.Data.Raw, &inv)
 		if err != nil {
-			logger.StdErr.Printf("Error parsing webhook JSON: %v\n", err)
+			utils.GetLogger(c).Warn("couldn't parse the webhook event", "eventType", event.Type, "error", err)
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
-		db.UsersCollection.UpdateOne(context.Background(), bson.M{"stripeCustomerId": inv.Customer.ID}, bson.M{"$set": bson.M{"isPremium": true}})
-		logger.StdOut.Printf("Customer %s renewed Schej!\n", inv.Customer.ID)
+		users := getRepositories(c).Users
+		user, err := users.GetByStripeCustomerId(inv.Customer.ID)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if user == nil {
+			utils.GetLogger(c).Error("couldn't find the user of the customer", "customerId", inv.Customer.ID)
+			return
+		}
+		if err := users.SetFields(user.Id, bson.M{"isPremium": true}); err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		utils.GetLogger(c).Info("customer renewed", "customerId", inv.Customer.ID)
 	} else if event.Type == stripe.EventTypeInvoicePaymentFailed {
 		var inv stripe.Invoice
 		err := json.Unmarshal(event.Data.Raw, &inv)
 		if err != nil {
-			logger.StdErr.Printf("Error parsing webhook JSON: %v\n", err)
+			utils.GetLogger(c).Warn("couldn't parse the webhook event", "eventType", event.Type, "error", err)
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
-		user := db.GetUserByStripeCustomerId(inv.Customer.ID)
+		users := getRepositories(c).Users
+		user, err := users.GetByStripeCustomerId(inv.Customer.ID)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 		if user == nil {
-			logger.StdErr.Printf("Error getting user: %v", err)
+			utils.GetLogger(c).Error("couldn't find the user of the customer", "customerId", inv.Customer.ID)
+			return
+		}
+		if err := users.SetFields(user.Id, bson.M{"isPremium": false}); err != nil {
+			utils.AbortWithError(c, err)
 			return
 		}
-		db.UsersCollection.UpdateOne(context.Background(), bson.M{"stripeCustomerId": inv.Customer.ID}, bson.M{"$set": bson.M{"isPremium": false}})
-		logger.StdOut.Printf("Customer %s failed to pay for Schej!\n", inv.Customer.ID)
+		utils.GetLogger(c).Info("customer failed to pay", "customerId", inv.Customer.ID)
 
 		message := fmt.Sprintf(":x: %s %s (%s) failed to pay for Schej :x:", user.FirstName, user.LastName, user.Email)
 		slackbot.SendTextMessageWithType(message, slackbot.MONETIZATION)
@@ -350,17 +380,25 @@ func stripeWebhook(c *gin.Context) {
 		var sub stripe.Subscription
 		err := json.Unmarshal(event.Data.Raw, &sub)
 		if err != nil {
-			logger.StdErr.Printf("Error parsing webhook JSON: %v\n", err)
+			utils.GetLogger(c).Warn("couldn't parse the webhook event", "eventType", event.Type, "error", err)
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
-		user := db.GetUserByStripeCustomerId(sub.Customer.ID)
+		users := getRepositories(c).Users
+		user, err := users.GetByStripeCustomerId(sub.Customer.ID)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
 		if user == nil {
-			logger.StdErr.Printf("Error getting user: %v", err)
+			utils.GetLogger(c).Error("couldn't find the user of the customer", "customerId", sub.Customer.ID)
+			return
+		}
+		if err := users.SetFields(user.Id, bson.M{"isPremium": false}); err != nil {
+			utils.AbortWithError(c, err)
 			return
 		}
-		db.UsersCollection.UpdateOne(context.Background(), bson.M{"stripeCustomerId": sub.Customer.ID}, bson.M{"$set": bson.M{"isPremium": false}})
-		logger.StdOut.Printf("Customer %s cancelled their subscription!\n", sub.Customer.ID)
+		utils.GetLogger(c).Info("customer cancelled their subscription", "customerId", sub.Customer.ID)
 
 		message := fmt.Sprintf(":x: %s %s (%s) cancelled their subscription :x:", user.FirstName, user.LastName, user.Email)
 		slackbot.SendTextMessageWithType(message, slackbot.MONETIZATION)
@@ -387,6 +425,7 @@ func getBillingPortalUrl(c *gin.Context) {
 		Customer:  stripe.String(customerID),
 		ReturnURL: stripe.String(returnURL),
 	}
+	params.Context = c.Request.Context()
 	ps, _ := portalsession.New(params)
 	c.JSON(http.StatusOK, gin.H{"url": ps.URL})
 }
@@ -0,0 +1,414 @@
+/* The /user/templates group contains the routes to save event configurations and reuse them for new events */
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/errs"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/responses"
+	"schej.it/server/utils"
+)
+
+// Maximum number of templates a user can have
+const maxEventTemplates = 100
+
+func InitTemplates(router *gin.RouterGroup) {
+	templateRouter := router.Group("/user/templates")
+	templateRouter.Use(middleware.AuthRequired())
+
+	templateRouter.GET("", getEventTemplates)
+	templateRouter.POST("", createEventTemplate)
+	templateRouter.POST("/from-event", createEventTemplateFromEvent)
+	templateRouter.GET("/:templateId", getEventTemplate)
+	templateRouter.PUT("/:templateId", updateEventTemplate)
+	templateRouter.DELETE("/:templateId", deleteEventTemplate)
+}
+
+// @Summary Gets the user's event templates
+// @Tags templates
+// @Produce json
+// @Success 200 {object} []models.EventTemplate
+// @Router /user/templates [get]
+func getEventTemplates(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	templates, err := getRepositories(c).Templates.GetAll(user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// @Summary Gets one of the user's event templates
+// @Tags templates
+// @Produce json
+// @Param templateId path string true "Template ID"
+// @Success 200 {object} models.EventTemplate
+// @Router /user/templates/{templateId} [get]
+func getEventTemplate(c *gin.Context) {
+	template := getUserEventTemplate(c)
+	if template == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// @Summary Creates an event template
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param payload body models.EventTemplate true "The template to create"
+// @Success 201 {object} object{templateId=string}
+// @Router /user/templates [post]
+func createEventTemplate(c *gin.Context) {
+	var template models.EventTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+	user := utils.GetAuthUser(c)
+
+	template.Id = primitive.NilObjectID
+	template.UserId = user.Id
+	insertEventTemplate(c, &template)
+}
+
+// @Summary Saves the configuration of an event as a template
+// @Description Copies everything but the event's dates, name, description, location, and responses
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param payload body object{eventId=string,name=string} true "The event to save and the name of the template"
+// @Success 201 {object} object{templateId=string}
+// @Router /user/templates/from-event [post]
+func createEventTemplateFromEvent(c *gin.Context) {
+	payload := struct {
+		EventId string `json:"eventId" binding:"required"`
+		Name    string `json:"name" binding:"required"`
+	}{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+	user := utils.GetAuthUser(c)
+
+	event, err := getRepositories(c).Events.GetByEitherId(payload.EventId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+	if event.OwnerId != user.Id {
+		c.JSON(http.StatusForbidden, responses.Error{Error: errs.UserNotEventOwner})
+		return
+	}
+
+	template := newEventTemplate(event)
+	template.UserId = user.Id
+	template.Name = payload.Name
+	insertEventTemplate(c, &template)
+}
+
+// @Summary Replaces one of the user's event templates
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param templateId path string true "Template ID"
+// @Param payload body models.EventTemplate true "The new template"
+// @Success 200
+// @Router /user/templates/{templateId} [put]
+func updateEventTemplate(c *gin.Context) {
+	var template models.EventTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+	if err := validateEventTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+
+	user := utils.GetAuthUser(c)
+	templateId, err := primitive.ObjectIDFromHex(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+		return
+	}
+
+	template.Id = templateId
+	template.UserId = user.Id
+	replaced, err := getRepositories(c).Templates.Replace(&template)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !replaced {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Deletes one of the user's event templates
+// @Tags templates
+// @Produce json
+// @Param templateId path string true "Template ID"
+// @Success 200
+// @Router /user/templates/{templateId} [delete]
+func deleteEventTemplate(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	templateId, err := primitive.ObjectIDFromHex(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+		return
+	}
+
+	deleted, err := getRepositories(c).Templates.Delete(templateId, user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Returns the template in the templateId param if the signed in user owns it, otherwise responds with a 404, or
+// an error if it couldn't be fetched, and returns nil
+func getUserEventTemplate(c *gin.Context) *models.EventTemplate {
+	user := utils.GetAuthUser(c)
+	templateId, err := primitive.ObjectIDFromHex(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+		return nil
+	}
+
+	template, err := getRepositories(c).Templates.Get(templateId, user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return nil
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventTemplateNotFound})
+		return nil
+	}
+	return template
+}
+
+// Validates and inserts the template, then responds with its id
+func insertEventTemplate(c *gin.Context, template *models.EventTemplate) {
+	if err := validateEventTemplate(template); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+
+	templates := getRepositories(c).Templates
+	count, err := templates.Count(template.UserId)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if count >= maxEventTemplates {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: fmt.Sprintf("You can't have more than %d templates", maxEventTemplates)})
+		return
+	}
+
+	templateId, err := templates.Create(template)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"templateId": templateId.Hex()})
+}
+
+// Checks that the template is well formed and assigns ids to new sign up questions
+func validateEventTemplate(template *models.EventTemplate) error {
+	if len(strings.TrimSpace(template.Name)) == 0 {
+		return fmt.Errorf("Templates must have a name")
+	}
+	switch template.Type {
+	case models.SPECIFIC_DATES, models.DOW, models.GROUP:
+	default:
+		return fmt.Errorf("Unknown event type \"%s\"", template.Type)
+	}
+	for _, timeOfDay := range template.TimesOfDay {
+		if timeOfDay < 0 || timeOfDay >= 24*60 {
+			return fmt.Errorf("Times of day must be between 0 and 1439 minutes")
+		}
+	}
+	for _, block := range template.SignUpBlocks {
+		if block.StartOffset < 0 || block.Length <= 0 {
+			return fmt.Errorf("Sign up blocks must start after the first date and have a positive length")
+		}
+	}
+	for _, remindee := range template.Remindees {
+		if len(remindee.Role) > 0 && remindee.Role != models.REQUIRED_PARTICIPANT && remindee.Role != models.OPTIONAL_PARTICIPANT {
+			return fmt.Errorf("Participant roles must be either required or optional")
+		}
+	}
+	return utils.ValidateSignUpQuestions(utils.Coalesce(template.SignUpQuestions))
+}
+
+// Returns a template with the configuration of the event, with its times made relative to its dates
+func newEventTemplate(event *models.Event) models.EventTemplate {
+	template := models.EventTemplate{
+		Type:                     event.Type,
+		Duration:                 event.Duration,
+		TimeIncrement:            event.TimeIncrement,
+		DaysOnly:                 event.DaysOnly,
+		StartOnMonday:            event.StartOnMonday,
+		HasSpecificTimes:         event.HasSpecificTimes,
+		IsSignUpForm:             event.IsSignUpForm,
+		SignUpQuestions:          event.SignUpQuestions,
+		CollectEmails:            event.CollectEmails,
+		BlindAvailabilityEnabled: event.BlindAvailabilityEnabled,
+	}
+
+	dates := make([]time.Time, len(event.Dates))
+	for i, date := range event.Dates {
+		dates[i] = date.Time()
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	// Each specific time is stored as minutes after the start of the date it's on
+	if utils.Coalesce(event.HasSpecificTimes) {
+		timesOfDay := make(models.Set[int])
+		for _, t := range event.Times {
+			index := sort.Search(len(dates), func(i int) bool { return dates[i].After(t.Time()) }) - 1
+			if index < 0 {
+				continue
+			}
+			if minutes := int(t.Time().Sub(dates[index]).Minutes()); minutes < 24*60 {
+				timesOfDay[minutes] = struct{}{}
+			}
+		}
+		for minutes := range timesOfDay {
+			template.TimesOfDay = append(template.TimesOfDay, minutes)
+		}
+		sort.Ints(template.TimesOfDay)
+	}
+
+	// Sign up blocks are stored relative to the first date
+	if len(dates) > 0 {
+		for _, block := range utils.Coalesce(event.SignUpBlocks) {
+			if block.StartDate == nil || block.EndDate == nil {
+				continue
+			}
+			startOffset := int(block.StartDate.Time().Sub(dates[0]).Minutes())
+			if startOffset < 0 {
+				continue
+			}
+			template.SignUpBlocks = append(template.SignUpBlocks, models.SignUpBlockTemplate{
+				Name:        block.Name,
+				Capacity:    block.Capacity,
+				StartOffset: startOffset,
+				Length:      int(block.EndDate.Time().Sub(block.StartDate.Time()).Minutes()),
+			})
+		}
+	}
+
+	for _, remindee := range utils.Coalesce(event.Remindees) {
+		template.Remindees = append(template.Remindees, models.TemplateRemindee{Email: remindee.Email, Role: remindee.Role})
+	}
+
+	return template
+}
+
+// Fills in the parts of the new event that weren't given from the template, placing its times on the event's dates
+func applyEventTemplate(payload *CreateEventRequest, template *models.EventTemplate) {
+	if payload.Type == "" {
+		payload.Type = template.Type
+	}
+	if payload.Duration == nil {
+		payload.Duration = template.Duration
+	}
+	if payload.TimeIncrement == nil {
+		payload.TimeIncrement = template.TimeIncrement
+	}
+	if payload.DaysOnly == nil {
+		payload.DaysOnly = template.DaysOnly
+	}
+	if payload.StartOnMonday == nil {
+		payload.StartOnMonday = template.StartOnMonday
+	}
+	if payload.IsSignUpForm == nil {
+		payload.IsSignUpForm = template.IsSignUpForm
+	}
+	if payload.SignUpQuestions == nil && template.SignUpQuestions != nil {
+		// Questions keep their ids, since they only have to be unique within an event
+		questions := append([]models.SignUpQuestion{}, *template.SignUpQuestions...)
+		payload.SignUpQuestions = &questions
+	}
+	if payload.CollectEmails == nil {
+		payload.CollectEmails = template.CollectEmails
+	}
+	if payload.BlindAvailabilityEnabled == nil {
+		payload.BlindAvailabilityEnabled = template.BlindAvailabilityEnabled
+	}
+
+	if payload.HasSpecificTimes == nil {
+		payload.HasSpecificTimes = template.HasSpecificTimes
+		if utils.Coalesce(template.HasSpecificTimes) && payload.Times == nil {
+			payload.Times = make([]primitive.DateTime, 0, len(payload.Dates)*len(template.TimesOfDay))
+			for _, date := range payload.Dates {
+				for _, minutes := range template.TimesOfDay {
+					payload.Times = append(payload.Times, primitive.NewDateTimeFromTime(date.Time().Add(time.Duration(minutes)*time.Minute)))
+				}
+			}
+		}
+	}
+
+	if payload.SignUpBlocks == nil && len(template.SignUpBlocks) > 0 && len(payload.Dates) > 0 {
+		firstDate := payload.Dates[0].Time()
+		for _, date := range payload.Dates {
+			if date.Time().Before(firstDate) {
+				firstDate = date.Time()
+			}
+		}
+
+		blocks := make([]models.SignUpBlock, len(template.SignUpBlocks))
+		for i, block := range template.SignUpBlocks {
+			startDate := primitive.NewDateTimeFromTime(firstDate.Add(time.Duration(block.StartOffset) * time.Minute))
+			endDate := primitive.NewDateTimeFromTime(startDate.Time().Add(time.Duration(block.Length) * time.Minute))
+			blocks[i] = models.SignUpBlock{
+				Id:        primitive.NewObjectID(),
+				Name:      block.Name,
+				Capacity:  block.Capacity,
+				StartDate: &startDate,
+				EndDate:   &endDate,
+			}
+		}
+		payload.SignUpBlocks = &blocks
+	}
+
+	if payload.Remindees == nil && len(template.Remindees) > 0 {
+		if payload.ParticipantRoles == nil {
+			payload.ParticipantRoles = make(map[string]models.ParticipantRole)
+		}
+		for _, remindee := range template.Remindees {
+			payload.Remindees = append(payload.Remindees, remindee.Email)
+			if _, ok := payload.ParticipantRoles[remindee.Email]; !ok && len(remindee.Role) > 0 {
+				payload.ParticipantRoles[remindee.Email] = remindee.Role
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func TestEventTemplateRoundTrip(t *testing.T) {
+	dateTime := func(day int, hour int) primitive.DateTime {
+		return primitive.NewDateTimeFromTime(time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC))
+	}
+	blockStart, blockEnd := dateTime(4, 10), dateTime(4, 12)
+	capacity := 3
+	duration := float32(8)
+	event := &models.Event{
+		Type:             models.SPECIFIC_DATES,
+		Duration:         &duration,
+		Dates:            []primitive.DateTime{dateTime(4, 9), dateTime(3, 9)},
+		HasSpecificTimes: utils.TruePtr(),
+		Times:            []primitive.DateTime{dateTime(3, 10), dateTime(3, 14), dateTime(4, 10)},
+		IsSignUpForm:     utils.TruePtr(),
+		SignUpBlocks:     &[]models.SignUpBlock{{Id: primitive.NewObjectID(), Name: "Setup", Capacity: &capacity, StartDate: &blockStart, EndDate: &blockEnd}},
+		Remindees:        &[]models.Remindee{{Email: "sam@example.com", Role: models.OPTIONAL_PARTICIPANT, TaskIds: []string{"task"}}},
+	}
+
+	template := newEventTemplate(event)
+	template.Name = "Volunteering"
+	if err := validateEventTemplate(&template); err != nil {
+		t.Fatalf("validateEventTemplate() = %v", err)
+	}
+	if len(template.TimesOfDay) != 2 || template.TimesOfDay[0] != 60 || template.TimesOfDay[1] != 300 {
+		t.Errorf("TimesOfDay = %v, want [60 300]", template.TimesOfDay)
+	}
+	if len(template.SignUpBlocks) != 1 || template.SignUpBlocks[0].StartOffset != 25*60 || template.SignUpBlocks[0].Length != 120 {
+		t.Errorf("SignUpBlocks = %+v, want one block starting 1500 minutes in and lasting 120", template.SignUpBlocks)
+	}
+
+	// Use the template for an event two weeks later on a single date
+	payload := CreateEventRequest{Name: "Volunteering again", Dates: []primitive.DateTime{dateTime(17, 9), dateTime(18, 9)}}
+	applyEventTemplate(&payload, &template)
+	if payload.Type != models.SPECIFIC_DATES || *payload.Duration != duration || !utils.Coalesce(payload.IsSignUpForm) {
+		t.Errorf("applyEventTemplate() didn't copy the configuration: %+v", payload)
+	}
+	if len(payload.Times) != 4 || !payload.Times[1].Time().Equal(dateTime(17, 14).Time()) {
+		t.Errorf("Times = %v, want 10:00 and 14:00 on both dates", payload.Times)
+	}
+	blocks := utils.Coalesce(payload.SignUpBlocks)
+	if len(blocks) != 1 || !blocks[0].StartDate.Time().Equal(dateTime(18, 10).Time()) || !blocks[0].EndDate.Time().Equal(dateTime(18, 12).Time()) || blocks[0].Id.IsZero() {
+		t.Errorf("SignUpBlocks = %+v, want a new block from 10:00 to 12:00 on the second date", blocks)
+	}
+	if len(payload.Remindees) != 1 || payload.ParticipantRoles["sam@example.com"] != models.OPTIONAL_PARTICIPANT {
+		t.Errorf("Remindees = %v with roles %v, want the optional remindee", payload.Remindees, payload.ParticipantRoles)
+	}
+}
@@ -0,0 +1,168 @@
+/* The /user/trash group contains the routes to restore or permanently delete the current user's deleted events and folders */
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/errs"
+	"schej.it/server/middleware"
+	"schej.it/server/models"
+	"schej.it/server/responses"
+	"schej.it/server/utils"
+)
+
+func InitTrash(router *gin.RouterGroup) {
+	trashRouter := router.Group("/user/trash")
+	trashRouter.Use(middleware.AuthRequired())
+
+	trashRouter.GET("", getTrash)
+	trashRouter.POST("/events/:eventId/restore", restoreEvent)
+	trashRouter.DELETE("/events/:eventId", middleware.NotImpersonating(), purgeEvent)
+	trashRouter.POST("/folders/:folderId/restore", restoreFolder)
+	trashRouter.DELETE("/folders/:folderId", middleware.NotImpersonating(), purgeFolder)
+}
+
+type TrashResponse struct {
+	Events  []models.Event  `json:"events"`
+	Folders []models.Folder `json:"folders"`
+
+	// Number of days after deletion that items are permanently deleted, 0 if they're kept forever
+	RetentionDays int `json:"retentionDays"`
+}
+
+// @Summary Gets the current user's deleted events and folders
+// @Tags trash
+// @Produce json
+// @Success 200 {object} TrashResponse
+// @Router /user/trash [get]
+func getTrash(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	repositories := getRepositories(c)
+	events, err := repositories.Events.GetDeleted(user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	folders, err := repositories.Folders.GetDeleted(user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TrashResponse{Events: events, Folders: folders, RetentionDays: db.TrashRetentionDays()})
+}
+
+// @Summary Restores a deleted event
+// @Description Email reminders that were scheduled for the event aren't restored
+// @Tags trash
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Success 200
+// @Router /user/trash/events/{eventId}/restore [post]
+func restoreEvent(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	eventId, err := primitive.ObjectIDFromHex(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	restored, err := getRepositories(c).Events.Restore(eventId, user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Permanently deletes a deleted event along with its responses
+// @Description Not allowed while an admin is impersonating the user
+// @Tags trash
+// @Produce json
+// @Param eventId path string true "Event ID"
+// @Success 200
+// @Router /user/trash/events/{eventId} [delete]
+func purgeEvent(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	eventId, err := primitive.ObjectIDFromHex(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	purged, err := getRepositories(c).Events.Purge(eventId, user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !purged {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.EventNotFound})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Restores a deleted folder along with the events that were deleted with it
+// @Tags trash
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Success 200
+// @Router /user/trash/folders/{folderId}/restore [post]
+func restoreFolder(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.FolderNotFound})
+		return
+	}
+
+	restored, err := getRepositories(c).Folders.Restore(folderId, user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.FolderNotFound})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary Permanently deletes a deleted folder along with the events that were deleted with it
+// @Description Not allowed while an admin is impersonating the user
+// @Tags trash
+// @Produce json
+// @Param folderId path string true "Folder ID"
+// @Success 200
+// @Router /user/trash/folders/{folderId} [delete]
+func purgeFolder(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+	folderId, err := primitive.ObjectIDFromHex(c.Param("folderId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.FolderNotFound})
+		return
+	}
+
+	purged, err := getRepositories(c).Folders.Purge(folderId, user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !purged {
+		c.JSON(http.StatusNotFound, responses.Error{Error: errs.FolderNotFound})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/db/memory"
+	"schej.it/server/models"
+)
+
+func TestTrashFolder(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	user := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(user)
+
+	// Work > Projects, with an event in each
+	workId, _ := database.Folders.Create(&models.Folder{UserId: user.Id, Name: "Work"})
+	projectsId, _ := database.Folders.Create(&models.Folder{UserId: user.Id, Name: "Projects", ParentId: &workId})
+	standup := &models.Event{OwnerId: user.Id, Name: "Standup", Type: models.SPECIFIC_DATES}
+	kickoff := &models.Event{OwnerId: user.Id, Name: "Kickoff", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(standup)
+	database.Events.Insert(kickoff)
+	database.Folders.SetEventFolder(standup.Id, &workId, user.Id)
+	database.Folders.SetEventFolder(kickoff.Id, &projectsId, user.Id)
+	database.Responses.Insert(&models.EventResponse{EventId: kickoff.Id, UserId: user.Id.Hex(), Response: &models.Response{}})
+
+	// Deleting the folder moves the events to the trash and drops them from the folders
+	if err := database.Folders.Delete(workId, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if eventIds, _ := database.Folders.GetEventIds(projectsId, user.Id); len(eventIds) != 0 {
+		t.Errorf("deleted folder has events %v, want none", eventIds)
+	}
+
+	w := sendRequest(t, router, http.MethodGet, "/api/user/trash", user.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /user/trash = %d %s", w.Code, w.Body.String())
+	}
+	var trash TrashResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &trash); err != nil {
+		t.Fatal(err)
+	}
+	if len(trash.Events) != 2 || len(trash.Folders) != 2 {
+		t.Errorf("trash has %d events and %d folders, want 2 and 2", len(trash.Events), len(trash.Folders))
+	}
+	if trash.RetentionDays != 0 {
+		t.Errorf("RetentionDays = %d, want 0 when TRASH_RETENTION_DAYS isn't set", trash.RetentionDays)
+	}
+
+	// Only the owner can restore the folder
+	w = sendRequest(t, router, http.MethodPost, "/api/user/trash/folders/"+workId.Hex()+"/restore", primitive.NewObjectID().Hex(), nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("restoring as a user that doesn't exist = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	other := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(other)
+	w = sendRequest(t, router, http.MethodPost, "/api/user/trash/folders/"+workId.Hex()+"/restore", other.Id.Hex(), nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("restoring someone else's folder = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// Restoring the folder puts the events back into the folders they were in
+	w = sendRequest(t, router, http.MethodPost, "/api/user/trash/folders/"+workId.Hex()+"/restore", user.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restoring the folder = %d %s", w.Code, w.Body.String())
+	}
+	for folderId, eventId := range map[primitive.ObjectID]primitive.ObjectID{workId: standup.Id, projectsId: kickoff.Id} {
+		if eventIds, _ := database.Folders.GetEventIds(folderId, user.Id); len(eventIds) != 1 || eventIds[0] != eventId {
+			t.Errorf("restored folder has events %v, want [%s]", eventIds, eventId.Hex())
+		}
+	}
+	if event := mustGetEvent(t, database, kickoff.Id); event == nil || event.DeletedFromFolderId != nil {
+		t.Errorf("restored event = %+v, want it out of the trash", event)
+	}
+
+	// Purging the folder deletes the events along with their responses
+	database.Folders.Delete(workId, user.Id)
+	w = sendRequest(t, router, http.MethodDelete, "/api/user/trash/folders/"+workId.Hex(), user.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("purging the folder = %d %s", w.Code, w.Body.String())
+	}
+	if deleted, _ := database.Events.GetIncludingDeleted(kickoff.Id.Hex()); deleted != nil {
+		t.Errorf("purged event still exists")
+	}
+	if responses := mustGetResponses(t, database, kickoff.Id); len(responses) != 0 {
+		t.Errorf("purged event has %d responses, want 0", len(responses))
+	}
+	if folders, _ := database.Folders.GetDeleted(user.Id); len(folders) != 0 {
+		t.Errorf("trash has %d folders after purging, want 0", len(folders))
+	}
+}
+
+func TestTrashEvent(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	user := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(user)
+	event := &models.Event{OwnerId: user.Id, Name: "Standup", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(event)
+	database.Attendees.Insert(&models.Attendee{EventId: event.Id, Email: "alice@example.com"})
+
+	// Events that aren't in the trash can't be restored or purged
+	path := "/api/user/trash/events/" + event.Id.Hex()
+	if w := sendRequest(t, router, http.MethodDelete, path, user.Id.Hex(), nil); w.Code != http.StatusNotFound {
+		t.Errorf("purging an event that isn't in the trash = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	database.Events.SetFields(event.Id, map[string]interface{}{"isDeleted": true})
+	if w := sendRequest(t, router, http.MethodPost, path+"/restore", user.Id.Hex(), nil); w.Code != http.StatusOK {
+		t.Fatalf("restoring the event = %d %s", w.Code, w.Body.String())
+	}
+	if mustGetEvent(t, database, event.Id) == nil {
+		t.Errorf("restored event isn't found")
+	}
+
+	database.Events.SetFields(event.Id, map[string]interface{}{"isDeleted": true})
+	if w := sendRequest(t, router, http.MethodDelete, path, user.Id.Hex(), nil); w.Code != http.StatusOK {
+		t.Fatalf("purging the event = %d %s", w.Code, w.Body.String())
+	}
+	if attendees := mustGetAttendees(t, database, event.Id); len(attendees) != 0 {
+		t.Errorf("purged event has %d attendees, want 0", len(attendees))
+	}
+}
+
+func TestTrashRetentionDays(t *testing.T) {
+	tests := map[string]int{"": 0, "30": 30, "0": 0, "-5": 0, "month": 0}
+	for value, want := range tests {
+		t.Setenv("TRASH_RETENTION_DAYS", value)
+		if days := db.TrashRetentionDays(); days != want {
+			t.Errorf("TrashRetentionDays() with %q = %d, want %d", value, days, want)
+		}
+	}
+}
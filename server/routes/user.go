@@ -2,24 +2,28 @@
 package routes
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"schej.it/server/db"
 	"schej.it/server/errs"
-	"schej.it/server/logger"
 	"schej.it/server/middleware"
 	"schej.it/server/models"
 	"schej.it/server/responses"
 	"schej.it/server/services/auth"
 	"schej.it/server/services/calendar"
 	"schej.it/server/services/contacts"
+	"schej.it/server/services/gcloud"
+	"schej.it/server/services/listmonk"
 	"schej.it/server/services/microsoftgraph"
 	"schej.it/server/utils"
 )
@@ -31,7 +35,9 @@ func InitUser(router *gin.RouterGroup) {
 	userRouter.GET("/profile", getProfile)
 	userRouter.PATCH("/name", updateName)
 	userRouter.PATCH("/calendar-options", updateCalendarOptions)
+	userRouter.PATCH("/timezone", updateTimezone)
 	userRouter.GET("/events", getEvents)
+	userRouter.GET("/events/search", searchEvents)
 	userRouter.POST("/events/:eventId/set-folder", setEventFolder)
 	userRouter.GET("/calendars", getCalendars)
 	userRouter.POST("/add-google-calendar-account", addGoogleCalendarAccount)
@@ -41,7 +47,8 @@ func InitUser(router *gin.RouterGroup) {
 	userRouter.POST("/toggle-calendar", toggleCalendar)
 	userRouter.POST("/toggle-sub-calendar", toggleSubCalendar)
 	userRouter.GET("/searchContacts", searchContacts)
-	userRouter.DELETE("", deleteUser)
+	userRouter.DELETE("", middleware.NotImpersonating(), deleteUser)
+	userRouter.GET("/export", middleware.NotImpersonating(), exportUserData)
 }
 
 // @Summary Gets the user's profile
@@ -54,10 +61,16 @@ func getProfile(c *gin.Context) {
 	user := userInterface.(*models.User)
 
 	// Get number of events created this month
-	eventsCreatedThisMonth := db.GetEventsCreatedThisMonth(user.Id)
+	eventsCreatedThisMonth, err := db.GetEventsCreatedThisMonth(user.Id)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 	user.NumEventsCreated = eventsCreatedThisMonth
 
-	db.UpdateDailyUserLog(user)
+	if err := db.UpdateDailyUserLog(user); err != nil {
+		utils.GetLogger(c).Error("couldn't update the daily user log", "userId", user.Id.Hex(), "error", err)
+	}
 
 	c.JSON(http.StatusOK, user)
 }
@@ -80,11 +93,10 @@ func updateName(c *gin.Context) {
 
 	authUser := utils.GetAuthUser(c)
 
-	_, err := db.UsersCollection.UpdateByID(context.Background(), authUser.Id, bson.M{
-		"$set": bson.M{"firstName": payload.FirstName, "lastName": payload.LastName, "hasCustomName": true},
-	})
+	err := getRepositories(c).Users.SetFields(authUser.Id, bson.M{"firstName": payload.FirstName, "lastName": payload.LastName, "hasCustomName": true})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		utils.AbortWithError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
@@ -132,106 +144,162 @@ func updateCalendarOptions(c *gin.Context) {
 	}
 
 	// Update database
-	_, err := db.UsersCollection.UpdateByID(context.Background(), authUser.Id, bson.M{
-		"$set": bson.M{"calendarOptions": authUser.CalendarOptions},
-	})
+	err := getRepositories(c).Users.SetFields(authUser.Id, bson.M{"calendarOptions": authUser.CalendarOptions})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		utils.AbortWithError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
 
-// @Summary Gets all the user's events
-// @Description Returns an array containing all the user's events
+// @Summary Updates the user's timezone
 // @Tags user
+// @Accept json
 // @Produce json
-// @Success 200 {object} []models.Event
-// @Router /user/events [get]
-func getEvents(c *gin.Context) {
-	user := utils.GetAuthUser(c)
-	userId := user.Id
+// @Param payload body object{timezone=string} true "Object containing the IANA timezone (i.e. America/New_York)"
+// @Success 200
+// @Router /user/timezone [patch]
+func updateTimezone(c *gin.Context) {
+	payload := struct {
+		Timezone string `json:"timezone" binding:"required"`
+	}{}
+	if err := c.BindJSON(&payload); err != nil {
+		return
+	}
 
-	// Get the events associated with the current user
-	events := make([]models.Event, 0)
-	opts := options.Find().SetSort(bson.M{"_id": -1})
+	if _, err := utils.LoadTimezone(payload.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
+	}
+
+	authUser := utils.GetAuthUser(c)
 
-	// Get all the event ids that the user has responded to
-	cursor, err := db.EventResponsesCollection.Find(context.Background(), bson.M{"userId": userId.Hex()})
+	err := getRepositories(c).Users.SetFields(authUser.Id, bson.M{"timezone": payload.Timezone})
 	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	defer cursor.Close(context.Background())
-	eventIds := make([]primitive.ObjectID, 0)
-	for cursor.Next(context.Background()) {
-		var eventResponse models.EventResponse
-		if err := cursor.Decode(&eventResponse); err != nil {
-			logger.StdErr.Panicln(err)
-		}
-		eventIds = append(eventIds, eventResponse.EventId)
+		utils.AbortWithError(c, err)
+		return
 	}
 
-	// Get all the event ids that the user is an attendee of
-	cursor, err = db.AttendeesCollection.Find(context.Background(), bson.M{"email": user.Email, "declined": false})
-	if err != nil {
-		logger.StdErr.Panicln(err)
-	}
-	defer cursor.Close(context.Background())
-	hasRespondedEventIds := make(models.Set[primitive.ObjectID])
-	for cursor.Next(context.Background()) {
-		var attendee models.Attendee
-		if err := cursor.Decode(&attendee); err != nil {
-			logger.StdErr.Panicln(err)
-		}
-		if utils.Contains(eventIds, attendee.EventId) {
-			hasRespondedEventIds[attendee.EventId] = struct{}{}
-		} else {
-			eventIds = append(eventIds, attendee.EventId)
-		}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary Gets the user's events
+// @Description Returns a page of the events the user owns, responded to, or is an attendee of, most recently created
+// @Description first, along with the total number of events
+// @Tags user
+// @Produce json
+// @Param cursor query string false "The nextCursor of the previous page"
+// @Param limit query int false "Maximum number of events to return, 50 by default and at most 200"
+// @Success 200 {object} db.EventSearchResult
+// @Router /user/events [get]
+func getEvents(c *gin.Context) {
+	cursor, limit, ok := getPage(c)
+	if !ok {
+		return
 	}
+	user := utils.GetAuthUser(c)
 
-	cursor, err = db.EventsCollection.Find(
-		context.Background(),
-		bson.M{
-			"$and": bson.A{
-				bson.M{
-					"$or": bson.A{
-						bson.M{"_id": bson.M{"$in": eventIds}},
-						bson.M{"ownerId": userId},
-					},
-				},
-				bson.M{
-					"$or": bson.A{
-						bson.M{"isDeleted": bson.M{"$exists": false}},
-						bson.M{"isDeleted": false},
-					},
-				},
-			},
-		},
-		opts,
-	)
+	result, err := getRepositories(c).Events.Search(user, db.EventSearch{Cursor: cursor, Limit: limit})
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// @Summary Searches the user's events
+// @Description Returns a page of the events the user owns, responded to, or is an attendee of that match the filters,
+// @Description most recently created first, along with the total number of matching events
+// @Tags user
+// @Produce json
+// @Param query query string false "Words to look for in the event's name, description, and location"
+// @Param type query string false "Comma separated list of event types, out of specific_dates, dow, group, and sign_up"
+// @Param archived query bool false "Only archived events if true, only unarchived events if false"
+// @Param scheduled query bool false "Only scheduled events if true, only unscheduled events if false"
+// @Param folderId query string false "Only events in this folder, which can be a folder shared with the user"
+// @Param timeMin query string false "Only events with a date at or after this time"
+// @Param timeMax query string false "Only events with a date before this time"
+// @Param cursor query string false "The nextCursor of the previous page"
+// @Param limit query int false "Maximum number of events to return, 50 by default and at most 200"
+// @Success 200 {object} db.EventSearchResult
+// @Router /user/events/search [get]
+func searchEvents(c *gin.Context) {
+	payload := struct {
+		Query     string     `form:"query"`
+		Type      string     `form:"type"`
+		Archived  *bool      `form:"archived"`
+		Scheduled *bool      `form:"scheduled"`
+		FolderId  string     `form:"folderId"`
+		TimeMin   *time.Time `form:"timeMin"`
+		TimeMax   *time.Time `form:"timeMax"`
+	}{}
+	if err := c.ShouldBindQuery(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+		return
 	}
-	if err := cursor.All(context.Background(), &events); err != nil {
-		logger.StdErr.Panicln(err)
+	cursor, limit, ok := getPage(c)
+	if !ok {
+		return
 	}
+	user := utils.GetAuthUser(c)
 
-	for i, event := range events {
-		// Set the hasResponded field for availability groups
-		if event.Type == models.GROUP {
-			if _, ok := hasRespondedEventIds[event.Id]; ok {
-				events[i].HasResponded = utils.TruePtr()
-			} else {
-				events[i].HasResponded = utils.FalsePtr()
+	search := db.EventSearch{
+		Query:     strings.TrimSpace(payload.Query),
+		Archived:  payload.Archived,
+		Scheduled: payload.Scheduled,
+		TimeMin:   payload.TimeMin,
+		TimeMax:   payload.TimeMax,
+		Cursor:    cursor,
+		Limit:     limit,
+	}
+
+	if len(payload.Type) > 0 {
+		for _, eventType := range strings.Split(payload.Type, ",") {
+			switch eventType := models.EventType(strings.TrimSpace(eventType)); eventType {
+			case models.SPECIFIC_DATES, models.DOW, models.GROUP, db.SignUpFormType:
+				search.Types = append(search.Types, eventType)
+			default:
+				c.JSON(http.StatusBadRequest, responses.Error{Error: fmt.Sprintf("unknown event type %q", eventType)})
+				return
 			}
 		}
 	}
 
-	c.JSON(http.StatusOK, events)
+	if len(payload.FolderId) > 0 {
+		folderId, err := primitive.ObjectIDFromHex(payload.FolderId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, responses.Error{Error: errs.FolderNotFound})
+			return
+		}
+		folders := getRepositories(c).Folders
+		folder, _ := getFolderAccess(folders, folderId, user.Id)
+		if folder == nil {
+			c.JSON(http.StatusNotFound, responses.Error{Error: errs.FolderNotFound})
+			return
+		}
+		eventIds, err := folders.GetEventIds(folderId, folder.UserId)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		search.EventIds = &eventIds
+	}
+
+	result, err := getRepositories(c).Events.Search(user, search)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
 // @Summary Sets the folder for the specified event
+// @Description The event has to be one the user owns, responded to, or is an attendee of. The folder can be one of
+// @Description the user's folders or a folder shared with them as an editor. A null folderId takes the event out of
+// @Description the user's folders
 // @Tags user
 // @Accept json
 // @Produce json
@@ -254,14 +322,29 @@ func setEventFolder(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	userIdString := session.Get("userId").(string)
-	userId, err := primitive.ObjectIDFromHex(userIdString)
+	user := utils.GetAuthUser(c)
+	userId := user.Id
+	repositories := getRepositories(c)
+	event, err := repositories.Events.GetById(eventId.Hex())
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	canAccess, err := canAccessEvent(repositories, event, user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !canAccess {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
 
+	// Events in a shared folder are put in it on behalf of the folder's owner
 	var folderId *primitive.ObjectID
 	if body.FolderId != nil {
 		id, err := primitive.ObjectIDFromHex(*body.FolderId)
@@ -269,10 +352,16 @@ func setEventFolder(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
 			return
 		}
+		folder, role := getFolderAccess(repositories.Folders, id, userId)
+		if folder == nil || !role.Includes(models.FolderEditor) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
 		folderId = &id
+		userId = folder.UserId
 	}
 
-	err = db.SetEventFolder(eventId, folderId, userId)
+	err = repositories.Folders.SetEventFolder(eventId, folderId, userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add event to folder"})
 		return
@@ -305,19 +394,26 @@ func getCalendars(c *gin.Context) {
 	if len(payload.Accounts) == 0 {
 		accounts = make([]string, 0)
 	} else {
-		accounts = utils.ParseArrayQueryParam(payload.Accounts)
+		var err error
+		accounts, err = utils.ParseArrayQueryParam(payload.Accounts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: err.Error()})
+			return
+		}
 	}
 	accountsSet := utils.ArrayToSet(accounts)
 	user := utils.GetAuthUser(c)
 
-	calendarEvents, editedCalendarAccounts := calendar.GetUsersCalendarEvents(user, accountsSet, payload.TimeMin, payload.TimeMax)
+	calendarEvents, editedCalendarAccounts, err := calendar.GetUsersCalendarEvents(c.Request.Context(), user, accountsSet, payload.TimeMin, payload.TimeMax)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	if editedCalendarAccounts {
-		db.UsersCollection.FindOneAndUpdate(
-			context.Background(),
-			bson.M{"_id": user.Id},
-			bson.M{"$set": user},
-		)
+		if err := getRepositories(c).Users.SetCalendarAccounts(user.Id, user.CalendarAccounts); err != nil {
+			utils.GetLogger(c).Error("couldn't save the user's calendar accounts", "userId", user.Id.Hex(), "error", err)
+		}
 	}
 
 	c.JSON(http.StatusOK, calendarEvents)
@@ -340,7 +436,11 @@ func addGoogleCalendarAccount(c *gin.Context) {
 	}
 
 	// Get tokens
-	tokens := auth.GetTokensFromAuthCode(payload.Code, payload.Scope, utils.GetOrigin(c), models.GoogleCalendarType)
+	tokens, err := auth.GetTokensFromAuthCode(c.Request.Context(), payload.Code, payload.Scope, utils.GetOrigin(c), models.GoogleCalendarType)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	// Get user info from JWT
 	claims := utils.ParseJWT(tokens.IdToken)
@@ -384,7 +484,8 @@ func addAppleCalendarAccount(c *gin.Context) {
 
 	encryptedPassword, err := utils.Encrypt(payload.Password)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		utils.AbortWithError(c, err)
+		return
 	}
 
 	auth := &models.AppleCalendarAuth{
@@ -396,7 +497,7 @@ func addAppleCalendarAccount(c *gin.Context) {
 	calendarProvider := calendar.AppleCalendar{
 		AppleCalendarAuth: *auth,
 	}
-	_, err = calendarProvider.GetCalendarList()
+	_, err = calendarProvider.GetCalendarList(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, responses.Error{Error: errs.InvalidCredentials})
 		return
@@ -432,7 +533,11 @@ func addOutlookCalendarAccount(c *gin.Context) {
 	authUser := utils.GetAuthUser(c)
 
 	// Get tokens
-	tokens := auth.GetTokensFromAuthCode(payload.Code, payload.Scope, utils.GetOrigin(c), models.OutlookCalendarType)
+	tokens, err := auth.GetTokensFromAuthCode(c.Request.Context(), payload.Code, payload.Scope, utils.GetOrigin(c), models.OutlookCalendarType)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	// Get access token expire time
 	accessTokenExpireDate := utils.GetAccessTokenExpireDate(tokens.ExpiresIn)
@@ -446,7 +551,11 @@ func addOutlookCalendarAccount(c *gin.Context) {
 	}
 
 	// Get user info
-	userInfo := microsoftgraph.GetUserInfo(authUser, calendarAuth)
+	userInfo, err := microsoftgraph.GetUserInfo(c.Request.Context(), authUser, calendarAuth)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	addCalendarAccount(c, addCalendarAccountArgs{
 		calendarType:       models.OutlookCalendarType,
@@ -493,7 +602,7 @@ func addCalendarAccount(c *gin.Context, args addCalendarAccountArgs) {
 	if oldCalendarAccount, ok := authUser.CalendarAccounts[calendarAccountKey]; ok && oldCalendarAccount.SubCalendars != nil {
 		calendarAccount.SubCalendars = oldCalendarAccount.SubCalendars
 	} else {
-		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList()
+		subCalendars, err := calendar.GetCalendarProvider(calendarAccount).GetCalendarList(c.Request.Context())
 		if err == nil {
 			calendarAccount.SubCalendars = &subCalendars
 		}
@@ -501,13 +610,9 @@ func addCalendarAccount(c *gin.Context, args addCalendarAccountArgs) {
 
 	// Set calendar account
 	authUser.CalendarAccounts[calendarAccountKey] = calendarAccount
-
-	// Perform mongo update
-	db.UsersCollection.FindOneAndUpdate(
-		context.Background(),
-		bson.M{"_id": authUser.Id},
-		bson.M{"$set": authUser},
-	)
+	if err := getRepositories(c).Users.SetCalendarAccounts(authUser.Id, map[string]models.CalendarAccount{calendarAccountKey: calendarAccount}); err != nil {
+		utils.GetLogger(c).Error("couldn't add the calendar account", "userId", authUser.Id.Hex(), "calendarAccountKey", calendarAccountKey, "error", err)
+	}
 }
 
 // @Summary Removes an existing calendar account
@@ -529,17 +634,10 @@ func removeCalendarAccount(c *gin.Context) {
 	calendarAccountKey := utils.GetCalendarAccountKey(payload.Email, payload.CalendarType)
 
 	authUser := utils.GetAuthUser(c)
-	db.UsersCollection.UpdateByID(context.Background(), authUser.Id, bson.A{
-		bson.M{"$set": bson.M{
-			"calendarAccounts": bson.M{
-				"$setField": bson.M{
-					"field": calendarAccountKey,
-					"input": "$$ROOT.calendarAccounts",
-					"value": "$$REMOVE",
-				},
-			},
-		}},
-	})
+	if err := getRepositories(c).Users.RemoveCalendarAccount(authUser.Id, calendarAccountKey); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
@@ -558,7 +656,6 @@ func toggleCalendar(c *gin.Context) {
 		Enabled      *bool               `json:"enabled" binding:"required"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
-		logger.StdErr.Panicln(err)
 		return
 	}
 
@@ -569,13 +666,9 @@ func toggleCalendar(c *gin.Context) {
 		account.Enabled = payload.Enabled
 		authUser.CalendarAccounts[calendarAccountKey] = account
 
-		_, err := db.UsersCollection.UpdateOne(context.Background(), bson.M{
-			"_id": authUser.Id,
-		}, bson.M{
-			"$set": authUser,
-		})
+		err := getRepositories(c).Users.SetCalendarAccounts(authUser.Id, map[string]models.CalendarAccount{calendarAccountKey: account})
 		if err != nil {
-			logger.StdErr.Panicln(err)
+			utils.AbortWithError(c, err)
 			return
 		}
 	}
@@ -598,7 +691,6 @@ func toggleSubCalendar(c *gin.Context) {
 		Enabled       *bool               `json:"enabled" binding:"required"`
 	}{}
 	if err := c.Bind(&payload); err != nil {
-		logger.StdErr.Panicln(err)
 		return
 	}
 
@@ -611,13 +703,9 @@ func toggleSubCalendar(c *gin.Context) {
 			(*account.SubCalendars)[payload.SubCalendarId] = subCalendar
 			authUser.CalendarAccounts[calendarAccountKey] = account
 
-			_, err := db.UsersCollection.UpdateOne(context.Background(), bson.M{
-				"_id": authUser.Id,
-			}, bson.M{
-				"$set": authUser,
-			})
+			err := getRepositories(c).Users.SetCalendarAccounts(authUser.Id, map[string]models.CalendarAccount{calendarAccountKey: account})
 			if err != nil {
-				logger.StdErr.Panicln(err)
+				utils.AbortWithError(c, err)
 				return
 			}
 		}
@@ -626,7 +714,8 @@ func toggleSubCalendar(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{})
 }
 
-// @Summary Searches the user's contacts based on the given query
+// @Summary Searches the user's friends and contacts based on the given query
+// @Description Friends come first, followed by the contacts of the account the user signed in with (Google or Outlook). For other accounts, or if Outlook contacts can't be searched, the people the user responded to events with are searched instead
 // @Tags user
 // @Produce json
 // @Param query query string true "Query to search for"
@@ -644,29 +733,104 @@ func searchContacts(c *gin.Context) {
 	userInterface, _ := c.Get("authUser")
 	user := userInterface.(*models.User)
 
-	contacts, googleError := contacts.SearchContacts(user, payload.Query)
-	if googleError != nil {
+	friends, err := getRepositories(c).Users.GetPublic(user.FriendIds)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	results := utils.FilterUsers(friends, payload.Query)
+
+	provider := contacts.GetContactsProvider(user)
+	found, err := provider.SearchContacts(c.Request.Context(), payload.Query)
+	if googleError, ok := err.(*errs.GoogleAPIError); ok {
+		// The client asks for contacts access when it gets this error
 		c.JSON(googleError.Code, responses.Error{Error: *googleError})
 		return
+	} else if err != nil {
+		utils.GetLogger(c).Warn("couldn't search the user's contacts, searching the people they responded with instead", "userId", user.Id.Hex(), "error", err)
+		found, err = (&contacts.LocalContacts{User: user}).SearchContacts(c.Request.Context(), payload.Query)
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+	}
+
+	// Skip contacts that are already listed as friends
+	emails := utils.ArrayToSet(utils.Map(results, func(u models.User) string { return strings.ToLower(u.Email) }))
+	for _, contact := range found {
+		if _, ok := emails[strings.ToLower(contact.Email)]; !ok {
+			results = append(results, contact)
+		}
 	}
 
-	c.JSON(http.StatusOK, contacts)
+	c.JSON(http.StatusOK, results)
 }
 
-// @Summary Deletes the currently signed in user
+// @Summary Deletes the currently signed in user along with all of their data
+// @Description Not allowed while an admin is impersonating the user
 // @Tags user
 // @Produce json
+// @Param ownedEvents query string false "What to do with the events the user owns, either delete (default) or transfer"
+// @Param transferTo query string false "Email of the user to give the owned events to if ownedEvents is transfer. They have to be the user's friend"
 // @Success 200
 // @Router /user [delete]
 func deleteUser(c *gin.Context) {
-	userInterface, _ := c.Get("authUser")
-	user := userInterface.(*models.User)
+	user := utils.GetAuthUser(c)
+	repositories := getRepositories(c)
 
-	_, err := db.UsersCollection.DeleteOne(context.Background(), bson.M{"_id": user.Id})
-	if err != nil {
-		logger.StdErr.Panicln(err)
+	var transferTo *primitive.ObjectID
+	switch c.DefaultQuery("ownedEvents", "delete") {
+	case "delete":
+	case "transfer":
+		newOwner, err := repositories.Users.GetByEmail(strings.ToLower(strings.TrimSpace(c.Query("transferTo"))))
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		if newOwner == nil || newOwner.Id == user.Id {
+			c.JSON(http.StatusBadRequest, responses.Error{Error: errs.UserDoesNotExist})
+			return
+		}
+		transferTo = &newOwner.Id
+	default:
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "ownedEvents must be either delete or transfer"})
+		return
+	}
+
+	// The events are kept unless the user they would go to has accepted the user as a friend
+	deleted, err := repositories.Accounts.Delete(context.Background(), user, transferTo)
+	if errors.Is(err, db.ErrTransferNotAccepted) {
+		c.JSON(http.StatusBadRequest, responses.Error{Error: errs.UserNotFriends})
+		return
+	} else if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	// Give the user's sign up spots to the users on the waitlists
+	for eventId, blockIds := range deleted.FreedSignUpBlockIds {
+		promoteSignUpWaitlists(c, repositories, eventId, blockIds)
 	}
 
+	// Cancel reminders and remove the user from the mailing list asynchronously. The context can't be used once
+	// the request is done, so its logger is kept
+	log := utils.GetLogger(c)
+	go func() {
+		// Recover from panics
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error("panic while cleaning up after the deleted user", "error", fmt.Sprint(err))
+			}
+		}()
+
+		for _, taskId := range deleted.TaskIds {
+			gcloud.DeleteEmailTask(context.Background(), taskId)
+		}
+		if len(user.Email) > 0 {
+			listmonk.DeleteSubscriber(context.Background(), user.Email)
+		}
+	}()
+
 	// Delete session
 	session := sessions.Default(c)
 	session.Delete("userId")
@@ -674,3 +838,38 @@ func deleteUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+// @Summary Exports everything stored about the currently signed in user
+// @Description Not allowed while an admin is impersonating the user
+// @Tags user
+// @Produce application/zip
+// @Produce json
+// @Param format query string false "Either zip (default), with a JSON file for each kind of data, or json"
+// @Success 200 {object} db.UserData
+// @Router /user/export [get]
+func exportUserData(c *gin.Context) {
+	user := utils.GetAuthUser(c)
+
+	data, err := getRepositories(c).Accounts.GetData(context.Background(), user)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("timeful-data-%s", time.Now().Format("2006-01-02"))
+	switch c.DefaultQuery("format", "zip") {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, data)
+	case "zip":
+		var buffer bytes.Buffer
+		if err := utils.WriteJSONZip(&buffer, data); err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+		c.Data(http.StatusOK, "application/zip", buffer.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, responses.Error{Error: "format must be either zip or json"})
+	}
+}
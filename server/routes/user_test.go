@@ -0,0 +1,349 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/db"
+	"schej.it/server/db/memory"
+	"schej.it/server/errs"
+	"schej.it/server/models"
+	"schej.it/server/responses"
+	"schej.it/server/utils"
+)
+
+func TestDeleteUser(t *testing.T) {
+	t.Setenv("LISTMONK_ENABLED", "false")
+	t.Setenv("LISTMONK_WAITLIST_PROMOTED_EMAIL_ID", "")
+	database := memory.New()
+	router := newTestRouter(database)
+
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice", FriendIds: []primitive.ObjectID{bob.Id}}
+	database.Users.Insert(alice)
+	stranger := &models.User{Email: "sam@example.com", FirstName: "Sam"}
+	database.Users.Insert(stranger)
+	bob.FriendIds = []primitive.ObjectID{alice.Id}
+
+	standup := &models.Event{OwnerId: bob.Id, Name: "Standup", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(standup)
+
+	// Bob has the only spot of Alice's sign up block, and Sam is on its waitlist
+	capacity := 1
+	block := models.SignUpBlock{Id: primitive.NewObjectID(), Name: "Setup", Capacity: &capacity}
+	volunteering := &models.Event{
+		OwnerId:      alice.Id,
+		Name:         "Volunteering",
+		Type:         models.SPECIFIC_DATES,
+		SignUpBlocks: &[]models.SignUpBlock{block},
+		SignUpResponses: map[string]*models.SignUpResponse{
+			bob.Id.Hex():      {UserId: bob.Id, SignUpBlockIds: []primitive.ObjectID{block.Id}},
+			stranger.Id.Hex(): {UserId: stranger.Id, Waitlist: []models.SignUpWaitlistEntry{{SignUpBlockId: block.Id, JoinedAt: primitive.NewDateTimeFromTime(time.Now())}}},
+		},
+	}
+	database.Events.Insert(volunteering)
+
+	// Events are only transferred to a friend, and nothing is deleted otherwise
+	w := sendRequest(t, router, http.MethodDelete, "/api/user?ownedEvents=transfer&transferTo=sam@example.com", bob.Id.Hex(), nil)
+	var response responses.Error
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusBadRequest || response.Error != errs.UserNotFriends {
+		t.Errorf("transferring to a user that isn't a friend = %d %s, want %d %s", w.Code, w.Body.String(), http.StatusBadRequest, errs.UserNotFriends)
+	}
+	w = sendRequest(t, router, http.MethodDelete, "/api/user?ownedEvents=transfer&transferTo=nobody@example.com", bob.Id.Hex(), nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("transferring to a user that doesn't exist = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if mustGetUser(t, database, bob.Id) == nil || mustGetEvent(t, database, standup.Id) == nil {
+		t.Fatal("user or their events were deleted when the transfer wasn't accepted")
+	}
+
+	w = sendRequest(t, router, http.MethodDelete, "/api/user?ownedEvents=transfer&transferTo=alice@example.com", bob.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE /user = %d %s", w.Code, w.Body.String())
+	}
+	if mustGetUser(t, database, bob.Id) != nil {
+		t.Error("user still exists")
+	}
+	if event := mustGetEvent(t, database, standup.Id); event == nil || event.OwnerId != alice.Id {
+		t.Errorf("transferred event = %+v, want it owned by Alice", event)
+	}
+	if friend := mustGetUser(t, database, alice.Id); friend == nil || len(friend.FriendIds) != 0 {
+		t.Errorf("friend = %+v, want no friends left", friend)
+	}
+
+	// Bob's spot goes to Sam
+	event := mustGetEvent(t, database, volunteering.Id)
+	if _, ok := event.SignUpResponses[bob.Id.Hex()]; ok {
+		t.Error("deleted user's sign up is still there")
+	}
+	if response := event.SignUpResponses[stranger.Id.Hex()]; response == nil || len(response.SignUpBlockIds) != 1 || len(response.Waitlist) != 0 {
+		t.Errorf("waitlisted user's sign up = %+v, want them moved into the freed spot", response)
+	}
+}
+
+func TestExportUserData(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+	standup := &models.Event{OwnerId: bob.Id, Name: "Standup", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(standup)
+	lunch := &models.Event{OwnerId: primitive.NewObjectID(), Name: "Lunch", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(lunch)
+	database.Responses.Insert(&models.EventResponse{EventId: lunch.Id, UserId: bob.Id.Hex(), Response: &models.Response{}})
+	database.Attendees.Insert(&models.Attendee{EventId: lunch.Id, Email: bob.Email})
+	database.Folders.Create(&models.Folder{UserId: bob.Id, Name: "Work"})
+
+	w := sendRequest(t, router, http.MethodGet, "/api/user/export?format=json", bob.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /user/export = %d %s", w.Code, w.Body.String())
+	}
+	var data db.UserData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.OwnedEvents) != 1 || len(data.Responses) != 1 || len(data.Attendees) != 1 || len(data.Folders) != 1 {
+		t.Errorf("export has %d events, %d responses, %d attendees, and %d folders, want 1 of each",
+			len(data.OwnedEvents), len(data.Responses), len(data.Attendees), len(data.Folders))
+	}
+
+	w = sendRequest(t, router, http.MethodGet, "/api/user/export", bob.Id.Hex(), nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("GET /user/export = %d %s, want a zip", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+// Gets the path as the user and decodes the page of events it responds with
+func mustGetEventsPage(t *testing.T, router *gin.Engine, path string, userId string) db.EventSearchResult {
+	w := sendRequest(t, router, http.MethodGet, path, userId, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", path, w.Code, w.Body.String())
+	}
+	var result db.EventSearchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// Returns the names of the events, in order
+func getEventNames(events []models.Event) []string {
+	return utils.Map(events, func(event models.Event) string { return event.Name })
+}
+
+func TestGetEventsPages(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(alice)
+
+	// Events are inserted in the order they were created, so their _ids increase
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Standup", Type: models.SPECIFIC_DATES})
+	responded := &models.Event{OwnerId: alice.Id, Name: "Lunch", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(responded)
+	database.Responses.Insert(&models.EventResponse{EventId: responded.Id, UserId: bob.Id.Hex(), Response: &models.Response{}})
+	group := &models.Event{OwnerId: alice.Id, Name: "Team", Type: models.GROUP}
+	database.Events.Insert(group)
+	database.Attendees.Insert(&models.Attendee{EventId: group.Id, Email: bob.Email, Declined: utils.FalsePtr()})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Retro", Type: models.DOW})
+	database.Events.Insert(&models.Event{OwnerId: alice.Id, Name: "Alice's event", Type: models.SPECIFIC_DATES})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Deleted", Type: models.SPECIFIC_DATES, IsDeleted: utils.TruePtr()})
+
+	// The last page is exactly full, so it has no next cursor
+	first := mustGetEventsPage(t, router, "/api/user/events?limit=2", bob.Id.Hex())
+	if names := getEventNames(first.Events); len(names) != 2 || names[0] != "Retro" || names[1] != "Team" {
+		t.Errorf("first page = %v, want [Retro Team]", names)
+	}
+	if first.NextCursor == nil || *first.NextCursor != group.Id || first.Total != 4 {
+		t.Fatalf("first page has next cursor %v and total %d, want %s and 4", first.NextCursor, first.Total, group.Id.Hex())
+	}
+	if first.Events[1].HasResponded == nil || *first.Events[1].HasResponded {
+		t.Error("hasResponded isn't false for a group the user hasn't responded to")
+	}
+	second := mustGetEventsPage(t, router, "/api/user/events?limit=2&cursor="+first.NextCursor.Hex(), bob.Id.Hex())
+	if names := getEventNames(second.Events); len(names) != 2 || names[0] != "Lunch" || names[1] != "Standup" {
+		t.Errorf("second page = %v, want [Lunch Standup]", names)
+	}
+	if second.NextCursor != nil || second.Total != 4 {
+		t.Errorf("second page has next cursor %v and total %d, want none and 4", second.NextCursor, second.Total)
+	}
+
+	// Every event fits on the default page
+	if all := mustGetEventsPage(t, router, "/api/user/events", bob.Id.Hex()); len(all.Events) != 4 || all.NextCursor != nil {
+		t.Errorf("default page has %d events and next cursor %v, want 4 and none", len(all.Events), all.NextCursor)
+	}
+
+	for _, path := range []string{"/api/user/events?limit=0", "/api/user/events?limit=ten", "/api/user/events?cursor=abc", "/api/user/events/search?limit=-1"} {
+		if w := sendRequest(t, router, http.MethodGet, path, bob.Id.Hex(), nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearchEvents(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+
+	monday := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	description := "Quarterly goals"
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Weekly standup", Type: models.SPECIFIC_DATES, Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(monday)}})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Old standup", Type: models.SPECIFIC_DATES, IsArchived: utils.TruePtr(), Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(monday.AddDate(0, -1, 0))}})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Planning", Type: models.DOW, Description: &description})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Offsite", Type: models.SPECIFIC_DATES, ScheduledEvent: &models.CalendarEvent{Summary: "Offsite"}, Dates: []primitive.DateTime{primitive.NewDateTimeFromTime(monday.AddDate(0, 0, 7))}})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Volunteers", Type: models.SPECIFIC_DATES, IsSignUpForm: utils.TruePtr()})
+	database.Events.Insert(&models.Event{OwnerId: bob.Id, Name: "Team", Type: models.GROUP})
+
+	timeMin := url.QueryEscape(monday.Add(-time.Hour).Format(time.RFC3339))
+	timeMax := url.QueryEscape(monday.AddDate(0, 0, 7).Format(time.RFC3339))
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"type=specific_dates", []string{"Offsite", "Old standup", "Weekly standup"}},
+		{"type=sign_up", []string{"Volunteers"}},
+		{"type=dow,group", []string{"Team", "Planning"}},
+		{"query=standup", []string{"Old standup", "Weekly standup"}},
+		{"query=quarterly", []string{"Planning"}},
+		{"query=standup&archived=false", []string{"Weekly standup"}},
+		{"type=specific_dates&scheduled=true", []string{"Offsite"}},
+		{"type=specific_dates&scheduled=false&archived=false", []string{"Weekly standup"}},
+		{"timeMin=" + timeMin + "&timeMax=" + timeMax, []string{"Weekly standup"}},
+		{"timeMin=" + timeMin, []string{"Offsite", "Weekly standup"}},
+		{"type=group&query=standup", []string{}},
+	}
+	for _, test := range tests {
+		result := mustGetEventsPage(t, router, "/api/user/events/search?"+test.query, bob.Id.Hex())
+		names := getEventNames(result.Events)
+		if strings.Join(names, ",") != strings.Join(test.expected, ",") || result.Total != len(test.expected) {
+			t.Errorf("search %s = %v with total %d, want %v", test.query, names, result.Total, test.expected)
+		}
+	}
+
+	// The total counts every page
+	page := mustGetEventsPage(t, router, "/api/user/events/search?type=specific_dates&limit=1", bob.Id.Hex())
+	if len(page.Events) != 1 || page.Total != 3 || page.NextCursor == nil {
+		t.Errorf("first page has %d events, total %d, and next cursor %v, want 1, 3, and a cursor", len(page.Events), page.Total, page.NextCursor)
+	}
+	page = mustGetEventsPage(t, router, "/api/user/events/search?type=specific_dates&limit=2&cursor="+page.NextCursor.Hex(), bob.Id.Hex())
+	if names := getEventNames(page.Events); strings.Join(names, ",") != "Old standup,Weekly standup" || page.NextCursor != nil {
+		t.Errorf("last page = %v with next cursor %v, want [Old standup Weekly standup] and none", names, page.NextCursor)
+	}
+
+	if w := sendRequest(t, router, http.MethodGet, "/api/user/events/search?type=meeting", bob.Id.Hex(), nil); w.Code != http.StatusBadRequest {
+		t.Errorf("searching an unknown type = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateUserSettings(t *testing.T) {
+	database := memory.New()
+	router := newTestRouter(database)
+
+	workKey := "bob@work.example.com_google"
+	personalKey := "bob@example.com_google"
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob", CalendarAccounts: map[string]models.CalendarAccount{
+		workKey:     {Email: "bob@work.example.com", CalendarType: models.GoogleCalendarType, Enabled: utils.TruePtr()},
+		personalKey: {Email: "bob@example.com", CalendarType: models.GoogleCalendarType, Enabled: utils.TruePtr()},
+	}}
+	database.Users.Insert(bob)
+
+	requests := []struct {
+		method  string
+		path    string
+		payload interface{}
+	}{
+		{http.MethodPatch, "/api/user/name", gin.H{"firstName": "Robert", "lastName": "Smith"}},
+		{http.MethodPatch, "/api/user/timezone", gin.H{"timezone": "Europe/Berlin"}},
+		{http.MethodPost, "/api/user/toggle-calendar", gin.H{"email": "bob@example.com", "calendarType": models.GoogleCalendarType, "enabled": false}},
+		{http.MethodDelete, "/api/user/remove-calendar-account", gin.H{"email": "bob@work.example.com", "calendarType": models.GoogleCalendarType}},
+	}
+	for _, request := range requests {
+		if w := sendRequest(t, router, request.method, request.path, bob.Id.Hex(), request.payload); w.Code != http.StatusOK {
+			t.Fatalf("%s %s = %d %s", request.method, request.path, w.Code, w.Body.String())
+		}
+	}
+
+	user := mustGetUser(t, database, bob.Id)
+	if user.FirstName != "Robert" || user.LastName != "Smith" || !utils.Coalesce(user.HasCustomName) {
+		t.Errorf("name = %s %s, want a custom name of Robert Smith", user.FirstName, user.LastName)
+	}
+	if user.Timezone != "Europe/Berlin" {
+		t.Errorf("timezone = %q, want Europe/Berlin", user.Timezone)
+	}
+	if _, ok := user.CalendarAccounts[workKey]; ok {
+		t.Error("the removed calendar account is still there")
+	}
+	if account, ok := user.CalendarAccounts[personalKey]; !ok || utils.Coalesce(account.Enabled) {
+		t.Error("the toggled calendar account is still enabled")
+	}
+}
+
+func TestUserEventHistory(t *testing.T) {
+	t.Setenv("LISTMONK_ENABLED", "false")
+	database := memory.New()
+	router := newTestRouter(database)
+
+	alice := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	database.Users.Insert(alice)
+	bob := &models.User{Email: "bob@example.com", FirstName: "Bob"}
+	database.Users.Insert(bob)
+	lunch := &models.Event{OwnerId: alice.Id, Name: "Lunch", Type: models.SPECIFIC_DATES}
+	database.Events.Insert(lunch)
+
+	// Bob's responses were deleted, once signed in and once as a guest with his email, and Bob renamed the event
+	database.EventHistory.Insert(&models.EventHistoryEntry{EventId: lunch.Id, Action: models.DeleteResponseAction, ActorId: &alice.Id, Before: map[string]interface{}{
+		"userId":   bob.Id.Hex(),
+		"response": &models.Response{Name: "Bob"},
+	}})
+	database.EventHistory.Insert(&models.EventHistoryEntry{EventId: lunch.Id, Action: models.DeleteResponseAction, ActorId: &alice.Id, Before: map[string]interface{}{
+		"userId":   "Bob",
+		"response": &models.Response{Name: "Bob", Email: bob.Email},
+	}})
+	database.EventHistory.Insert(&models.EventHistoryEntry{EventId: lunch.Id, Action: models.EditEventAction, ActorId: &bob.Id,
+		Before: map[string]interface{}{"name": "Lunch"},
+		After:  map[string]interface{}{"name": "Team lunch"},
+	})
+	database.EventHistory.Insert(&models.EventHistoryEntry{EventId: lunch.Id, Action: models.AddAttendeeAction, ActorId: &alice.Id,
+		After: map[string]interface{}{"email": "sam@example.com"},
+	})
+
+	w := sendRequest(t, router, http.MethodGet, "/api/user/export?format=json", bob.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /user/export = %d %s", w.Code, w.Body.String())
+	}
+	var data db.UserData
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.EventHistory) != 3 {
+		t.Errorf("export has %d event history entries, want 3", len(data.EventHistory))
+	}
+
+	w = sendRequest(t, router, http.MethodDelete, "/api/user", bob.Id.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE /user = %d %s", w.Code, w.Body.String())
+	}
+
+	// The entries about Bob's responses are gone, and his change is kept without his user id
+	history, _, _ := database.EventHistory.GetByEventId(lunch.Id, nil, 10)
+	if len(history) != 2 || history[0].Action != models.AddAttendeeAction || history[1].Action != models.EditEventAction {
+		t.Fatalf("event history = %+v, want the edit and the added attendee", history)
+	}
+	if history[1].ActorId != nil {
+		t.Errorf("edit actor = %s, want none", history[1].ActorId.Hex())
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joho/godotenv"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+	"schej.it/server/migrations"
+)
+
+// Usage: go run ./scripts/migrate [-dry-run] up|status
+func main() {
+	dryRun := flag.Bool("dry-run", false, "List the pending migrations without applying them")
+	flag.Parse()
+
+	godotenv.Load(".env")
+	logger.Init(io.Discard)
+	closeConnection := db.Init()
+	defer closeConnection()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrations.Up(context.Background(), *dryRun, logger.StdOut)
+		if err != nil {
+			logger.StdErr.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		} else if *dryRun {
+			fmt.Printf("%d migrations are pending\n", len(applied))
+		} else {
+			fmt.Printf("Applied %d migrations\n", len(applied))
+		}
+	case "status":
+		statuses, err := migrations.Status(context.Background())
+		if err != nil {
+			logger.StdErr.Fatal(err)
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("[applied %s] %s\n", status.AppliedAt.Format("2006-01-02 15:04"), status.Id)
+			} else {
+				fmt.Printf("[pending]                  %s\n", status.Id)
+			}
+		}
+	default:
+		fmt.Println("Usage: go run ./scripts/migrate [-dry-run] up|status")
+		os.Exit(2)
+	}
+}
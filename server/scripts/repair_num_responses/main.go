@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/joho/godotenv"
+	"schej.it/server/db"
+	"schej.it/server/logger"
+)
+
+// Recomputes the number of responses of every event from the eventResponses collection
+//
+// Usage: go run ./scripts/repair_num_responses [-dry-run]
+func main() {
+	dryRun := flag.Bool("dry-run", false, "List the events whose number of responses is wrong without fixing them")
+	flag.Parse()
+
+	godotenv.Load(".env")
+	logger.Init(io.Discard)
+	closeConnection := db.Init()
+	defer closeConnection()
+
+	drifts, err := db.RepairNumResponses(context.Background(), *dryRun)
+	if err != nil {
+		logger.StdErr.Fatal(err)
+	}
+
+	for _, drift := range drifts {
+		stored := "missing"
+		if drift.Stored != nil {
+			stored = fmt.Sprint(*drift.Stored)
+		}
+		fmt.Printf("%s: %s => %d\n", drift.EventId.Hex(), stored, drift.Actual)
+	}
+	if *dryRun {
+		fmt.Printf("%d events have the wrong number of responses\n", len(drifts))
+	} else {
+		fmt.Printf("Fixed the number of responses of %d events\n", len(drifts))
+	}
+}
@@ -7,18 +7,21 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"schej.it/server/db"
+	"schej.it/server/errs"
 	"schej.it/server/logger"
 	"schej.it/server/models"
+	"schej.it/server/services/httpclient"
 	"schej.it/server/utils"
 )
 
-// Returns access, refresh, and id tokens from the auth code
-func GetTokensFromAuthCode(code string, scope string, origin string, calendarType models.CalendarType) TokenResponse {
+// Returns access, refresh, and id tokens from the auth code. Returns an *errs.Error with the invalid-auth-code
+// code if the auth code has expired or was already used
+func GetTokensFromAuthCode(ctx context.Context, code string, scope string, origin string, calendarType models.CalendarType) (TokenResponse, error) {
 	clientId, clientSecret := getCredentialsFromCalendarType(calendarType)
 	tokenEndpoint := getTokenEndpointFromCalendarType(calendarType)
 
@@ -32,27 +35,30 @@ func GetTokensFromAuthCode(code string, scope string, origin string, calendarTyp
 		"redirect_uri":  {redirectUri},
 		"grant_type":    {"authorization_code"},
 	}
-	resp, err := http.PostForm(
-		tokenEndpoint,
-		values,
-	)
+	resp, err := postForm(ctx, tokenEndpoint, values)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return TokenResponse{}, errs.From(err)
 	}
 	defer resp.Body.Close()
 
 	var res TokenResponse
-
-	json.NewDecoder(resp.Body).Decode(&res)
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return TokenResponse{}, errs.Upstream(err, getProviderName(calendarType))
+	}
 	if len(res.Error) > 0 {
-		data, _ := json.MarshalIndent(res, "", "  ")
-		logger.StdErr.Panicln(string(data))
+		err := fmt.Errorf("%s: %s", res.Error, res.ErrorDescription)
+		if res.Error == "invalid_grant" {
+			return TokenResponse{}, errs.Wrap(err, http.StatusBadRequest, errs.InvalidAuthCode, "The authorization code is invalid or has expired")
+		}
+		return TokenResponse{}, errs.Upstream(err, getProviderName(calendarType))
 	}
 
-	return res
+	return res, nil
 }
 
-func RefreshAccessToken(accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType) AccessTokenResponse {
+// Returns a new access token for the account. Returns an *errs.Error with the calendar-token-expired code if
+// the refresh token has expired or been revoked
+func RefreshAccessToken(ctx context.Context, accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType) (AccessTokenResponse, error) {
 	clientId, clientSecret := getCredentialsFromCalendarType(calendarType)
 	tokenEndpoint := getTokenEndpointFromCalendarType(calendarType)
 	values := url.Values{
@@ -63,46 +69,47 @@ func RefreshAccessToken(accountAuth *models.OAuth2CalendarAuth, calendarType mod
 		"grant_type":    {"refresh_token"},
 	}
 
-	resp, err := http.PostForm(
-		tokenEndpoint,
-		values,
-	)
+	resp, err := postForm(ctx, tokenEndpoint, values)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return AccessTokenResponse{}, errs.From(err)
 	}
 	defer resp.Body.Close()
 
 	var res AccessTokenResponse
-	json.NewDecoder(resp.Body).Decode(&res)
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return AccessTokenResponse{}, errs.Upstream(err, getProviderName(calendarType))
+	}
+	if len(res.Error) > 0 {
+		err := fmt.Errorf("%s: %s", res.Error, res.ErrorDescription)
+		if res.Error == "invalid_grant" {
+			return AccessTokenResponse{}, errs.TokenExpired(err)
+		}
+		return AccessTokenResponse{}, errs.Upstream(err, getProviderName(calendarType))
+	}
 
-	return res
+	return res, nil
 }
 
 type RefreshAccessTokenData struct {
 	TokenResponse AccessTokenResponse
 	Email         string
 	CalendarType  models.CalendarType
-	Error         *interface{}
+	Error         error
 }
 
-func RefreshAccessTokenAsync(email string, accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType, c chan RefreshAccessTokenData) {
-	// Recover from panics
-	defer func() {
-		if err := recover(); err != nil {
-			c <- RefreshAccessTokenData{Error: &err}
-		}
-	}()
+func RefreshAccessTokenAsync(ctx context.Context, email string, accountAuth *models.OAuth2CalendarAuth, calendarType models.CalendarType, c chan RefreshAccessTokenData) {
+	tokenResponse, err := RefreshAccessToken(ctx, accountAuth, calendarType)
 
-	tokenResponse := RefreshAccessToken(accountAuth, calendarType)
-
-	c <- RefreshAccessTokenData{tokenResponse, email, calendarType, nil}
+	c <- RefreshAccessTokenData{tokenResponse, email, calendarType, err}
 }
 
 // If access token has expired, get a new token for the primary account as well as all other calendar accounts, update the user object, and save it to the database
 // `accounts` specifies for which accounts to refresh access tokens. If `accounts` is nil or empty, then update tokens for all accounts
-func RefreshUserTokenIfNecessary(u *models.User, accounts models.Set[string]) {
+// Returns the errors of the accounts whose access tokens couldn't be refreshed, by calendar account key
+func RefreshUserTokenIfNecessary(ctx context.Context, u *models.User, accounts models.Set[string]) map[string]error {
 	refreshTokenChan := make(chan RefreshAccessTokenData)
 	numAccountsToUpdate := 0
+	refreshErrors := make(map[string]error)
 
 	// If `accounts` is nil, then update tokens for all accounts
 	updateAllAccounts := len(accounts) == 0
@@ -114,7 +121,7 @@ func RefreshUserTokenIfNecessary(u *models.User, accounts models.Set[string]) {
 
 			if _, ok := accounts[accountKey]; ok || updateAllAccounts {
 				if time.Now().After(accountAuth.AccessTokenExpireDate.Time()) && len(accountAuth.RefreshToken) > 0 {
-					go RefreshAccessTokenAsync(account.Email, accountAuth, account.CalendarType, refreshTokenChan)
+					go RefreshAccessTokenAsync(ctx, account.Email, accountAuth, account.CalendarType, refreshTokenChan)
 					numAccountsToUpdate++
 				}
 			}
@@ -122,31 +129,51 @@ func RefreshUserTokenIfNecessary(u *models.User, accounts models.Set[string]) {
 	}
 
 	// Update access tokens as responses are received
+	updatedAccounts := make(map[string]models.CalendarAccount)
 	for i := 0; i < numAccountsToUpdate; i++ {
 		res := <-refreshTokenChan
 
+		calendarAccountKey := utils.GetCalendarAccountKey(res.Email, res.CalendarType)
 		if res.Error != nil {
+			logger.StdErr.Printf("Couldn't refresh access token of %s: %v\n", calendarAccountKey, res.Error)
+			refreshErrors[calendarAccountKey] = res.Error
 			continue
 		}
 
 		accessTokenExpireDate := utils.GetAccessTokenExpireDate(res.TokenResponse.ExpiresIn)
 
-		calendarAccountKey := utils.GetCalendarAccountKey(res.Email, res.CalendarType)
 		if calendarAccount, ok := u.CalendarAccounts[calendarAccountKey]; ok {
 			calendarAccount.OAuth2CalendarAuth.AccessToken = res.TokenResponse.AccessToken
 			calendarAccount.OAuth2CalendarAuth.AccessTokenExpireDate = primitive.NewDateTimeFromTime(accessTokenExpireDate)
 			u.CalendarAccounts[calendarAccountKey] = calendarAccount
+			updatedAccounts[calendarAccountKey] = calendarAccount
 		}
 	}
 
-	// Update user object if accounts were updated
-	if numAccountsToUpdate > 0 {
-		db.UsersCollection.FindOneAndUpdate(
-			context.Background(),
-			bson.M{"_id": u.Id},
-			bson.M{"$set": u},
-		)
+	// Save the accounts that were updated. This isn't canceled with ctx, since the new tokens would be lost
+	if err := db.SetCalendarAccounts(context.Background(), u.Id, updatedAccounts); err != nil {
+		logger.StdErr.Println(err)
+	}
+
+	return refreshErrors
+}
+
+// Posts the form to the token endpoint. Token requests aren't retried since auth codes can only be used once
+func postForm(ctx context.Context, tokenEndpoint string, values url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return httpclient.Client.Do(req)
+}
+
+// Returns the name of the company whose accounts have the given calendar type, i.e. "Google"
+func getProviderName(calendarType models.CalendarType) string {
+	if calendarType == models.OutlookCalendarType {
+		return "Microsoft"
 	}
+	return "Google"
 }
 
 func getCredentialsFromCalendarType(calendarType models.CalendarType) (string, string) {
@@ -11,6 +11,7 @@ import (
 	"github.com/jonyTF/go-webdav/caldav"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"schej.it/server/models"
+	"schej.it/server/services/httpclient"
 	"schej.it/server/utils"
 )
 
@@ -18,23 +19,23 @@ type AppleCalendar struct {
 	models.AppleCalendarAuth
 }
 
-func (calendar *AppleCalendar) GetCalendarList() (map[string]models.SubCalendar, error) {
+func (calendar *AppleCalendar) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
 	webdavClient, caldavClient, err := calendar.getClients()
 	if err != nil {
 		return nil, err
 	}
 
-	principal, err := webdavClient.FindCurrentUserPrincipal(context.Background())
+	principal, err := webdavClient.FindCurrentUserPrincipal(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	calendarHomeSet, err := caldavClient.FindCalendarHomeSet(context.Background(), principal)
+	calendarHomeSet, err := caldavClient.FindCalendarHomeSet(ctx, principal)
 	if err != nil {
 		return nil, err
 	}
 
-	calendars, err := caldavClient.FindCalendars(context.Background(), calendarHomeSet)
+	calendars, err := caldavClient.FindCalendars(ctx, calendarHomeSet)
 	if err != nil {
 		return nil, err
 	}
@@ -56,14 +57,14 @@ func (calendar *AppleCalendar) GetCalendarList() (map[string]models.SubCalendar,
 	return filteredCalendars, nil
 }
 
-func (calendar *AppleCalendar) GetCalendarEvents(calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+func (calendar *AppleCalendar) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
 	_, caldavClient, err := calendar.getClients()
 	if err != nil {
 		return nil, err
 	}
 
 	// Get events
-	events, err := caldavClient.QueryCalendar(context.Background(), calendarId, &caldav.CalendarQuery{
+	events, err := caldavClient.QueryCalendar(ctx, calendarId, &caldav.CalendarQuery{
 		CompRequest: caldav.CalendarCompRequest{
 			Name: "VCALENDAR",
 			Comps: []caldav.CalendarCompRequest{{
@@ -138,7 +139,7 @@ func (calendar *AppleCalendar) getClients() (*webdav.Client, *caldav.Client, err
 		return nil, nil, err
 	}
 
-	httpClient := webdav.HTTPClientWithBasicAuth(nil, calendar.Email, decryptedPassword)
+	httpClient := webdav.HTTPClientWithBasicAuth(httpclient.Client, calendar.Email, decryptedPassword)
 
 	webdavClient, err := webdav.NewClient(httpClient, "https://caldav.icloud.com")
 	if err != nil {
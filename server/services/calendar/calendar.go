@@ -1,8 +1,12 @@
 package calendar
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"time"
 
+	"schej.it/server/errs"
 	"schej.it/server/models"
 	"schej.it/server/services/auth"
 	"schej.it/server/utils"
@@ -14,18 +18,18 @@ type GetCalendarListData struct {
 	Error              error                         `json:"error"`
 }
 
-// Calls GetCalendarList but broadcasts the result to channel
-func GetCalendarListAsync(calendarAccountKey string, calendarProvider *CalendarProvider, c chan GetCalendarListData) {
+// Calls GetCalendarList but broadcasts the result to channel, unless ctx is done first
+func GetCalendarListAsync(ctx context.Context, calendarAccountKey string, calendarProvider *CalendarProvider, c chan GetCalendarListData) {
 	// Recover from panics
 	defer func() {
 		if err := recover(); err != nil {
-			c <- GetCalendarListData{Error: err.(error)}
+			send(ctx, c, GetCalendarListData{CalendarAccountKey: calendarAccountKey, Error: fmt.Errorf("%v", err)})
 		}
 	}()
 
-	calendarList, err := (*calendarProvider).GetCalendarList()
+	calendarList, err := (*calendarProvider).GetCalendarList(ctx)
 
-	c <- GetCalendarListData{CalendarList: calendarList, CalendarAccountKey: calendarAccountKey, Error: err}
+	send(ctx, c, GetCalendarListData{CalendarList: calendarList, CalendarAccountKey: calendarAccountKey, Error: err})
 }
 
 type GetCalendarEventsData struct {
@@ -34,28 +38,46 @@ type GetCalendarEventsData struct {
 	Error              error                  `json:"error"`
 }
 
-// Get the user's list of calendar events for the given calendar
-func GetCalendarEventsAsync(calendarAccountKey string, calendarProvider *CalendarProvider, calendarId string, timeMin time.Time, timeMax time.Time, c chan GetCalendarEventsData) {
+// Get the user's list of calendar events for the given calendar, unless ctx is done first
+func GetCalendarEventsAsync(ctx context.Context, calendarAccountKey string, calendarProvider *CalendarProvider, calendarId string, timeMin time.Time, timeMax time.Time, c chan GetCalendarEventsData) {
 	// Recover from panics
 	defer func() {
 		if err := recover(); err != nil {
-			c <- GetCalendarEventsData{Error: err.(error)}
+			send(ctx, c, GetCalendarEventsData{CalendarAccountKey: calendarAccountKey, Error: fmt.Errorf("%v", err)})
 		}
 	}()
 
-	calendarEvents, err := (*calendarProvider).GetCalendarEvents(calendarId, timeMin, timeMax)
+	calendarEvents, err := (*calendarProvider).GetCalendarEvents(ctx, calendarId, timeMin, timeMax)
 
-	c <- GetCalendarEventsData{CalendarEvents: calendarEvents, CalendarAccountKey: calendarAccountKey, Error: err}
+	send(ctx, c, GetCalendarEventsData{CalendarEvents: calendarEvents, CalendarAccountKey: calendarAccountKey, Error: err})
+}
+
+// Sends the value on the channel, or gives up if ctx is done so that the goroutine sending it can exit once
+// nothing is receiving anymore
+func send[T any](ctx context.Context, c chan T, value T) {
+	select {
+	case c <- value:
+	case <-ctx.Done():
+	}
 }
 
 type CalendarEventsWithError struct {
 	CalendarEvents []models.CalendarEvent `json:"calendarEvents"`
-	Error          error                  `json:"error,omitempty"`
+	Error          *errs.Error            `json:"error,omitempty"`
 }
 
+// Replaced in tests
+var getCalendarProvider = GetCalendarProvider
+
 // Returns a map mapping email to the calendar events associated with that email, and an error if there was an error fetching events for that email
-func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], timeMin time.Time, timeMax time.Time) (map[string]CalendarEventsWithError, bool) {
-	auth.RefreshUserTokenIfNecessary(user, accounts)
+// The error has the calendar-token-expired code if the account has to be signed in to again, and the timeout code if some of the account's
+// calendars didn't respond within fetchTimeout, in which case the events of the calendars that did respond are still returned
+// If ctx is done before all the events are fetched, the requests that are still going are canceled and ctx's error is returned
+func GetUsersCalendarEvents(ctx context.Context, user *models.User, accounts models.Set[string], timeMin time.Time, timeMax time.Time) (map[string]CalendarEventsWithError, bool, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	refreshErrors := auth.RefreshUserTokenIfNecessary(fetchCtx, user, accounts)
 
 	returnAllAccounts := len(accounts) == 0
 	editedCalendarAccounts := false
@@ -65,16 +87,29 @@ func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], time
 	calendarListChan := make(chan GetCalendarListData)
 	calendarEventsChan := make(chan GetCalendarEventsData)
 
+	// The number of requests of each account that haven't responded yet
+	pendingRequests := make(map[string]int)
+	numPendingRequests := 0
+
 	// Get calendar lists
-	numCalendarListRequests := 0
 	for _, account := range user.CalendarAccounts {
-		calendarProvider := GetCalendarProvider(account)
+		calendarProvider := getCalendarProvider(account)
 		calendarAccountKey := utils.GetCalendarAccountKey(account.Email, account.CalendarType)
 
 		// Get secondary account calendars
 		if _, ok := accounts[calendarAccountKey]; ok || returnAllAccounts {
-			go GetCalendarListAsync(calendarAccountKey, &calendarProvider, calendarListChan)
-			numCalendarListRequests++
+			// Don't call the provider with an access token that's known to be expired
+			if err, ok := refreshErrors[calendarAccountKey]; ok {
+				calendarEventsMap[calendarAccountKey] = CalendarEventsWithError{
+					CalendarEvents: make([]models.CalendarEvent, 0),
+					Error:          errs.From(err),
+				}
+				continue
+			}
+
+			go GetCalendarListAsync(fetchCtx, calendarAccountKey, &calendarProvider, calendarListChan)
+			pendingRequests[calendarAccountKey]++
+			numPendingRequests++
 
 			calendarEventsMap[calendarAccountKey] = CalendarEventsWithError{
 				CalendarEvents: make([]models.CalendarEvent, 0),
@@ -82,76 +117,82 @@ func GetUsersCalendarEvents(user *models.User, accounts models.Set[string], time
 		}
 	}
 
-	// After each calendar list is fetched, get the calendar events from each calendar
-	numCalendarEventsRequests := 0
-	for i := 0; i < numCalendarListRequests; i++ {
-		calendarListData := <-calendarListChan
-
-		if calendarListData.Error != nil {
-			// This is needed to be able to send an error back to user if a given calendar account's refresh token is invalid, for example
-			go func() { // needs to be async because writing to a channel is blocking
-				calendarEventsChan <- GetCalendarEventsData{CalendarAccountKey: calendarListData.CalendarAccountKey, Error: calendarListData.Error}
-			}()
-			numCalendarEventsRequests++
-			continue
-		}
+	// After each calendar list is fetched, get the calendar events from each calendar, and append them to the
+	// calendarEvents array associated with the given email as they're fetched
+	for numPendingRequests > 0 {
+		select {
+		case calendarListData := <-calendarListChan:
+			calendarAccountKey := calendarListData.CalendarAccountKey
+			pendingRequests[calendarAccountKey]--
+			numPendingRequests--
+
+			if calendarListData.Error != nil {
+				// This is needed to be able to send an error back to user if a given calendar account's refresh token is invalid, for example
+				events := calendarEventsMap[calendarAccountKey]
+				events.Error = errs.From(calendarListData.Error)
+				calendarEventsMap[calendarAccountKey] = events
+				continue
+			}
 
-		// Edit subcalendars map
-		account := user.CalendarAccounts[calendarListData.CalendarAccountKey]
-		calendarProvider := GetCalendarProvider(account)
-		if account.SubCalendars == nil {
-			account.SubCalendars = &calendarListData.CalendarList
-			user.CalendarAccounts[calendarListData.CalendarAccountKey] = account
-			editedCalendarAccounts = true
-		} else {
-			// Add subCalendar if it doesn't exist
-			for id, subCalendar := range calendarListData.CalendarList {
-				if _, ok := (*account.SubCalendars)[id]; !ok {
-					(*account.SubCalendars)[id] = subCalendar
-
-					if !editedCalendarAccounts {
+			// Edit subcalendars map
+			account := user.CalendarAccounts[calendarAccountKey]
+			calendarProvider := getCalendarProvider(account)
+			if account.SubCalendars == nil {
+				account.SubCalendars = &calendarListData.CalendarList
+				editedCalendarAccounts = true
+			} else {
+				// Add subCalendar if it doesn't exist
+				for id, subCalendar := range calendarListData.CalendarList {
+					if _, ok := (*account.SubCalendars)[id]; !ok {
+						(*account.SubCalendars)[id] = subCalendar
 						editedCalendarAccounts = true
 					}
 				}
-			}
 
-			// Remove subCalendar if it no longer exists
-			for id := range *account.SubCalendars {
-				if _, ok := calendarListData.CalendarList[id]; !ok {
-					delete(*account.SubCalendars, id)
-
-					if !editedCalendarAccounts {
+				// Remove subCalendar if it no longer exists
+				for id := range *account.SubCalendars {
+					if _, ok := calendarListData.CalendarList[id]; !ok {
+						delete(*account.SubCalendars, id)
 						editedCalendarAccounts = true
 					}
 				}
 			}
-		}
-		user.CalendarAccounts[calendarListData.CalendarAccountKey] = account
+			user.CalendarAccounts[calendarAccountKey] = account
 
-		for id := range *account.SubCalendars {
-			go GetCalendarEventsAsync(calendarListData.CalendarAccountKey, &calendarProvider, id, timeMin, timeMax, calendarEventsChan)
-			numCalendarEventsRequests++
-		}
-	}
+			for id := range *account.SubCalendars {
+				go GetCalendarEventsAsync(fetchCtx, calendarAccountKey, &calendarProvider, id, timeMin, timeMax, calendarEventsChan)
+				pendingRequests[calendarAccountKey]++
+				numPendingRequests++
+			}
 
-	// After calendar events are fetched, append to the calendarEvents array associated with the given email
-	for i := 0; i < numCalendarEventsRequests; i++ {
-		calendarEventsData := <-calendarEventsChan
-		calendarAccountKey := calendarEventsData.CalendarAccountKey
+		case calendarEventsData := <-calendarEventsChan:
+			calendarAccountKey := calendarEventsData.CalendarAccountKey
+			pendingRequests[calendarAccountKey]--
+			numPendingRequests--
 
-		if _, ok := calendarEventsMap[calendarAccountKey]; !ok {
-			calendarEventsMap[calendarAccountKey] = CalendarEventsWithError{}
-		}
-
-		if events, ok := calendarEventsMap[calendarAccountKey]; ok {
+			events := calendarEventsMap[calendarAccountKey]
 			if calendarEventsData.Error != nil {
-				events.Error = calendarEventsData.Error
+				events.Error = errs.From(calendarEventsData.Error)
 			} else {
 				events.CalendarEvents = append(events.CalendarEvents, calendarEventsData.CalendarEvents...)
 			}
 			calendarEventsMap[calendarAccountKey] = events
+
+		case <-fetchCtx.Done():
+			if ctx.Err() != nil {
+				return nil, false, ctx.Err()
+			}
+
+			// Return what was fetched before the deadline, marking the accounts that are missing calendars
+			for calendarAccountKey, numPending := range pendingRequests {
+				if events := calendarEventsMap[calendarAccountKey]; numPending > 0 && events.Error == nil {
+					events.Error = errs.Wrap(fetchCtx.Err(), http.StatusGatewayTimeout, errs.Timeout, "The calendar took too long to respond")
+					calendarEventsMap[calendarAccountKey] = events
+				}
+			}
+			return calendarEventsMap, editedCalendarAccounts, nil
 		}
 	}
 
-	return calendarEventsMap, editedCalendarAccounts
+	return calendarEventsMap, editedCalendarAccounts, nil
 }
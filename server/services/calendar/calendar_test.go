@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"schej.it/server/errs"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Has one calendar with one event, and takes `delay` to respond to each call unless ctx is done first
+type fakeCalendar struct {
+	delay time.Duration
+}
+
+func (calendar *fakeCalendar) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
+	if err := calendar.wait(ctx); err != nil {
+		return nil, err
+	}
+	return map[string]models.SubCalendar{"primary": {Name: "Primary"}}, nil
+}
+
+func (calendar *fakeCalendar) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+	if err := calendar.wait(ctx); err != nil {
+		return nil, err
+	}
+	return []models.CalendarEvent{{CalendarId: calendarId, Summary: "Busy"}}, nil
+}
+
+func (calendar *fakeCalendar) wait(ctx context.Context) error {
+	select {
+	case <-time.After(calendar.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestGetUsersCalendarEventsPartialResults(t *testing.T) {
+	user := &models.User{CalendarAccounts: map[string]models.CalendarAccount{}}
+	for _, email := range []string{"fast@example.com", "slow@example.com"} {
+		user.CalendarAccounts[utils.GetCalendarAccountKey(email, models.AppleCalendarType)] = models.CalendarAccount{
+			CalendarType:      models.AppleCalendarType,
+			AppleCalendarAuth: &models.AppleCalendarAuth{Email: email},
+			Email:             email,
+		}
+	}
+	fastKey := utils.GetCalendarAccountKey("fast@example.com", models.AppleCalendarType)
+	slowKey := utils.GetCalendarAccountKey("slow@example.com", models.AppleCalendarType)
+
+	defer func(original func(models.CalendarAccount) CalendarProvider, originalTimeout time.Duration) {
+		getCalendarProvider = original
+		fetchTimeout = originalTimeout
+	}(getCalendarProvider, fetchTimeout)
+	getCalendarProvider = func(account models.CalendarAccount) CalendarProvider {
+		delay := time.Millisecond
+		if account.Email == "slow@example.com" {
+			delay = time.Hour
+		}
+		return &limitedCalendarProvider{provider: &fakeCalendar{delay: delay}, calendarType: account.CalendarType}
+	}
+	fetchTimeout = 200 * time.Millisecond
+
+	// The slow account times out, without holding back the fast one
+	start := time.Now()
+	calendarEvents, _, err := GetUsersCalendarEvents(context.Background(), user, nil, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %v, want about fetchTimeout", elapsed)
+	}
+	if fast := calendarEvents[fastKey]; fast.Error != nil || len(fast.CalendarEvents) != 1 {
+		t.Errorf("fast account = %+v, want its event", fast)
+	}
+	if slow := calendarEvents[slowKey]; slow.Error == nil || slow.Error.Code != errs.Timeout {
+		t.Errorf("slow account = %+v, want a timeout error", slow)
+	}
+
+	// If the caller gives up first, its error is returned instead
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, _, err := GetUsersCalendarEvents(ctx, user, nil, time.Now(), time.Now().Add(time.Hour)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	defer func(originalGlobal limiter, originalProviders map[models.CalendarType]limiter) {
+		globalLimiter = originalGlobal
+		providerLimiters = originalProviders
+	}(globalLimiter, providerLimiters)
+	globalLimiter = newLimiter(2)
+	providerLimiters = newProviderLimiters(1)
+
+	releaseGoogle, err := acquire(context.Background(), models.GoogleCalendarType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other providers can still be called, but not Google
+	releaseApple, err := acquire(context.Background(), models.AppleCalendarType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := acquire(ctx, models.GoogleCalendarType); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want to wait for the Google call to finish", err)
+	}
+
+	// The global limit is reached, so Outlook has to wait too
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := acquire(ctx, models.OutlookCalendarType); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want to wait for the global limit", err)
+	}
+
+	releaseGoogle()
+	releaseApple()
+	releaseOutlook, err := acquire(context.Background(), models.OutlookCalendarType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseOutlook()
+}
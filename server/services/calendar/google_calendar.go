@@ -1,6 +1,7 @@
 package calendar
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,8 +10,8 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"schej.it/server/errs"
-	"schej.it/server/logger"
 	"schej.it/server/models"
+	"schej.it/server/services/httpclient"
 	"schej.it/server/utils"
 )
 
@@ -18,16 +19,17 @@ type GoogleCalendar struct {
 	models.OAuth2CalendarAuth
 }
 
-func (calendar GoogleCalendar) GetCalendarList() (map[string]models.SubCalendar, error) {
-	req, _ := http.NewRequest(
+func (calendar GoogleCalendar) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
+	req, _ := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		"https://www.googleapis.com/calendar/v3/users/me/calendarList?fields=items(id,summary,selected)",
 		nil,
 	)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", calendar.AccessToken))
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, errs.From(err)
 	}
 	defer resp.Body.Close()
 
@@ -44,7 +46,7 @@ func (calendar GoogleCalendar) GetCalendarList() (map[string]models.SubCalendar,
 	// Parse the response
 	var res Response
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, errs.Upstream(err, "Google")
 	}
 
 	// Check if the response returned an error
@@ -71,18 +73,19 @@ func (calendar GoogleCalendar) GetCalendarList() (map[string]models.SubCalendar,
 	return calendars, nil
 }
 
-func (calendar *GoogleCalendar) GetCalendarEvents(calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+func (calendar *GoogleCalendar) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
 	min, _ := timeMin.MarshalText()
 	max, _ := timeMax.MarshalText()
-	req, _ := http.NewRequest(
+	req, _ := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("https://www.googleapis.com/calendar/v3/calendars/%s/events?fields=items(id,summary,start,end,transparency,attendees)&timeMin=%s&timeMax=%s&singleEvents=true&eventTypes=default&eventTypes=outOfOffice", url.PathEscape(calendarId), min, max),
 		nil,
 	)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", calendar.AccessToken))
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, errs.From(err)
 	}
 	defer resp.Body.Close()
 
@@ -112,7 +115,7 @@ func (calendar *GoogleCalendar) GetCalendarEvents(calendarId string, timeMin tim
 	// Parse the response
 	var res Response
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, errs.Upstream(err, "Google")
 	}
 
 	// Check if the response returned an error
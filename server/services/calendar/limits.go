@@ -0,0 +1,90 @@
+package calendar
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"schej.it/server/models"
+)
+
+// Calls to calendar providers are limited so that loading the availability of a large group, or many users
+// loading their calendars at once, can't open an unbounded number of connections or get the server rate limited
+
+const (
+	defaultFetchTimeout                     = 15 * time.Second
+	defaultMaxConcurrentRequests            = 64
+	defaultMaxConcurrentRequestsPerProvider = 32
+)
+
+var (
+	// The longest that GetUsersCalendarEvents waits for a user's calendars
+	fetchTimeout = defaultFetchTimeout
+
+	globalLimiter    = newLimiter(defaultMaxConcurrentRequests)
+	providerLimiters = newProviderLimiters(defaultMaxConcurrentRequestsPerProvider)
+)
+
+// Configures the limits from the environment. CALENDAR_FETCH_TIMEOUT is the most seconds to wait for a user's
+// calendars (15 by default), CALENDAR_MAX_CONCURRENT_REQUESTS is the most calls to calendar providers that can be
+// in progress at once (64 by default), and CALENDAR_MAX_CONCURRENT_REQUESTS_PER_PROVIDER is the most to each of
+// Google, Outlook, and iCloud (32 by default)
+func InitLimits() {
+	if seconds, err := strconv.Atoi(os.Getenv("CALENDAR_FETCH_TIMEOUT")); err == nil && seconds > 0 {
+		fetchTimeout = time.Duration(seconds) * time.Second
+	}
+	if limit, err := strconv.Atoi(os.Getenv("CALENDAR_MAX_CONCURRENT_REQUESTS")); err == nil && limit > 0 {
+		globalLimiter = newLimiter(limit)
+	}
+	if limit, err := strconv.Atoi(os.Getenv("CALENDAR_MAX_CONCURRENT_REQUESTS_PER_PROVIDER")); err == nil && limit > 0 {
+		providerLimiters = newProviderLimiters(limit)
+	}
+}
+
+// A counting semaphore
+type limiter chan struct{}
+
+func newLimiter(limit int) limiter {
+	return make(limiter, limit)
+}
+
+// Waits until fewer than the limit are in progress, or returns ctx's error if it's done first
+func (l limiter) acquire(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l limiter) release() {
+	<-l
+}
+
+func newProviderLimiters(limit int) map[models.CalendarType]limiter {
+	return map[models.CalendarType]limiter{
+		models.GoogleCalendarType:  newLimiter(limit),
+		models.OutlookCalendarType: newLimiter(limit),
+		models.AppleCalendarType:   newLimiter(limit),
+	}
+}
+
+// Waits for both the calendar type's limit and the global one, and returns a function that releases them.
+// The provider's limit is acquired first so that calls queued behind a slow provider don't take up global slots
+// that calls to the other providers could use
+func acquire(ctx context.Context, calendarType models.CalendarType) (func(), error) {
+	providerLimiter := providerLimiters[calendarType]
+	if err := providerLimiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	if err := globalLimiter.acquire(ctx); err != nil {
+		providerLimiter.release()
+		return nil, err
+	}
+	return func() {
+		globalLimiter.release()
+		providerLimiter.release()
+	}, nil
+}
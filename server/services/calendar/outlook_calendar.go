@@ -1,12 +1,15 @@
 package calendar
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/errs"
 	"schej.it/server/models"
 	"schej.it/server/services"
 	"schej.it/server/utils"
@@ -16,8 +19,11 @@ type OutlookCalendar struct {
 	models.OAuth2CalendarAuth
 }
 
-func (calendar *OutlookCalendar) GetCalendarList() (map[string]models.SubCalendar, error) {
-	response := services.CallApi(nil, &calendar.OAuth2CalendarAuth, "GET", "https://graph.microsoft.com/v1.0/me/calendars?$select=id,name", nil)
+func (calendar *OutlookCalendar) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
+	response, err := services.CallApi(ctx, nil, &calendar.OAuth2CalendarAuth, "GET", "https://graph.microsoft.com/v1.0/me/calendars?$select=id,name", nil)
+	if err != nil {
+		return nil, err
+	}
 	defer response.Body.Close()
 
 	responseBody := struct {
@@ -28,13 +34,13 @@ func (calendar *OutlookCalendar) GetCalendarList() (map[string]models.SubCalenda
 		Error bson.M `json:"error"`
 	}{}
 
-	err := json.NewDecoder(response.Body).Decode(&responseBody)
+	err = json.NewDecoder(response.Body).Decode(&responseBody)
 	if err != nil {
 		return nil, err
 	}
 
 	if responseBody.Error != nil {
-		return nil, fmt.Errorf("error fetching Outlook calendars: %v", responseBody.Error)
+		return nil, graphError(response.StatusCode, fmt.Errorf("error fetching Outlook calendars: %v", responseBody.Error))
 	}
 
 	calendars := make(map[string]models.SubCalendar)
@@ -48,12 +54,15 @@ func (calendar *OutlookCalendar) GetCalendarList() (map[string]models.SubCalenda
 	return calendars, nil
 }
 
-func (calendar *OutlookCalendar) GetCalendarEvents(calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+func (calendar *OutlookCalendar) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
 	url := fmt.Sprintf("https://graph.microsoft.com/v1.0/me/calendars/%s/calendarview?startdatetime=%s&enddatetime=%s&$select=id,subject,start,end,showAs",
 		calendarId,
 		timeMin.Format(time.RFC3339),
 		timeMax.Format(time.RFC3339))
-	response := services.CallApi(nil, &calendar.OAuth2CalendarAuth, "GET", url, nil)
+	response, err := services.CallApi(ctx, nil, &calendar.OAuth2CalendarAuth, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	defer response.Body.Close()
 
 	responseBody := struct {
@@ -70,13 +79,13 @@ func (calendar *OutlookCalendar) GetCalendarEvents(calendarId string, timeMin ti
 		} `json:"value"`
 		Error bson.M `json:"error"`
 	}{}
-	err := json.NewDecoder(response.Body).Decode(&responseBody)
+	err = json.NewDecoder(response.Body).Decode(&responseBody)
 	if err != nil {
 		return nil, err
 	}
 
 	if responseBody.Error != nil {
-		return nil, fmt.Errorf("error fetching Outlook events: %v", responseBody.Error)
+		return nil, graphError(response.StatusCode, fmt.Errorf("error fetching Outlook events: %v", responseBody.Error))
 	}
 
 	calendarEvents := make([]models.CalendarEvent, 0)
@@ -105,3 +114,12 @@ func (calendar *OutlookCalendar) GetCalendarEvents(calendarId string, timeMin ti
 
 	return calendarEvents, nil
 }
+
+// Returns the error that Microsoft Graph responded to a request with, which has the calendar-token-expired code
+// if the access token was rejected
+func graphError(statusCode int, err error) error {
+	if statusCode == http.StatusUnauthorized {
+		return errs.TokenExpired(err)
+	}
+	return err
+}
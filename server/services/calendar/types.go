@@ -1,30 +1,97 @@
 package calendar
 
 import (
+	"context"
+	"errors"
 	"time"
 
+	"schej.it/server/metrics"
 	"schej.it/server/models"
 )
 
+// A calendar account's calendars. Calls give up once ctx is done
 type CalendarProvider interface {
-	GetCalendarList() (map[string]models.SubCalendar, error)
-	GetCalendarEvents(calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error)
+	GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error)
+	GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error)
 }
 
 func GetCalendarProvider(calendarAccount models.CalendarAccount) CalendarProvider {
+	var provider CalendarProvider
 	switch calendarAccount.CalendarType {
 	case models.GoogleCalendarType:
-		return &GoogleCalendar{
+		provider = &GoogleCalendar{
 			OAuth2CalendarAuth: *calendarAccount.OAuth2CalendarAuth,
 		}
 	case models.OutlookCalendarType:
-		return &OutlookCalendar{
+		provider = &OutlookCalendar{
 			OAuth2CalendarAuth: *calendarAccount.OAuth2CalendarAuth,
 		}
 	case models.AppleCalendarType:
-		return &AppleCalendar{
+		provider = &AppleCalendar{
 			AppleCalendarAuth: *calendarAccount.AppleCalendarAuth,
 		}
+	default:
+		return nil
 	}
-	return nil
+	return &limitedCalendarProvider{
+		provider:     &instrumentedCalendarProvider{provider: provider, calendarType: string(calendarAccount.CalendarType)},
+		calendarType: calendarAccount.CalendarType,
+	}
+}
+
+var errProviderPanicked = errors.New("calendar provider panicked")
+
+// Records the number of calls to the provider, how many failed, and how long they took
+type instrumentedCalendarProvider struct {
+	provider     CalendarProvider
+	calendarType string
+}
+
+func (p *instrumentedCalendarProvider) GetCalendarList(ctx context.Context) (calendarList map[string]models.SubCalendar, err error) {
+	defer func(start time.Time) {
+		// Providers return errors instead of panicking, but panics are still recovered further up
+		if r := recover(); r != nil {
+			metrics.ObserveCalendarRequest(p.calendarType, "list_calendars", start, errProviderPanicked)
+			panic(r)
+		}
+		metrics.ObserveCalendarRequest(p.calendarType, "list_calendars", start, err)
+	}(time.Now())
+	return p.provider.GetCalendarList(ctx)
+}
+
+func (p *instrumentedCalendarProvider) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) (calendarEvents []models.CalendarEvent, err error) {
+	defer func(start time.Time) {
+		// Providers return errors instead of panicking, but panics are still recovered further up
+		if r := recover(); r != nil {
+			metrics.ObserveCalendarRequest(p.calendarType, "list_events", start, errProviderPanicked)
+			panic(r)
+		}
+		metrics.ObserveCalendarRequest(p.calendarType, "list_events", start, err)
+	}(time.Now())
+	return p.provider.GetCalendarEvents(ctx, calendarId, timeMin, timeMax)
+}
+
+// Waits for the concurrency limits in limits.go before each call to the provider, so the metrics above don't
+// include the time spent waiting
+type limitedCalendarProvider struct {
+	provider     CalendarProvider
+	calendarType models.CalendarType
+}
+
+func (p *limitedCalendarProvider) GetCalendarList(ctx context.Context) (map[string]models.SubCalendar, error) {
+	release, err := acquire(ctx, p.calendarType)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.provider.GetCalendarList(ctx)
+}
+
+func (p *limitedCalendarProvider) GetCalendarEvents(ctx context.Context, calendarId string, timeMin time.Time, timeMax time.Time) ([]models.CalendarEvent, error) {
+	release, err := acquire(ctx, p.calendarType)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.provider.GetCalendarEvents(ctx, calendarId, timeMin, timeMax)
 }
@@ -0,0 +1,83 @@
+/* Verifies the CAPTCHA tokens that users who aren't signed in send with requests, if CAPTCHA_PROVIDER is set */
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"schej.it/server/errs"
+	"schej.it/server/logger"
+	"schej.it/server/services/httpclient"
+)
+
+// The siteverify endpoints of the supported providers, which all take the same form and respond the same way
+var verifyUrls = map[string]string{
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+}
+
+var (
+	provider  string
+	secretKey string
+)
+
+// Configures verification from the environment. CAPTCHA_PROVIDER is turnstile, hcaptcha, or recaptcha, and
+// CAPTCHA_SECRET_KEY is the secret key from the provider. Tokens aren't verified unless both are set
+func Init() {
+	provider = strings.ToLower(os.Getenv("CAPTCHA_PROVIDER"))
+	secretKey = os.Getenv("CAPTCHA_SECRET_KEY")
+	if len(provider) == 0 {
+		return
+	}
+	if _, ok := verifyUrls[provider]; !ok {
+		logger.StdErr.Printf("Unknown CAPTCHA_PROVIDER %q, CAPTCHAs won't be verified\n", provider)
+		provider = ""
+	} else if len(secretKey) == 0 {
+		logger.StdErr.Println("CAPTCHA_SECRET_KEY isn't set, CAPTCHAs won't be verified")
+		provider = ""
+	}
+}
+
+// Returns whether tokens have to be verified
+func Enabled() bool {
+	return len(provider) > 0
+}
+
+// Checks the token with the provider. Returns an *errs.Error with the captcha-failed code if the token is missing
+// or invalid
+func Verify(ctx context.Context, token string, remoteIp string) error {
+	if len(token) == 0 {
+		return errs.New(http.StatusForbidden, errs.CaptchaFailed, "Complete the CAPTCHA and try again")
+	}
+
+	values := url.Values{"secret": {secretKey}, "response": {token}, "remoteip": {remoteIp}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyUrls[provider], strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := httpclient.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	result := struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return errs.Upstream(err, provider)
+	}
+	if !result.Success {
+		cause := fmt.Errorf("%s rejected the token: %s", provider, strings.Join(result.ErrorCodes, ", "))
+		return errs.Wrap(cause, http.StatusForbidden, errs.CaptchaFailed, "Complete the CAPTCHA and try again")
+	}
+	return nil
+}
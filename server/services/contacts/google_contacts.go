@@ -1,18 +1,24 @@
 package contacts
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 
 	"schej.it/server/errs"
-	"schej.it/server/logger"
 	"schej.it/server/models"
 	"schej.it/server/services"
-	"schej.it/server/utils"
 )
 
-func SearchContacts(user *models.User, query string) ([]models.User, *errs.GoogleAPIError) {
+type GoogleContacts struct {
+	User               *models.User
+	OAuth2CalendarAuth *models.OAuth2CalendarAuth
+}
+
+// Searches the user's Google contacts and, for Google Workspace users, their organization's directory.
+// Returns an *errs.GoogleAPIError if contacts access hasn't been granted
+func (contacts *GoogleContacts) SearchContacts(ctx context.Context, query string) ([]models.User, error) {
 	type Person struct {
 		Names []struct {
 			FamilyName string `json:"familyName"`
@@ -25,27 +31,21 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 			Value string `json:"value"`
 		} `json:"emailAddresses"`
 	}
-
-	// Set calendar auth to the user's primary google calendar account
-	calendarAccountKey := utils.GetCalendarAccountKey(user.Email, models.GoogleCalendarType)
-	calendarAccount, ok := user.CalendarAccounts[calendarAccountKey]
-	if !ok || calendarAccount.OAuth2CalendarAuth == nil {
-		return nil, &errs.GoogleAPIError{
-			Code:    400,
-			Message: "Cannot search contacts: Google account not connected. This feature requires Google account authentication.",
-			Status:  "INVALID_ARGUMENT",
-		}
-	}
-	calendarAuth := calendarAccount.OAuth2CalendarAuth
+	user := contacts.User
+	calendarAuth := contacts.OAuth2CalendarAuth
 
 	// Search contacts
-	response := services.CallApi(
+	response, err := services.CallApi(
+		ctx,
 		user,
 		calendarAuth,
 		"GET",
 		fmt.Sprintf("https://people.googleapis.com/v1/people:searchContacts?query=%s&pageSize=10&readMask=names,emailAddresses,photos", url.QueryEscape(query)),
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
 	defer response.Body.Close()
 
 	// Parse response
@@ -56,7 +56,7 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 		Error *errs.GoogleAPIError `json:"error"`
 	}{}
 	if err := json.NewDecoder(response.Body).Decode(&contactsData); err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, errs.Upstream(err, "Google")
 	}
 
 	directoryData := struct {
@@ -65,18 +65,22 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 	}{}
 	if len(query) > 0 {
 		// Search Directory
-		response = services.CallApi(
+		directoryResponse, err := services.CallApi(
+			ctx,
 			user,
 			calendarAuth,
 			"GET",
 			fmt.Sprintf("https://people.googleapis.com/v1/people:searchDirectoryPeople?query=%s&pageSize=10&readMask=names,emailAddresses,photos&sources=DIRECTORY_SOURCE_TYPE_DOMAIN_PROFILE", url.QueryEscape(query)),
 			nil,
 		)
-		defer response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		defer directoryResponse.Body.Close()
 
 		// Parse response
-		if err := json.NewDecoder(response.Body).Decode(&directoryData); err != nil {
-			logger.StdErr.Panicln(err)
+		if err := json.NewDecoder(directoryResponse.Body).Decode(&directoryData); err != nil {
+			return nil, errs.Upstream(err, "Google")
 		}
 	}
 
@@ -89,7 +93,7 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 	}
 
 	// Format list of contacts search results
-	contacts := make([]models.User, 0)
+	results := make([]models.User, 0)
 	for _, result := range contactsData.Results {
 		var userProfile models.User
 		userProfile.FirstName = result.Person.Names[0].GivenName
@@ -100,7 +104,7 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 
 		for _, email := range result.Person.EmailAddresses {
 			userProfile.Email = email.Value
-			contacts = append(contacts, userProfile)
+			results = append(results, userProfile)
 		}
 	}
 	for _, person := range directoryData.People {
@@ -113,9 +117,9 @@ func SearchContacts(user *models.User, query string) ([]models.User, *errs.Googl
 
 		for _, email := range person.EmailAddresses {
 			userProfile.Email = email.Value
-			contacts = append(contacts, userProfile)
+			results = append(results, userProfile)
 		}
 	}
 
-	return contacts, nil
+	return results, nil
 }
@@ -0,0 +1,29 @@
+package contacts
+
+import (
+	"context"
+	"schej.it/server/db"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+// Maximum number of people to return from a search of the people the user responded to events with
+const maxLocalContacts = 10
+
+type LocalContacts struct {
+	User *models.User
+}
+
+// Searches the people that responded to events the user owns or was invited to, for accounts that have no contacts
+func (contacts *LocalContacts) SearchContacts(ctx context.Context, query string) ([]models.User, error) {
+	people, err := db.GetCoRespondents(contacts.User)
+	if err != nil {
+		return nil, err
+	}
+
+	results := utils.FilterUsers(people, query)
+	if len(results) > maxLocalContacts {
+		results = results[:maxLocalContacts]
+	}
+	return results, nil
+}
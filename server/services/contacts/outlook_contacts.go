@@ -0,0 +1,92 @@
+package contacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"schej.it/server/errs"
+	"schej.it/server/models"
+	"schej.it/server/services"
+)
+
+type OutlookContacts struct {
+	User               *models.User
+	OAuth2CalendarAuth *models.OAuth2CalendarAuth
+}
+
+// Searches the people most relevant to the user according to Microsoft Graph, which includes their contacts,
+// the people they email, and their organization's directory. Returns an *errs.GoogleAPIError like Google does if
+// the People.Read scope hasn't been granted, so that the client asks for it
+func (contacts *OutlookContacts) SearchContacts(ctx context.Context, query string) ([]models.User, error) {
+	if !hasGraphScope(contacts.OAuth2CalendarAuth.Scope, "People.Read") {
+		return nil, &errs.GoogleAPIError{Code: http.StatusForbidden, Status: "PERMISSION_DENIED", Message: "People.Read hasn't been granted"}
+	}
+
+	apiUrl := "https://graph.microsoft.com/v1.0/me/people?$top=10&$select=givenName,surname,displayName,scoredEmailAddresses"
+	if len(query) > 0 {
+		apiUrl += fmt.Sprintf("&$search=%s", url.QueryEscape(fmt.Sprintf(`"%s"`, query)))
+	}
+	response, err := services.CallApi(ctx, contacts.User, contacts.OAuth2CalendarAuth, "GET", apiUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return parseGraphPeople(response.Body)
+}
+
+// Returns whether the space separated scopes that Microsoft granted include the given Graph scope, which they
+// may list with or without the Graph url in front of it
+func hasGraphScope(scopes string, scope string) bool {
+	for _, granted := range strings.Fields(scopes) {
+		if strings.EqualFold(strings.TrimPrefix(granted, "https://graph.microsoft.com/"), scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a Microsoft Graph list of people into one user per email address
+func parseGraphPeople(body io.Reader) ([]models.User, error) {
+	responseBody := struct {
+		Value []struct {
+			GivenName            string `json:"givenName"`
+			Surname              string `json:"surname"`
+			DisplayName          string `json:"displayName"`
+			ScoredEmailAddresses []struct {
+				Address string `json:"address"`
+			} `json:"scoredEmailAddresses"`
+		} `json:"value"`
+		Error bson.M `json:"error"`
+	}{}
+	if err := json.NewDecoder(body).Decode(&responseBody); err != nil {
+		return nil, err
+	}
+
+	if responseBody.Error != nil {
+		return nil, fmt.Errorf("error searching Outlook people: %v", responseBody.Error)
+	}
+
+	results := make([]models.User, 0)
+	for _, person := range responseBody.Value {
+		var userProfile models.User
+		userProfile.FirstName = person.GivenName
+		userProfile.LastName = person.Surname
+		if len(userProfile.FirstName) == 0 && len(userProfile.LastName) == 0 {
+			userProfile.FirstName = person.DisplayName
+		}
+
+		for _, email := range person.ScoredEmailAddresses {
+			userProfile.Email = email.Address
+			results = append(results, userProfile)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,68 @@
+package contacts
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"schej.it/server/errs"
+	"schej.it/server/models"
+)
+
+func TestSearchContactsWithoutPeopleRead(t *testing.T) {
+	contacts := &OutlookContacts{
+		User:               &models.User{},
+		OAuth2CalendarAuth: &models.OAuth2CalendarAuth{Scope: "openid profile email https://graph.microsoft.com/User.Read https://graph.microsoft.com/Calendars.Read"},
+	}
+	_, err := contacts.SearchContacts(context.Background(), "ada")
+	if apiError, ok := err.(*errs.GoogleAPIError); !ok || apiError.Code != http.StatusForbidden {
+		t.Errorf("SearchContacts() error = %v, want a 403 so the client asks for People.Read", err)
+	}
+}
+
+func TestHasGraphScope(t *testing.T) {
+	tests := []struct {
+		scopes   string
+		expected bool
+	}{
+		{"User.Read People.Read", true},
+		{"openid https://graph.microsoft.com/People.Read", true},
+		{"User.Read https://graph.microsoft.com/People.ReadWrite", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if hasGraphScope(test.scopes, "People.Read") != test.expected {
+			t.Errorf("hasGraphScope(%q) = %v, want %v", test.scopes, !test.expected, test.expected)
+		}
+	}
+}
+
+func TestParseGraphPeople(t *testing.T) {
+	people, err := parseGraphPeople(strings.NewReader(`{"value": [
+		{"givenName": "Ada", "surname": "Lovelace", "scoredEmailAddresses": [{"address": "ada@example.com"}, {"address": "ada@example.org"}]},
+		{"displayName": "Team inbox", "scoredEmailAddresses": [{"address": "team@example.com"}]},
+		{"givenName": "No", "surname": "Email", "scoredEmailAddresses": []}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct{ firstName, email string }{
+		{"Ada", "ada@example.com"},
+		{"Ada", "ada@example.org"},
+		{"Team inbox", "team@example.com"},
+	}
+	if len(people) != len(expected) {
+		t.Fatalf("parseGraphPeople() returned %d people, want %d", len(people), len(expected))
+	}
+	for i, person := range expected {
+		if people[i].FirstName != person.firstName || people[i].Email != person.email {
+			t.Errorf("person %d = %s <%s>, want %s <%s>", i, people[i].FirstName, people[i].Email, person.firstName, person.email)
+		}
+	}
+
+	if _, err := parseGraphPeople(strings.NewReader(`{"error": {"code": "ErrorAccessDenied"}}`)); err == nil {
+		t.Error("parseGraphPeople() didn't return the Graph error")
+	}
+}
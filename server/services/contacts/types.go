@@ -0,0 +1,35 @@
+package contacts
+
+import (
+	"context"
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+type ContactsProvider interface {
+	// Returns at most about 10 people matching the query to suggest as attendees
+	SearchContacts(ctx context.Context, query string) ([]models.User, error)
+}
+
+// Returns the contacts of the Google or Outlook account with the same email the user signed in with, or the people
+// that responded to the user's events if there isn't one
+func GetContactsProvider(user *models.User) ContactsProvider {
+	for _, calendarType := range []models.CalendarType{models.GoogleCalendarType, models.OutlookCalendarType} {
+		calendarAccount, ok := user.CalendarAccounts[utils.GetCalendarAccountKey(user.Email, calendarType)]
+		if !ok || calendarAccount.OAuth2CalendarAuth == nil {
+			continue
+		}
+
+		if calendarType == models.GoogleCalendarType {
+			return &GoogleContacts{
+				User:               user,
+				OAuth2CalendarAuth: calendarAccount.OAuth2CalendarAuth,
+			}
+		}
+		return &OutlookContacts{
+			User:               user,
+			OAuth2CalendarAuth: calendarAccount.OAuth2CalendarAuth,
+		}
+	}
+	return &LocalContacts{User: user}
+}
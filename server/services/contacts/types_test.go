@@ -0,0 +1,54 @@
+package contacts
+
+import (
+	"testing"
+
+	"schej.it/server/models"
+	"schej.it/server/utils"
+)
+
+func TestGetContactsProvider(t *testing.T) {
+	auth := &models.OAuth2CalendarAuth{AccessToken: "ada"}
+	otherAuth := &models.OAuth2CalendarAuth{AccessToken: "work"}
+	outlookKey := utils.GetCalendarAccountKey("ada@example.com", models.OutlookCalendarType)
+	googleKey := utils.GetCalendarAccountKey("ada@example.com", models.GoogleCalendarType)
+	otherGoogleKey := utils.GetCalendarAccountKey("ada@work.example.com", models.GoogleCalendarType)
+
+	tests := []struct {
+		name     string
+		accounts map[string]models.CalendarAccount
+		primary  string
+		expected string
+	}{
+		{"google", map[string]models.CalendarAccount{googleKey: {CalendarType: models.GoogleCalendarType, OAuth2CalendarAuth: auth}}, googleKey, "google"},
+		{"outlook", map[string]models.CalendarAccount{outlookKey: {CalendarType: models.OutlookCalendarType, OAuth2CalendarAuth: auth}}, outlookKey, "outlook"},
+		{"sign in account over primary account", map[string]models.CalendarAccount{
+			googleKey:      {CalendarType: models.GoogleCalendarType, OAuth2CalendarAuth: auth},
+			otherGoogleKey: {CalendarType: models.GoogleCalendarType, OAuth2CalendarAuth: otherAuth},
+		}, otherGoogleKey, "google"},
+		{"only another account", map[string]models.CalendarAccount{otherGoogleKey: {CalendarType: models.GoogleCalendarType, OAuth2CalendarAuth: otherAuth}}, otherGoogleKey, "local"},
+		{"no accounts", nil, "", "local"},
+	}
+	for _, test := range tests {
+		user := &models.User{Email: "ada@example.com", CalendarAccounts: test.accounts}
+		if len(test.primary) > 0 {
+			user.PrimaryAccountKey = &test.primary
+		}
+
+		var provider string
+		switch p := GetContactsProvider(user).(type) {
+		case *GoogleContacts:
+			provider = "google"
+			if p.OAuth2CalendarAuth != test.accounts[googleKey].OAuth2CalendarAuth {
+				t.Errorf("%s: searched a Google account other than the one signed in with", test.name)
+			}
+		case *OutlookContacts:
+			provider = "outlook"
+		case *LocalContacts:
+			provider = "local"
+		}
+		if provider != test.expected {
+			t.Errorf("%s: GetContactsProvider() = %s, want %s", test.name, provider, test.expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
 	"time"
@@ -14,7 +15,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"google.golang.org/api/option"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"schej.it/server/errs"
 	"schej.it/server/logger"
+	"schej.it/server/metrics"
 	"schej.it/server/services/listmonk"
 	"schej.it/server/utils"
 )
@@ -51,11 +54,13 @@ func InitTasks() func() {
 	}
 }
 
-func CreateEmailTask(email string, ownerName string, eventName string, eventId string) []string {
+// Schedules the reminder emails for the given remindee and returns the IDs of their tasks. Returns an *errs.Error
+// if the listmonk template IDs aren't configured or the tasks can't be created
+func CreateEmailTask(ctx context.Context, email string, ownerName string, eventName string, eventId string) ([]string, error) {
 	// Check if TasksClient is initialized
 	if TasksClient == nil {
 		logger.StdOut.Println("Google Cloud Tasks not initialized, skipping email task creation")
-		return []string{}
+		return []string{}, nil
 	}
 
 	// Get listmonk url env vars
@@ -65,25 +70,25 @@ func CreateEmailTask(email string, ownerName string, eventName string, eventId s
 	basicAuthString := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", listmonkUsername, listmonkPassword)))
 
 	// Find if subscriber exists in listmonk
-	subscriberExists, _ := listmonk.DoesUserExist(email)
+	subscriberExists, _ := listmonk.DoesUserExist(ctx, email)
 
 	// If subscriber doesn't exist, add subscriber to listmonk
 	if !subscriberExists {
-		listmonk.AddUserToListmonk(email, "", "", "", nil, false)
+		listmonk.AddUserToListmonk(ctx, email, "", "", "", nil, false)
 	}
 
 	// Get email template ids
-	initialEmailReminderId, err := strconv.Atoi(os.Getenv("LISTMONK_INITIAL_EMAIL_REMINDER_ID"))
+	initialEmailReminderId, err := getTemplateId("LISTMONK_INITIAL_EMAIL_REMINDER_ID")
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
-	secondEmailReminderId, err := strconv.Atoi(os.Getenv("LISTMONK_SECOND_EMAIL_REMINDER_ID"))
+	secondEmailReminderId, err := getTemplateId("LISTMONK_SECOND_EMAIL_REMINDER_ID")
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
-	finalEmailReminderId, err := strconv.Atoi(os.Getenv("LISTMONK_FINAL_EMAIL_REMINDER_ID"))
+	finalEmailReminderId, err := getTemplateId("LISTMONK_FINAL_EMAIL_REMINDER_ID")
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 
 	// Create map of emails to iterate through
@@ -113,11 +118,11 @@ func CreateEmailTask(email string, ownerName string, eventName string, eventId s
 			"content_type": "html",
 		})
 		if err != nil {
-			logger.StdErr.Panicln(err)
+			return nil, errs.From(err)
 		}
 
 		// Create task
-		task, err := TasksClient.CreateTask(context.Background(), &cloudtaskspb.CreateTaskRequest{
+		task, err := TasksClient.CreateTask(ctx, &cloudtaskspb.CreateTaskRequest{
 			Parent: "projects/schej-it/locations/us-central1/queues/SendReminderEmail",
 			Task: &cloudtaskspb.Task{
 				ScheduleTime: scheduleTime,
@@ -134,25 +139,35 @@ func CreateEmailTask(email string, ownerName string, eventName string, eventId s
 				},
 			},
 		})
+		metrics.EmailsSent.WithLabelValues("cloud_tasks", metrics.Result(err)).Inc()
 
 		if err != nil {
-			logger.StdErr.Panicln(err)
+			return nil, errs.Upstream(err, "Google Cloud Tasks")
 		}
 
 		taskIds = append(taskIds, task.Name)
 	}
 
-	return taskIds
+	return taskIds, nil
 }
 
-func DeleteEmailTask(taskId string) {
+// Returns the listmonk template ID in the given environment variable
+func getTemplateId(envVar string) (int, error) {
+	templateId, err := strconv.Atoi(os.Getenv(envVar))
+	if err != nil {
+		return 0, errs.Wrap(fmt.Errorf("%s: %w", envVar, err), http.StatusInternalServerError, errs.InternalError, "Reminder emails aren't configured correctly")
+	}
+	return templateId, nil
+}
+
+func DeleteEmailTask(ctx context.Context, taskId string) {
 	// Check if TasksClient is initialized
 	if TasksClient == nil {
 		logger.StdOut.Println("Google Cloud Tasks not initialized, skipping email task deletion")
 		return
 	}
 
-	err := TasksClient.DeleteTask(context.Background(), &cloudtaskspb.DeleteTaskRequest{
+	err := TasksClient.DeleteTask(ctx, &cloudtaskspb.DeleteTaskRequest{
 		Name: taskId,
 	})
 
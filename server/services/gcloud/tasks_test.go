@@ -1,6 +1,7 @@
 package gcloud
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -28,7 +29,9 @@ func TestCreateEmailTask(t *testing.T) {
 	}
 
 	InitTasks()
-	CreateEmailTask("schej.team@gmail.com", "Jonathan", "casablanca", "65e636bb760d3ea2e113e161")
+	if _, err := CreateEmailTask(context.Background(), "schej.team@gmail.com", "Jonathan", "casablanca", "65e636bb760d3ea2e113e161"); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestDeleteEmailTask(t *testing.T) {
@@ -51,18 +54,21 @@ func TestDeleteEmailTask(t *testing.T) {
 
 	// Should fail
 	fmt.Println("Delete email task that doesn't exist...")
-	DeleteEmailTask("id_that_doesn't_exist")
+	DeleteEmailTask(context.Background(), "id_that_doesn't_exist")
 	fmt.Println("Should have thrown an error ^")
 
 	// Should succeed
 	fmt.Println("Creating email task...")
-	taskIds := CreateEmailTask("schej.team@gmail.com", "Jonathan", "casablanca", "65e636bb760d3ea2e113e161")
+	taskIds, err := CreateEmailTask(context.Background(), "schej.team@gmail.com", "Jonathan", "casablanca", "65e636bb760d3ea2e113e161")
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println("Email task created")
 
 	time.Sleep(10 * time.Second)
 	for _, taskId := range taskIds {
 		fmt.Println("Deleting email task with taskId: ", taskId)
-		DeleteEmailTask(taskId)
+		DeleteEmailTask(context.Background(), taskId)
 		fmt.Println("Deleted email task with taskId: ", taskId)
 	}
 
@@ -0,0 +1,146 @@
+/* The HTTP client used to call other services, i.e. calendar providers and listmonk */
+package httpclient
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 2
+
+	// Time to wait before the first retry, which doubles with every retry up to maxRetryDelay
+	baseRetryDelay = 250 * time.Millisecond
+	maxRetryDelay  = 4 * time.Second
+)
+
+// Shared by all outbound calls so that connections are reused. Requests should be made with the context of the
+// request being handled, so that they're canceled if the client disconnects
+var Client = New(defaultTimeout, defaultMaxRetries)
+
+// Configures Client from the environment. HTTP_CLIENT_TIMEOUT is the most seconds a call can take including
+// retries (30 by default), and HTTP_CLIENT_MAX_RETRIES is how many times failed idempotent requests are
+// retried (2 by default)
+func Init() {
+	timeout := defaultTimeout
+	if seconds, err := strconv.Atoi(os.Getenv("HTTP_CLIENT_TIMEOUT")); err == nil && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	maxRetries := defaultMaxRetries
+	if retries, err := strconv.Atoi(os.Getenv("HTTP_CLIENT_MAX_RETRIES")); err == nil && retries >= 0 {
+		maxRetries = retries
+	}
+	Client = New(timeout, maxRetries)
+}
+
+// Returns a client whose calls time out after `timeout`, and that retries idempotent requests up to `maxRetries`
+// times when they fail with a network error or a 429, 502, 503, or 504
+func New(timeout time.Duration, maxRetries int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 5 * time.Second
+	transport.ResponseHeaderTimeout = 15 * time.Second
+	transport.MaxIdleConnsPerHost = 20
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &retryTransport{base: transport, maxRetries: maxRetries},
+	}
+}
+
+// Methods that can be sent again without changing the result. PROPFIND and REPORT are the CalDAV queries
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	"PROPFIND":         true,
+	"REPORT":           true,
+}
+
+var retryStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.maxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		// The body has to be sent again, so it can only be retried if it can be read again
+		retry := req
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			retry = req.Clone(req.Context())
+			retry.Body = body
+		}
+
+		delay := retryDelay(attempt, resp)
+		if resp != nil {
+			// Drain the body so that the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		req = retry
+	}
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !idempotentMethods[req.Method] || req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return retryStatusCodes[resp.StatusCode]
+}
+
+// Returns how long to wait before retrying, which is the Retry-After the service responded with if it isn't
+// too long, otherwise exponential backoff with jitter
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if delay := time.Duration(seconds) * time.Second; delay <= maxRetryDelay {
+				return delay
+			}
+		}
+	}
+
+	delay := baseRetryDelay << attempt
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	// Wait between half and all of the delay so that clients that failed together don't retry together
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -2,19 +2,38 @@ package listmonk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/services/httpclient"
 )
 
+var listmonkLogger = logger.With("subsystem", "listmonk")
+
+// Template used when no LISTMONK_GROUP_INVITE_EMAIL_ID is set
+const defaultGroupInviteEmailId = 9
+
+// Returns the template of the email inviting someone to an availability group, which is
+// LISTMONK_GROUP_INVITE_EMAIL_ID if it's set
+func GroupInviteEmailId() int {
+	if templateId, err := strconv.Atoi(os.Getenv("LISTMONK_GROUP_INVITE_EMAIL_ID")); err == nil {
+		return templateId
+	}
+	return defaultGroupInviteEmailId
+}
+
 // Adds the given user to the Listmonk contact list
 // If subscriberId is not nil, then UPDATE the user instead of adding user
-func AddUserToListmonk(email string, firstName string, lastName string, picture string, subscriberId *int, sendMarketingEmails bool) {
+func AddUserToListmonk(ctx context.Context, email string, firstName string, lastName string, picture string, subscriberId *int, sendMarketingEmails bool) {
 	if os.Getenv("LISTMONK_ENABLED") == "false" {
 		return
 	}
@@ -26,7 +45,7 @@ func AddUserToListmonk(email string, firstName string, lastName string, picture
 
 	listId, err := strconv.Atoi(listIdString)
 	if err != nil {
-		logger.StdErr.Println(err)
+		listmonkLogger.Error("invalid LISTMONK_LIST_ID", "error", err)
 		return
 	}
 
@@ -51,17 +70,17 @@ func AddUserToListmonk(email string, firstName string, lastName string, picture
 	var req *http.Request
 	if subscriberId != nil {
 		// Existing subscriber
-		req, _ = http.NewRequest("PUT", fmt.Sprintf("%s/api/subscribers/%d", url, *subscriberId), bodyBuffer)
+		req, _ = http.NewRequestWithContext(ctx, "PUT", fmt.Sprintf("%s/api/subscribers/%d", url, *subscriberId), bodyBuffer)
 	} else {
 		// New subscriber
-		req, _ = http.NewRequest("POST", fmt.Sprintf("%s/api/subscribers", url), bodyBuffer)
+		req, _ = http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/subscribers", url), bodyBuffer)
 	}
 	req.SetBasicAuth(username, password)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		logger.StdErr.Println(err)
+		listmonkLogger.Error("couldn't save subscriber", "error", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -69,7 +88,7 @@ func AddUserToListmonk(email string, firstName string, lastName string, picture
 
 // Check if the user is already in listmonk
 // Returns a bool representing whether the subscriber exists and the id of the subscriber if it does exist
-func DoesUserExist(email string) (bool, *int) {
+func DoesUserExist(ctx context.Context, email string) (bool, *int) {
 	if os.Getenv("LISTMONK_ENABLED") == "false" {
 		return false, nil
 	}
@@ -78,13 +97,15 @@ func DoesUserExist(email string) (bool, *int) {
 	username := os.Getenv("LISTMONK_USERNAME")
 	password := os.Getenv("LISTMONK_PASSWORD")
 
-	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/subscribers?query=subscribers.email='%s'", url, email), nil)
+	// Escape the email since it's put into an SQL expression
+	query := fmt.Sprintf("subscribers.email='%s'", strings.ReplaceAll(email, "'", "''"))
+	req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/subscribers?query=%s", url, neturl.QueryEscape(query)), nil)
 	req.SetBasicAuth(username, password)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
-		logger.StdErr.Println(err)
+		listmonkLogger.Error("couldn't get subscriber", "error", err)
 		return false, nil
 	}
 	defer resp.Body.Close()
@@ -98,7 +119,7 @@ func DoesUserExist(email string) (bool, *int) {
 	}
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
-		logger.StdErr.Println(err)
+		listmonkLogger.Error("couldn't decode subscriber", "error", err)
 		return false, nil
 	}
 
@@ -109,8 +130,34 @@ func DoesUserExist(email string) (bool, *int) {
 	}
 }
 
+// Deletes the subscriber with the given email from Listmonk, if they exist
+func DeleteSubscriber(ctx context.Context, email string) {
+	if os.Getenv("LISTMONK_ENABLED") == "false" {
+		return
+	}
+
+	exists, subscriberId := DoesUserExist(ctx, email)
+	if !exists {
+		return
+	}
+
+	url := os.Getenv("LISTMONK_URL")
+	username := os.Getenv("LISTMONK_USERNAME")
+	password := os.Getenv("LISTMONK_PASSWORD")
+
+	req, _ := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/api/subscribers/%d", url, *subscriberId), nil)
+	req.SetBasicAuth(username, password)
+
+	resp, err := httpclient.Client.Do(req)
+	if err != nil {
+		listmonkLogger.Error("couldn't delete subscriber", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+}
+
 // Send a transactional email using the specified template and data
-func SendEmail(email string, templateId int, data bson.M) {
+func SendEmail(ctx context.Context, email string, templateId int, data bson.M) {
 	if os.Getenv("LISTMONK_ENABLED") == "false" {
 		return
 	}
@@ -128,32 +175,39 @@ func SendEmail(email string, templateId int, data bson.M) {
 		"content_type":     "html",
 	})
 	if err != nil {
-		logger.StdErr.Println(err)
+		listmonkLogger.Error("couldn't encode email", "templateId", templateId, "error", err)
 		return
 	}
 
 	// Construct request
-	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/tx", listmonkUrl), bytes.NewBuffer(body))
+	req, _ := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/tx", listmonkUrl), bytes.NewBuffer(body))
 	req.SetBasicAuth(listmonkUsername, listmonkPassword)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Execute request
-	response, err := http.DefaultClient.Do(req)
+	response, err := httpclient.Client.Do(req)
 	if err != nil {
-		logger.StdErr.Println(err)
+		metrics.EmailsSent.WithLabelValues("listmonk", metrics.Result(err)).Inc()
+		listmonkLogger.Error("couldn't send email", "templateId", templateId, "error", err)
+		return
 	}
 	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		err = fmt.Errorf("listmonk responded with %s", response.Status)
+		listmonkLogger.Error("couldn't send email", "templateId", templateId, "error", err)
+	}
+	metrics.EmailsSent.WithLabelValues("listmonk", metrics.Result(err)).Inc()
 }
 
 // Send a transactional email using the specified template and data. Adds subscriber if they don't exist
-func SendEmailAddSubscriberIfNotExist(email string, templateId int, data bson.M, sendMarketingEmails bool) {
+func SendEmailAddSubscriberIfNotExist(ctx context.Context, email string, templateId int, data bson.M, sendMarketingEmails bool) {
 	if os.Getenv("LISTMONK_ENABLED") == "false" {
 		return
 	}
 
-	if exists, _ := DoesUserExist(email); !exists {
-		AddUserToListmonk(email, "", "", "", nil, sendMarketingEmails)
+	if exists, _ := DoesUserExist(ctx, email); !exists {
+		AddUserToListmonk(ctx, email, "", "", "", nil, sendMarketingEmails)
 	}
 
-	SendEmail(email, templateId, data)
+	SendEmail(ctx, email, templateId, data)
 }
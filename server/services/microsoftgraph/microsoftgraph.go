@@ -1,9 +1,12 @@
 package microsoftgraph
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
 
-	"schej.it/server/logger"
+	"schej.it/server/errs"
 	"schej.it/server/models"
 	"schej.it/server/services"
 )
@@ -14,14 +17,18 @@ type UserInfo struct {
 	Email     string `json:"mail"`
 }
 
-func GetUserInfo(user *models.User, calendarAuth *models.OAuth2CalendarAuth) UserInfo {
-	response := services.CallApi(
+func GetUserInfo(ctx context.Context, user *models.User, calendarAuth *models.OAuth2CalendarAuth) (UserInfo, error) {
+	response, err := services.CallApi(
+		ctx,
 		user,
 		calendarAuth,
 		"GET",
 		"https://graph.microsoft.com/v1.0/me?$select=givenName,surname,mail",
 		nil,
 	)
+	if err != nil {
+		return UserInfo{}, err
+	}
 	defer response.Body.Close()
 
 	userResponse := struct {
@@ -31,12 +38,15 @@ func GetUserInfo(user *models.User, calendarAuth *models.OAuth2CalendarAuth) Use
 	}{}
 
 	if err := json.NewDecoder(response.Body).Decode(&userResponse); err != nil {
-		logger.StdErr.Panicln(err)
+		return UserInfo{}, errs.Upstream(err, "Microsoft")
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		return UserInfo{}, errs.TokenExpired(fmt.Errorf("Microsoft Graph responded with %s", response.Status))
 	}
 
 	return UserInfo{
 		FirstName: userResponse.GivenName,
 		LastName:  userResponse.Surname,
 		Email:     userResponse.Mail,
-	}
+	}, nil
 }
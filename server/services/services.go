@@ -2,21 +2,23 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"schej.it/server/logger"
+	"schej.it/server/errs"
 	"schej.it/server/models"
 	"schej.it/server/services/auth"
+	"schej.it/server/services/httpclient"
 )
 
-// Calls the given url with the given method using the user's OAuth 2 access token.
-// Set user to nil if refreshing the token is not necessary
-func CallApi(user *models.User, calendarAuth *models.OAuth2CalendarAuth, method string, url string, body *bson.M) *http.Response {
+// Calls the given url with the given method using the user's OAuth 2 access token, until ctx is done.
+// Set user to nil if refreshing the token is not necessary. Returns an *errs.Error if the url can't be reached
+func CallApi(ctx context.Context, user *models.User, calendarAuth *models.OAuth2CalendarAuth, method string, url string, body *bson.M) (*http.Response, error) {
 	if user != nil {
-		auth.RefreshUserTokenIfNecessary(user, nil)
+		auth.RefreshUserTokenIfNecessary(ctx, user, nil)
 	}
 
 	// Format body as a buffer if not nil
@@ -31,17 +33,17 @@ func CallApi(user *models.User, calendarAuth *models.OAuth2CalendarAuth, method
 	// Construct request
 	var req *http.Request
 	if bodyBuffer != nil {
-		req, _ = http.NewRequest(method, url, bodyBuffer)
+		req, _ = http.NewRequestWithContext(ctx, method, url, bodyBuffer)
 	} else {
-		req, _ = http.NewRequest(method, url, nil)
+		req, _ = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", calendarAuth.AccessToken))
 
 	// Execute request
-	response, err := http.DefaultClient.Do(req)
+	response, err := httpclient.Client.Do(req)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, errs.From(err)
 	}
 
-	return response
+	return response, nil
 }
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"os"
+	"strings"
+
+	"schej.it/server/models"
+)
+
+// Returns whether the user can use the admin console, either because of their role or because their email is
+// in the comma separated ADMIN_EMAILS, which is how the first admin of an instance is set up
+func IsAdmin(user *models.User) bool {
+	if user.Role == models.AdminUser {
+		return true
+	}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		email = strings.TrimSpace(email)
+		if len(email) > 0 && strings.EqualFold(email, user.Email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+
+	"schej.it/server/models"
+)
+
+func TestIsAdmin(t *testing.T) {
+	t.Setenv("ADMIN_EMAILS", "owner@example.com, Second@Example.com")
+
+	tests := []struct {
+		user     models.User
+		expected bool
+	}{
+		{models.User{Email: "owner@example.com"}, true},
+		{models.User{Email: "second@example.com"}, true},
+		{models.User{Email: "user@example.com"}, false},
+		{models.User{Email: "user@example.com", Role: models.AdminUser}, true},
+		{models.User{Email: ""}, false},
+	}
+	for _, test := range tests {
+		if result := IsAdmin(&test.user); result != test.expected {
+			t.Errorf("IsAdmin(%q, %q) = %v, want %v", test.user.Email, test.user.Role, result, test.expected)
+		}
+	}
+}
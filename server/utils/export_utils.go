@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// Default number of minutes between time slots, used when an event doesn't have a time increment
+const defaultTimeIncrement = 15
+
+// Returns the start times of every time slot that users can mark their availability for, in order
+func GetEventTimeSlots(event *models.Event) []primitive.DateTime {
+	slots := make([]primitive.DateTime, 0)
+
+	if Coalesce(event.HasSpecificTimes) {
+		slots = append(slots, event.Times...)
+	} else {
+		for _, date := range event.Dates {
+			if Coalesce(event.DaysOnly) {
+				slots = append(slots, date)
+				continue
+			}
+
+			increment := time.Duration(defaultTimeIncrement) * time.Minute
+			if event.TimeIncrement != nil && *event.TimeIncrement > 0 {
+				increment = time.Duration(*event.TimeIncrement) * time.Minute
+			}
+			end := date.Time().Add(time.Duration(float64(Coalesce(event.Duration)) * float64(time.Hour)))
+			for t := date.Time(); t.Before(end); t = t.Add(increment) {
+				slots = append(slots, primitive.NewDateTimeFromTime(t))
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots
+}
+
+// Returns a human readable version of the answer to a sign up question
+func FormatSignUpAnswer(answer models.SignUpAnswer) string {
+	if answer.Text != nil {
+		return *answer.Text
+	}
+	if answer.Checked != nil {
+		if *answer.Checked {
+			return "Yes"
+		}
+		return "No"
+	}
+	return strings.Join(answer.Selected, ", ")
+}
+
+// Writes the rows to `w` as a CSV file
+func WriteCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	for _, row := range rows {
+		if err := writer.Write(Map(row, escapeCSVFormula)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Prevents spreadsheet programs from evaluating user provided values as formulas
+func escapeCSVFormula(value string) string {
+	if len(value) > 0 && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
+
+var xlsxInvalidSheetNameChars = strings.NewReplacer("[", "", "]", "", ":", "", "*", "", "?", "", "/", "", "\\", "")
+
+// Writes the rows to `w` as an XLSX workbook with a single sheet, storing every cell as a string
+func WriteXLSX(w io.Writer, sheetName string, rows [][]string) error {
+	var sheet strings.Builder
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			fmt.Fprintf(&sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumnName(j), i+1)
+			xml.EscapeText(&sheet, []byte(value))
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	// Sheet names can't contain certain characters and are limited to 31 characters
+	sheetName = strings.TrimSpace(xlsxInvalidSheetNameChars.Replace(sheetName))
+	if runes := []rune(sheetName); len(runes) > 31 {
+		sheetName = string(runes[:31])
+	}
+	if len(sheetName) == 0 {
+		sheetName = "Sheet1"
+	}
+	var escapedSheetName strings.Builder
+	xml.EscapeText(&escapedSheetName, []byte(sheetName))
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + escapedSheetName.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(writer, file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Writes `data` to `w` as a ZIP archive with a JSON file for each of its top level fields
+func WriteJSONZip(w io.Writer, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	archive := zip.NewWriter(w)
+	for _, name := range names {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, fields[name], "", "  "); err != nil {
+			return err
+		}
+		writer, err := archive.Create(name + ".json")
+		if err != nil {
+			return err
+		}
+		if _, err := indented.WriteTo(writer); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Returns the spreadsheet name of the column at the given index (i.e. 0 => A, 26 => AA)
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+func TestGetEventTimeSlots(t *testing.T) {
+	start := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	duration := float32(1)
+	increment := 30
+	event := &models.Event{
+		Dates:         []primitive.DateTime{primitive.NewDateTimeFromTime(start.AddDate(0, 0, 1)), primitive.NewDateTimeFromTime(start)},
+		Duration:      &duration,
+		TimeIncrement: &increment,
+	}
+
+	slots := GetEventTimeSlots(event)
+	expected := []time.Time{start, start.Add(30 * time.Minute), start.AddDate(0, 0, 1), start.AddDate(0, 0, 1).Add(30 * time.Minute)}
+	if len(slots) != len(expected) {
+		t.Fatalf("GetEventTimeSlots() returned %d slots, want %d", len(slots), len(expected))
+	}
+	for i := range expected {
+		if !slots[i].Time().Equal(expected[i]) {
+			t.Errorf("slot %d = %v, want %v", i, slots[i].Time().UTC(), expected[i])
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, [][]string{{"Name", "Phone"}, {"=HYPERLINK(\"x\")", "555, 1234"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Name,Phone\n\"'=HYPERLINK(\"\"x\"\")\",\"555, 1234\"\n"
+	if buf.String() != expected {
+		t.Errorf("WriteCSV() = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, "Team [lunch]", [][]string{{"Name"}, {"Tom & Jerry"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+	}
+
+	if !strings.Contains(files["xl/workbook.xml"], `name="Team lunch"`) {
+		t.Errorf("workbook.xml doesn't contain the sanitized sheet name: %s", files["xl/workbook.xml"])
+	}
+	if !strings.Contains(files["xl/worksheets/sheet1.xml"], `<c r="A2" t="inlineStr"><is><t xml:space="preserve">Tom &amp; Jerry</t></is></c>`) {
+		t.Errorf("sheet1.xml doesn't contain the escaped cell: %s", files["xl/worksheets/sheet1.xml"])
+	}
+	if _, ok := files["[Content_Types].xml"]; !ok {
+		t.Error("missing [Content_Types].xml")
+	}
+}
+
+func TestWriteJSONZip(t *testing.T) {
+	var buf bytes.Buffer
+	data := struct {
+		User   map[string]string `json:"user"`
+		Events []int             `json:"events"`
+	}{User: map[string]string{"email": "a@example.com"}, Events: []int{1, 2}}
+	if err := WriteJSONZip(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if strings.Join(names, ",") != "events.json,user.json" {
+		t.Errorf("WriteJSONZip() wrote files %v, want [events.json user.json]", names)
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Returns the values of the given fields that differ between the two documents, as they're stored in the
+// database, before and after the change. A field that's missing from a document has a nil value
+func DiffFields(before interface{}, after interface{}, fields []string) (map[string]interface{}, map[string]interface{}, error) {
+	beforeFields, err := toStoredFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := toStoredFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changedBefore := make(map[string]interface{})
+	changedAfter := make(map[string]interface{})
+	for _, field := range fields {
+		if !reflect.DeepEqual(beforeFields[field], afterFields[field]) {
+			changedBefore[field] = beforeFields[field]
+			changedAfter[field] = afterFields[field]
+		}
+	}
+	return changedBefore, changedAfter, nil
+}
+
+func toStoredFields(document interface{}) (bson.M, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
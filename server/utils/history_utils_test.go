@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+
+	"schej.it/server/models"
+)
+
+func TestDiffFields(t *testing.T) {
+	location := "Room 101"
+	before := models.Event{Name: "Standup", Location: &location, Type: models.SPECIFIC_DATES}
+	after := models.Event{Name: "Daily standup", Type: models.SPECIFIC_DATES}
+
+	changedBefore, changedAfter, err := DiffFields(&before, &after, []string{"name", "location", "type", "description"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changedBefore) != 2 || changedBefore["name"] != "Standup" || changedBefore["location"] != location {
+		t.Errorf("before = %v, want the old name and location", changedBefore)
+	}
+	if len(changedAfter) != 2 || changedAfter["name"] != "Daily standup" || changedAfter["location"] != nil {
+		t.Errorf("after = %v, want the new name and no location", changedAfter)
+	}
+}
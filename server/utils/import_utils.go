@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"schej.it/server/models"
+)
+
+// Maximum number of participants that can be imported at once
+const MaxImportRows = 1000
+
+// A participant parsed from an imported CSV file
+type ImportRow struct {
+	Line  int                    `json:"line"`
+	Name  string                 `json:"name"`
+	Email string                 `json:"email"`
+	Role  models.ParticipantRole `json:"role"`
+}
+
+// A line of an imported CSV file that couldn't be parsed
+type ImportError struct {
+	Line  int    `json:"line"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// Parses a CSV file of participants. The file can either have a header row with "name", "email", and "role"
+// columns in any order, or no header, in which case the columns are name, email, and role (or just email
+// if there is a single column). Rows with an email that appears earlier in the file are skipped
+func ParseImportCSV(r io.Reader) ([]ImportRow, []ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	// Keep track of the line each record starts on, since blank lines are skipped
+	records := make([][]string, 0)
+	lines := make([]int, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	// Determine which column holds which field
+	nameCol, emailCol, roleCol := 0, 1, 2
+	startRecord := 0
+	if len(records) > 0 {
+		header := Map(records[0], func(s string) string { return strings.ToLower(strings.TrimSpace(s)) })
+		if Contains(header, "email") {
+			nameCol, emailCol, roleCol = Find(header, isString("name")), Find(header, isString("email")), Find(header, isString("role"))
+			startRecord = 1
+		} else if len(records[0]) == 1 {
+			nameCol, emailCol, roleCol = -1, 0, -1
+		}
+	}
+
+	if len(records)-startRecord > MaxImportRows {
+		return nil, nil, fmt.Errorf("Only %d participants can be imported at once", MaxImportRows)
+	}
+
+	rows := make([]ImportRow, 0)
+	invalid := make([]ImportError, 0)
+	seen := make(models.Set[string])
+	for i := startRecord; i < len(records); i++ {
+		record := records[i]
+		line := lines[i]
+		column := func(index int) string {
+			if index < 0 || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		// Skip lines with only whitespace or empty columns
+		if len(strings.TrimSpace(strings.Join(record, ""))) == 0 {
+			continue
+		}
+
+		row := ImportRow{Line: line, Name: column(nameCol)}
+
+		email, err := ParseEmail(column(emailCol))
+		if err != nil {
+			invalid = append(invalid, ImportError{Line: line, Value: column(emailCol), Error: err.Error()})
+			continue
+		}
+		row.Email = email
+
+		switch role := strings.ToLower(column(roleCol)); role {
+		case "", string(models.REQUIRED_PARTICIPANT):
+			row.Role = models.REQUIRED_PARTICIPANT
+		case string(models.OPTIONAL_PARTICIPANT):
+			row.Role = models.OPTIONAL_PARTICIPANT
+		default:
+			invalid = append(invalid, ImportError{Line: line, Value: column(roleCol), Error: "Role must be either required or optional"})
+			continue
+		}
+
+		if _, ok := seen[row.Email]; ok {
+			invalid = append(invalid, ImportError{Line: line, Value: row.Email, Error: "Email appears more than once"})
+			continue
+		}
+		seen[row.Email] = struct{}{}
+
+		rows = append(rows, row)
+	}
+
+	return rows, invalid, nil
+}
+
+// Returns the normalized version of a bare email address, or an error if it isn't valid
+func ParseEmail(s string) (string, error) {
+	if len(s) == 0 {
+		return "", errors.New("Email is missing")
+	}
+	address, err := mail.ParseAddress(s)
+	if err != nil || address.Address != s || !strings.Contains(s[strings.LastIndex(s, "@"):], ".") {
+		return "", errors.New("Email is invalid")
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+func isString(value string) func(string) bool {
+	return func(s string) bool { return s == value }
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"schej.it/server/models"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	csv := "Email,Name,Role\n" +
+		"ada@example.com,Ada Lovelace,\n" +
+		"Alan@Example.com, Alan Turing ,Optional\n" +
+		"\n" +
+		"not-an-email,Nobody,required\n" +
+		"grace@example.com,Grace Hopper,sometimes\n" +
+		"ada@example.com,Ada again,\n"
+
+	rows, invalid, err := ParseImportCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRows := []ImportRow{
+		{Line: 2, Name: "Ada Lovelace", Email: "ada@example.com", Role: models.REQUIRED_PARTICIPANT},
+		{Line: 3, Name: "Alan Turing", Email: "alan@example.com", Role: models.OPTIONAL_PARTICIPANT},
+	}
+	if !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("ParseImportCSV() rows = %+v, want %+v", rows, expectedRows)
+	}
+
+	expectedInvalidLines := []int{5, 6, 7}
+	if got := Map(invalid, func(e ImportError) int { return e.Line }); !reflect.DeepEqual(got, expectedInvalidLines) {
+		t.Errorf("ParseImportCSV() invalid lines = %v, want %v", got, expectedInvalidLines)
+	}
+}
+
+func TestParseImportCSVWithoutHeader(t *testing.T) {
+	rows, invalid, err := ParseImportCSV(strings.NewReader("ada@example.com\nalan@example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || len(invalid) != 0 {
+		t.Errorf("ParseImportCSV() = %+v, %+v, want 2 rows and no invalid lines", rows, invalid)
+	}
+}
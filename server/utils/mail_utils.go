@@ -10,6 +10,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"gopkg.in/gomail.v2"
 	"schej.it/server/logger"
+	"schej.it/server/metrics"
+	"schej.it/server/services/httpclient"
 )
 
 // Send email to the given email
@@ -30,9 +32,11 @@ func SendEmail(toEmail string, subject string, body string, contentType string)
 	d := gomail.NewDialer("smtp.gmail.com", 587, fromEmail, appPassword)
 
 	// Send the email to Bob, Cora and Dan.
-	if err := d.DialAndSend(m); err != nil {
+	err := d.DialAndSend(m)
+	if err != nil {
 		logger.StdErr.Println(err)
 	}
+	metrics.EmailsSent.WithLabelValues("gmail", metrics.Result(err)).Inc()
 }
 
 func AddUserToMailchimp(email string, firstName string, lastName string) {
@@ -51,7 +55,7 @@ func AddUserToMailchimp(email string, firstName string, lastName string) {
 	req, _ := http.NewRequest("POST", "https://us21.api.mailchimp.com/3.0/lists/b5c79106b4/members", bodyBuffer)
 	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", apiKey))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
 		logger.StdErr.Println(err)
 	}
@@ -76,7 +80,7 @@ func AddUserToMailjet(email string, firstName string, lastName string, picture s
 	req.SetBasicAuth(apiKey, apiSecret)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Client.Do(req)
 	if err != nil {
 		logger.StdErr.Println(err)
 		return
@@ -123,7 +127,7 @@ func AddUserToMailjet(email string, firstName string, lastName string, picture s
 	req.SetBasicAuth(apiKey, apiSecret)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpclient.Client.Do(req)
 	if err != nil {
 		logger.StdErr.Println(err)
 		return
@@ -147,7 +151,7 @@ func AddUserToMailjet(email string, firstName string, lastName string, picture s
 	req.SetBasicAuth(apiKey, apiSecret)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpclient.Client.Do(req)
 	if err != nil {
 		logger.StdErr.Println(err)
 		return
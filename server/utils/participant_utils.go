@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// The availability of respondents at a time slot, split up by whether they are required or optional
+type SlotAggregate struct {
+	Time              primitive.DateTime `json:"time"`
+	RequiredAvailable int                `json:"requiredAvailable"`
+	OptionalAvailable int                `json:"optionalAvailable"`
+	IfNeeded          int                `json:"ifNeeded"`
+
+	// Number of required participants that are neither available nor available if needed, including the ones that
+	// haven't responded
+	RequiredMissing int `json:"requiredMissing"`
+}
+
+// Returns the times the respondent is available, including manually entered availability for groups
+func GetAvailableTimes(response *models.Response) models.Set[primitive.DateTime] {
+	available := ArrayToSet(response.Availability)
+	for _, times := range Coalesce(response.ManualAvailability) {
+		for _, t := range times {
+			available[t] = struct{}{}
+		}
+	}
+	return available
+}
+
+// Returns the availability at each of the slots. `roles` maps the keys of `responses` to the respondent's role,
+// respondents without a role are treated as optional. The `numRequiredNotResponded` required participants that
+// haven't responded are missing at every slot
+func AggregateAvailability(slots []primitive.DateTime, responses map[string]*models.Response, roles map[string]models.ParticipantRole, numRequiredNotResponded int) []SlotAggregate {
+	type respondentAvailability struct {
+		required  bool
+		available models.Set[primitive.DateTime]
+		ifNeeded  models.Set[primitive.DateTime]
+	}
+	respondents := make([]respondentAvailability, 0, len(responses))
+	for key, response := range responses {
+		respondents = append(respondents, respondentAvailability{
+			required:  roles[key] == models.REQUIRED_PARTICIPANT,
+			available: GetAvailableTimes(response),
+			ifNeeded:  ArrayToSet(response.IfNeeded),
+		})
+	}
+
+	aggregates := make([]SlotAggregate, 0, len(slots))
+	for _, slot := range slots {
+		aggregate := SlotAggregate{Time: slot, RequiredMissing: numRequiredNotResponded}
+		for _, respondent := range respondents {
+			if _, ok := respondent.available[slot]; ok {
+				if respondent.required {
+					aggregate.RequiredAvailable++
+				} else {
+					aggregate.OptionalAvailable++
+				}
+			} else if _, ok := respondent.ifNeeded[slot]; ok {
+				aggregate.IfNeeded++
+			} else if respondent.required {
+				aggregate.RequiredMissing++
+			}
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+
+	return aggregates
+}
+
+// Returns the slots where no required participant is missing, ordered from the most to the fewest available
+// respondents. Ties go to the slot with fewer respondents that are only available if needed, then the earlier slot
+func RankSlots(aggregates []SlotAggregate) []SlotAggregate {
+	ranking := make([]SlotAggregate, 0)
+	for _, aggregate := range aggregates {
+		if aggregate.RequiredMissing == 0 {
+			ranking = append(ranking, aggregate)
+		}
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if availableA, availableB := a.RequiredAvailable+a.OptionalAvailable, b.RequiredAvailable+b.OptionalAvailable; availableA != availableB {
+			return availableA > availableB
+		}
+		if a.IfNeeded != b.IfNeeded {
+			return a.IfNeeded < b.IfNeeded
+		}
+		return a.Time < b.Time
+	})
+
+	return ranking
+}
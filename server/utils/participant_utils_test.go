@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+func TestAggregateAvailability(t *testing.T) {
+	slots := []primitive.DateTime{1000, 2000, 3000}
+	responses := map[string]*models.Response{
+		"boss":   {Availability: []primitive.DateTime{1000, 2000}},
+		"intern": {Availability: []primitive.DateTime{3000}, IfNeeded: []primitive.DateTime{1000}},
+		"guest":  {ManualAvailability: &map[primitive.DateTime][]primitive.DateTime{0: {2000, 3000}}},
+	}
+	roles := map[string]models.ParticipantRole{
+		"boss":   models.REQUIRED_PARTICIPANT,
+		"intern": models.REQUIRED_PARTICIPANT,
+	}
+
+	expected := []SlotAggregate{
+		{Time: 1000, RequiredAvailable: 1, OptionalAvailable: 0, IfNeeded: 1, RequiredMissing: 0},
+		{Time: 2000, RequiredAvailable: 1, OptionalAvailable: 1, IfNeeded: 0, RequiredMissing: 1},
+		{Time: 3000, RequiredAvailable: 1, OptionalAvailable: 1, IfNeeded: 0, RequiredMissing: 1},
+	}
+	if got := AggregateAvailability(slots, responses, roles, 0); !reflect.DeepEqual(got, expected) {
+		t.Errorf("AggregateAvailability() = %+v, want %+v", got, expected)
+	}
+
+	// Required participants that haven't responded are missing everywhere
+	if got := AggregateAvailability(slots, responses, roles, 2); got[0].RequiredMissing != 2 || got[1].RequiredMissing != 3 {
+		t.Errorf("AggregateAvailability() with 2 required participants that haven't responded = %+v", got)
+	}
+}
+
+func TestRankSlots(t *testing.T) {
+	aggregates := []SlotAggregate{
+		{Time: 1000, RequiredAvailable: 1, OptionalAvailable: 1, IfNeeded: 1},
+		{Time: 2000, RequiredAvailable: 2, OptionalAvailable: 3, RequiredMissing: 1},
+		{Time: 3000, RequiredAvailable: 2, OptionalAvailable: 0},
+		{Time: 4000, RequiredAvailable: 1, OptionalAvailable: 2},
+	}
+
+	got := Map(RankSlots(aggregates), func(a SlotAggregate) primitive.DateTime { return a.Time })
+	if expected := []primitive.DateTime{4000, 3000, 1000}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("RankSlots() = %v, want %v", got, expected)
+	}
+}
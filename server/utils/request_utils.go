@@ -5,19 +5,58 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/errs"
 	"schej.it/server/logger"
+	"schej.it/server/responses"
 )
 
-func ParseArrayQueryParam(s string) []string {
+// Returns the values of a comma separated query parameter, or an error if it isn't escaped properly
+func ParseArrayQueryParam(s string) ([]string, error) {
 	decoded, err := url.QueryUnescape(s)
 	if err != nil {
-		logger.StdErr.Panicln(err)
+		return nil, err
 	}
 	arr := strings.Split(decoded, ",")
-	return arr
+	return arr, nil
 }
 
 // Returns origin of the given request (i.e. http://localhost:8080 or http://localhost:3002 or https://schej.it)
 func GetOrigin(c *gin.Context) string {
 	return c.Request.Header.Get("Origin")
 }
+
+// Key of the request's logger in the gin context
+const LoggerKey = "logger"
+
+// Returns the logger set by middleware.RequestLogger, which adds the request ID to every entry
+func GetLogger(c *gin.Context) *logger.Logger {
+	if log, ok := c.Get(LoggerKey); ok {
+		return log.(*logger.Logger)
+	}
+	return logger.With()
+}
+
+// Key of the admin impersonating the signed in user in the gin context
+const ImpersonatorIdKey = "impersonatorId"
+
+// Returns the id of the admin impersonating the signed in user, set by middleware.Impersonation, or nil if the
+// user signed in themselves
+func GetImpersonatorId(c *gin.Context) *primitive.ObjectID {
+	if impersonatorId, ok := c.Get(ImpersonatorIdKey); ok {
+		return impersonatorId.(*primitive.ObjectID)
+	}
+	return nil
+}
+
+// Aborts the request with the responses.Error for err, classified by errs.From. The error is added to the
+// context, so middleware.RequestLogger logs it along with the request
+func AbortWithError(c *gin.Context, err error) {
+	e := errs.From(err)
+	c.Error(err)
+	c.AbortWithStatusJSON(e.Status, responses.Error{
+		Error:     e.Code,
+		Message:   e.Message,
+		RequestId: c.Writer.Header().Get("X-Request-Id"),
+	})
+}
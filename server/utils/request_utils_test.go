@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArrayQueryParam(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    string
+		expected []string
+		wantErr  bool
+	}{
+		{"Single value", "alice@example.com", []string{"alice@example.com"}, false},
+		{"Multiple values", "alice@example.com,bob@example.com", []string{"alice@example.com", "bob@example.com"}, false},
+		{"Escaped values", "alice%40example.com%2Cbob%40example.com", []string{"alice@example.com", "bob@example.com"}, false},
+		{"Malformed escape", "alice%4", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseArrayQueryParam(tt.param)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseArrayQueryParam(%q) error = %v, wantErr %v", tt.param, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ParseArrayQueryParam(%q) = %v, want %v", tt.param, result, tt.expected)
+			}
+		})
+	}
+}
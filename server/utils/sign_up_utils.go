@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// Returns the sign up block with the given id, nil if it doesn't exist
+func FindSignUpBlock(event *models.Event, blockId primitive.ObjectID) *models.SignUpBlock {
+	for i, block := range Coalesce(event.SignUpBlocks) {
+		if block.Id == blockId {
+			return &(*event.SignUpBlocks)[i]
+		}
+	}
+	return nil
+}
+
+// Returns the number of users signed up for the given block, not counting the user with the key `excludeUserKey`
+func CountSignUps(event *models.Event, blockId primitive.ObjectID, excludeUserKey string) int {
+	count := 0
+	for userKey, response := range event.SignUpResponses {
+		if userKey == excludeUserKey || response == nil {
+			continue
+		}
+		if Contains(response.SignUpBlockIds, blockId) {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the number of guests with a sign up response to the event
+func CountGuestSignUps(event *models.Event) int {
+	count := 0
+	for _, response := range event.SignUpResponses {
+		if response == nil || response.UserId.IsZero() {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the keys of the users on the waitlist for the given block, ordered by when they joined the waitlist
+func GetSignUpWaitlist(event *models.Event, blockId primitive.ObjectID) []string {
+	type waitlistedUser struct {
+		userKey  string
+		joinedAt primitive.DateTime
+	}
+
+	waitlist := make([]waitlistedUser, 0)
+	for userKey, response := range event.SignUpResponses {
+		if response == nil {
+			continue
+		}
+		for _, entry := range response.Waitlist {
+			if entry.SignUpBlockId == blockId {
+				waitlist = append(waitlist, waitlistedUser{userKey: userKey, joinedAt: entry.JoinedAt})
+				break
+			}
+		}
+	}
+
+	// Sort by join time, falling back to the user key so the order is stable
+	sort.Slice(waitlist, func(i, j int) bool {
+		if waitlist[i].joinedAt != waitlist[j].joinedAt {
+			return waitlist[i].joinedAt < waitlist[j].joinedAt
+		}
+		return waitlist[i].userKey < waitlist[j].userKey
+	})
+
+	return Map(waitlist, func(w waitlistedUser) string { return w.userKey })
+}
+
+// Returns whether the user with the key `userKey` can take a spot in the given block.
+// Users that joined the waitlist before them get the open spots first
+func SignUpBlockHasRoom(event *models.Event, block *models.SignUpBlock, userKey string) bool {
+	if block.Capacity == nil {
+		return true
+	}
+
+	numAhead := 0
+	for _, waitlistedUserKey := range GetSignUpWaitlist(event, block.Id) {
+		if waitlistedUserKey == userKey {
+			break
+		}
+		numAhead++
+	}
+
+	return CountSignUps(event, block.Id, userKey)+numAhead < *block.Capacity
+}
+
+var phoneNumberRegex = regexp.MustCompile(`^\+?[0-9 ()\-.]{7,20}$`)
+
+// Maximum length of answers to sign up questions and of the options of select and checkboxes questions
+const maxSignUpAnswerLength = 1000
+
+// Checks that the sign up questions are well formed and assigns ids to new questions
+func ValidateSignUpQuestions(questions []models.SignUpQuestion) error {
+	for i := range questions {
+		question := &questions[i]
+		if question.Id.IsZero() {
+			question.Id = primitive.NewObjectID()
+		}
+
+		if len(strings.TrimSpace(question.Label)) == 0 {
+			return errors.New("Sign up questions must have a label")
+		}
+
+		switch question.Type {
+		case models.TEXT_QUESTION, models.PHONE_QUESTION, models.CHECKBOX_QUESTION:
+		case models.SELECT_QUESTION, models.CHECKBOXES_QUESTION:
+			if len(question.Options) == 0 {
+				return fmt.Errorf("Sign up question \"%s\" must have at least one option", question.Label)
+			}
+			for _, option := range question.Options {
+				if len(option) > maxSignUpAnswerLength {
+					return fmt.Errorf("The options of \"%s\" must be less than %d characters", question.Label, maxSignUpAnswerLength)
+				}
+			}
+		default:
+			return fmt.Errorf("Sign up question \"%s\" has an invalid type", question.Label)
+		}
+	}
+
+	return nil
+}
+
+// Returns whether the question should be asked to a user signing up for the given blocks
+func IsSignUpQuestionAsked(question models.SignUpQuestion, signUpBlockIds []primitive.ObjectID) bool {
+	if len(question.SignUpBlockIds) == 0 {
+		return true
+	}
+	for _, blockId := range signUpBlockIds {
+		if Contains(question.SignUpBlockIds, blockId) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates the answers to the event's sign up questions for a user signing up for the given blocks.
+// Returns the answers with unknown questions and unused fields stripped out
+func ValidateSignUpAnswers(event *models.Event, signUpBlockIds []primitive.ObjectID, answers []models.SignUpAnswer) ([]models.SignUpAnswer, error) {
+	validAnswers := make([]models.SignUpAnswer, 0)
+
+	for _, question := range Coalesce(event.SignUpQuestions) {
+		if !IsSignUpQuestionAsked(question, signUpBlockIds) {
+			continue
+		}
+
+		index := Find(answers, func(a models.SignUpAnswer) bool { return a.QuestionId == question.Id })
+		var answer *models.SignUpAnswer
+		if index != -1 {
+			answer = &models.SignUpAnswer{QuestionId: question.Id}
+			switch question.Type {
+			case models.TEXT_QUESTION, models.PHONE_QUESTION, models.SELECT_QUESTION:
+				if text := strings.TrimSpace(Coalesce(answers[index].Text)); len(text) > 0 {
+					answer.Text = &text
+				}
+			case models.CHECKBOX_QUESTION:
+				answer.Checked = answers[index].Checked
+			case models.CHECKBOXES_QUESTION:
+				// Each option can only be selected once
+				for _, option := range answers[index].Selected {
+					if !Contains(answer.Selected, option) {
+						answer.Selected = append(answer.Selected, option)
+					}
+				}
+			}
+			if answer.Text == nil && answer.Checked == nil && len(answer.Selected) == 0 {
+				answer = nil
+			}
+		}
+
+		if answer == nil {
+			// Checkboxes that must be checked aren't considered answered until they are
+			if Coalesce(question.Required) {
+				return nil, fmt.Errorf("\"%s\" is required", question.Label)
+			}
+			continue
+		}
+
+		if answer.Text != nil && len(*answer.Text) > maxSignUpAnswerLength {
+			return nil, fmt.Errorf("\"%s\" must be less than %d characters", question.Label, maxSignUpAnswerLength)
+		}
+		for _, option := range answer.Selected {
+			if len(option) > maxSignUpAnswerLength {
+				return nil, fmt.Errorf("\"%s\" must be less than %d characters", question.Label, maxSignUpAnswerLength)
+			}
+		}
+
+		switch question.Type {
+		case models.PHONE_QUESTION:
+			if !phoneNumberRegex.MatchString(*answer.Text) {
+				return nil, fmt.Errorf("\"%s\" must be a valid phone number", question.Label)
+			}
+		case models.SELECT_QUESTION:
+			if !Contains(question.Options, *answer.Text) {
+				return nil, fmt.Errorf("\"%s\" must be one of the options", question.Label)
+			}
+		case models.CHECKBOX_QUESTION:
+			if Coalesce(question.Required) && !*answer.Checked {
+				return nil, fmt.Errorf("\"%s\" must be checked", question.Label)
+			}
+		case models.CHECKBOXES_QUESTION:
+			for _, option := range answer.Selected {
+				if !Contains(question.Options, option) {
+					return nil, fmt.Errorf("\"%s\" must only contain the options", question.Label)
+				}
+			}
+		}
+
+		validAnswers = append(validAnswers, *answer)
+	}
+
+	return validAnswers, nil
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+func TestSignUpWaitlist(t *testing.T) {
+	capacity := 2
+	block := models.SignUpBlock{Id: primitive.NewObjectID(), Capacity: &capacity}
+	event := &models.Event{
+		SignUpBlocks: &[]models.SignUpBlock{block},
+		SignUpResponses: map[string]*models.SignUpResponse{
+			"alice": {SignUpBlockIds: []primitive.ObjectID{block.Id}},
+			"bob":   {Waitlist: []models.SignUpWaitlistEntry{{SignUpBlockId: block.Id, JoinedAt: 300}}},
+			"carol": {Waitlist: []models.SignUpWaitlistEntry{{SignUpBlockId: block.Id, JoinedAt: 100}}},
+			"dave":  {},
+		},
+	}
+
+	if got := CountSignUps(event, block.Id, ""); got != 1 {
+		t.Errorf("CountSignUps() = %d, want 1", got)
+	}
+	if got := CountSignUps(event, block.Id, "alice"); got != 0 {
+		t.Errorf("CountSignUps() excluding alice = %d, want 0", got)
+	}
+
+	if got, want := GetSignUpWaitlist(event, block.Id), []string{"carol", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSignUpWaitlist() = %v, want %v", got, want)
+	}
+
+	// One spot is left, and carol is first in line for it
+	tests := []struct {
+		userKey  string
+		expected bool
+	}{
+		{"carol", true},
+		{"bob", false},
+		{"dave", false},
+	}
+	for _, tt := range tests {
+		if got := SignUpBlockHasRoom(event, FindSignUpBlock(event, block.Id), tt.userKey); got != tt.expected {
+			t.Errorf("SignUpBlockHasRoom(%q) = %v, want %v", tt.userKey, got, tt.expected)
+		}
+	}
+
+	// Blocks without a capacity never fill up
+	event.SignUpBlocks = &[]models.SignUpBlock{{Id: block.Id}}
+	if !SignUpBlockHasRoom(event, FindSignUpBlock(event, block.Id), "dave") {
+		t.Errorf("SignUpBlockHasRoom() = false for block without capacity")
+	}
+}
+
+func TestValidateSignUpAnswers(t *testing.T) {
+	blockA := primitive.NewObjectID()
+	blockB := primitive.NewObjectID()
+	phone := models.SignUpQuestion{Id: primitive.NewObjectID(), Label: "Phone", Type: models.PHONE_QUESTION, Required: TruePtr()}
+	shirt := models.SignUpQuestion{Id: primitive.NewObjectID(), Label: "Shirt size", Type: models.SELECT_QUESTION, Options: []string{"S", "M", "L"}}
+	waiver := models.SignUpQuestion{Id: primitive.NewObjectID(), Label: "Waiver", Type: models.CHECKBOX_QUESTION, Required: TruePtr(), SignUpBlockIds: []primitive.ObjectID{blockB}}
+	diet := models.SignUpQuestion{Id: primitive.NewObjectID(), Label: "Diet", Type: models.CHECKBOXES_QUESTION, Options: []string{"Vegan", "Gluten free"}}
+	notes := models.SignUpQuestion{Id: primitive.NewObjectID(), Label: "Notes", Type: models.TEXT_QUESTION}
+	event := &models.Event{SignUpQuestions: &[]models.SignUpQuestion{phone, shirt, waiver, diet, notes}}
+
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		blockIds []primitive.ObjectID
+		answers  []models.SignUpAnswer
+		valid    bool
+		numValid int
+	}{
+		{"valid", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("+1 (555) 123-4567")}, {QuestionId: shirt.Id, Text: str("M")}}, true, 2},
+		{"optional question skipped", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}}, true, 1},
+		{"required question missing", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: shirt.Id, Text: str("M")}}, false, 0},
+		{"invalid phone", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("call me")}}, false, 0},
+		{"invalid option", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: shirt.Id, Text: str("XXL")}}, false, 0},
+		{"block question not asked", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: waiver.Id, Checked: FalsePtr()}}, true, 1},
+		{"block question unchecked", []primitive.ObjectID{blockB}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: waiver.Id, Checked: FalsePtr()}}, false, 0},
+		{"long answer", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: notes.Id, Text: str(strings.Repeat("a", 1001))}}, false, 0},
+		{"unknown checkboxes option", []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: diet.Id, Selected: []string{"Vegan", "Keto"}}}, false, 0},
+		{"block question checked", []primitive.ObjectID{blockB}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: waiver.Id, Checked: TruePtr()}}, true, 2},
+	}
+
+	// Options selected more than once are only kept once
+	answers, err := ValidateSignUpAnswers(event, []primitive.ObjectID{blockA}, []models.SignUpAnswer{{QuestionId: phone.Id, Text: str("5551234567")}, {QuestionId: diet.Id, Selected: []string{"Vegan", "Vegan", "Gluten free"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected := answers[1].Selected; len(selected) != 2 {
+		t.Errorf("selected = %v, want each option once", selected)
+	}
+
+	for _, test := range tests {
+		answers, err := ValidateSignUpAnswers(event, test.blockIds, test.answers)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: ValidateSignUpAnswers() error = %v, want valid = %v", test.name, err, test.valid)
+			continue
+		}
+		if test.valid && len(answers) != test.numValid {
+			t.Errorf("%s: ValidateSignUpAnswers() returned %d answers, want %d", test.name, len(answers), test.numValid)
+		}
+	}
+}
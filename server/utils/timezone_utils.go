@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	// Embed the timezone database so IANA timezones can be loaded on hosts without one
+	_ "time/tzdata"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"schej.it/server/models"
+)
+
+// Returns the location of the IANA timezone (i.e. America/New_York), or an error if it isn't a valid timezone
+func LoadTimezone(timezone string) (*time.Location, error) {
+	// LoadLocation treats "" as UTC and "Local" as the server's timezone, neither of which a client means
+	if len(timezone) == 0 || timezone == "Local" {
+		return nil, fmt.Errorf("Invalid timezone \"%s\"", timezone)
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid timezone \"%s\"", timezone)
+	}
+	return location, nil
+}
+
+// Returns the user's timezone, falling back to their fixed timezone offset for users that haven't sent a
+// timezone since signing in
+func GetUserLocation(user *models.User) *time.Location {
+	if location, err := LoadTimezone(user.Timezone); err == nil {
+		return location
+	}
+	// The offset is the result of Date.getTimezoneOffset(), which is positive west of UTC
+	return time.FixedZone("", -user.TimezoneOffset*60)
+}
+
+/*
+Moves each date of an event grid to the same time of day in the location as the first date, so that the grid
+starts at the same local time on every day even when the dates span a daylight saving time change. Clients that
+computed the dates with a fixed offset are off by the DST difference on the other side of the change.
+
+Returns an error if two dates fall on the same day in the location
+*/
+func NormalizeEventDates(dates []primitive.DateTime, location *time.Location) ([]primitive.DateTime, error) {
+	if len(dates) == 0 {
+		return dates, nil
+	}
+
+	first := dates[0].Time().In(location)
+	hour, minute, second := first.Clock()
+	timeOfDay := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
+
+	normalized := make([]primitive.DateTime, len(dates))
+	days := make(models.Set[string])
+	for i, date := range dates {
+		// Use the day whose start time is closest to the date, so dates that are an hour off don't slip into
+		// the previous or next day
+		local := date.Time().In(location).Add(12*time.Hour - timeOfDay)
+		day := local.Format("2006-01-02")
+		if _, ok := days[day]; ok {
+			return nil, errors.New("Event dates must be on different days")
+		}
+		days[day] = struct{}{}
+
+		year, month, dayOfMonth := local.Date()
+		normalized[i] = primitive.NewDateTimeFromTime(time.Date(year, month, dayOfMonth, hour, minute, second, 0, location))
+	}
+	return normalized, nil
+}
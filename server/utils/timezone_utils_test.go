@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormalizeEventDates(t *testing.T) {
+	location, err := LoadTimezone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client computed the dates with the EST offset, so the date after the change to EDT on March 9 starts
+	// an hour late
+	est := time.FixedZone("", -5*60*60)
+	dates := []primitive.DateTime{
+		primitive.NewDateTimeFromTime(time.Date(2025, 3, 8, 9, 0, 0, 0, est)),
+		primitive.NewDateTimeFromTime(time.Date(2025, 3, 10, 9, 0, 0, 0, est)),
+	}
+
+	normalized, err := NormalizeEventDates(dates, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, day := range []int{8, 10} {
+		expected := time.Date(2025, 3, day, 9, 0, 0, 0, location)
+		if !normalized[i].Time().Equal(expected) {
+			t.Errorf("date %d = %v, want %v", i, normalized[i].Time().In(location), expected)
+		}
+	}
+
+	if _, err := NormalizeEventDates(append(dates, dates[0]), location); err == nil {
+		t.Error("NormalizeEventDates() accepted two dates on the same day")
+	}
+	for _, timezone := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		if _, err := LoadTimezone(timezone); err == nil {
+			t.Errorf("LoadTimezone(%q) didn't return an error", timezone)
+		}
+	}
+}
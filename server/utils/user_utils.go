@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"strings"
+
+	"schej.it/server/models"
+)
+
+// Returns the users whose name or email contains every word of the query, ignoring case
+func FilterUsers(users []models.User, query string) []models.User {
+	words := strings.Fields(strings.ToLower(query))
+	result := make([]models.User, 0)
+	for _, user := range users {
+		searchable := strings.ToLower(strings.Join([]string{user.FirstName, user.LastName, user.Email}, " "))
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(searchable, word) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, user)
+		}
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"schej.it/server/models"
+)
+
+func TestFilterUsers(t *testing.T) {
+	users := []models.User{
+		{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"},
+		{FirstName: "Alan", LastName: "Turing", Email: "alan@example.org"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"ada@example.com", "alan@example.org"}},
+		{"  ", []string{"ada@example.com", "alan@example.org"}},
+		{"ALAN", []string{"alan@example.org"}},
+		{"ada love", []string{"ada@example.com"}},
+		{"example.org", []string{"alan@example.org"}},
+		{"ada turing", []string{}},
+	}
+	for _, test := range tests {
+		result := FilterUsers(users, test.query)
+		if len(result) != len(test.expected) {
+			t.Errorf("FilterUsers(%q) returned %d users, want %d", test.query, len(result), len(test.expected))
+			continue
+		}
+		for i, email := range test.expected {
+			if result[i].Email != email {
+				t.Errorf("FilterUsers(%q)[%d] = %s, want %s", test.query, i, result[i].Email, email)
+			}
+		}
+	}
+}